@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/anton2920/gofa/database"
+	"github.com/anton2920/gofa/session"
+	"github.com/anton2920/gofa/time"
+	"github.com/anton2920/gofa/trace/trace_"
+)
+
+/* Authenticator checks passwords and throttles failed attempts both per account, to stop guessing password of one user, and per address, to stop trying one password against many users. */
+type Authenticator struct {
+	Params
+
+	Accounts  *Throttle
+	Addresses *Throttle
+
+	/* Dummy is hash checked for unknown accounts, so they take as long as existing ones. */
+	Dummy     string
+	DummyOnce sync.Once
+}
+
+type ThrottledError struct {
+	Wait int64
+}
+
+var InvalidCredentials = errors.New("invalid credentials")
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("too many failed attempts, try again in %d seconds", (e.Wait+time.Second-1)/time.Second)
+}
+
+func NewAuthenticator() *Authenticator {
+	return &Authenticator{
+		Params:    DefaultParams,
+		Accounts:  NewThrottle(5, time.Second, 15*time.Minute, time.Day),
+		Addresses: NewThrottle(50, time.Second, 15*time.Minute, time.Hour),
+	}
+}
+
+/* AddressKey returns key for throttling of remote address. IPv6 clients usually get the whole /64, so they are throttled by prefix. */
+func AddressKey(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return addr
+	}
+	if ip.To4() == nil {
+		return ip.Mask(net.CIDRMask(64, 128)).String()
+	}
+	return ip.String()
+}
+
+/* Check verifies password of account from addr against hash, which is empty if account does not exist. If hash uses outdated parameters, new hash is returned and should be stored. Errors are InvalidCredentials, *ThrottledError or error for malformed hash. */
+func (a *Authenticator) Check(addr string, account string, password string, hash string, now int64) (string, error) {
+	defer trace_.End(trace_.Begin(""))
+
+	addr = AddressKey(addr)
+
+	/* NOTE(anton2920): verification is slow, so attempts are recorded as failures before it. Otherwise many concurrent requests could pass the throttle before the first of them fails. */
+	if wait := a.Addresses.Attempt(addr, now); wait > 0 {
+		if w := a.Accounts.Wait(account, now); w > wait {
+			wait = w
+		}
+		return "", &ThrottledError{Wait: wait}
+	}
+	if wait := a.Accounts.Attempt(account, now); wait > 0 {
+		a.Addresses.Undo(addr)
+		return "", &ThrottledError{Wait: wait}
+	}
+
+	if len(hash) == 0 {
+		a.DummyOnce.Do(func() {
+			a.Dummy, _ = HashPassword("", a.Params)
+		})
+		VerifyPassword(a.Dummy, password, a.Params)
+		return "", InvalidCredentials
+	}
+
+	ok, rehash, err := VerifyPassword(hash, password, a.Params)
+	if err != nil {
+		a.Addresses.Undo(addr)
+		a.Accounts.Undo(account)
+		return "", err
+	}
+	if !ok {
+		return "", InvalidCredentials
+	}
+	a.Addresses.Undo(addr)
+
+	/* NOTE(anton2920): address is not reset, otherwise attacker with one valid account could keep guessing passwords of others. */
+	a.Accounts.Reset(account)
+
+	if rehash {
+		hash, err = HashPassword(password, a.Params)
+		if err != nil {
+			return "", fmt.Errorf("failed to rehash password: %w", err)
+		}
+		return hash, nil
+	}
+	return "", nil
+}
+
+/* Authorize binds session to user and gives it new token, so token known before login, e.g. fixed by attacker, becomes useless. It must be called on every change of privileges. */
+func Authorize(s *session.Session, id database.ID) error {
+	defer trace_.End(trace_.Begin(""))
+
+	old := s.Token
+
+	s.ID = id
+	s.Token = session.GenerateToken()
+	s.Expiry = time.Now() + session.Lifetime
+	if err := session.DefaultStore.Set(*s); err != nil {
+		return fmt.Errorf("failed to store session: %w", err)
+	}
+
+	if len(old) > 0 {
+		if err := session.DefaultStore.Del(old); err != nil {
+			return fmt.Errorf("failed to remove previous session: %w", err)
+		}
+	}
+
+	return nil
+}
+
+/* Logout replaces session with new anonymous one. Customization is kept. */
+func Logout(s *session.Session) error {
+	return Authorize(s, 0)
+}
+
+/* RevokeAll removes all sessions of user, e.g. after password change, and gives the current client new one. */
+func RevokeAll(s *session.Session) error {
+	s.RemoveAllForThisUser()
+	return Authorize(s, s.ID)
+}
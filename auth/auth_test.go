@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/anton2920/gofa/session"
+	"github.com/anton2920/gofa/time"
+)
+
+func TestAddressKey(t *testing.T) {
+	tests := [...]struct {
+		Addr     string
+		Expected string
+	}{
+		{"192.0.2.1:4242", "192.0.2.1"},
+		{"192.0.2.1", "192.0.2.1"},
+		{"[2001:db8:1:2:3:4:5:6]:4242", "2001:db8:1:2::"},
+		{"2001:db8:1:2:ffff::1", "2001:db8:1:2::"},
+		{"unix", "unix"},
+	}
+	for _, test := range tests {
+		if key := AddressKey(test.Addr); key != test.Expected {
+			t.Errorf("%q: expected %q, got %q", test.Addr, test.Expected, key)
+		}
+	}
+}
+
+func TestAuthenticator(t *testing.T) {
+	const now = 1000 * time.Second
+
+	a := NewAuthenticator()
+	a.Params = testParams
+
+	hash, _ := HashPassword("secret", testParams)
+
+	if _, err := a.Check("192.0.2.1:1", "alice", "secret", hash, now); err != nil {
+		t.Fatalf("Expected valid password, got %v", err)
+	}
+
+	for i := 0; i < a.Accounts.Free; i++ {
+		if _, err := a.Check("192.0.2.1:1", "alice", "guess", hash, now); err != InvalidCredentials {
+			t.Fatalf("Expected invalid credentials, got %v", err)
+		}
+	}
+	var throttled *ThrottledError
+	if _, err := a.Check("192.0.2.2:1", "alice", "secret", hash, now); !errors.As(err, &throttled) {
+		t.Fatalf("Expected account to be throttled from other address, got %v", err)
+	}
+	if _, err := a.Check("192.0.2.1:1", "bob", "secret", hash, now); err != nil {
+		t.Errorf("Expected other account not to be throttled, got %v", err)
+	}
+	if _, err := a.Check("192.0.2.1:1", "alice", "secret", hash, now+throttled.Wait); err != nil {
+		t.Errorf("Expected account to be allowed after wait, got %v", err)
+	}
+
+	/* Unknown accounts fail the same way as known ones. */
+	if _, err := a.Check("192.0.2.3:1", "nobody", "secret", "", now); err != InvalidCredentials {
+		t.Errorf("Expected invalid credentials for unknown account, got %v", err)
+	}
+
+	a.Params = Params{LogN: 5, R: 1, P: 1}
+	upgraded, err := a.Check("192.0.2.1:1", "carol", "secret", hash, now)
+	if err != nil {
+		t.Fatalf("Expected valid password, got %v", err)
+	}
+	if ok, rehash, err := VerifyPassword(upgraded, "secret", a.Params); (err != nil) || (!ok) || (rehash) {
+		t.Errorf("Expected upgraded hash, got %v, %v, %v", ok, rehash, err)
+	}
+}
+
+func TestAuthenticatorConcurrent(t *testing.T) {
+	const (
+		now        = 1000 * time.Second
+		goroutines = 32
+	)
+
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	var checked, throttled int
+
+	/* NOTE(anton2920): verification must be slow enough for attempts to overlap. */
+	params := Params{LogN: 12, R: 8, P: 1}
+
+	a := NewAuthenticator()
+	a.Params = params
+
+	hash, _ := HashPassword("secret", params)
+
+	start := make(chan struct{})
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			<-start
+			_, err := a.Check("192.0.2.1:1", "alice", "guess", hash, now)
+
+			lock.Lock()
+			defer lock.Unlock()
+
+			var te *ThrottledError
+			switch {
+			case err == InvalidCredentials:
+				checked++
+			case errors.As(err, &te):
+				throttled++
+			default:
+				t.Errorf("Unexpected error %v", err)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if (checked != a.Accounts.Free) || (throttled != goroutines-a.Accounts.Free) {
+		t.Errorf("Expected %d passwords to be checked, got %d (%d throttled)", a.Accounts.Free, checked, throttled)
+	}
+	if failures := a.Addresses.Entries["192.0.2.1"].Failures; failures != a.Accounts.Free {
+		t.Errorf("Expected throttled attempts not to count against address, got %d failures", failures)
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	s := session.New(0)
+	s.ColorScheme = session.ColorSchemeDark
+	anonymous := s.Token
+
+	if err := Authorize(&s, 7); err != nil {
+		t.Fatalf("Failed to authorize: %v", err)
+	}
+	if (s.Token == anonymous) || (s.ID != 7) || (s.ColorScheme != session.ColorSchemeDark) {
+		t.Errorf("Unexpected session after authorization: %+v", s)
+	}
+	if got := session.Get(anonymous); len(got.Token) != 0 {
+		t.Errorf("Expected previous token to be invalid")
+	}
+	if got := session.Get(s.Token); got.ID != 7 {
+		t.Errorf("Expected authorized session, got %+v", got)
+	}
+
+	other := session.New(0)
+	Authorize(&other, 7)
+
+	if err := RevokeAll(&s); err != nil {
+		t.Fatalf("Failed to revoke sessions: %v", err)
+	}
+	if got := session.Get(other.Token); len(got.Token) != 0 {
+		t.Errorf("Expected other sessions of user to be removed")
+	}
+	if got := session.Get(s.Token); got.ID != 7 {
+		t.Errorf("Expected current client to stay authorized, got %+v", got)
+	}
+
+	authorized := s.Token
+	if err := Logout(&s); err != nil {
+		t.Fatalf("Failed to log out: %v", err)
+	}
+	if (s.ID != 0) || (s.Token == authorized) {
+		t.Errorf("Unexpected session after logout: %+v", s)
+	}
+	if got := session.Get(authorized); len(got.Token) != 0 {
+		t.Errorf("Expected authorized token to be invalid after logout")
+	}
+}
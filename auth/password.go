@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/anton2920/gofa/strings"
+	"github.com/anton2920/gofa/trace/trace_"
+)
+
+/* Params are scrypt parameters. Hash stores parameters it was created with, so they can be raised without invalidating existing passwords. */
+type Params struct {
+	/* LogN is base 2 logarithm of CPU/memory cost. */
+	LogN int
+	R    int
+	P    int
+}
+
+const (
+	SaltSize = 16
+	KeySize  = 32
+)
+
+/* DefaultParams use 32MiB of memory, as recommended for interactive logins. */
+var DefaultParams = Params{LogN: 15, R: 8, P: 1}
+
+const hashPrefix = "$scrypt$"
+
+/* HashPassword returns password hash in PHC string format:
+ *     $scrypt$ln=15,r=8,p=1$<salt>$<key>
+ * where salt and key are base64 without padding. */
+func HashPassword(password string, params Params) (string, error) {
+	defer trace_.End(trace_.Begin(""))
+
+	salt := make([]byte, SaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	key, err := Scrypt([]byte(password), salt, 1<<params.LogN, params.R, params.P, KeySize)
+	if err != nil {
+		return "", fmt.Errorf("failed to derive key: %w", err)
+	}
+
+	hash := make([]byte, 0, 128)
+	hash = append(hash, hashPrefix...)
+	hash = append(hash, "ln="...)
+	hash = strconv.AppendInt(hash, int64(params.LogN), 10)
+	hash = append(hash, ",r="...)
+	hash = strconv.AppendInt(hash, int64(params.R), 10)
+	hash = append(hash, ",p="...)
+	hash = strconv.AppendInt(hash, int64(params.P), 10)
+	hash = append(hash, '$')
+	hash = append(hash, base64.RawStdEncoding.EncodeToString(salt)...)
+	hash = append(hash, '$')
+	hash = append(hash, base64.RawStdEncoding.EncodeToString(key)...)
+
+	return string(hash), nil
+}
+
+func parseParam(s string, name string) (int, error) {
+	if (!strings.StartsWith(s, name)) || (!strings.StartsWith(s[len(name):], "=")) {
+		return 0, fmt.Errorf("expected parameter %q", name)
+	}
+	return strconv.Atoi(s[len(name)+1:])
+}
+
+func parseHash(hash string) (params Params, salt []byte, key []byte, err error) {
+	if !strings.StartsWith(hash, hashPrefix) {
+		return params, nil, nil, errors.New("unknown hash format")
+	}
+	ps, rest, _ := strings.Cut(hash[len(hashPrefix):], "$")
+	encodedSalt, encodedKey, ok := strings.Cut(rest, "$")
+	if !ok {
+		return params, nil, nil, errors.New("malformed hash")
+	}
+
+	ln, ps, _ := strings.Cut(ps, ",")
+	if params.LogN, err = parseParam(ln, "ln"); err != nil {
+		return
+	}
+	r, p, _ := strings.Cut(ps, ",")
+	if params.R, err = parseParam(r, "r"); err != nil {
+		return
+	}
+	if params.P, err = parseParam(p, "p"); err != nil {
+		return
+	}
+	if (params.LogN <= 0) || (params.LogN >= 32) {
+		return params, nil, nil, fmt.Errorf("invalid cost %d", params.LogN)
+	}
+
+	if salt, err = base64.RawStdEncoding.DecodeString(encodedSalt); err != nil {
+		return params, nil, nil, fmt.Errorf("failed to decode salt: %w", err)
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(encodedKey); err != nil {
+		return params, nil, nil, fmt.Errorf("failed to decode key: %w", err)
+	}
+	if len(key) == 0 {
+		return params, nil, nil, errors.New("empty key")
+	}
+
+	return params, salt, key, nil
+}
+
+/* VerifyPassword checks password against hash in constant time. If hash was created with parameters other than params, rehash is true and caller should store new hash, while password is known. */
+func VerifyPassword(hash string, password string, params Params) (ok bool, rehash bool, err error) {
+	defer trace_.End(trace_.Begin(""))
+
+	old, salt, key, err := parseHash(hash)
+	if err != nil {
+		return false, false, fmt.Errorf("failed to parse password hash: %w", err)
+	}
+
+	derived, err := Scrypt([]byte(password), salt, 1<<old.LogN, old.R, old.P, len(key))
+	if err != nil {
+		return false, false, fmt.Errorf("failed to derive key: %w", err)
+	}
+	if subtle.ConstantTimeCompare(derived, key) != 1 {
+		return false, false, nil
+	}
+
+	return true, (old != params) || (len(salt) < SaltSize) || (len(key) < KeySize), nil
+}
@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+var testParams = Params{LogN: 4, R: 1, P: 1}
+
+func TestPassword(t *testing.T) {
+	hash, err := HashPassword("secret", testParams)
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$scrypt$ln=4,r=1,p=1$") {
+		t.Errorf("Unexpected hash format %q", hash)
+	}
+
+	if other, _ := HashPassword("secret", testParams); other == hash {
+		t.Errorf("Expected different salts for the same password")
+	}
+
+	ok, rehash, err := VerifyPassword(hash, "secret", testParams)
+	if (err != nil) || (!ok) || (rehash) {
+		t.Errorf("Expected valid password without rehash, got %v, %v, %v", ok, rehash, err)
+	}
+	if ok, _, err := VerifyPassword(hash, "Secret", testParams); (err != nil) || (ok) {
+		t.Errorf("Expected invalid password, got %v, %v", ok, err)
+	}
+
+	/* Parameters were raised. */
+	ok, rehash, err = VerifyPassword(hash, "secret", Params{LogN: 5, R: 1, P: 1})
+	if (err != nil) || (!ok) || (!rehash) {
+		t.Errorf("Expected valid password with rehash, got %v, %v, %v", ok, rehash, err)
+	}
+	if _, rehash, _ := VerifyPassword(hash, "wrong", Params{LogN: 5, R: 1, P: 1}); rehash {
+		t.Errorf("Expected no rehash for invalid password")
+	}
+
+	for _, hash := range [...]string{
+		"",
+		"$2a$10$abcdefghijklmnopqrstuu",
+		"$scrypt$ln=4,r=1$c2FsdA$a2V5",
+		"$scrypt$ln=4,r=1,p=1$c2FsdA",
+		"$scrypt$ln=x,r=1,p=1$c2FsdA$a2V5",
+		"$scrypt$ln=40,r=1,p=1$c2FsdA$a2V5",
+		"$scrypt$r=1,ln=4,p=1$c2FsdA$a2V5",
+		"$scrypt$ln=4,r=1,p=1$!!$a2V5",
+		"$scrypt$ln=4,r=1,p=1$c2FsdA$",
+		"$scrypt$ln=30,r=1024,p=1$c2FsdA$a2V5",
+	} {
+		if ok, _, err := VerifyPassword(hash, "secret", testParams); (err == nil) || (ok) {
+			t.Errorf("Expected error for hash %q", hash)
+		}
+	}
+}
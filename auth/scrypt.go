@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math/bits"
+
+	"github.com/anton2920/gofa/trace/trace_"
+)
+
+/* NOTE(anton2920): see RFC 7914. */
+
+/* MaxScryptMemory limits memory used by single call of Scrypt, so malformed or malicious hash cannot exhaust it. */
+const MaxScryptMemory = 1 << 30
+
+func pbkdf2(password, salt []byte, iterations, keyLen int) []byte {
+	var counter [4]byte
+
+	prf := hmac.New(sha256.New, password)
+	key := make([]byte, 0, (keyLen+sha256.Size-1)/sha256.Size*sha256.Size)
+	u := make([]byte, 0, sha256.Size)
+
+	for block := uint32(1); len(key) < keyLen; block++ {
+		binary.BigEndian.PutUint32(counter[:], block)
+
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(counter[:])
+		key = prf.Sum(key)
+
+		t := key[len(key)-sha256.Size:]
+		u = append(u[:0], t...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := 0; j < len(t); j++ {
+				t[j] ^= u[j]
+			}
+		}
+	}
+
+	return key[:keyLen]
+}
+
+func salsaQuarterRound(x *[16]uint32, a, b, c, d int) {
+	x[b] ^= bits.RotateLeft32(x[a]+x[d], 7)
+	x[c] ^= bits.RotateLeft32(x[b]+x[a], 9)
+	x[d] ^= bits.RotateLeft32(x[c]+x[b], 13)
+	x[a] ^= bits.RotateLeft32(x[d]+x[c], 18)
+}
+
+/* salsa208 applies Salsa20/8 core to b in place. */
+func salsa208(b *[16]uint32) {
+	x := *b
+	for i := 0; i < 8; i += 2 {
+		/* Columns. */
+		salsaQuarterRound(&x, 0, 4, 8, 12)
+		salsaQuarterRound(&x, 5, 9, 13, 1)
+		salsaQuarterRound(&x, 10, 14, 2, 6)
+		salsaQuarterRound(&x, 15, 3, 7, 11)
+
+		/* Rows. */
+		salsaQuarterRound(&x, 0, 1, 2, 3)
+		salsaQuarterRound(&x, 5, 6, 7, 4)
+		salsaQuarterRound(&x, 10, 11, 8, 9)
+		salsaQuarterRound(&x, 15, 12, 13, 14)
+	}
+	for i := 0; i < len(b); i++ {
+		b[i] += x[i]
+	}
+}
+
+/* blockMix mixes 2*r 64-byte blocks of b into y. Even blocks go to the first half of y, odd ones go to the second half. */
+func blockMix(b, y []uint32, r int) {
+	var x [16]uint32
+
+	copy(x[:], b[(2*r-1)*16:])
+	for i := 0; i < 2*r; i++ {
+		for j := 0; j < 16; j++ {
+			x[j] ^= b[i*16+j]
+		}
+		salsa208(&x)
+		copy(y[((i&1)*r+i/2)*16:], x[:])
+	}
+}
+
+func integerify(b []uint32, r int) uint64 {
+	j := (2*r - 1) * 16
+	return uint64(b[j]) | uint64(b[j+1])<<32
+}
+
+/* roMix is sequential memory-hard function of scrypt. v must have space for n blocks of 32*r words. */
+func roMix(b []byte, r, n int, v, xy []uint32) {
+	x := xy[:32*r]
+	y := xy[32*r:]
+
+	for i := 0; i < len(x); i++ {
+		x[i] = binary.LittleEndian.Uint32(b[i*4:])
+	}
+
+	for i := 0; i < n; i++ {
+		copy(v[i*32*r:], x)
+		blockMix(x, y, r)
+		x, y = y, x
+	}
+	for i := 0; i < n; i++ {
+		j := int(integerify(x, r) & uint64(n-1))
+		for k := 0; k < len(x); k++ {
+			x[k] ^= v[j*32*r+k]
+		}
+		blockMix(x, y, r)
+		x, y = y, x
+	}
+
+	for i := 0; i < len(x); i++ {
+		binary.LittleEndian.PutUint32(b[i*4:], x[i])
+	}
+}
+
+/* Scrypt derives key of keyLen bytes from password and salt. n is CPU/memory cost, must be a power of two greater than 1. Memory used is 128*r*n bytes. */
+func Scrypt(password, salt []byte, n, r, p, keyLen int) ([]byte, error) {
+	defer trace_.End(trace_.Begin(""))
+
+	if (n <= 1) || (n&(n-1) != 0) {
+		return nil, errors.New("n must be a power of two greater than 1")
+	}
+	if (r <= 0) || (p <= 0) || (uint64(r)*uint64(p) >= 1<<30) {
+		return nil, errors.New("r and p are invalid")
+	}
+	if (keyLen <= 0) || (uint64(keyLen) > (1<<32-1)*sha256.Size) {
+		return nil, errors.New("key length is invalid")
+	}
+	if uint64(n)*uint64(r) > MaxScryptMemory/128 {
+		return nil, errors.New("parameters require too much memory")
+	}
+
+	b := pbkdf2(password, salt, 1, p*128*r)
+	v := make([]uint32, 32*r*n)
+	xy := make([]uint32, 64*r)
+	for i := 0; i < p; i++ {
+		roMix(b[i*128*r:], r, n, v, xy)
+	}
+
+	return pbkdf2(password, b, 1, keyLen), nil
+}
@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestPBKDF2(t *testing.T) {
+	expected, _ := hex.DecodeString("55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783")
+	if key := pbkdf2([]byte("passwd"), []byte("salt"), 1, 64); !bytes.Equal(key, expected) {
+		t.Errorf("Expected %x, got %x", expected, key)
+	}
+}
+
+func TestScrypt(t *testing.T) {
+	tests := [...]struct {
+		Password string
+		Salt     string
+		N, R, P  int
+		Expected string
+	}{
+		/* RFC 7914, section 12. */
+		{"", "", 16, 1, 1, "77d6576238657b203b19ca42c18a0497f16b4844e3074ae8dfdffa3fede21442fcd0069ded0948f8326a753a0fc81f17e8d3e0fb2e0d3628cf35e20c38d18906"},
+		{"password", "NaCl", 1024, 8, 16, "fdbabe1c9d3472007856e7190d01e9fe7c6ad7cbc8237830e77376634b3731622eaf30d92e22a3886ff109279d9830dac727afb94a83ee6d8360cbdfa2cc0640"},
+	}
+
+	for _, test := range tests {
+		expected, _ := hex.DecodeString(test.Expected)
+		key, err := Scrypt([]byte(test.Password), []byte(test.Salt), test.N, test.R, test.P, len(expected))
+		if err != nil {
+			t.Fatalf("Failed to derive key: %v", err)
+		}
+		if !bytes.Equal(key, expected) {
+			t.Errorf("Expected %x, got %x", expected, key)
+		}
+	}
+
+	for _, params := range [...][3]int{{0, 1, 1}, {3, 1, 1}, {16, 0, 1}, {16, 1, 0}, {16, 1 << 15, 1 << 15}, {1 << 30, 8, 1}} {
+		if _, err := Scrypt(nil, nil, params[0], params[1], params[2], 32); err == nil {
+			t.Errorf("Expected error for N=%d, r=%d, p=%d", params[0], params[1], params[2])
+		}
+	}
+}
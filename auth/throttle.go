@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"sync"
+
+	"github.com/anton2920/gofa/trace/trace_"
+)
+
+/* Throttle limits rate of failed attempts per key, e.g. account name or IP address. The first Free failures are not delayed, after that every attempt must wait twice as long as the previous one, but not longer than MaxDelay. Failures are forgotten after Window without new ones. */
+type Throttle struct {
+	Free     int
+	Delay    int64
+	MaxDelay int64
+	Window   int64
+
+	sync.Mutex
+	Entries map[string]ThrottleEntry
+
+	/* Pruned is the last time entries older than Window were removed. */
+	Pruned int64
+}
+
+type ThrottleEntry struct {
+	Failures int
+	Last     int64
+}
+
+func NewThrottle(free int, delay, maxDelay, window int64) *Throttle {
+	return &Throttle{Free: free, Delay: delay, MaxDelay: maxDelay, Window: window, Entries: make(map[string]ThrottleEntry)}
+}
+
+/* delay returns time entry must wait after the last failure. Lock must be held. */
+func (t *Throttle) delay(entry ThrottleEntry) int64 {
+	if entry.Failures < t.Free {
+		return 0
+	}
+
+	delay := t.Delay
+	for i := t.Free; (i < entry.Failures) && (delay < t.MaxDelay); i++ {
+		delay *= 2
+	}
+	if delay > t.MaxDelay {
+		delay = t.MaxDelay
+	}
+	return delay
+}
+
+/* wait returns time entry must wait at now. Lock must be held. */
+func (t *Throttle) wait(entry ThrottleEntry, now int64) int64 {
+	delay := t.delay(entry)
+	if delay == 0 {
+		return 0
+	}
+	if wait := entry.Last + delay - now; wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+/* Wait returns how long key must wait before the next attempt, zero if attempt is allowed now. */
+func (t *Throttle) Wait(key string, now int64) int64 {
+	defer trace_.End(trace_.Begin(""))
+
+	t.Lock()
+	defer t.Unlock()
+
+	entry, ok := t.Entries[key]
+	if !ok {
+		return 0
+	}
+	return t.wait(entry, now)
+}
+
+func (t *Throttle) Fail(key string, now int64) {
+	defer trace_.End(trace_.Begin(""))
+
+	t.Lock()
+	defer t.Unlock()
+
+	t.fail(key, now)
+}
+
+/* Attempt returns how long key must wait like Wait does. If attempt is allowed, it's recorded as failure at once, so concurrent attempts can't all pass before the first one fails. Successful attempt must be released with Undo or Reset. */
+func (t *Throttle) Attempt(key string, now int64) int64 {
+	defer trace_.End(trace_.Begin(""))
+
+	t.Lock()
+	defer t.Unlock()
+
+	if entry, ok := t.Entries[key]; ok {
+		if wait := t.wait(entry, now); wait > 0 {
+			return wait
+		}
+	}
+	t.fail(key, now)
+	return 0
+}
+
+/* Undo releases failure recorded by Attempt. Time of the last failure is kept, so Undo never makes throttling weaker than it was before Attempt. */
+func (t *Throttle) Undo(key string) {
+	t.Lock()
+	defer t.Unlock()
+
+	entry, ok := t.Entries[key]
+	if !ok {
+		return
+	}
+	if entry.Failures--; entry.Failures <= 0 {
+		delete(t.Entries, key)
+		return
+	}
+	t.Entries[key] = entry
+}
+
+/* fail records failure of key. Lock must be held. */
+func (t *Throttle) fail(key string, now int64) {
+	/* NOTE(anton2920): attacker controls keys, so map is pruned periodically to keep it from growing. */
+	if now-t.Pruned >= t.Window {
+		t.prune(now)
+	}
+
+	entry := t.Entries[key]
+	if now-entry.Last >= t.Window {
+		entry.Failures = 0
+	}
+	entry.Failures++
+	entry.Last = now
+	t.Entries[key] = entry
+}
+
+func (t *Throttle) Reset(key string) {
+	t.Lock()
+	delete(t.Entries, key)
+	t.Unlock()
+}
+
+/* prune removes entries without failures for Window. Lock must be held. */
+func (t *Throttle) prune(now int64) {
+	for key, entry := range t.Entries {
+		if now-entry.Last >= t.Window {
+			delete(t.Entries, key)
+		}
+	}
+	t.Pruned = now
+}
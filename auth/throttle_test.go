@@ -0,0 +1,60 @@
+package auth
+
+import "testing"
+
+func TestThrottle(t *testing.T) {
+	th := NewThrottle(2, 10, 35, 1000)
+
+	for i, expected := range [...]int64{0, 0, 10, 20, 35, 35} {
+		if wait := th.Wait("key", 100); wait != expected {
+			t.Errorf("After %d failures expected to wait %d, got %d", i, expected, wait)
+		}
+		th.Fail("key", 100)
+	}
+	if wait := th.Wait("key", 120); wait != 15 {
+		t.Errorf("Expected to wait 15, got %d", wait)
+	}
+	if wait := th.Wait("other", 100); wait != 0 {
+		t.Errorf("Expected other key not to wait, got %d", wait)
+	}
+
+	/* Failures are forgotten after window. */
+	th.Fail("key", 1100)
+	if wait := th.Wait("key", 1100); wait != 0 {
+		t.Errorf("Expected failures to be forgotten, got %d", wait)
+	}
+
+	th.Fail("stale", 1100)
+	th.Fail("key", 2100)
+	if _, ok := th.Entries["stale"]; ok {
+		t.Errorf("Expected stale entry to be pruned")
+	}
+
+	th.Reset("key")
+	if wait := th.Wait("key", 2100); wait != 0 {
+		t.Errorf("Expected reset key not to wait, got %d", wait)
+	}
+}
+
+func TestThrottleAttempt(t *testing.T) {
+	th := NewThrottle(2, 10, 35, 1000)
+
+	for i, expected := range [...]int64{0, 0, 10, 10} {
+		if wait := th.Attempt("key", 100); wait != expected {
+			t.Errorf("Attempt %d: expected to wait %d, got %d", i, expected, wait)
+		}
+	}
+	if failures := th.Entries["key"].Failures; failures != 2 {
+		t.Errorf("Expected throttled attempts not to be recorded, got %d failures", failures)
+	}
+
+	th.Undo("key")
+	if wait := th.Wait("key", 100); wait != 0 {
+		t.Errorf("Expected released attempt not to count, got %d", wait)
+	}
+	th.Undo("key")
+	if _, ok := th.Entries["key"]; ok {
+		t.Errorf("Expected entry without failures to be removed")
+	}
+	th.Undo("key")
+}
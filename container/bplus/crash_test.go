@@ -0,0 +1,143 @@
+package bplus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"github.com/anton2920/gofa/io/fs"
+)
+
+const (
+	crashRounds = 5
+	crashKeys   = 8
+)
+
+/* crashRoundKeys returns number of keys that exist after round. Every round rewrites all existing keys and adds new ones. */
+func crashRoundKeys(round int) int {
+	return crashKeys * (round + 1)
+}
+
+func crashKey(i int) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(i))
+	return key
+}
+
+func crashValue(round int, i int) []byte {
+	n := 16
+	if i%7 == 0 {
+		/* Big enough to need overflow pages. */
+		n = PageSize + 100
+	}
+
+	value := make([]byte, n)
+	for j := 0; j < len(value); j++ {
+		value[j] = byte(round)
+	}
+	binary.BigEndian.PutUint64(value, uint64(i))
+
+	return value
+}
+
+func crashCheckRound(tree *Tree, round int) error {
+	it, err := tree.Iter()
+	if err != nil {
+		return fmt.Errorf("failed to create iterator: %v", err)
+	}
+	defer it.Close()
+
+	var i int
+	for it.Next() {
+		if !bytes.Equal(it.Key(), crashKey(i)) {
+			return fmt.Errorf("expected key %v, got %v", crashKey(i), it.Key())
+		}
+		if !bytes.Equal(it.Value(), crashValue(round, i)) {
+			return fmt.Errorf("value for key %d does not belong to round %d", i, round)
+		}
+		i++
+	}
+	if it.Error != nil {
+		return fmt.Errorf("failed to iterate: %v", it.Error)
+	}
+	if i != crashRoundKeys(round) {
+		return fmt.Errorf("expected %d keys after round %d, got %d", crashRoundKeys(round), round, i)
+	}
+
+	return nil
+}
+
+func TestTreeCrash(t *testing.T) {
+	/* committed is the last round for which Commit returned successfully. */
+	var committed int
+
+	workload := func(vfs fs.VFS) error {
+		committed = -1
+
+		f, err := vfs.Open("tree", fs.OpenForReading|fs.OpenForWriting|fs.CreateFileIfItDoesNotExist)
+		if err != nil {
+			return err
+		}
+		tree, err := OpenTreeAt(f, 0)
+		if err != nil {
+			return err
+		}
+
+	rounds:
+		for round := 0; round < crashRounds; round++ {
+			tx, err := tree.Begin()
+			if err != nil {
+				return err
+			}
+			for i := 0; i < crashRoundKeys(round); i++ {
+				if err := tx.Set(crashKey(i), crashValue(round, i)); err != nil {
+					tx.Rollback()
+					if vfs.(*fs.FaultFS).Crashed {
+						return err
+					}
+					/* Retry the whole round. */
+					round--
+					continue rounds
+				}
+			}
+			if err := tx.Commit(); err != nil {
+				if vfs.(*fs.FaultFS).Crashed {
+					return err
+				}
+				round--
+				continue
+			}
+			committed = round
+		}
+
+		return nil
+	}
+
+	check := func(vfs fs.VFS) error {
+		f, err := vfs.Open("tree", fs.OpenForReading|fs.OpenForWriting)
+		if err != nil {
+			if (err == fs.ErrNotExist) && (committed == -1) {
+				return nil
+			}
+			return fmt.Errorf("failed to open file: %v", err)
+		}
+		tree, err := OpenTreeAt(f, 0)
+		if err != nil {
+			return fmt.Errorf("failed to open tree: %v", err)
+		}
+
+		/* Round that was in progress during crash may or may not be visible. */
+		err = crashCheckRound(tree, committed)
+		if (err != nil) && (committed+1 < crashRounds) {
+			if crashCheckRound(tree, committed+1) == nil {
+				return nil
+			}
+		}
+		return err
+	}
+
+	if err := fs.RunCrashTest(workload, check); err != nil {
+		t.Fatal(err)
+	}
+}
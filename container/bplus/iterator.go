@@ -0,0 +1,315 @@
+package bplus
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/anton2920/gofa/trace/trace_"
+)
+
+/* TreeIterator keeps path from root to the current leaf instead of following sibling links, so stepping in either direction only reads pages reachable from the snapshot it was started from. Pages are copied into iterator, so multiple iterators may be used concurrently with each other and with other readers. */
+type TreeIterator struct {
+	Tree *Tree
+	Root int64
+
+	/* Owner is snapshot created for iterator by Tree, it is released by Close. */
+	Owner *ReadTx
+
+	Path    []TreePathItem
+	Leaf    Leaf
+	Current int
+
+	/* Bound is an exclusive upper bound for forward iterators and an inclusive lower bound for reverse iterators; nil means unbounded. */
+	Bound []byte
+
+	/* Buffer holds last value returned by Value(). */
+	Buffer []byte
+
+	Error error
+}
+
+type TreeForwardIterator struct {
+	TreeIterator
+}
+
+type TreeReverseIterator struct {
+	TreeIterator
+}
+
+const (
+	descendFirst = iota
+	descendLast
+	descendKey
+)
+
+/* PrefixEnd returns the smallest key that is greater than all keys starting with prefix or nil, if there is no such key. */
+func PrefixEnd(prefix []byte) []byte {
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if prefix[i] != 0xFF {
+			end := make([]byte, i+1)
+			copy(end, prefix)
+			end[i]++
+			return end
+		}
+	}
+	return nil
+}
+
+func (it *TreeIterator) descend(index int64, how int, key []byte) bool {
+	var page Page
+
+	for {
+		if _, err := it.Tree.ReadPageAt(&page, index); err != nil {
+			it.Error = fmt.Errorf("failed to read page: %v", err)
+			return false
+		}
+
+		switch page.Type() {
+		default:
+			it.Error = fmt.Errorf("unexpected page type %d at %d", page.Type(), index)
+			return false
+		case PageTypeNode:
+			var pos int
+
+			node := page.Node()
+			switch how {
+			case descendFirst:
+				pos = -1
+			case descendLast:
+				pos = int(node.N) - 1
+			case descendKey:
+				pos = node.Find(key)
+			}
+			it.Path = append(it.Path, TreePathItem{page, index, pos})
+			index = node.GetChildAt(pos)
+		case PageTypeLeaf:
+			it.Leaf = *page.Leaf()
+			return true
+		}
+	}
+}
+
+func (it *TreeIterator) nextLeaf() bool {
+	for p := len(it.Path) - 1; p >= 0; p-- {
+		item := &it.Path[p]
+		node := item.Page.Node()
+		if item.Pos < int(node.N)-1 {
+			item.Pos++
+			it.Path = it.Path[:p+1]
+			return it.descend(node.GetChildAt(item.Pos), descendFirst, nil)
+		}
+	}
+	return false
+}
+
+func (it *TreeIterator) prevLeaf() bool {
+	for p := len(it.Path) - 1; p >= 0; p-- {
+		item := &it.Path[p]
+		node := item.Page.Node()
+		if item.Pos > -1 {
+			item.Pos--
+			it.Path = it.Path[:p+1]
+			return it.descend(node.GetChildAt(item.Pos), descendLast, nil)
+		}
+	}
+	return false
+}
+
+func (it *TreeIterator) reset(how int, key []byte) bool {
+	it.Path = it.Path[:0]
+	it.Error = nil
+	return it.descend(it.Root, how, key)
+}
+
+func (it *TreeIterator) Key() []byte {
+	return it.Leaf.GetKeyAt(it.Current)
+}
+
+/* Value returns value at current position, reassembling it from overflow pages if necessary. Returned slice is valid until the next call to Value. */
+func (it *TreeIterator) Value() []byte {
+	value, err := it.Tree.ReadValue(it.Buffer[:0], it.Leaf.GetValueAt(it.Current))
+	if err != nil {
+		it.Error = err
+		return nil
+	}
+	it.Buffer = value
+	return value
+}
+
+/* Close releases snapshot held by iterator. Iterators created from ReadTx are released with it, so Close is a no-op for them. */
+func (it *TreeIterator) Close() {
+	if it.Owner != nil {
+		it.Owner.End()
+		it.Owner = nil
+	}
+}
+
+/* Seek positions iterator so that the following call to Next lands on the first key that is greater than or equal to key. */
+func (it *TreeForwardIterator) Seek(key []byte) {
+	defer trace_.End(trace_.Begin(""))
+
+	if it.reset(descendKey, key) {
+		it.Current, _ = it.Leaf.Find(key)
+	}
+}
+
+func (it *TreeForwardIterator) Next() bool {
+	if it.Error != nil {
+		return false
+	}
+
+	it.Current++
+	for it.Current >= int(it.Leaf.N) {
+		if !it.nextLeaf() {
+			return false
+		}
+		it.Current = 0
+	}
+
+	return (it.Bound == nil) || (bytes.Compare(it.Key(), it.Bound) < 0)
+}
+
+func (it *TreeReverseIterator) seek(key []byte, inclusive bool) {
+	if it.reset(descendKey, key) {
+		pos, ok := it.Leaf.Find(key)
+		if (ok) && (inclusive) {
+			pos++
+		}
+		it.Current = pos + 1
+	}
+}
+
+/* Seek positions iterator so that the following call to Next lands on the last key that is less than or equal to key. */
+func (it *TreeReverseIterator) Seek(key []byte) {
+	defer trace_.End(trace_.Begin(""))
+
+	it.seek(key, true)
+}
+
+func (it *TreeReverseIterator) Next() bool {
+	if it.Error != nil {
+		return false
+	}
+
+	it.Current--
+	for it.Current < 0 {
+		if !it.prevLeaf() {
+			return false
+		}
+		it.Current = int(it.Leaf.N) - 1
+	}
+
+	return (it.Bound == nil) || (bytes.Compare(it.Key(), it.Bound) >= 0)
+}
+
+/* Iter returns iterator positioned before the smallest key. */
+func (tx *ReadTx) Iter() (*TreeForwardIterator, error) {
+	return tx.Range(nil, nil)
+}
+
+/* IterReverse returns iterator positioned after the largest key. */
+func (tx *ReadTx) IterReverse() (*TreeReverseIterator, error) {
+	return tx.RangeReverse(nil, nil)
+}
+
+/* Range returns iterator over keys in [from, to) in ascending order. Nil from or to means unbounded. */
+func (tx *ReadTx) Range(from []byte, to []byte) (*TreeForwardIterator, error) {
+	defer trace_.End(trace_.Begin(""))
+
+	var it TreeForwardIterator
+
+	it.Tree = tx.Tree
+	it.Root = tx.Root
+	it.Bound = to
+
+	if from == nil {
+		if it.reset(descendFirst, nil) {
+			it.Current = -1
+		}
+	} else {
+		it.Seek(from)
+	}
+	if it.Error != nil {
+		return nil, it.Error
+	}
+
+	return &it, nil
+}
+
+/* RangeReverse returns iterator over keys in [from, to) in descending order. Nil from or to means unbounded. */
+func (tx *ReadTx) RangeReverse(from []byte, to []byte) (*TreeReverseIterator, error) {
+	defer trace_.End(trace_.Begin(""))
+
+	var it TreeReverseIterator
+
+	it.Tree = tx.Tree
+	it.Root = tx.Root
+	it.Bound = from
+
+	if to == nil {
+		if it.reset(descendLast, nil) {
+			it.Current = int(it.Leaf.N)
+		}
+	} else {
+		it.seek(to, false)
+	}
+	if it.Error != nil {
+		return nil, it.Error
+	}
+
+	return &it, nil
+}
+
+/* Prefix returns iterator over keys starting with prefix in ascending order. */
+func (tx *ReadTx) Prefix(prefix []byte) (*TreeForwardIterator, error) {
+	return tx.Range(prefix, PrefixEnd(prefix))
+}
+
+/* PrefixReverse returns iterator over keys starting with prefix in descending order. */
+func (tx *ReadTx) PrefixReverse(prefix []byte) (*TreeReverseIterator, error) {
+	return tx.RangeReverse(prefix, PrefixEnd(prefix))
+}
+
+/* Iter returns iterator over the last committed snapshot. It must be closed with Close. */
+func (t *Tree) Iter() (*TreeForwardIterator, error) {
+	return t.Range(nil, nil)
+}
+
+/* IterReverse returns reverse iterator over the last committed snapshot. It must be closed with Close. */
+func (t *Tree) IterReverse() (*TreeReverseIterator, error) {
+	return t.RangeReverse(nil, nil)
+}
+
+func (t *Tree) Range(from []byte, to []byte) (*TreeForwardIterator, error) {
+	tx := t.BeginRead()
+
+	it, err := tx.Range(from, to)
+	if err != nil {
+		tx.End()
+		return nil, err
+	}
+	it.Owner = tx
+
+	return it, nil
+}
+
+func (t *Tree) RangeReverse(from []byte, to []byte) (*TreeReverseIterator, error) {
+	tx := t.BeginRead()
+
+	it, err := tx.RangeReverse(from, to)
+	if err != nil {
+		tx.End()
+		return nil, err
+	}
+	it.Owner = tx
+
+	return it, nil
+}
+
+func (t *Tree) Prefix(prefix []byte) (*TreeForwardIterator, error) {
+	return t.Range(prefix, PrefixEnd(prefix))
+}
+
+func (t *Tree) PrefixReverse(prefix []byte) (*TreeReverseIterator, error) {
+	return t.RangeReverse(prefix, PrefixEnd(prefix))
+}
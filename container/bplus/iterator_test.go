@@ -0,0 +1,158 @@
+package bplus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func testIterKeys(t *testing.T, it interface {
+	Next() bool
+	Key() []byte
+}) []int {
+	var keys []int
+	for it.Next() {
+		keys = append(keys, int(binary.BigEndian.Uint64(it.Key())))
+	}
+	return keys
+}
+
+func testEqualKeys(keys []int, expected []int) bool {
+	if len(keys) != len(expected) {
+		return false
+	}
+	for i := 0; i < len(keys); i++ {
+		if keys[i] != expected[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestTreeIterators(t *testing.T) {
+	const keys = 500
+
+	tree, _ := testOpenTree(t)
+
+	/* Even keys only, so bounds between keys are tested as well. Deleted range leaves empty leaves behind. */
+	tx, _ := tree.Begin()
+	for i := 0; i < keys; i++ {
+		tx.Set(testKey(2*i), testValue(2*i, 0))
+	}
+	for i := 200; i < 300; i++ {
+		tx.Del(testKey(2 * i))
+	}
+	tx.Commit()
+
+	expected := func(from int, to int) []int {
+		var result []int
+		for i := 0; i < keys; i++ {
+			if (2*i >= from) && (2*i < to) && ((i < 200) || (i >= 300)) {
+				result = append(result, 2*i)
+			}
+		}
+		return result
+	}
+	reversed := func(keys []int) []int {
+		result := make([]int, len(keys))
+		for i := 0; i < len(keys); i++ {
+			result[len(keys)-1-i] = keys[i]
+		}
+		return result
+	}
+
+	rtx := tree.BeginRead()
+	defer rtx.End()
+
+	tests := [...]struct {
+		From, To int
+	}{
+		{-1, -1},
+		{0, 2 * keys},
+		{10, 20},
+		{11, 21},
+		{11, 12},
+		{380, 620},
+		{400, 600},
+		{401, 599},
+		{-1, 101},
+		{901, -1},
+		{2 * keys, -1},
+	}
+	for _, test := range tests {
+		var from, to []byte
+		lo, hi := test.From, test.To
+		if lo >= 0 {
+			from = testKey(lo)
+		} else {
+			lo = 0
+		}
+		if hi >= 0 {
+			to = testKey(hi)
+		} else {
+			hi = 2 * keys
+		}
+
+		it, err := rtx.Range(from, to)
+		if err != nil {
+			t.Fatalf("Failed to create iterator: %v", err)
+		}
+		if got := testIterKeys(t, it); !testEqualKeys(got, expected(lo, hi)) {
+			t.Errorf("Range(%d, %d): expected %v, got %v", test.From, test.To, expected(lo, hi), got)
+		}
+
+		rit, err := rtx.RangeReverse(from, to)
+		if err != nil {
+			t.Fatalf("Failed to create iterator: %v", err)
+		}
+		if got := testIterKeys(t, rit); !testEqualKeys(got, reversed(expected(lo, hi))) {
+			t.Errorf("RangeReverse(%d, %d): expected %v, got %v", test.From, test.To, reversed(expected(lo, hi)), got)
+		}
+	}
+
+	/* Keys 0x100-0x1FE share prefix 0x01 in the seventh byte. */
+	prefix := []byte{0, 0, 0, 0, 0, 0, 1}
+	it, _ := rtx.Prefix(prefix)
+	if got := testIterKeys(t, it); !testEqualKeys(got, expected(0x100, 0x200)) {
+		t.Errorf("Prefix: expected %v, got %v", expected(0x100, 0x200), got)
+	}
+	rit, _ := rtx.PrefixReverse(prefix)
+	if got := testIterKeys(t, rit); !testEqualKeys(got, reversed(expected(0x100, 0x200))) {
+		t.Errorf("PrefixReverse: expected %v, got %v", reversed(expected(0x100, 0x200)), got)
+	}
+
+	fit, _ := tree.Iter()
+	defer fit.Close()
+	fit.Seek(testKey(101))
+	if (!fit.Next()) || (!bytes.Equal(fit.Key(), testKey(102))) || (!bytes.Equal(fit.Value(), testValue(102, 0))) {
+		t.Errorf("Expected forward Seek to land on the next key")
+	}
+
+	rit, _ = tree.IterReverse()
+	defer rit.Close()
+	rit.Seek(testKey(101))
+	if (!rit.Next()) || (!bytes.Equal(rit.Key(), testKey(100))) || (!bytes.Equal(rit.Value(), testValue(100, 0))) {
+		t.Errorf("Expected reverse Seek to land on the previous key")
+	}
+	rit.Seek(testKey(100))
+	if (!rit.Next()) || (!bytes.Equal(rit.Key(), testKey(100))) {
+		t.Errorf("Expected reverse Seek to land on the key itself")
+	}
+}
+
+func TestPrefixEnd(t *testing.T) {
+	tests := [...]struct {
+		Prefix   []byte
+		Expected []byte
+	}{
+		{[]byte{1, 2}, []byte{1, 3}},
+		{[]byte{1, 0xFF}, []byte{2}},
+		{[]byte{0xFF, 0xFF}, nil},
+		{nil, nil},
+	}
+	for _, test := range tests {
+		if end := PrefixEnd(test.Prefix); !bytes.Equal(end, test.Expected) {
+			t.Errorf("%v: expected %v, got %v", test.Prefix, test.Expected, end)
+		}
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
-	"reflect"
 	"unsafe"
 
 	"github.com/anton2920/gofa/bools"
@@ -15,10 +14,8 @@ import (
 type Leaf struct {
 	PageHeader
 
-	Next int64
-
 	/* Data is structured as follows: | N*sizeof(uint16) bytes of keyOffsets | keys... | ...empty space... | ...values | N*sizeof(uint16) bytes of valueOffsets | */
-	Data [PageSize - PageHeaderSize - 1*unsafe.Sizeof(int64(0))]byte
+	Data [PageSize - PageHeaderSize]byte
 }
 
 func init() {
@@ -100,7 +97,7 @@ func (l *Leaf) GetKeyOffsetInData(index int) int {
 }
 
 func (l *Leaf) GetKeyOffsets() []uint16 {
-	return *(*[]uint16)(unsafe.Pointer(&reflect.SliceHeader{Data: uintptr(unsafe.Pointer(&l.Data[0])), Len: int(l.N), Cap: int(l.N)}))
+	return (*[len(l.Data) / 2]uint16)(unsafe.Pointer(&l.Data[0]))[:l.N:l.N]
 }
 
 func (l *Leaf) GetValueAt(index int) []byte {
@@ -133,7 +130,10 @@ func (l *Leaf) GetValueOffsetInData(index int) int {
 func (l *Leaf) GetValueOffsets() []uint16 {
 	var valueOffset uint16
 
-	return *(*[]uint16)(unsafe.Pointer(&reflect.SliceHeader{Data: uintptr(unsafe.Pointer(&l.Data[0])) + uintptr(len(l.Data)) - unsafe.Sizeof(valueOffset)*uintptr(l.N), Len: int(l.N), Cap: int(l.N)}))
+	if l.N == 0 {
+		return nil
+	}
+	return (*[len(l.Data) / 2]uint16)(unsafe.Pointer(&l.Data[len(l.Data)-int(unsafe.Sizeof(valueOffset))*int(l.N)]))[:l.N:l.N]
 }
 
 func (l *Leaf) InsertKeyValueAt(key []byte, value []byte, index int) {
@@ -261,32 +261,7 @@ func (src *Leaf) MoveData(dst *Leaf, where int, from int, to int) {
 	dst.N += uint8(count)
 
 	/* Bulk remove of 'src[from:to]'.*/
-	extraOffset = src.GetExtraOffset(-count)
-
-	keyOffsets = src.GetKeyOffsets()
-	valueOffsets = src.GetValueOffsets()
-	if extraOffset > 0 {
-		for i := 0; i < from; i++ {
-			keyOffsets[i] -= uint16(extraOffset)
-			valueOffsets[int(src.N)-1-i] += uint16(extraOffset)
-		}
-	}
-	for i := to; i < int(src.N); i++ {
-		keyOffsets[i] -= uint16(keyLengths + extraOffset)
-		valueOffsets[int(src.N)-1-i] += uint16(valueLengths + extraOffset)
-	}
-
-	copy(src.Data[src.GetKeyOffsetInData(from):], src.Data[src.GetKeyOffsetInData(to):src.GetKeyOffsetInData(int(src.N))])
-	copy(src.Data[src.GetFirstKeyOffset()-extraOffset:], src.Data[src.GetFirstKeyOffset():fromKeyOffset])
-	copy(src.Data[fromKeyOffset-extraOffset:], src.Data[fromKeyOffset+keyLengths:src.Head])
-
-	copy(src.Data[src.GetValueOffsetInData(from):], src.Data[src.GetValueOffsetInData(int(src.N)-1):src.GetValueOffsetInData(to-1)])
-	copy(src.Data[fromValueOffset+extraOffset:], src.Data[fromValueOffset:src.GetFirstValueOffset()])
-	copy(src.Data[len(src.Data)-int(src.Tail)+valueLengths+extraOffset:], src.Data[len(src.Data)-int(src.Tail):fromValueOffset-valueLengths])
-
-	src.Head -= uint16(keyLengths + extraOffset)
-	src.Tail -= uint16(valueLengths + extraOffset)
-	src.N -= uint8(count)
+	src.RemoveKeyValues(from, to)
 }
 
 func (l *Leaf) OverflowAfterInsertKeyValue(keyLength int, valueLength int) bool {
@@ -376,6 +351,60 @@ func (l *Leaf) String() string {
 	return buf.String()
 }
 
+/* RemoveKeyValues removes 'l[from:to]'. */
+func (l *Leaf) RemoveKeyValues(from int, to int) {
+	var keyLengths, valueLengths int
+
+	if (from < 0) || (to > int(l.N)) || (from > to) {
+		panic("leaf index out of range")
+	}
+	count := to - from
+	if count == 0 {
+		return
+	}
+
+	fromKeyOffset, _ := l.GetKeyOffsetAndLength(from)
+	fromValueOffset, _ := l.GetValueOffsetAndLength(from)
+	for i := from; i < to; i++ {
+		_, keyLength := l.GetKeyOffsetAndLength(i)
+		keyLengths += keyLength
+
+		_, valueLength := l.GetValueOffsetAndLength(i)
+		valueLengths += valueLength
+	}
+
+	extraOffset := l.GetExtraOffset(-count)
+
+	keyOffsets := l.GetKeyOffsets()
+	valueOffsets := l.GetValueOffsets()
+	if extraOffset > 0 {
+		for i := 0; i < from; i++ {
+			keyOffsets[i] -= uint16(extraOffset)
+			valueOffsets[int(l.N)-1-i] += uint16(extraOffset)
+		}
+	}
+	for i := to; i < int(l.N); i++ {
+		keyOffsets[i] -= uint16(keyLengths + extraOffset)
+		valueOffsets[int(l.N)-1-i] += uint16(valueLengths + extraOffset)
+	}
+
+	copy(l.Data[l.GetKeyOffsetInData(from):], l.Data[l.GetKeyOffsetInData(to):l.GetKeyOffsetInData(int(l.N))])
+	copy(l.Data[l.GetFirstKeyOffset()-extraOffset:], l.Data[l.GetFirstKeyOffset():fromKeyOffset])
+	copy(l.Data[fromKeyOffset-extraOffset:], l.Data[fromKeyOffset+keyLengths:l.Head])
+
+	copy(l.Data[l.GetValueOffsetInData(int(l.N)-count-1):], l.Data[l.GetValueOffsetInData(int(l.N)-1):l.GetValueOffsetInData(to-1)])
+	copy(l.Data[fromValueOffset+extraOffset:], l.Data[fromValueOffset:l.GetFirstValueOffset()])
+	copy(l.Data[len(l.Data)-int(l.Tail)+valueLengths+extraOffset:], l.Data[len(l.Data)-int(l.Tail):fromValueOffset-valueLengths])
+
+	l.Head -= uint16(keyLengths + extraOffset)
+	l.Tail -= uint16(valueLengths + extraOffset)
+	l.N -= uint8(count)
+}
+
+func (l *Leaf) RemoveKeyValueAt(index int) {
+	l.RemoveKeyValues(index, index+1)
+}
+
 func (l *Leaf) Reset() {
 	l.N = 0
 	l.Head = 0
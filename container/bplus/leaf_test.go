@@ -1,6 +1,9 @@
 package bplus
 
-import "testing"
+import (
+	"bytes"
+	"testing"
+)
 
 func BenchmarkLeafInsertKeyValueAt(b *testing.B) {
 	var page Page
@@ -29,3 +32,37 @@ func BenchmarkLeafInsertKeyValueAt(b *testing.B) {
 		}
 	})
 }
+
+func TestLeafRemoveKeyValueAt(t *testing.T) {
+	var page Page
+	var keys [][]byte
+
+	page.Init(PageTypeLeaf)
+	leaf := page.Leaf()
+
+	for i := 0; i < 40; i++ {
+		key := bytes.Repeat([]byte{byte(i)}, i%5+1)
+		leaf.InsertKeyValueAt(key, key, i)
+		keys = append(keys, key)
+	}
+
+	/* Remove from the middle, then from both ends. */
+	for _, pos := range [...]int{20, 19, 0, 36, 10} {
+		leaf.RemoveKeyValueAt(pos)
+		keys = append(keys[:pos], keys[pos+1:]...)
+
+		if int(leaf.N) != len(keys) {
+			t.Fatalf("expected %d keys, got %d", len(keys), leaf.N)
+		}
+		for i := 0; i < len(keys); i++ {
+			if (!bytes.Equal(leaf.GetKeyAt(i), keys[i])) || (!bytes.Equal(leaf.GetValueAt(i), keys[i])) {
+				t.Fatalf("after removing %d: expected %v at %d, got %v => %v", pos, keys[i], i, leaf.GetKeyAt(i), leaf.GetValueAt(i))
+			}
+		}
+	}
+
+	leaf.RemoveKeyValues(0, int(leaf.N))
+	if (leaf.N != 0) || (leaf.Head != 0) || (leaf.Tail != 0) {
+		t.Fatalf("expected empty leaf, got N=%d, Head=%d, Tail=%d", leaf.N, leaf.Head, leaf.Tail)
+	}
+}
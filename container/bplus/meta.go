@@ -1,6 +1,9 @@
 package bplus
 
-import "unsafe"
+import (
+	"hash/crc32"
+	"unsafe"
+)
 
 type Meta struct {
 	PageHeader
@@ -8,14 +11,26 @@ type Meta struct {
 	Magic   uint64
 	Version uint64
 
-	Root        int64
-	EndSentinel int64
-
+	Root    int64
 	LastSeq uint64
 
+	/* Checksum is CRC32C of the whole page with Checksum set to zero. */
+	Checksum uint32
+	_        uint32
+
 	_ [PageSize - PageHeaderSize - 5*unsafe.Sizeof(int64(0))]byte
 }
 
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
 func (m *Meta) Page() *Page {
 	return (*Page)(unsafe.Pointer(m))
 }
+
+func (m *Meta) Sum() uint32 {
+	checksum := m.Checksum
+	m.Checksum = 0
+	sum := crc32.Checksum(Page2Bytes(m.Page()), castagnoli)
+	m.Checksum = checksum
+	return sum
+}
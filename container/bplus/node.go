@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
-	"reflect"
 	"unsafe"
 
 	"github.com/anton2920/gofa/debug"
@@ -114,7 +113,7 @@ func (n *Node) GetKeyOffsetInData(index int) int {
 }
 
 func (n *Node) GetKeyOffsets() []uint16 {
-	return *(*[]uint16)(unsafe.Pointer(&reflect.SliceHeader{Data: uintptr(unsafe.Pointer(&n.Data[0])), Len: int(n.N), Cap: int(n.N)}))
+	return (*[len(n.Data) / 2]uint16)(unsafe.Pointer(&n.Data[0]))[:n.N:n.N]
 }
 
 func (n *Node) InsertKeyChildAt(key []byte, child int64, index int) {
@@ -257,6 +256,49 @@ func (n *Node) OverflowAfterInsertKeyChild(keyLength int) bool {
 	return int(n.Head)+int(n.Tail)+keyLength+int(unsafe.Sizeof(child))+n.GetExtraOffset(1) > len(n.Data)
 }
 
+/* RemoveKeyChildren removes keys and children 'n[from:to]'. Child at -1 cannot be removed this way. */
+func (n *Node) RemoveKeyChildren(from int, to int) {
+	var keyLengths int
+	var child int64
+
+	if (from < 0) || (to > int(n.N)) || (from > to) {
+		panic("node index out of range")
+	}
+	count := to - from
+	if count == 0 {
+		return
+	}
+
+	fromKeyOffset, _ := n.GetKeyOffsetAndLength(from)
+	for i := from; i < to; i++ {
+		_, keyLength := n.GetKeyOffsetAndLength(i)
+		keyLengths += keyLength
+	}
+	childrenLengths := int(unsafe.Sizeof(child)) * count
+
+	extraOffset := n.GetExtraOffset(-count)
+
+	keyOffsets := n.GetKeyOffsets()
+	if extraOffset > 0 {
+		for i := 0; i < from; i++ {
+			keyOffsets[i] -= uint16(extraOffset)
+		}
+	}
+	for i := to; i < int(n.N); i++ {
+		keyOffsets[i] -= uint16(keyLengths + extraOffset)
+	}
+
+	copy(n.Data[n.GetKeyOffsetInData(from):], n.Data[n.GetKeyOffsetInData(to):n.GetKeyOffsetInData(int(n.N))])
+	copy(n.Data[n.GetFirstKeyOffset()-extraOffset:], n.Data[n.GetFirstKeyOffset():fromKeyOffset])
+	copy(n.Data[fromKeyOffset-extraOffset:], n.Data[fromKeyOffset+keyLengths:n.Head])
+
+	copy(n.Data[n.GetChildOffsetInData(int(n.N)-1-count):], n.Data[n.GetChildOffsetInData(int(n.N)-1):n.GetChildOffsetInData(to-1)])
+
+	n.Head -= uint16(keyLengths + extraOffset)
+	n.Tail -= uint16(childrenLengths)
+	n.N -= uint8(count)
+}
+
 func (n *Node) SetChildAt(offset int64, index int) {
 	binary.LittleEndian.PutUint64(n.Data[n.GetChildOffsetInData(index):], uint64(offset))
 }
@@ -1,6 +1,7 @@
 package bplus
 
 import (
+	"bytes"
 	"testing"
 	"unsafe"
 )
@@ -38,3 +39,57 @@ func BenchmarkNodeInsertKeyChildAt(b *testing.B) {
 		}
 	})
 }
+
+func TestNodeRemoveKeyChildren(t *testing.T) {
+	var page Page
+	var keys [][]byte
+	var children []int64
+
+	page.Init(PageTypeNode)
+	node := page.Node()
+
+	node.Init([]byte{0}, 100, 0)
+	keys = append(keys, []byte{0})
+	children = append(children, 100, 0)
+	for i := 1; i < 40; i++ {
+		key := bytes.Repeat([]byte{byte(i)}, i%5+1)
+		node.InsertKeyChildAt(key, int64(i), i)
+		keys = append(keys, key)
+		children = append(children, int64(i))
+	}
+
+	/* NOTE(anton2920): children[0] is child at -1, so key i goes with children[i+1]. */
+	for _, r := range [...][2]int{{20, 21}, {18, 20}, {0, 1}, {33, 35}, {5, 9}} {
+		node.RemoveKeyChildren(r[0], r[1])
+		keys = append(keys[:r[0]], keys[r[1]:]...)
+		children = append(children[:r[0]+1], children[r[1]+1:]...)
+
+		if int(node.N) != len(keys) {
+			t.Fatalf("expected %d keys, got %d", len(keys), node.N)
+		}
+		for i := 0; i < len(keys); i++ {
+			if !bytes.Equal(node.GetKeyAt(i), keys[i]) {
+				t.Fatalf("after removing %v: expected key %v at %d, got %v", r, keys[i], i, node.GetKeyAt(i))
+			}
+		}
+		for i := -1; i < len(keys); i++ {
+			if node.GetChildAt(i) != children[i+1] {
+				t.Fatalf("after removing %v: expected child %d at %d, got %d", r, children[i+1], i, node.GetChildAt(i))
+			}
+		}
+	}
+
+	/* Removed space must be reusable. */
+	for i := 0; i < 100; i++ {
+		key := bytes.Repeat([]byte{0xFF}, 32)
+		if node.OverflowAfterInsertKeyChild(len(key)) {
+			break
+		}
+		node.InsertKeyChildAt(key, 1000, int(node.N))
+	}
+	for i := 0; i < len(keys); i++ {
+		if !bytes.Equal(node.GetKeyAt(i), keys[i]) {
+			t.Fatalf("after refill: expected key %v at %d, got %v", keys[i], i, node.GetKeyAt(i))
+		}
+	}
+}
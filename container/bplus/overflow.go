@@ -1,6 +1,10 @@
 package bplus
 
-import "unsafe"
+import (
+	"unsafe"
+
+	"github.com/anton2920/gofa/ints"
+)
 
 type Overflow struct {
 	PageHeader
@@ -10,8 +14,9 @@ type Overflow struct {
 	Data [PageSize - PageHeaderSize - unsafe.Sizeof(int64(0))]byte
 }
 
+/* SetValue stores the tail of value that fits into overflow page and returns what is left. */
 func (o *Overflow) SetValue(value []byte) []byte {
-	o.Head = uint16(copy(o.Data[:], value[len(value)-len(o.Data):]))
+	o.Head = uint16(copy(o.Data[:], value[len(value)-ints.Min(len(value), len(o.Data)):]))
 	return value[:len(value)-int(o.Head)]
 }
 
@@ -2,7 +2,6 @@ package bplus
 
 import (
 	"log"
-	"reflect"
 	"unsafe"
 
 	"github.com/anton2920/gofa/bools"
@@ -114,10 +113,11 @@ func GetExtraOffset(n int, count int) int {
 	}
 }
 
+/* NOTE(anton2920): pages are passed to VFile through interface and often live on stack. Slices made from uintptr hide them from escape analysis, so they are not updated when stack moves. */
 func Page2Bytes(p *Page) []byte {
-	return *(*[]byte)(unsafe.Pointer(&reflect.SliceHeader{Data: uintptr(unsafe.Pointer(p)), Len: int(unsafe.Sizeof(*p)), Cap: int(unsafe.Sizeof(*p))}))
+	return p[:]
 }
 
 func Pages2Bytes(ps []Page) []byte {
-	return *(*[]byte)(unsafe.Pointer(&reflect.SliceHeader{Data: uintptr(unsafe.Pointer(&ps[0])), Len: len(ps) * PageSize, Cap: cap(ps) * PageSize}))
+	return (*[1 << 30]byte)(unsafe.Pointer(&ps[0]))[: len(ps)*PageSize : cap(ps)*PageSize]
 }
@@ -2,6 +2,8 @@ package bplus
 
 import (
 	"fmt"
+	"io"
+	"sync"
 	"unsafe"
 
 	"github.com/anton2920/gofa/errors"
@@ -9,24 +11,30 @@ import (
 	"github.com/anton2920/gofa/trace/trace_"
 )
 
-/* Tree is an implementation of a B+tree. */
+/* Tree is an implementation of a copy-on-write B+tree. Any number of read transactions may run concurrently with a single write transaction. */
 type Tree struct {
-	Meta Meta
+	/* Meta is a copy of the last committed meta page. */
+	Meta      Meta
+	MetaIndex int64
 
 	File fs.VFile
-}
 
-type Tx struct {
-	Tree       *Tree
-	Status     int
-	SearchPath []TreePathItem
-	SavedPages map[int64]int64
+	/* WriterLock is held by write transaction from 'Begin' until 'Commit' or 'Rollback'. */
+	WriterLock sync.Mutex
+
+	/* ReadersLock protects Meta and Readers. */
+	ReadersLock sync.Mutex
+	Readers     map[uint64]int
+
+	/* Pending and Free are only accessed by the writer. Free list is not stored on disk, it's rebuilt by OpenTreeAt from pages unreachable from root. */
+	Pending []TreeFreedPages
+	Free    []int64
 }
 
-type TreeForwardIterator struct {
-	Tree    *Tree
-	Leaf    Leaf
-	Current int
+/* TreeFreedPages are pages that were replaced by transaction that committed Seq. They are still visible to readers of older snapshots. */
+type TreeFreedPages struct {
+	Seq   uint64
+	Pages []int64
 }
 
 type TreePathItem struct {
@@ -40,14 +48,11 @@ const (
 	TreeMaxOrder = 5
 
 	TreeMagic   = uint64(0xFAFEFAAFDEADBEEF)
-	TreeVersion = 0x1
+	TreeVersion = 0x2
 )
 
-const (
-	TxStatusInProgress = iota
-	TxStatusAborted
-	TxStatusCommited
-)
+/* TreeMetaCount is the number of meta pages. Commits alternate between them, so torn write of one leaves the other intact. */
+const TreeMetaCount = 2
 
 func duplicate(buffer []byte, x []byte) []byte {
 	if len(buffer) < len(x) {
@@ -59,72 +64,158 @@ func duplicate(buffer []byte, x []byte) []byte {
 func OpenTreeAt(f fs.VFile, index int64) (*Tree, error) {
 	defer trace_.End(trace_.Begin(""))
 
-	var t Tree
-	t.File = f
+	var metas [TreeMetaCount]Page
+	var empty [TreeMetaCount]bool
+	var valid int
 
-	base, err := t.ReadPageAt(t.Meta.Page(), index)
-	if err != nil {
-		const (
-			Meta = iota
-			Root
-			End
-			Count
-		)
+	t := new(Tree)
+	t.File = f
+	t.MetaIndex = index
+	t.Readers = make(map[uint64]int)
+
+	for i := 0; i < len(metas); i++ {
+		/* NOTE(anton2920): only page past the end of file is empty. Other errors are returned, otherwise unreadable meta page may get tree re-initialized. */
+		if _, err := t.ReadPageAt(&metas[i], index+int64(i)); err != nil {
+			if err != io.EOF {
+				return nil, fmt.Errorf("failed to read meta page %d: %v", i, err)
+			}
+			empty[i] = true
+			continue
+		}
+		if metas[i].Type() == PageTypeNone {
+			empty[i] = true
+			continue
+		}
 
-		var pages [Count]Page
+		if metas[i].Type() != PageTypeMeta {
+			continue
+		}
+		meta := metas[i].Meta()
+		if meta.Checksum != meta.Sum() {
+			continue
+		}
+		if meta.Magic != TreeMagic {
+			return nil, fmt.Errorf("wrong tree magic: %16X != %16X", TreeMagic, meta.Magic)
+		}
+		if (valid == 0) || (meta.LastSeq > t.Meta.LastSeq) {
+			t.Meta = *meta
+		}
+		valid++
+	}
 
-		pages[Meta].Init(PageTypeMeta)
-		meta := pages[Meta].Meta()
-		meta.Magic = TreeMagic
-		meta.Version = TreeVersion
-		meta.Root = base + Root
-		meta.EndSentinel = base + End
+	if valid == 0 {
+		/* NOTE(anton2920): second meta page is written by the first commit, after the first one was synced. If it's empty, tree creation did not finish. */
+		if !empty[1] {
+			return nil, errors.New("no valid meta page found")
+		}
 
-		pages[Root].Init(PageTypeLeaf)
-		leaf := pages[Root].Leaf()
-		leaf.Next = base + End
+		var pages [TreeMetaCount + 1]Page
 
-		pages[End].Init(PageTypeLeaf)
+		root := len(pages) - 1
+		pages[root].Init(PageTypeLeaf)
 
-		if _, err := t.WritePagesAt(pages[:], base); err != nil {
+		/* Root must be on disk before meta page that points to it. */
+		if _, err := t.WritePagesAt(pages[:], index); err != nil {
 			return nil, fmt.Errorf("failed to write initial pages: %v", err)
 		}
+		if err := t.File.Sync(); err != nil {
+			return nil, fmt.Errorf("failed to sync tree file: %v", err)
+		}
+
+		pages[0].Init(PageTypeMeta)
+		meta := pages[0].Meta()
+		meta.Magic = TreeMagic
+		meta.Version = TreeVersion
+		meta.Root = index + int64(root)
+		meta.Checksum = meta.Sum()
+
+		if _, err := t.WritePageAt(&pages[0], index); err != nil {
+			return nil, fmt.Errorf("failed to write meta page: %v", err)
+		}
+		if err := t.File.Sync(); err != nil {
+			return nil, fmt.Errorf("failed to sync tree file: %v", err)
+		}
 
-		t.Meta.Magic = meta.Magic
-		t.Meta.Version = meta.Version
-		t.Meta.Root = meta.Root
-		t.Meta.EndSentinel = meta.EndSentinel
+		t.Meta = *meta
 	}
 
-	if t.Meta.Magic != TreeMagic {
-		return nil, fmt.Errorf("wrong tree magic: %16X != %16X", TreeMagic, t.Meta.Magic)
+	if t.Meta.Version != TreeVersion {
+		return nil, fmt.Errorf("unsupported tree version %d, expected %d", t.Meta.Version, TreeVersion)
 	}
-	/* TODO(anton2920): check integrity? */
 
-	return &t, nil
+	if err := t.rebuildFreeList(); err != nil {
+		return nil, fmt.Errorf("failed to rebuild free list: %v", err)
+	}
 
+	return t, nil
 }
 
-func (it *TreeForwardIterator) Next() bool {
-	it.Current++
-	if it.Current >= int(it.Leaf.N) {
-		if it.Leaf.Next == it.Tree.Meta.EndSentinel {
-			return false
+/* rebuildFreeList puts every page after meta pages that is not reachable from root to the free list. Those are pages freed by transactions committed before tree was closed and pages written by transactions that did not commit. */
+func (t *Tree) rebuildFreeList() error {
+	defer trace_.End(trace_.Begin(""))
+
+	var page Page
+
+	size, err := t.File.Size()
+	if err != nil {
+		return fmt.Errorf("failed to get size of tree file: %v", err)
+	}
+	count := int64(size)/int64(unsafe.Sizeof(page)) - t.MetaIndex
+	if count < TreeMetaCount {
+		return nil
+	}
+
+	reachable := make([]bool, count)
+	stack := []int64{t.Meta.Root}
+	for len(stack) > 0 {
+		index := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if (index < t.MetaIndex+TreeMetaCount) || (index >= t.MetaIndex+count) {
+			return fmt.Errorf("page index %d is out of range", index)
+		}
+		if reachable[index-t.MetaIndex] {
+			return fmt.Errorf("page %d is referenced twice", index)
 		}
-		if _, err := it.Tree.ReadPageAt(it.Leaf.Page(), it.Leaf.Next); err != nil {
-			return false
+		reachable[index-t.MetaIndex] = true
+
+		if _, err := t.ReadPageAt(&page, index); err != nil {
+			return fmt.Errorf("failed to read page: %v", err)
+		}
+
+		switch page.Type() {
+		default:
+			return fmt.Errorf("unexpected page type %d at %d", page.Type(), index)
+		case PageTypeNode:
+			node := page.Node()
+			for i := -1; i < int(node.N); i++ {
+				stack = append(stack, node.GetChildAt(i))
+			}
+		case PageTypeLeaf:
+			leaf := page.Leaf()
+			for i := 0; i < int(leaf.N); i++ {
+				if v := leaf.GetValueAt(i); ValueGetType(v) == ValueTypePartial {
+					if _, next := ValueGetPartial(v); next != 0 {
+						stack = append(stack, next)
+					}
+				}
+			}
+		case PageTypeOverflow:
+			if next := page.Overflow().Next; next != 0 {
+				stack = append(stack, next)
+			}
 		}
-		it.Current = 0
 	}
-	return true
-}
 
-func (it *TreeForwardIterator) Key() []byte {
-	return it.Leaf.GetKeyAt(it.Current)
-}
+	/* NOTE(anton2920): free list is used as a stack, so pages at the beginning of file are reused first. */
+	t.Free = t.Free[:0]
+	for i := count - 1; i >= TreeMetaCount; i-- {
+		if !reachable[i] {
+			t.Free = append(t.Free, t.MetaIndex+i)
+		}
+	}
 
-func (it *TreeForwardIterator) Value() []byte {
-	return it.Leaf.GetValueAt(it.Current)
+	return nil
 }
 
 func (t *Tree) ReadPageAt(page *Page, index int64) (int64, error) {
@@ -172,374 +263,133 @@ func (t *Tree) WritePagesAt(pages []Page, index int64) (int64, error) {
 	return index, nil
 }
 
-func (t *Tree) Begin() (*Tx, error) {
-	var tx Tx
+/* BeginRead pins last committed root. Pages reachable from it are not reused until 'End' is called. It is safe to call BeginRead from multiple goroutines. */
+func (t *Tree) BeginRead() *ReadTx {
+	var tx ReadTx
 
 	tx.Tree = t
-	tx.SavedPages = make(map[int64]int64)
-	//tx.SearchPath = make([]TreePathItem, 0, 16)
-
-	return &tx, nil
-}
-
-func (t *Tree) Iter() (*TreeForwardIterator, error) {
-	var it TreeForwardIterator
-	var page Page
-
-	it.Tree = t
-
-	index := t.Meta.Root
-	for index != 0 {
-		if _, err := t.ReadPageAt(&page, index); err != nil {
-			return nil, fmt.Errorf("failed to read page: %v", err)
-		}
-
-		switch page.Type() {
-		case PageTypeNode:
-			node := page.Node()
-			index = node.GetChildAt(-1)
-		case PageTypeLeaf:
-			it.Current = -1
-			it.Leaf = *page.Leaf()
-			return &it, nil
-		}
-	}
-
-	panic("unreachable")
-}
-
-func (tx *Tx) Commit() error {
-	if tx.Status != TxStatusInProgress {
-		return errors.New("failed to commit Tx that is not in progress")
-	}
 
-	tx.Tree.Meta.LastSeq = 0
-	if _, err := tx.Tree.WritePageAt(tx.Tree.Meta.Page(), 0); err != nil {
-		return fmt.Errorf("failed to update meta page: %v", err)
-	}
-	if err := tx.Tree.File.Sync(); err != nil {
-		return fmt.Errorf("failed to sync tree file: %v", err)
-	}
+	t.ReadersLock.Lock()
+	tx.Root = t.Meta.Root
+	tx.Seq = t.Meta.LastSeq
+	t.Readers[tx.Seq]++
+	t.ReadersLock.Unlock()
 
-	return nil
+	return &tx
 }
 
-func (tx *Tx) Rollback() error {
-	if tx.Status == TxStatusInProgress {
-		var page Page
-
-		for from, to := range tx.SavedPages {
-			if _, err := tx.Tree.ReadPageAt(&page, to); err != nil {
-				return fmt.Errorf("failed to read page from %d: %v", to, err)
-			}
-			if _, err := tx.Tree.WritePageAt(&page, from); err != nil {
-				return fmt.Errorf("failed to write page to %d: %v", from, err)
-			}
+/* reclaimPages moves pages nobody can see anymore to the free list. */
+func (t *Tree) reclaimPages() {
+	t.ReadersLock.Lock()
+	oldest := t.Meta.LastSeq
+	for seq := range t.Readers {
+		if seq < oldest {
+			oldest = seq
 		}
-
-		if err := tx.Tree.File.Sync(); err != nil {
-			return fmt.Errorf("failed to sync tree file: %v", err)
-		}
-
-		tx.Status = TxStatusAborted
 	}
-	return nil
-}
-
-func (tx *Tx) BackupPage(index int64) error {
-	var page Page
-
-	if _, ok := tx.SavedPages[index]; !ok {
-		if _, err := tx.Tree.ReadPageAt(&page, index); err != nil {
-			return fmt.Errorf("failed to read page: %v", err)
-		}
-
-		nindex, err := tx.Tree.WritePageAt(&page, -1)
-		if err != nil {
-			return fmt.Errorf("failed to write page: %v", err)
-		}
+	t.ReadersLock.Unlock()
 
-		tx.SavedPages[index] = nindex
+	var n int
+	for (n < len(t.Pending)) && (t.Pending[n].Seq <= oldest) {
+		t.Free = append(t.Free, t.Pending[n].Pages...)
+		n++
 	}
-
-	return nil
+	t.Pending = t.Pending[:copy(t.Pending, t.Pending[n:])]
 }
 
-func (t *Tree) Get(key []byte) ([]byte, error) {
-	defer trace_.End(trace_.Begin(""))
-
-	var buffer []byte
-	var page Page
-	var v []byte
-
-	index := t.Meta.Root
+/* find reads leaf that may contain key into page and returns position of key in it. */
+func (t *Tree) find(page *Page, root int64, key []byte) (int, bool, error) {
+	index := root
 	for index != 0 {
-		if _, err := t.ReadPageAt(&page, index); err != nil {
-			return nil, fmt.Errorf("failed to read page: %v", err)
+		if _, err := t.ReadPageAt(page, index); err != nil {
+			return -1, false, fmt.Errorf("failed to read page: %v", err)
 		}
 
 		switch page.Type() {
+		default:
+			return -1, false, fmt.Errorf("unexpected page type %d at %d", page.Type(), index)
 		case PageTypeNode:
 			node := page.Node()
-			pos := node.Find(key)
-			index = node.GetChildAt(pos)
+			index = node.GetChildAt(node.Find(key))
 		case PageTypeLeaf:
-			index = 0
-			leaf := page.Leaf()
-			pos, ok := leaf.Find(key)
-			if ok {
-				v = leaf.GetValueAt(pos + 1)
-				switch ValueGetType(v) {
-				default:
-					panic("unknown value type")
-				case ValueTypeFull:
-					return ValueGetFull(v), nil
-				case ValueTypePartial:
-					v, next := ValueGetPartial(v)
-					buffer = append(buffer, v...)
-
-					for next != 0 {
-						if _, err := t.ReadPageAt(&page, next); err != nil {
-							return nil, fmt.Errorf("failed to read page: %v", err)
-						}
-						overflow := page.Overflow()
-						buffer = append(buffer, overflow.GetValue()...)
-						next = overflow.Next
-					}
-
-					return buffer, nil
-				}
-			}
+			pos, ok := page.Leaf().Find(key)
+			return pos + 1, ok, nil
 		}
 	}
 
-	return nil, nil
+	return -1, false, nil
 }
 
-func (t *Tree) Del(key []byte) error {
-	return errors.New("not implemented")
-}
-
-func (t *Tree) Has(key []byte) (bool, error) {
+func (t *Tree) get(root int64, key []byte) ([]byte, error) {
 	defer trace_.End(trace_.Begin(""))
 
 	var page Page
 
-	offset := t.Meta.Root
-	for offset != 0 {
-		if _, err := t.ReadPageAt(&page, offset); err != nil {
-			return false, fmt.Errorf("failed to read page: %v", err)
-		}
-
-		switch page.Type() {
-		case PageTypeNode:
-			node := page.Node()
-			index := node.Find(key)
-			offset = node.GetChildAt(index)
-		case PageTypeLeaf:
-			leaf := page.Leaf()
-			_, ok := leaf.Find(key)
-			return ok, nil
-		}
+	pos, ok, err := t.find(&page, root, key)
+	if (err != nil) || (!ok) {
+		return nil, err
 	}
 
-	return false, nil
+	return t.ReadValue(nil, page.Leaf().GetValueAt(pos))
 }
 
-func (tx *Tx) Set(key []byte, value []byte) error {
+func (t *Tree) has(root int64, key []byte) (bool, error) {
 	defer trace_.End(trace_.Begin(""))
 
 	var page Page
 
-	var err error
-	var ok bool
-	var pos int
-
-	tx.SearchPath = tx.SearchPath[:0]
-
-	index := tx.Tree.Meta.Root
-forIndex:
-	for index != 0 {
-		if _, err := tx.Tree.ReadPageAt(&page, index); err != nil {
-			return fmt.Errorf("failed to read page: %v", err)
-		}
-
-		switch page.Type() {
-		case PageTypeNode:
-			node := page.Node()
-			pos = node.Find(key)
-			tx.SearchPath = append(tx.SearchPath, TreePathItem{page, index, pos})
-			index = node.GetChildAt(pos)
-		case PageTypeLeaf:
-			leaf := page.Leaf()
-			pos, ok = leaf.Find(key)
-			break forIndex
-		}
-	}
-
-	var overflow bool
-	leaf := page.Leaf()
-
-	if leaf.OverflowAfterInsertKeyValueInEmpty(len(key), FullValueLen(value)) {
-		var page Page
-		page.Init(PageTypeOverflow)
-		overflow := page.Overflow()
+	_, ok, err := t.find(&page, root, key)
+	return ok, err
+}
 
-		value = overflow.SetValue(value)
-		index, err := tx.Tree.WritePageAt(&page, -1)
-		if err != nil {
-			return fmt.Errorf("failed to write new overflow: %v", err)
-		}
+/* ReadValue appends value stored in leaf to buffer, following chain of overflow pages for values that did not fit. */
+func (t *Tree) ReadValue(buffer []byte, v []byte) ([]byte, error) {
+	var page Page
 
-		for (len(value) != 0) && (leaf.OverflowAfterInsertKeyValueInEmpty(len(key), PartialValueLen(value))) {
-			overflow.Next = index
-			value = overflow.SetValue(value)
-			index, err = tx.Tree.WritePageAt(&page, -1)
-			if err != nil {
-				return fmt.Errorf("failed to write new overflow: %v", err)
+	switch ValueGetType(v) {
+	default:
+		return nil, fmt.Errorf("unknown value type %d", ValueGetType(v))
+	case ValueTypeFull:
+		return append(buffer, ValueGetFull(v)...), nil
+	case ValueTypePartial:
+		v, next := ValueGetPartial(v)
+		buffer = append(buffer, v...)
+
+		for next != 0 {
+			if _, err := t.ReadPageAt(&page, next); err != nil {
+				return nil, fmt.Errorf("failed to read page: %v", err)
 			}
+			overflow := page.Overflow()
+			buffer = append(buffer, overflow.GetValue()...)
+			next = overflow.Next
 		}
 
-		/* TODO(anton2920): remove extra memory allocation. */
-		value = PartialValue(value, index)
-	} else {
-		/* TODO(anton2920): remove extra memory allocation. */
-		value = FullValue(value)
-	}
-
-	if ok {
-		/* Found key, check for overflow before updating value. */
-		overflow = leaf.OverflowAfterInsertValue(len(value))
-	} else {
-		/* Check for overflow before inserting new key. */
-		overflow = leaf.OverflowAfterInsertKeyValue(len(key), len(value)) || (leaf.N >= TreeMaxOrder-1)
+		return buffer, nil
 	}
+}
 
-	if !overflow {
-		if ok {
-			/* Updating value for existing key. */
-			leaf.SetValueAt(value, pos+1)
-		} else {
-			/* Insering new key-value. */
-			leaf.InsertKeyValueAt(key, value, pos+1)
-		}
-		if err := tx.BackupPage(index); err != nil {
-			return fmt.Errorf("failed to back-up page at %d: %v", index, err)
-		}
-		if _, err = tx.Tree.WritePageAt(&page, index); err != nil {
-			return fmt.Errorf("failed to write updated leaf: %v", err)
-		}
-		return nil
-	}
+func (t *Tree) Get(key []byte) ([]byte, error) {
+	tx := t.BeginRead()
+	defer tx.End()
 
-	/* Split leaf into two. */
-	var newLeaf Leaf
-	newLeaf.Page().Init(PageTypeLeaf)
-	newBuffer := make([]byte, PageSize)
-
-	half := int(leaf.N) / 2
-	if pos < half-1 {
-		leaf.MoveData(&newLeaf, 0, half-1, -1)
-		if ok {
-			leaf.SetValueAt(value, pos)
-		} else {
-			leaf.InsertKeyValueAt(key, value, pos+1)
-		}
-	} else {
-		leaf.MoveData(&newLeaf, 0, half, -1)
-		if ok {
-			newLeaf.SetValueAt(value, pos-half)
-		} else {
-			newLeaf.InsertKeyValueAt(key, value, pos+1-half)
-		}
-	}
+	return tx.Get(key)
+}
 
-	newLeaf.Next = leaf.Next
-	newKey := duplicate(newBuffer, newLeaf.GetKeyAt(0))
-	newPage, err := tx.Tree.WritePageAt(newLeaf.Page(), -1)
+func (t *Tree) Del(key []byte) error {
+	tx, err := t.Begin()
 	if err != nil {
-		return fmt.Errorf("failed to write new leaf: %v", err)
+		return err
 	}
-
-	leaf.Next = newPage
-	if err := tx.BackupPage(index); err != nil {
-		return fmt.Errorf("failed to back-up page at %d: %v", index, err)
-	}
-	if _, err = tx.Tree.WritePageAt(&page, index); err != nil {
-		return fmt.Errorf("failed to write updated leaf: %v", err)
-	}
-
-	/* Update posing structure. */
-	for p := len(tx.SearchPath) - 1; p >= 0; p-- {
-		page := tx.SearchPath[p].Page
-		pos := tx.SearchPath[p].Pos
-		node := page.Node()
-
-		node.SetChildAt(index, pos)
-
-		overflow = node.OverflowAfterInsertKeyChild(len(key)) || (node.N >= TreeMaxOrder-1)
-		if !overflow {
-			node.InsertKeyChildAt(newKey, newPage, pos+1)
-			if err := tx.BackupPage(tx.SearchPath[p].Index); err != nil {
-				return fmt.Errorf("failed to back-up page at %d: %v", index, err)
-			}
-			if _, err = tx.Tree.WritePageAt(&page, tx.SearchPath[p].Index); err != nil {
-				return fmt.Errorf("failed to write updated node: %v", err)
-			}
-			return nil
-		}
-
-		var insertKey []byte
-		var newNode Page
-		newNode.Init(PageTypeNode)
-
-		insertBuffer := make([]byte, PageSize)
-
-		half = int(node.N) / 2
-		if pos < half-1 {
-			insertKey = duplicate(insertBuffer, newKey)
-			newKey = duplicate(newBuffer, node.GetKeyAt(half-1))
-
-			node.MoveData(newNode.Node(), -1, half-1, -1)
-			node.InsertKeyChildAt(insertKey, newPage, pos+1)
-		} else if pos == half-1 {
-			insertKey = duplicate(insertBuffer, node.GetKeyAt(half))
-			insertPage := node.GetChildAt(half)
-
-			node.MoveData(newNode.Node(), -1, half, -1)
-			newNode.Node().SetChildAt(newPage, -1)
-			newNode.Node().InsertKeyChildAt(insertKey, insertPage, pos+1-half)
-		} else {
-			insertKey = duplicate(insertBuffer, newKey)
-			newKey = duplicate(newBuffer, node.GetKeyAt(half))
-
-			node.MoveData(newNode.Node(), -1, half, -1)
-			newNode.Node().InsertKeyChildAt(insertKey, newPage, pos-half)
-		}
-
-		newPage, err = tx.Tree.WritePageAt(&newNode, -1)
-		if err != nil {
-			return fmt.Errorf("failed to write new node: %v", err)
-		}
-
-		if err := tx.BackupPage(tx.SearchPath[p].Index); err != nil {
-			return fmt.Errorf("failed to back-up page at %d: %v", index, err)
-		}
-		index, err = tx.Tree.WritePageAt(&page, tx.SearchPath[p].Index)
-		if err != nil {
-			return fmt.Errorf("failed to write updated node: %v", err)
-		}
+	if err := tx.Del(key); err != nil {
+		tx.Rollback()
+		return err
 	}
+	return tx.Commit()
+}
 
-	var root Page
-	root.Init(PageTypeNode)
-	node := root.Node()
-	node.Init(newKey, tx.Tree.Meta.Root, newPage)
-
-	tx.Tree.Meta.Root, err = tx.Tree.WritePageAt(&root, -1)
-	if err != nil {
-		return fmt.Errorf("failed to write new root: %v", err)
-	}
+func (t *Tree) Has(key []byte) (bool, error) {
+	tx := t.BeginRead()
+	defer tx.End()
 
-	return nil
+	return tx.Has(key)
 }
@@ -0,0 +1,488 @@
+package bplus
+
+import (
+	"fmt"
+
+	"github.com/anton2920/gofa/errors"
+	"github.com/anton2920/gofa/trace/trace_"
+)
+
+/* Tx is a write transaction. Pages are never modified in place: each page is copied on first write, so readers of older snapshots are not affected. */
+type Tx struct {
+	Tree *Tree
+	Meta Meta
+
+	Status     int
+	SearchPath []TreePathItem
+
+	/* Allocated pages were written by this transaction and can be modified in place. */
+	Allocated map[int64]struct{}
+
+	/* Freed pages were replaced by copies and are reused only after commit, when no reader can see them. */
+	Freed []int64
+}
+
+/* ReadTx is a read-only snapshot of the tree. */
+type ReadTx struct {
+	Tree *Tree
+	Root int64
+	Seq  uint64
+}
+
+const (
+	TxStatusInProgress = iota
+	TxStatusAborted
+	TxStatusCommited
+)
+
+/* Begin starts write transaction. Only one write transaction can be in progress at a time, others will block until it is finished. */
+func (t *Tree) Begin() (*Tx, error) {
+	var tx Tx
+
+	t.WriterLock.Lock()
+	t.reclaimPages()
+
+	tx.Tree = t
+	tx.Allocated = make(map[int64]struct{})
+	//tx.SearchPath = make([]TreePathItem, 0, 16)
+
+	t.ReadersLock.Lock()
+	tx.Meta = t.Meta
+	t.ReadersLock.Unlock()
+
+	return &tx, nil
+}
+
+func (tx *Tx) Commit() error {
+	if tx.Status != TxStatusInProgress {
+		return errors.New("failed to commit Tx that is not in progress")
+	}
+
+	t := tx.Tree
+	defer t.WriterLock.Unlock()
+
+	/* NOTE(anton2920): on failure pages allocated by this transaction are not returned to free list, since we don't know what made it to disk. */
+	tx.Status = TxStatusAborted
+
+	if err := t.File.Sync(); err != nil {
+		return fmt.Errorf("failed to sync tree file: %v", err)
+	}
+
+	tx.Meta.LastSeq++
+	tx.Meta.Checksum = tx.Meta.Sum()
+	if _, err := t.WritePageAt(tx.Meta.Page(), t.MetaIndex+int64(tx.Meta.LastSeq%TreeMetaCount)); err != nil {
+		return fmt.Errorf("failed to update meta page: %v", err)
+	}
+	if err := t.File.Sync(); err != nil {
+		return fmt.Errorf("failed to sync tree file: %v", err)
+	}
+
+	t.ReadersLock.Lock()
+	t.Meta = tx.Meta
+	t.ReadersLock.Unlock()
+
+	if len(tx.Freed) > 0 {
+		t.Pending = append(t.Pending, TreeFreedPages{Seq: tx.Meta.LastSeq, Pages: tx.Freed})
+	}
+
+	tx.Status = TxStatusCommited
+	return nil
+}
+
+func (tx *Tx) Rollback() error {
+	if tx.Status == TxStatusInProgress {
+		t := tx.Tree
+
+		/* Nobody has seen pages written by this transaction. */
+		for index := range tx.Allocated {
+			t.Free = append(t.Free, index)
+		}
+
+		tx.Status = TxStatusAborted
+		t.WriterLock.Unlock()
+	}
+	return nil
+}
+
+/* WriteNewPage writes page to a free location. */
+func (tx *Tx) WriteNewPage(page *Page) (int64, error) {
+	t := tx.Tree
+
+	index := int64(-1)
+	if len(t.Free) > 0 {
+		index = t.Free[len(t.Free)-1]
+		t.Free = t.Free[:len(t.Free)-1]
+	}
+
+	index, err := t.WritePageAt(page, index)
+	if err != nil {
+		return -1, err
+	}
+	tx.Allocated[index] = struct{}{}
+
+	return index, nil
+}
+
+/* WritePage writes updated page, that was read from index, and returns its new location. */
+func (tx *Tx) WritePage(page *Page, index int64) (int64, error) {
+	if _, ok := tx.Allocated[index]; ok {
+		return tx.Tree.WritePageAt(page, index)
+	}
+
+	nindex, err := tx.WriteNewPage(page)
+	if err != nil {
+		return -1, err
+	}
+	tx.Freed = append(tx.Freed, index)
+
+	return nindex, nil
+}
+
+func (tx *Tx) FreePage(index int64) {
+	if _, ok := tx.Allocated[index]; ok {
+		delete(tx.Allocated, index)
+		tx.Tree.Free = append(tx.Tree.Free, index)
+	} else {
+		tx.Freed = append(tx.Freed, index)
+	}
+}
+
+/* FreeValue frees overflow pages of value stored in leaf. */
+func (tx *Tx) FreeValue(v []byte) error {
+	var page Page
+
+	if ValueGetType(v) == ValueTypePartial {
+		_, next := ValueGetPartial(v)
+		for next != 0 {
+			if _, err := tx.Tree.ReadPageAt(&page, next); err != nil {
+				return fmt.Errorf("failed to read page: %v", err)
+			}
+			tx.FreePage(next)
+			next = page.Overflow().Next
+		}
+	}
+
+	return nil
+}
+
+/* updatePath points nodes in search path starting from p to the new location of their child, copying them as needed. */
+func (tx *Tx) updatePath(p int, child int64) error {
+	var err error
+
+	for ; p >= 0; p-- {
+		item := &tx.SearchPath[p]
+		node := item.Page.Node()
+
+		if node.GetChildAt(item.Pos) == child {
+			/* Child was updated in place, so is everything above it. */
+			return nil
+		}
+		node.SetChildAt(child, item.Pos)
+
+		child, err = tx.WritePage(&item.Page, item.Index)
+		if err != nil {
+			return fmt.Errorf("failed to write updated node: %v", err)
+		}
+	}
+	tx.Meta.Root = child
+
+	return nil
+}
+
+func (tx *Tx) Get(key []byte) ([]byte, error) {
+	return tx.Tree.get(tx.Meta.Root, key)
+}
+
+func (tx *Tx) Has(key []byte) (bool, error) {
+	return tx.Tree.has(tx.Meta.Root, key)
+}
+
+func (tx *Tx) Set(key []byte, value []byte) error {
+	defer trace_.End(trace_.Begin(""))
+
+	var page Page
+
+	var err error
+	var ok bool
+	var pos int
+
+	if tx.Status != TxStatusInProgress {
+		return errors.New("failed to set value in Tx that is not in progress")
+	}
+
+	tx.SearchPath = tx.SearchPath[:0]
+
+	index := tx.Meta.Root
+forIndex:
+	for index != 0 {
+		if _, err := tx.Tree.ReadPageAt(&page, index); err != nil {
+			return fmt.Errorf("failed to read page: %v", err)
+		}
+
+		switch page.Type() {
+		case PageTypeNode:
+			node := page.Node()
+			pos = node.Find(key)
+			tx.SearchPath = append(tx.SearchPath, TreePathItem{page, index, pos})
+			index = node.GetChildAt(pos)
+		case PageTypeLeaf:
+			leaf := page.Leaf()
+			pos, ok = leaf.Find(key)
+			break forIndex
+		}
+	}
+
+	var overflow bool
+	leaf := page.Leaf()
+
+	if leaf.OverflowAfterInsertKeyValueInEmpty(len(key), FullValueLen(value)) {
+		var page Page
+		page.Init(PageTypeOverflow)
+		overflow := page.Overflow()
+
+		value = overflow.SetValue(value)
+		next, err := tx.WriteNewPage(&page)
+		if err != nil {
+			return fmt.Errorf("failed to write new overflow: %v", err)
+		}
+
+		for (len(value) != 0) && (leaf.OverflowAfterInsertKeyValueInEmpty(len(key), PartialValueLen(value))) {
+			overflow.Next = next
+			value = overflow.SetValue(value)
+			next, err = tx.WriteNewPage(&page)
+			if err != nil {
+				return fmt.Errorf("failed to write new overflow: %v", err)
+			}
+		}
+
+		/* TODO(anton2920): remove extra memory allocation. */
+		value = PartialValue(value, next)
+	} else {
+		/* TODO(anton2920): remove extra memory allocation. */
+		value = FullValue(value)
+	}
+
+	if ok {
+		/* Found key, free old value and check for overflow before updating it. */
+		if err := tx.FreeValue(leaf.GetValueAt(pos + 1)); err != nil {
+			return fmt.Errorf("failed to free old value: %v", err)
+		}
+		overflow = leaf.OverflowAfterInsertValue(len(value))
+	} else {
+		/* Check for overflow before inserting new key. */
+		overflow = leaf.OverflowAfterInsertKeyValue(len(key), len(value)) || (leaf.N >= TreeMaxOrder-1)
+	}
+
+	if !overflow {
+		if ok {
+			/* Updating value for existing key. */
+			leaf.SetValueAt(value, pos+1)
+		} else {
+			/* Insering new key-value. */
+			leaf.InsertKeyValueAt(key, value, pos+1)
+		}
+		index, err = tx.WritePage(&page, index)
+		if err != nil {
+			return fmt.Errorf("failed to write updated leaf: %v", err)
+		}
+		return tx.updatePath(len(tx.SearchPath)-1, index)
+	}
+
+	/* Split leaf into two. */
+	var newLeaf Leaf
+	newLeaf.Page().Init(PageTypeLeaf)
+	newBuffer := make([]byte, PageSize)
+
+	half := int(leaf.N) / 2
+	if ok {
+		leaf.MoveData(&newLeaf, 0, half, -1)
+		if pos+1 < half {
+			leaf.SetValueAt(value, pos+1)
+		} else {
+			newLeaf.SetValueAt(value, pos+1-half)
+		}
+	} else if pos < half-1 {
+		leaf.MoveData(&newLeaf, 0, half-1, -1)
+		leaf.InsertKeyValueAt(key, value, pos+1)
+	} else {
+		leaf.MoveData(&newLeaf, 0, half, -1)
+		newLeaf.InsertKeyValueAt(key, value, pos+1-half)
+	}
+
+	newKey := duplicate(newBuffer, newLeaf.GetKeyAt(0))
+	newPage, err := tx.WriteNewPage(newLeaf.Page())
+	if err != nil {
+		return fmt.Errorf("failed to write new leaf: %v", err)
+	}
+
+	index, err = tx.WritePage(&page, index)
+	if err != nil {
+		return fmt.Errorf("failed to write updated leaf: %v", err)
+	}
+
+	/* Update posing structure. */
+	for p := len(tx.SearchPath) - 1; p >= 0; p-- {
+		item := &tx.SearchPath[p]
+		pos := item.Pos
+		node := item.Page.Node()
+
+		node.SetChildAt(index, pos)
+
+		overflow = node.OverflowAfterInsertKeyChild(len(newKey)) || (node.N >= TreeMaxOrder-1)
+		if !overflow {
+			node.InsertKeyChildAt(newKey, newPage, pos+1)
+			index, err = tx.WritePage(&item.Page, item.Index)
+			if err != nil {
+				return fmt.Errorf("failed to write updated node: %v", err)
+			}
+			return tx.updatePath(p-1, index)
+		}
+
+		var insertKey []byte
+		var newNode Page
+		newNode.Init(PageTypeNode)
+
+		insertBuffer := make([]byte, PageSize)
+
+		half = int(node.N) / 2
+		if pos < half-1 {
+			insertKey = duplicate(insertBuffer, newKey)
+			newKey = duplicate(newBuffer, node.GetKeyAt(half-1))
+
+			node.MoveData(newNode.Node(), -1, half-1, -1)
+			node.InsertKeyChildAt(insertKey, newPage, pos+1)
+		} else if pos == half-1 {
+			insertKey = duplicate(insertBuffer, node.GetKeyAt(half))
+			insertPage := node.GetChildAt(half)
+
+			node.MoveData(newNode.Node(), -1, half, -1)
+			newNode.Node().SetChildAt(newPage, -1)
+			newNode.Node().InsertKeyChildAt(insertKey, insertPage, pos+1-half)
+		} else {
+			insertKey = duplicate(insertBuffer, newKey)
+			newKey = duplicate(newBuffer, node.GetKeyAt(half))
+
+			node.MoveData(newNode.Node(), -1, half, -1)
+			newNode.Node().InsertKeyChildAt(insertKey, newPage, pos-half)
+		}
+
+		newPage, err = tx.WriteNewPage(&newNode)
+		if err != nil {
+			return fmt.Errorf("failed to write new node: %v", err)
+		}
+
+		index, err = tx.WritePage(&item.Page, item.Index)
+		if err != nil {
+			return fmt.Errorf("failed to write updated node: %v", err)
+		}
+	}
+
+	var root Page
+	root.Init(PageTypeNode)
+	node := root.Node()
+	node.Init(newKey, index, newPage)
+
+	tx.Meta.Root, err = tx.WriteNewPage(&root)
+	if err != nil {
+		return fmt.Errorf("failed to write new root: %v", err)
+	}
+
+	return nil
+}
+
+/* Del removes key from the tree. It's not an error if key does not exist. */
+func (tx *Tx) Del(key []byte) error {
+	defer trace_.End(trace_.Begin(""))
+
+	var page Page
+
+	var ok bool
+	var pos int
+
+	if tx.Status != TxStatusInProgress {
+		return errors.New("failed to delete value in Tx that is not in progress")
+	}
+
+	tx.SearchPath = tx.SearchPath[:0]
+
+	index := tx.Meta.Root
+forIndex:
+	for index != 0 {
+		if _, err := tx.Tree.ReadPageAt(&page, index); err != nil {
+			return fmt.Errorf("failed to read page: %v", err)
+		}
+
+		switch page.Type() {
+		case PageTypeNode:
+			node := page.Node()
+			pos = node.Find(key)
+			tx.SearchPath = append(tx.SearchPath, TreePathItem{page, index, pos})
+			index = node.GetChildAt(pos)
+		case PageTypeLeaf:
+			pos, ok = page.Leaf().Find(key)
+			break forIndex
+		}
+	}
+	if !ok {
+		return nil
+	}
+
+	leaf := page.Leaf()
+	if err := tx.FreeValue(leaf.GetValueAt(pos + 1)); err != nil {
+		return fmt.Errorf("failed to free old value: %v", err)
+	}
+
+	/* TODO(anton2920): merge underfull leaves. For now only empty leaves are removed, empty root leaf is kept. */
+	leaf.RemoveKeyValueAt(pos + 1)
+
+	p := len(tx.SearchPath) - 1
+	if (leaf.N > 0) || (p < 0) {
+		index, err := tx.WritePage(&page, index)
+		if err != nil {
+			return fmt.Errorf("failed to write updated leaf: %v", err)
+		}
+		return tx.updatePath(p, index)
+	}
+	tx.FreePage(index)
+
+	/* Range of removed child is merged into its left sibling, or the right one, if it was the leftmost child. */
+	item := &tx.SearchPath[p]
+	node := item.Page.Node()
+	if item.Pos == -1 {
+		node.SetChildAt(node.GetChildAt(0), -1)
+		node.RemoveKeyChildren(0, 1)
+	} else {
+		node.RemoveKeyChildren(item.Pos, item.Pos+1)
+	}
+
+	if node.N == 0 {
+		/* Node with a single child is replaced by that child. */
+		tx.FreePage(item.Index)
+		return tx.updatePath(p-1, node.GetChildAt(-1))
+	}
+
+	index, err := tx.WritePage(&item.Page, item.Index)
+	if err != nil {
+		return fmt.Errorf("failed to write updated node: %v", err)
+	}
+	return tx.updatePath(p-1, index)
+}
+
+func (tx *ReadTx) Get(key []byte) ([]byte, error) {
+	return tx.Tree.get(tx.Root, key)
+}
+
+func (tx *ReadTx) Has(key []byte) (bool, error) {
+	return tx.Tree.has(tx.Root, key)
+}
+
+/* End releases snapshot. Neither tx nor iterators created from it can be used after that. */
+func (tx *ReadTx) End() {
+	t := tx.Tree
+
+	t.ReadersLock.Lock()
+	if t.Readers[tx.Seq]--; t.Readers[tx.Seq] == 0 {
+		delete(t.Readers, tx.Seq)
+	}
+	t.ReadersLock.Unlock()
+}
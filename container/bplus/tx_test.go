@@ -0,0 +1,353 @@
+package bplus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/anton2920/gofa/io/fs"
+)
+
+func testKey(i int) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(i))
+	return key
+}
+
+func testOpenTree(t *testing.T) (*Tree, fs.VFile) {
+	f, err := fs.NewMemoryFS().Open("tree", fs.OpenForReading|fs.OpenForWriting|fs.CreateFileIfItDoesNotExist)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	tree, err := OpenTreeAt(f, 0)
+	if err != nil {
+		t.Fatalf("Failed to open tree: %v", err)
+	}
+	return tree, f
+}
+
+/* testSetRange sets keys in [from, to) to value, which is derived from key and round. Every tenth value needs overflow pages. */
+func testSetRange(t *testing.T, tree *Tree, from int, to int, round int) {
+	tx, err := tree.Begin()
+	if err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+	for i := from; i < to; i++ {
+		if err := tx.Set(testKey(i), testValue(i, round)); err != nil {
+			t.Fatalf("Failed to set key %d: %v", i, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+}
+
+func testValue(i int, round int) []byte {
+	n := 16
+	if i%10 == 0 {
+		n = 2*PageSize + 100
+	}
+	value := bytes.Repeat([]byte{byte(round)}, n)
+	binary.BigEndian.PutUint64(value, uint64(i))
+	return value
+}
+
+func testFileSize(t *testing.T, f fs.VFile) int {
+	size, err := f.Size()
+	if err != nil {
+		t.Fatalf("Failed to get file size: %v", err)
+	}
+	return size
+}
+
+func TestTreeSnapshot(t *testing.T) {
+	tree, _ := testOpenTree(t)
+	testSetRange(t, tree, 0, 100, 0)
+
+	snapshot := tree.BeginRead()
+	defer snapshot.End()
+
+	testSetRange(t, tree, 0, 200, 1)
+	tx, _ := tree.Begin()
+	for i := 0; i < 50; i++ {
+		tx.Del(testKey(i))
+	}
+	tx.Commit()
+
+	for i := 0; i < 200; i++ {
+		value, err := snapshot.Get(testKey(i))
+		if err != nil {
+			t.Fatalf("Failed to get key %d: %v", i, err)
+		}
+		if (i < 100) && (!bytes.Equal(value, testValue(i, 0))) {
+			t.Errorf("Key %d: expected value from snapshot", i)
+		} else if (i >= 100) && (value != nil) {
+			t.Errorf("Key %d: expected key added after snapshot to be invisible", i)
+		}
+	}
+
+	it, err := snapshot.Iter()
+	if err != nil {
+		t.Fatalf("Failed to create iterator: %v", err)
+	}
+	var n int
+	for it.Next() {
+		n++
+	}
+	if n != 100 {
+		t.Errorf("Expected 100 keys in snapshot, got %d", n)
+	}
+
+	if value, _ := tree.Get(testKey(10)); value != nil {
+		t.Errorf("Expected deleted key to be invisible after commit")
+	}
+	if value, _ := tree.Get(testKey(150)); !bytes.Equal(value, testValue(150, 1)) {
+		t.Errorf("Expected committed value")
+	}
+}
+
+func TestTreeConcurrentReaders(t *testing.T) {
+	const (
+		keys    = 300
+		rounds  = 20
+		readers = 4
+	)
+
+	var wg sync.WaitGroup
+
+	tree, _ := testOpenTree(t)
+	testSetRange(t, tree, 0, keys, 0)
+
+	done := make(chan struct{})
+	for r := 0; r < readers; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				/* All values in snapshot must belong to the same round. */
+				it, err := tree.Iter()
+				if err != nil {
+					t.Errorf("Failed to create iterator: %v", err)
+					return
+				}
+				var n int
+				var round byte
+				for it.Next() {
+					value := it.Value()
+					if n == 0 {
+						round = value[len(value)-1]
+					}
+					if !bytes.Equal(value, testValue(n, int(round))) {
+						t.Errorf("Key %d: value does not belong to round %d", n, round)
+					}
+					n++
+				}
+				if it.Error != nil {
+					t.Errorf("Failed to iterate: %v", it.Error)
+				}
+				if n != keys {
+					t.Errorf("Expected %d keys, got %d", keys, n)
+				}
+				it.Close()
+			}
+		}()
+	}
+
+	for round := 1; round < rounds; round++ {
+		testSetRange(t, tree, 0, keys, round)
+	}
+	close(done)
+	wg.Wait()
+}
+
+func TestTreeReclaimPages(t *testing.T) {
+	tree, f := testOpenTree(t)
+	testSetRange(t, tree, 0, 100, 0)
+
+	snapshot := tree.BeginRead()
+	testSetRange(t, tree, 0, 100, 1)
+
+	tx, _ := tree.Begin()
+	if len(tree.Free) != 0 {
+		t.Errorf("Expected pages visible to reader not to be reused, got %d free pages", len(tree.Free))
+	}
+	if len(tree.Pending) != 1 {
+		t.Errorf("Expected 1 pending commit, got %d", len(tree.Pending))
+	}
+	tx.Rollback()
+
+	snapshot.End()
+	tx, _ = tree.Begin()
+	if (len(tree.Free) == 0) || (len(tree.Pending) != 0) {
+		t.Errorf("Expected pending pages to be reclaimed, got %d free and %d pending", len(tree.Free), len(tree.Pending))
+	}
+	free := len(tree.Free)
+	tx.Set(testKey(1000), testValue(1000, 1))
+	tx.Rollback()
+	if len(tree.Free) != free {
+		t.Errorf("Expected pages of rolled back transaction to be freed, got %d free pages instead of %d", len(tree.Free), free)
+	}
+
+	size := testFileSize(t, f)
+	testSetRange(t, tree, 0, 100, 2)
+	if testFileSize(t, f) != size {
+		t.Errorf("Expected free pages to be reused")
+	}
+}
+
+func TestTreeFreeListReopen(t *testing.T) {
+	tree, f := testOpenTree(t)
+	testSetRange(t, tree, 0, 100, 0)
+	testSetRange(t, tree, 0, 100, 1)
+
+	/* Uncommitted pages are unreachable too. */
+	tx, _ := tree.Begin()
+	tx.Set(testKey(1000), testValue(1000, 1))
+	tx.Commit()
+	tx, _ = tree.Begin()
+	tx.Del(testKey(1000))
+
+	/* NOTE(anton2920): rewrite of all keys needs as many pages as tree has, so file may grow once. Without free list it would grow on every reopen. */
+	var size int
+	for round := 2; round < 10; round++ {
+		tree, err := OpenTreeAt(f, 0)
+		if err != nil {
+			t.Fatalf("Failed to reopen tree: %v", err)
+		}
+		if len(tree.Free) == 0 {
+			t.Fatalf("Expected free list to be rebuilt")
+		}
+		testSetRange(t, tree, 0, 100, round)
+		if round == 2 {
+			size = testFileSize(t, f)
+		} else if testFileSize(t, f) != size {
+			t.Errorf("Round %d: expected file not to grow, %d != %d", round, testFileSize(t, f), size)
+		}
+	}
+
+	tree, err := OpenTreeAt(f, 0)
+	if err != nil {
+		t.Fatalf("Failed to reopen tree: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		if value, _ := tree.Get(testKey(i)); !bytes.Equal(value, testValue(i, 9)) {
+			t.Fatalf("Key %d: unexpected value after reopen", i)
+		}
+	}
+}
+
+func TestTreeDelEmptyLeaves(t *testing.T) {
+	tree, f := testOpenTree(t)
+
+	for round := 0; round < 5; round++ {
+		testSetRange(t, tree, 0, 200, round)
+		size := testFileSize(t, f)
+
+		/* Delete from the middle first, so inner leaves become empty before the leftmost and the rightmost ones. */
+		tx, _ := tree.Begin()
+		for i := 50; i < 150; i++ {
+			if err := tx.Del(testKey(i)); err != nil {
+				t.Fatalf("Failed to delete key %d: %v", i, err)
+			}
+		}
+		for i := 0; i < 50; i++ {
+			if err := tx.Del(testKey(i)); err != nil {
+				t.Fatalf("Failed to delete key %d: %v", i, err)
+			}
+			if value, _ := tx.Get(testKey(199 - i)); !bytes.Equal(value, testValue(199-i, round)) {
+				t.Fatalf("Key %d: unexpected value after deleting key %d", 199-i, i)
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("Failed to commit: %v", err)
+		}
+
+		it, _ := tree.Iter()
+		i := 150
+		for it.Next() {
+			if !bytes.Equal(it.Key(), testKey(i)) {
+				t.Fatalf("Expected key %d, got %v", i, it.Key())
+			}
+			i++
+		}
+		it.Close()
+		if i != 200 {
+			t.Fatalf("Expected iteration to end at key 200, got %d", i)
+		}
+
+		testSetRange(t, tree, 150, 200, round)
+		tx, _ = tree.Begin()
+		for i := 150; i < 200; i++ {
+			tx.Del(testKey(i))
+		}
+		tx.Commit()
+
+		var page Page
+		if _, err := tree.ReadPageAt(&page, tree.Meta.Root); err != nil {
+			t.Fatalf("Failed to read root: %v", err)
+		}
+		if (page.Type() != PageTypeLeaf) || (page.Leaf().N != 0) {
+			t.Fatalf("Expected empty tree to have empty root leaf")
+		}
+		if (round > 0) && (testFileSize(t, f) > size) {
+			t.Errorf("Round %d: expected pages of empty leaves to be reused, file grew from %d to %d", round, size, testFileSize(t, f))
+		}
+	}
+}
+
+/* testFailingFile fails reads at offset Fail. */
+type testFailingFile struct {
+	fs.VFile
+	Fail int64
+}
+
+func (f *testFailingFile) ReadAt(buf []byte, pos int64) (int, error) {
+	if pos == f.Fail {
+		return 0, errors.New("input/output error")
+	}
+	return f.VFile.ReadAt(buf, pos)
+}
+
+func TestTreeOpenReadError(t *testing.T) {
+	tree, f := testOpenTree(t)
+	testSetRange(t, tree, 0, 10, 0)
+
+	/* NOTE(anton2920): first meta page is torn, second one cannot be read, so neither is valid, but tree must not be re-initialized. */
+	torn := bytes.Repeat([]byte{0xAA}, PageSize/2)
+	if _, err := f.WriteAt(torn, 0); err != nil {
+		t.Fatalf("Failed to tear meta page: %v", err)
+	}
+	size := testFileSize(t, f)
+
+	if _, err := OpenTreeAt(&testFailingFile{VFile: f, Fail: PageSize}, 0); err == nil {
+		t.Fatalf("Expected read error to be returned")
+	}
+
+	buf := make([]byte, len(torn))
+	if _, err := f.ReadAt(buf, 0); err != nil {
+		t.Fatalf("Failed to read meta page: %v", err)
+	}
+	if (!bytes.Equal(buf, torn)) || (testFileSize(t, f) != size) {
+		t.Errorf("Expected tree file not to be modified")
+	}
+
+	/* Once read succeeds, tree is opened from second meta page. */
+	tree, err := OpenTreeAt(f, 0)
+	if err != nil {
+		t.Fatalf("Failed to reopen tree: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		if value, _ := tree.Get(testKey(i)); !bytes.Equal(value, testValue(i, 0)) {
+			t.Fatalf("Key %d: unexpected value after reopen", i)
+		}
+	}
+}
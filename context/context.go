@@ -26,9 +26,13 @@ func (ctx *Context) InitWithFourByteSlices(arenaBuf []byte, fmtBuf []byte, logBu
 	ctx.Fmt.InitWithByteSlice(fmtBuf)
 	ctx.Log.InitWithByteSlice(logBuf)
 
+	/* NOTE(anton2920): log lines and errors may be long, so cutting them must be visible. */
+	ctx.Log.Fmt.TruncateOnOverflow("")
+
 	size := len(errBuf) / len(ctx.ErrFmt)
 	for i := 0; i < len(ctx.ErrFmt); i++ {
 		ctx.ErrFmt[i].InitWithByteSlice(errBuf[i*size : (i+1)*size])
+		ctx.ErrFmt[i].TruncateOnOverflow("")
 	}
 }
 
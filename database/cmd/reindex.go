@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"unsafe"
+
+	"github.com/anton2920/gofa/database"
+	"github.com/anton2920/gofa/io/fs"
+	"github.com/anton2920/gofa/io/fs/fs_"
+)
+
+/* Rebuilds index of fixed-size field located at 'offset' in records of 'size' bytes. */
+func main() {
+	dbPath := flag.String("db", "", "path to database file")
+	indexPath := flag.String("index", "", "path to index file, created if it does not exist")
+	size := flag.Int("size", 0, "size of record in bytes")
+	offset := flag.Int("offset", 0, "offset of indexed field in record")
+	length := flag.Int("length", 0, "length of indexed field")
+	unique := flag.Bool("unique", false, "fail if two records have the same field value")
+	flag.Parse()
+
+	if (*dbPath == "") || (*indexPath == "") || (*size <= 0) || (*length <= 0) || (*offset < 0) || (*offset+*length > *size) {
+		flag.Usage()
+		log.Fatalf("Invalid arguments")
+	}
+
+	db, err := database.Open(*dbPath)
+	if err != nil {
+		log.Fatalf("Failed to open database %q: %v", *dbPath, err)
+	}
+	defer database.Close(db)
+
+	f, err := fs_.FS{}.Open(*indexPath, fs.OpenForReading|fs.OpenForWriting|fs.CreateFileIfItDoesNotExist)
+	if err != nil {
+		log.Fatalf("Failed to open index file %q: %v", *indexPath, err)
+	}
+	defer f.Close()
+
+	key := func(buf []byte, t unsafe.Pointer) []byte {
+		return append(buf, (*[1 << 30]byte)(t)[*offset:*offset+*length]...)
+	}
+
+	idx, err := database.AddIndex(db, *indexPath, f, *unique, key)
+	if err != nil {
+		log.Fatalf("Failed to add index: %v", err)
+	}
+	if err := database.RebuildIndex(db, idx, *size); err != nil {
+		log.Fatalf("Failed to rebuild index: %v", err)
+	}
+}
@@ -27,6 +27,9 @@ type RecordHeader struct {
 type DB struct {
 	Version uint32
 	FD      int32
+
+	/* Indexes are updated by every Write. */
+	Indexes []*Index
 }
 
 const Version uint32 = 0x0
@@ -218,7 +221,7 @@ func ReadMany(db *DB, pos *int64, ts []byte, size int) (int, error) {
 	return n / size, nil
 }
 
-func Write(db *DB, id ID, t unsafe.Pointer, size int) error {
+func writeRecord(db *DB, id ID, t unsafe.Pointer, size int) error {
 	offset := int64(int(id)*size) + DataOffset
 
 	_, err := syscall.Pwrite(db.FD, *(*[]byte)(unsafe.Pointer(&reflect.SliceHeader{Data: uintptr(t), Len: size, Cap: size})), offset)
@@ -227,3 +230,20 @@ func Write(db *DB, id ID, t unsafe.Pointer, size int) error {
 	}
 	return nil
 }
+
+/* Write stores record and updates indexes, see WriteBatch. Every index is committed before Write returns, use Batch to write many records. */
+func Write(db *DB, id ID, t unsafe.Pointer, size int) error {
+	if len(db.Indexes) == 0 {
+		return writeRecord(db, id, t, size)
+	}
+
+	b, err := BeginBatch(db)
+	if err != nil {
+		return err
+	}
+	if err := WriteBatch(b, id, t, size); err != nil {
+		RollbackBatch(b)
+		return err
+	}
+	return CommitBatch(b)
+}
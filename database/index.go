@@ -0,0 +1,328 @@
+package database
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"unsafe"
+
+	"github.com/anton2920/gofa/container/bplus"
+	"github.com/anton2920/gofa/errors"
+	"github.com/anton2920/gofa/io/fs"
+)
+
+/* Index maps bytes of record field to IDs of records with that value, so records can be found without scanning the whole file. Indexed records must start with RecordHeader.
+ * Unique index stores field bytes as key and ID as value. Non-unique index stores field bytes followed by big-endian ID as key and empty value, so records with the same field are ordered by ID. */
+type Index struct {
+	Name   string
+	Unique bool
+
+	Key  IndexKeyFunc
+	Tree *bplus.Tree
+}
+
+/* IndexKeyFunc appends bytes of indexed field of record t to buf. Records with empty key are not indexed. */
+type IndexKeyFunc func(buf []byte, t unsafe.Pointer) []byte
+
+const IDSize = int(unsafe.Sizeof(ID(0)))
+
+var AlreadyExists = errors.New("already exists")
+
+func putID(buf []byte, id ID) []byte {
+	var b [IDSize]byte
+	binary.BigEndian.PutUint32(b[:], uint32(id))
+	return append(buf, b[:]...)
+}
+
+func getID(buf []byte) ID {
+	return ID(binary.BigEndian.Uint32(buf))
+}
+
+/* AddIndex opens index stored in f and registers it in db, so it is updated by Write. If f is empty, index is created empty; use RebuildIndex to fill it from existing records. */
+func AddIndex(db *DB, name string, f fs.VFile, unique bool, key IndexKeyFunc) (*Index, error) {
+	tree, err := bplus.OpenTreeAt(f, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open index %q: %w", name, err)
+	}
+
+	idx := &Index{Name: name, Unique: unique, Key: key, Tree: tree}
+	db.Indexes = append(db.Indexes, idx)
+
+	return idx, nil
+}
+
+/* indexKey returns key under which record t with id is stored in index or nil, if record is not indexed. */
+func indexKey(idx *Index, id ID, t unsafe.Pointer) []byte {
+	if (t == nil) || ((*RecordHeader)(t).Flags.Has(FlagsDeleted)) {
+		return nil
+	}
+
+	key := idx.Key(nil, t)
+	if len(key) == 0 {
+		return nil
+	}
+	if !idx.Unique {
+		key = putID(key, id)
+	}
+
+	return key
+}
+
+/* indexSet inserts key for record with id into index, checking that unique key does not belong to another record. */
+func indexSet(tx *bplus.Tx, idx *Index, id ID, key []byte) error {
+	if !idx.Unique {
+		return tx.Set(key, nil)
+	}
+
+	value, err := tx.Get(key)
+	if err != nil {
+		return err
+	}
+	if (value != nil) && (getID(value) != id) {
+		return fmt.Errorf("key %q: %w for record %d", key, AlreadyExists, getID(value))
+	}
+
+	return tx.Set(key, putID(nil, id))
+}
+
+/* indexCheck reports AlreadyExists, if record t with id cannot be stored in unique index, because its key belongs to another record. */
+func indexCheck(tx *bplus.Tx, idx *Index, id ID, old unsafe.Pointer, t unsafe.Pointer) error {
+	if !idx.Unique {
+		return nil
+	}
+
+	newKey := indexKey(idx, id, t)
+	if (newKey == nil) || (bytes.Equal(indexKey(idx, id, old), newKey)) {
+		return nil
+	}
+
+	value, err := tx.Get(newKey)
+	if err != nil {
+		return err
+	}
+	if (value != nil) && (getID(value) != id) {
+		return fmt.Errorf("key %q: %w for record %d", newKey, AlreadyExists, getID(value))
+	}
+
+	return nil
+}
+
+/* indexUpdate replaces key of record old with key of record t. Both old and t may be nil. */
+func indexUpdate(tx *bplus.Tx, idx *Index, id ID, old unsafe.Pointer, t unsafe.Pointer) error {
+	oldKey := indexKey(idx, id, old)
+	newKey := indexKey(idx, id, t)
+	if bytes.Equal(oldKey, newKey) {
+		return nil
+	}
+
+	if oldKey != nil {
+		if err := tx.Del(oldKey); err != nil {
+			return err
+		}
+	}
+	if newKey != nil {
+		if err := indexSet(tx, idx, id, newKey); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/* Batch is a group of Writes, which index updates are committed together. Commit of index syncs its file twice, so writing many records in one batch is much cheaper than calling Write for each of them. Indexes of db are locked for writing until batch is committed or rolled back, so Write must not be called in between. */
+type Batch struct {
+	DB  *DB
+	Txs []*bplus.Tx
+}
+
+/* BeginBatch starts write transactions for all indexes of db. */
+func BeginBatch(db *DB) (*Batch, error) {
+	b := &Batch{DB: db, Txs: make([]*bplus.Tx, 0, len(db.Indexes))}
+
+	for _, idx := range db.Indexes {
+		tx, err := idx.Tree.Begin()
+		if err != nil {
+			RollbackBatch(b)
+			return nil, fmt.Errorf("failed to begin transaction for index %q: %w", idx.Name, err)
+		}
+		b.Txs = append(b.Txs, tx)
+	}
+
+	return b, nil
+}
+
+/* WriteBatch stores record and updates indexes in batch. Unique index violation is reported before anything is changed, so batch can be continued. On any other error batch is rolled back. */
+func WriteBatch(b *Batch, id ID, t unsafe.Pointer, size int) error {
+	db := b.DB
+	var old unsafe.Pointer
+
+	if len(b.Txs) > 0 {
+		buffer := make([]byte, size)
+
+		old = unsafe.Pointer(&buffer[0])
+		if err := Read(db, id, old, size); err == NotFound {
+			old = nil
+		} else if err != nil {
+			return fmt.Errorf("failed to read old record: %w", err)
+		}
+
+		for i, tx := range b.Txs {
+			if err := indexCheck(tx, db.Indexes[i], id, old, t); err != nil {
+				return fmt.Errorf("failed to update index %q: %w", db.Indexes[i].Name, err)
+			}
+		}
+		for i, tx := range b.Txs {
+			if err := indexUpdate(tx, db.Indexes[i], id, old, t); err != nil {
+				RollbackBatch(b)
+				return fmt.Errorf("failed to update index %q: %w", db.Indexes[i].Name, err)
+			}
+		}
+	}
+
+	if err := writeRecord(db, id, t, size); err != nil {
+		RollbackBatch(b)
+		return err
+	}
+
+	return nil
+}
+
+/* CommitBatch commits indexes updated by batch. Indexes are committed after records are written, so crash in between may leave them stale; RebuildIndex fixes that. If one of them fails, the rest are rolled back. */
+func CommitBatch(b *Batch) error {
+	for i, tx := range b.Txs {
+		if err := tx.Commit(); err != nil {
+			RollbackBatch(b)
+			return fmt.Errorf("failed to commit index %q: %w", b.DB.Indexes[i].Name, err)
+		}
+	}
+	return nil
+}
+
+/* RollbackBatch discards index updates of batch, which are not committed yet. Records written by batch are not restored. */
+func RollbackBatch(b *Batch) {
+	for _, tx := range b.Txs {
+		tx.Rollback()
+	}
+}
+
+/* Lookup returns ID of record with key or NotFound. For non-unique index the smallest ID is returned. */
+func Lookup(idx *Index, key []byte) (ID, error) {
+	if len(key) == 0 {
+		return -1, NotFound
+	}
+
+	if idx.Unique {
+		value, err := idx.Tree.Get(key)
+		if err != nil {
+			return -1, fmt.Errorf("failed to get key from index %q: %w", idx.Name, err)
+		}
+		if value == nil {
+			return -1, NotFound
+		}
+		return getID(value), nil
+	}
+
+	ids, err := LookupMany(idx, key, nil)
+	if err != nil {
+		return -1, err
+	}
+	if len(ids) == 0 {
+		return -1, NotFound
+	}
+	return ids[0], nil
+}
+
+/* LookupMany appends IDs of all records with key to ids in ascending order. */
+func LookupMany(idx *Index, key []byte, ids []ID) ([]ID, error) {
+	if len(key) == 0 {
+		return ids, nil
+	}
+
+	if idx.Unique {
+		id, err := Lookup(idx, key)
+		if err == NotFound {
+			return ids, nil
+		} else if err != nil {
+			return ids, err
+		}
+		return append(ids, id), nil
+	}
+
+	it, err := idx.Tree.Prefix(key)
+	if err != nil {
+		return ids, fmt.Errorf("failed to iterate over index %q: %w", idx.Name, err)
+	}
+	defer it.Close()
+
+	for it.Next() {
+		/* NOTE(anton2920): prefix also matches longer fields that start with key. */
+		if k := it.Key(); len(k) == len(key)+IDSize {
+			ids = append(ids, getID(k[len(key):]))
+		}
+	}
+	if it.Error != nil {
+		return ids, fmt.Errorf("failed to iterate over index %q: %w", idx.Name, it.Error)
+	}
+
+	return ids, nil
+}
+
+/* RebuildIndex recreates index from records in db. It must be used after index is added to existing database, or when database was modified without index being registered, e.g. after crash between writing record and committing index. */
+func RebuildIndex(db *DB, idx *Index, size int) error {
+	const batch = 64
+
+	var keys [][]byte
+
+	tx, err := idx.Tree.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction for index %q: %w", idx.Name, err)
+	}
+	defer tx.Rollback()
+
+	/* NOTE(anton2920): no other writer can commit while tx is in progress, so the last snapshot is what tx sees. */
+	it, err := idx.Tree.Iter()
+	if err != nil {
+		return fmt.Errorf("failed to iterate over index %q: %w", idx.Name, err)
+	}
+	for it.Next() {
+		keys = append(keys, append([]byte{}, it.Key()...))
+	}
+	it.Close()
+	if it.Error != nil {
+		return fmt.Errorf("failed to iterate over index %q: %w", idx.Name, it.Error)
+	}
+
+	for _, key := range keys {
+		if err := tx.Del(key); err != nil {
+			return fmt.Errorf("failed to remove old key from index %q: %w", idx.Name, err)
+		}
+	}
+
+	var pos int64
+	var id ID
+
+	ts := make([]byte, batch*size)
+	for {
+		n, err := ReadMany(db, &pos, ts[:batch], size)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			break
+		}
+
+		for i := 0; i < n; i++ {
+			if key := indexKey(idx, id, unsafe.Pointer(&ts[i*size])); key != nil {
+				if err := indexSet(tx, idx, id, key); err != nil {
+					return fmt.Errorf("failed to add record %d to index %q: %w", id, idx.Name, err)
+				}
+			}
+			id++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit index %q: %w", idx.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,270 @@
+package database
+
+import (
+	"encoding/binary"
+	"errors"
+	"path/filepath"
+	"testing"
+	"unsafe"
+
+	"github.com/anton2920/gofa/io/fs"
+)
+
+type testUser struct {
+	RecordHeader
+
+	Email [16]byte
+	Group uint32
+}
+
+const testUserSize = int(unsafe.Sizeof(testUser{}))
+
+/* testSyncFile counts syncs of index file. */
+type testSyncFile struct {
+	fs.VFile
+	Syncs int
+}
+
+func (f *testSyncFile) Sync() error {
+	f.Syncs++
+	return f.VFile.Sync()
+}
+
+func testEmailKey(buf []byte, t unsafe.Pointer) []byte {
+	email := (*testUser)(t).Email[:]
+	for (len(email) > 0) && (email[len(email)-1] == 0) {
+		email = email[:len(email)-1]
+	}
+	return append(buf, email...)
+}
+
+func testGroupKey(buf []byte, t unsafe.Pointer) []byte {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], (*testUser)(t).Group)
+	return append(buf, b[:]...)
+}
+
+func testGroup(group uint32) []byte {
+	return testGroupKey(nil, unsafe.Pointer(&testUser{Group: group}))
+}
+
+func testOpenDB(t *testing.T) *DB {
+	db, err := Open(filepath.Join(t.TempDir(), "db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { Close(db) })
+	return db
+}
+
+func testAddIndex(t *testing.T, db *DB, name string, unique bool, key IndexKeyFunc) (*Index, *testSyncFile) {
+	f, err := fs.NewMemoryFS().Open(name, fs.OpenForReading|fs.OpenForWriting|fs.CreateFileIfItDoesNotExist)
+	if err != nil {
+		t.Fatalf("Failed to open index file: %v", err)
+	}
+	sf := &testSyncFile{VFile: f}
+
+	idx, err := AddIndex(db, name, sf, unique, key)
+	if err != nil {
+		t.Fatalf("Failed to add index: %v", err)
+	}
+	return idx, sf
+}
+
+func testWriteUser(t *testing.T, db *DB, id ID, email string, group uint32) {
+	var user testUser
+	user.ID = id
+	copy(user.Email[:], email)
+	user.Group = group
+
+	if err := Write(db, id, unsafe.Pointer(&user), testUserSize); err != nil {
+		t.Fatalf("Failed to write user %d: %v", id, err)
+	}
+}
+
+func testLookup(t *testing.T, idx *Index, key string, expected ID) {
+	id, err := Lookup(idx, []byte(key))
+	if expected == -1 {
+		if err != NotFound {
+			t.Errorf("Expected %q not to be found in %q, got %d, %v", key, idx.Name, id, err)
+		}
+	} else if (err != nil) || (id != expected) {
+		t.Errorf("Expected %q to be record %d in %q, got %d, %v", key, expected, idx.Name, id, err)
+	}
+}
+
+func testLookupMany(t *testing.T, idx *Index, key []byte, expected ...ID) {
+	ids, err := LookupMany(idx, key, nil)
+	if err != nil {
+		t.Fatalf("Failed to lookup %v in %q: %v", key, idx.Name, err)
+	}
+	if len(ids) != len(expected) {
+		t.Fatalf("Expected %v for %v in %q, got %v", expected, key, idx.Name, ids)
+	}
+	for i := 0; i < len(ids); i++ {
+		if ids[i] != expected[i] {
+			t.Fatalf("Expected %v for %v in %q, got %v", expected, key, idx.Name, ids)
+		}
+	}
+}
+
+func TestIndexWrite(t *testing.T) {
+	db := testOpenDB(t)
+	emails, _ := testAddIndex(t, db, "email", true, testEmailKey)
+	groups, _ := testAddIndex(t, db, "group", false, testGroupKey)
+
+	testWriteUser(t, db, 0, "a@example.com", 1)
+	testWriteUser(t, db, 1, "b@example.com", 2)
+	testWriteUser(t, db, 2, "c@example.com", 1)
+
+	testLookup(t, emails, "a@example.com", 0)
+	testLookup(t, emails, "b@example.com", 1)
+	testLookup(t, emails, "a@example", -1)
+	testLookupMany(t, groups, testGroup(1), 0, 2)
+	testLookupMany(t, groups, testGroup(2), 1)
+
+	/* Update moves record to new keys. */
+	testWriteUser(t, db, 0, "d@example.com", 2)
+	testLookup(t, emails, "a@example.com", -1)
+	testLookup(t, emails, "d@example.com", 0)
+	testLookupMany(t, groups, testGroup(1), 2)
+	testLookupMany(t, groups, testGroup(2), 0, 1)
+
+	/* Unique violation leaves both record and indexes intact. */
+	var user testUser
+	copy(user.Email[:], "b@example.com")
+	user.Group = 3
+	if err := Write(db, 2, unsafe.Pointer(&user), testUserSize); !errors.Is(err, AlreadyExists) {
+		t.Errorf("Expected %v, got %v", AlreadyExists, err)
+	}
+	if err := Read(db, 2, unsafe.Pointer(&user), testUserSize); err != nil {
+		t.Fatalf("Failed to read user: %v", err)
+	}
+	if (string(testEmailKey(nil, unsafe.Pointer(&user))) != "c@example.com") || (user.Group != 1) {
+		t.Errorf("Expected record not to be overwritten, got %q in group %d", user.Email, user.Group)
+	}
+	testLookup(t, emails, "c@example.com", 2)
+	testLookupMany(t, groups, testGroup(1), 2)
+	testLookupMany(t, groups, testGroup(3))
+
+	/* Deleted records are removed from indexes. */
+	if err := Read(db, 1, unsafe.Pointer(&user), testUserSize); err != nil {
+		t.Fatalf("Failed to read user: %v", err)
+	}
+	user.Flags.Set(FlagsDeleted)
+	if err := Write(db, 1, unsafe.Pointer(&user), testUserSize); err != nil {
+		t.Fatalf("Failed to delete user: %v", err)
+	}
+	testLookup(t, emails, "b@example.com", -1)
+	testLookupMany(t, groups, testGroup(2), 0)
+
+	/* Key of deleted record can be taken by another one. */
+	testWriteUser(t, db, 3, "b@example.com", 2)
+	testLookup(t, emails, "b@example.com", 3)
+}
+
+func TestIndexBatch(t *testing.T) {
+	const count = 50
+
+	db := testOpenDB(t)
+	emails, ef := testAddIndex(t, db, "email", true, testEmailKey)
+	groups, gf := testAddIndex(t, db, "group", false, testGroupKey)
+	syncs := ef.Syncs + gf.Syncs
+
+	b, err := BeginBatch(db)
+	if err != nil {
+		t.Fatalf("Failed to begin batch: %v", err)
+	}
+	for i := 0; i < count; i++ {
+		var user testUser
+		copy(user.Email[:], string(rune('A'+i)))
+		user.Group = uint32(i % 3)
+		if err := WriteBatch(b, ID(i), unsafe.Pointer(&user), testUserSize); err != nil {
+			t.Fatalf("Failed to write user %d: %v", i, err)
+		}
+	}
+
+	/* Unique keys are checked against records of the same batch, which can continue after violation. */
+	var user testUser
+	copy(user.Email[:], "A")
+	if err := WriteBatch(b, count, unsafe.Pointer(&user), testUserSize); !errors.Is(err, AlreadyExists) {
+		t.Errorf("Expected %v, got %v", AlreadyExists, err)
+	}
+	if err := CommitBatch(b); err != nil {
+		t.Fatalf("Failed to commit batch: %v", err)
+	}
+
+	if n := ef.Syncs + gf.Syncs - syncs; n != 2*len(db.Indexes) {
+		t.Errorf("Expected %d syncs for batch, got %d", 2*len(db.Indexes), n)
+	}
+	for i := 0; i < count; i++ {
+		testLookup(t, emails, string(rune('A'+i)), ID(i))
+	}
+	var expected []ID
+	for i := 0; i < count; i++ {
+		if i%3 == 1 {
+			expected = append(expected, ID(i))
+		}
+	}
+	testLookupMany(t, groups, testGroup(1), expected...)
+
+	/* Rolled back batch does not change indexes. */
+	b, err = BeginBatch(db)
+	if err != nil {
+		t.Fatalf("Failed to begin batch: %v", err)
+	}
+	copy(user.Email[:], "new")
+	if err := WriteBatch(b, 0, unsafe.Pointer(&user), testUserSize); err != nil {
+		t.Fatalf("Failed to write user: %v", err)
+	}
+	RollbackBatch(b)
+	testLookup(t, emails, "new", -1)
+	testLookup(t, emails, "A", 0)
+}
+
+func TestRebuildIndex(t *testing.T) {
+	db := testOpenDB(t)
+
+	testWriteUser(t, db, 0, "a@example.com", 1)
+	testWriteUser(t, db, 1, "b@example.com", 1)
+
+	emails, _ := testAddIndex(t, db, "email", true, testEmailKey)
+	groups, _ := testAddIndex(t, db, "group", false, testGroupKey)
+	testLookup(t, emails, "a@example.com", -1)
+
+	for _, idx := range db.Indexes {
+		if err := RebuildIndex(db, idx, testUserSize); err != nil {
+			t.Fatalf("Failed to rebuild index %q: %v", idx.Name, err)
+		}
+	}
+	testLookup(t, emails, "a@example.com", 0)
+	testLookupMany(t, groups, testGroup(1), 0, 1)
+
+	/* Records written while indexes are not registered leave them stale, until they are rebuilt. */
+	indexes := db.Indexes
+	db.Indexes = nil
+	testWriteUser(t, db, 0, "c@example.com", 2)
+	testWriteUser(t, db, 2, "d@example.com", 1)
+	db.Indexes = indexes
+	testLookup(t, emails, "a@example.com", 0)
+
+	for _, idx := range db.Indexes {
+		if err := RebuildIndex(db, idx, testUserSize); err != nil {
+			t.Fatalf("Failed to rebuild index %q: %v", idx.Name, err)
+		}
+	}
+	testLookup(t, emails, "a@example.com", -1)
+	testLookup(t, emails, "c@example.com", 0)
+	testLookup(t, emails, "d@example.com", 2)
+	testLookupMany(t, groups, testGroup(1), 1, 2)
+	testLookupMany(t, groups, testGroup(2), 0)
+
+	/* Duplicate of unique key fails rebuild. */
+	db.Indexes = nil
+	testWriteUser(t, db, 3, "d@example.com", 1)
+	db.Indexes = indexes
+	if err := RebuildIndex(db, emails, testUserSize); !errors.Is(err, AlreadyExists) {
+		t.Errorf("Expected %v, got %v", AlreadyExists, err)
+	}
+	testLookup(t, emails, "c@example.com", 0)
+}
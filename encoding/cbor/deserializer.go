@@ -0,0 +1,681 @@
+package cbor
+
+import (
+	"fmt"
+	"math"
+	"unicode/utf8"
+
+	"github.com/anton2920/gofa/bytes"
+	"github.com/anton2920/gofa/time"
+	"github.com/anton2920/gofa/trace/trace_"
+)
+
+/* Deserializer is a pull-parser over Buffer with the same API as json.Deserializer. Every method returns false on error and sets Error; after that all methods return false. Typical usage:
+ *     d := cbor.Deserializer{Buffer: body}
+ *     d.Begin()
+ *     d.ObjectBegin()
+ *     for d.Key(&key) {
+ *         switch key {
+ *         case "name":
+ *             d.String(&name)
+ *         default:
+ *             d.Skip()
+ *         }
+ *     }
+ *     d.ObjectEnd()
+ *     if !d.End() {
+ *         return d.Error
+ *     }
+ * Both definite and indefinite-length maps and arrays are accepted, strings must have definite length. Returned strings and byte strings point into Buffer. */
+type Deserializer struct {
+	Buffer []byte
+	Pos    int
+
+	Stack [MaxDepth]frame
+	Depth int
+
+	Error error
+}
+
+type frame struct {
+	Major      byte
+	Indefinite bool
+
+	/* Remaining is the number of elements (pairs for maps) that are not read yet. */
+	Remaining uint64
+}
+
+/* SyntaxError describes invalid input. */
+type SyntaxError struct {
+	Offset  int
+	Message string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("offset %d: %s", e.Offset, e.Message)
+}
+
+func (d *Deserializer) errorf(format string, args ...interface{}) bool {
+	if d.Error == nil {
+		d.Error = &SyntaxError{Offset: d.Pos, Message: fmt.Sprintf(format, args...)}
+	}
+	return false
+}
+
+/* head reads head of the next data item. For indefinite-length items n is zero. */
+func (d *Deserializer) head() (major byte, n uint64, indefinite bool, ok bool) {
+	if d.Error != nil {
+		return
+	}
+	if d.Pos == len(d.Buffer) {
+		d.errorf("unexpected end of input")
+		return
+	}
+
+	c := d.Buffer[d.Pos]
+	major = c >> 5
+	info := c & 0x1F
+
+	var size int
+	switch {
+	case info < InfoUint8:
+		n = uint64(info)
+	case info == InfoUint8:
+		size = 1
+	case info == InfoUint16:
+		size = 2
+	case info == InfoUint32:
+		size = 4
+	case info == InfoUint64:
+		size = 8
+	case info == InfoIndefinite:
+		if (major < MajorBytes) || (major == MajorTag) {
+			d.errorf("invalid indefinite length for major type %d", major)
+			return
+		}
+		indefinite = true
+	default:
+		d.errorf("reserved additional information %d", info)
+		return
+	}
+
+	if len(d.Buffer)-d.Pos-1 < size {
+		d.errorf("unexpected end of input")
+		return
+	}
+	for i := 0; i < size; i++ {
+		n = (n << 8) | uint64(d.Buffer[d.Pos+1+i])
+	}
+	d.Pos += 1 + size
+
+	ok = true
+	return
+}
+
+/* peek returns major type of the next data item without consuming it. */
+func (d *Deserializer) peek() (byte, bool) {
+	if d.Error != nil {
+		return 0, false
+	}
+	if d.Pos == len(d.Buffer) {
+		return 0, d.errorf("unexpected end of input")
+	}
+	return d.Buffer[d.Pos] >> 5, true
+}
+
+func (d *Deserializer) expect(major byte) (uint64, bool) {
+	start := d.Pos
+	m, n, indefinite, ok := d.head()
+	if !ok {
+		return 0, false
+	}
+	if m != major {
+		d.Pos = start
+		return 0, d.errorf("unexpected major type %d, expected %d", m, major)
+	}
+	if indefinite {
+		d.Pos = start
+		return 0, d.errorf("indefinite-length strings are not supported")
+	}
+	return n, true
+}
+
+/* str reads text or byte string. */
+func (d *Deserializer) str(major byte) ([]byte, bool) {
+	start := d.Pos
+	n, ok := d.expect(major)
+	if !ok {
+		return nil, false
+	}
+	if n > uint64(len(d.Buffer)-d.Pos) {
+		d.Pos = start
+		return nil, d.errorf("string length %d exceeds the rest of input", n)
+	}
+
+	s := d.Buffer[d.Pos : d.Pos+int(n)]
+	if (major == MajorText) && (!utf8.Valid(s)) {
+		d.Pos = start
+		return nil, d.errorf("invalid UTF-8 in text string")
+	}
+	d.Pos += int(n)
+	return s, true
+}
+
+func (d *Deserializer) push(major byte) bool {
+	start := d.Pos
+	n, ok := d.length(major)
+	if !ok {
+		return false
+	}
+	if d.Depth == len(d.Stack) {
+		d.Pos = start
+		return d.errorf("nesting is too deep")
+	}
+
+	e := frame{Major: major, Indefinite: n < 0, Remaining: uint64(n)}
+	if !e.Indefinite {
+		/* NOTE(anton2920): every element takes at least one byte, so hostile count can be rejected early. */
+		perElement := uint64(1)
+		if major == MajorMap {
+			perElement = 2
+		}
+		if e.Remaining > uint64(len(d.Buffer)-d.Pos)/perElement {
+			d.Pos = start
+			return d.errorf("number of elements %d exceeds the rest of input", n)
+		}
+	}
+	d.Stack[d.Depth] = e
+	d.Depth++
+	return true
+}
+
+/* length reads head of map or array, which may have indefinite length, reported as -1. */
+func (d *Deserializer) length(major byte) (int64, bool) {
+	start := d.Pos
+	m, n, indefinite, ok := d.head()
+	if !ok {
+		return 0, false
+	}
+	if m != major {
+		d.Pos = start
+		return 0, d.errorf("unexpected major type %d, expected %d", m, major)
+	}
+	if indefinite {
+		return -1, true
+	}
+	if n > math.MaxInt64 {
+		d.Pos = start
+		return 0, d.errorf("number of elements %d is too large", n)
+	}
+	return int64(n), true
+}
+
+func (d *Deserializer) pop(major byte) bool {
+	if d.Error != nil {
+		return false
+	}
+	if (d.Depth == 0) || (d.Stack[d.Depth-1].Major != major) {
+		return d.errorf("not inside of container with major type %d", major)
+	}
+
+	e := &d.Stack[d.Depth-1]
+	if e.Indefinite {
+		if (d.Pos == len(d.Buffer)) || (d.Buffer[d.Pos] != Break) {
+			return d.errorf("expected break")
+		}
+		d.Pos++
+	} else if e.Remaining > 0 {
+		return d.errorf("%d elements are not read", e.Remaining)
+	}
+	d.Depth--
+	return true
+}
+
+/* element moves to the next element of the innermost container, which must be of major type. It returns false at the end of container. */
+func (d *Deserializer) element(major byte) bool {
+	if d.Error != nil {
+		return false
+	}
+	if (d.Depth == 0) || (d.Stack[d.Depth-1].Major != major) {
+		return d.errorf("not inside of container with major type %d", major)
+	}
+
+	e := &d.Stack[d.Depth-1]
+	if e.Indefinite {
+		if d.Pos == len(d.Buffer) {
+			return d.errorf("unexpected end of input")
+		}
+		return d.Buffer[d.Pos] != Break
+	}
+	if e.Remaining == 0 {
+		return false
+	}
+	e.Remaining--
+	return true
+}
+
+/* integer reads integer in range [min, max]. For negative integers it returns absolute value minus one. */
+func (d *Deserializer) integer(min int64, max uint64) (uint64, bool, bool) {
+	start := d.Pos
+	major, n, _, ok := d.head()
+	if !ok {
+		return 0, false, false
+	}
+
+	switch major {
+	case MajorUnsigned:
+		if n > max {
+			d.Pos = start
+			return 0, false, d.errorf("integer %d is out of range", n)
+		}
+		return n, false, true
+	case MajorNegative:
+		if (min >= 0) || (n > uint64(-(min + 1))) {
+			d.Pos = start
+			return 0, false, d.errorf("integer -1-%d is out of range", n)
+		}
+		return n, true, true
+	default:
+		d.Pos = start
+		return 0, false, d.errorf("unexpected major type %d, expected integer", major)
+	}
+}
+
+func float16to64(h uint16) float64 {
+	sign := 1.0
+	if h&0x8000 != 0 {
+		sign = -1.0
+	}
+	exp := int((h >> 10) & 0x1F)
+	mant := float64(h & 0x3FF)
+
+	switch exp {
+	case 0:
+		return sign * math.Ldexp(mant, -24)
+	case 0x1F:
+		if mant != 0 {
+			return math.NaN()
+		}
+		return math.Inf(int(sign))
+	default:
+		return sign * math.Ldexp(mant+1024, exp-25)
+	}
+}
+
+/* Begin prepares to read value. Self-described CBOR tag is skipped. */
+func (d *Deserializer) Begin() bool {
+	d.Depth = 0
+	d.Error = nil
+
+	if (len(d.Buffer)-d.Pos >= 3) && (d.Buffer[d.Pos] == (MajorTag<<5)|InfoUint16) && (uint16(d.Buffer[d.Pos+1])<<8|uint16(d.Buffer[d.Pos+2]) == TagSelfDescribe) {
+		d.Pos += 3
+	}
+	return true
+}
+
+func (d *Deserializer) End() bool {
+	if d.Error != nil {
+		return false
+	}
+	if d.Depth > 0 {
+		return d.errorf("%d containers are not closed", d.Depth)
+	}
+	if d.Pos != len(d.Buffer) {
+		return d.errorf("%d bytes are left after value", len(d.Buffer)-d.Pos)
+	}
+	return true
+}
+
+func (d *Deserializer) ObjectBegin() bool {
+	t := trace_.Begin("")
+
+	ok := d.push(MajorMap)
+
+	trace_.End(t)
+	return ok
+}
+
+/* Key reads key of the next pair. Only text keys are supported. */
+func (d *Deserializer) Key(key *string) bool {
+	t := trace_.Begin("")
+
+	if !d.element(MajorMap) {
+		trace_.End(t)
+		return false
+	}
+
+	s, ok := d.str(MajorText)
+	if ok {
+		*key = bytes.AsString(s)
+	}
+
+	trace_.End(t)
+	return ok
+}
+
+func (d *Deserializer) ObjectEnd() bool {
+	t := trace_.Begin("")
+
+	ok := d.pop(MajorMap)
+
+	trace_.End(t)
+	return ok
+}
+
+func (d *Deserializer) ArrayBegin() bool {
+	t := trace_.Begin("")
+
+	ok := d.push(MajorArray)
+
+	trace_.End(t)
+	return ok
+}
+
+func (d *Deserializer) Next() bool {
+	t := trace_.Begin("")
+
+	ok := d.element(MajorArray)
+
+	trace_.End(t)
+	return ok
+}
+
+func (d *Deserializer) ArrayEnd() bool {
+	t := trace_.Begin("")
+
+	ok := d.pop(MajorArray)
+
+	trace_.End(t)
+	return ok
+}
+
+func (d *Deserializer) simple() (uint64, bool) {
+	start := d.Pos
+	major, n, indefinite, ok := d.head()
+	if !ok {
+		return 0, false
+	}
+	if (major != MajorSimple) || (indefinite) {
+		d.Pos = start
+		return 0, d.errorf("unexpected major type %d, expected simple value", major)
+	}
+	return n, true
+}
+
+func (d *Deserializer) Null() bool {
+	start := d.Pos
+	n, ok := d.simple()
+	if (ok) && (n != SimpleNull) {
+		d.Pos = start
+		ok = d.errorf("expected null")
+	}
+	return ok
+}
+
+func (d *Deserializer) Bool(b *bool) bool {
+	start := d.Pos
+	n, ok := d.simple()
+	if ok {
+		switch n {
+		case SimpleFalse:
+			*b = false
+		case SimpleTrue:
+			*b = true
+		default:
+			d.Pos = start
+			ok = d.errorf("expected boolean")
+		}
+	}
+	return ok
+}
+
+func (d *Deserializer) Int32(i *int32) bool {
+	n, neg, ok := d.integer(math.MinInt32, math.MaxInt32)
+	if ok {
+		if neg {
+			*i = int32(-1 - int64(n))
+		} else {
+			*i = int32(n)
+		}
+	}
+	return ok
+}
+
+func (d *Deserializer) Uint32(i *uint32) bool {
+	n, _, ok := d.integer(0, math.MaxUint32)
+	if ok {
+		*i = uint32(n)
+	}
+	return ok
+}
+
+func (d *Deserializer) Int64(i *int64) bool {
+	n, neg, ok := d.integer(math.MinInt64, math.MaxInt64)
+	if ok {
+		if neg {
+			*i = -1 - int64(n)
+		} else {
+			*i = int64(n)
+		}
+	}
+	return ok
+}
+
+func (d *Deserializer) Uint64(i *uint64) bool {
+	n, _, ok := d.integer(0, math.MaxUint64)
+	if ok {
+		*i = n
+	}
+	return ok
+}
+
+/* Int reads integer in range of int, which is narrower than int64 on 32-bit platforms. */
+func (d *Deserializer) Int(i *int) bool {
+	var n int64
+
+	ok := d.IntRange(&n, math.MinInt, math.MaxInt)
+	if ok {
+		*i = int(n)
+	}
+	return ok
+}
+
+func (d *Deserializer) Uint(u *uint) bool {
+	var n uint64
+
+	ok := d.UintRange(&n, math.MaxUint)
+	if ok {
+		*u = uint(n)
+	}
+	return ok
+}
+
+/* IntRange reads integer in [min, max], where min <= 0 <= max. It's meant for types narrower than int32. */
+func (d *Deserializer) IntRange(i *int64, min, max int64) bool {
+	n, neg, ok := d.integer(min, uint64(max))
+	if ok {
+		if neg {
+			*i = -1 - int64(n)
+		} else {
+			*i = int64(n)
+		}
+	}
+	return ok
+}
+
+/* UintRange reads integer in [0, max]. */
+func (d *Deserializer) UintRange(u *uint64, max uint64) bool {
+	n, _, ok := d.integer(0, max)
+	if ok {
+		*u = n
+	}
+	return ok
+}
+
+/* Float64 reads float of any precision or integer. */
+func (d *Deserializer) Float64(f *float64) bool {
+	t := trace_.Begin("")
+	defer trace_.End(t)
+
+	major, ok := d.peek()
+	if !ok {
+		return false
+	}
+	if (major == MajorUnsigned) || (major == MajorNegative) {
+		var i int64
+		if d.Int64(&i) {
+			*f = float64(i)
+			return true
+		}
+		return false
+	}
+
+	if major != MajorSimple {
+		return d.errorf("unexpected major type %d, expected float", major)
+	}
+	info := d.Buffer[d.Pos] & 0x1F
+	if (info != InfoUint16) && (info != InfoUint32) && (info != InfoUint64) {
+		return d.errorf("expected float")
+	}
+
+	_, n, _, ok := d.head()
+	if !ok {
+		return false
+	}
+	switch info {
+	case InfoUint16:
+		*f = float16to64(uint16(n))
+	case InfoUint32:
+		*f = float64(math.Float32frombits(uint32(n)))
+	case InfoUint64:
+		*f = math.Float64frombits(n)
+	}
+	return true
+}
+
+func (d *Deserializer) String(s *string) bool {
+	t := trace_.Begin("")
+
+	str, ok := d.str(MajorText)
+	if ok {
+		*s = bytes.AsString(str)
+	}
+
+	trace_.End(t)
+	return ok
+}
+
+func (d *Deserializer) ByteString(b *[]byte) bool {
+	t := trace_.Begin("")
+
+	bs, ok := d.str(MajorBytes)
+	if ok {
+		*b = bs
+	}
+
+	trace_.End(t)
+	return ok
+}
+
+/* Tag reads tag of the following value. */
+func (d *Deserializer) Tag(tag *uint64) bool {
+	start := d.Pos
+	major, n, _, ok := d.head()
+	if (ok) && (major != MajorTag) {
+		d.Pos = start
+		ok = d.errorf("unexpected major type %d, expected tag", major)
+	}
+	if ok {
+		*tag = n
+	}
+	return ok
+}
+
+/* Time reads epoch-based date/time into number of nanoseconds since epoch. Other representations, e.g. RFC 3339 strings, are not supported. */
+func (d *Deserializer) Time(t *int64) bool {
+	var tag uint64
+
+	start := d.Pos
+	if !d.Tag(&tag) {
+		return false
+	}
+	if tag != TagEpoch {
+		d.Pos = start
+		return d.errorf("unsupported date/time tag %d", tag)
+	}
+
+	major, ok := d.peek()
+	if !ok {
+		return false
+	}
+	if major == MajorSimple {
+		var f float64
+		if !d.Float64(&f) {
+			return false
+		}
+		if (math.IsNaN(f)) || (math.Abs(f) >= math.MaxInt64/time.Second) {
+			return d.errorf("date/time %v is out of range", f)
+		}
+		*t = int64(f * time.Second)
+		return true
+	}
+
+	var sec int64
+	if !d.Int64(&sec) {
+		return false
+	}
+	if (sec > math.MaxInt64/time.Second) || (sec < math.MinInt64/time.Second) {
+		return d.errorf("date/time %d is out of range", sec)
+	}
+	*t = sec * time.Second
+	return true
+}
+
+/* Skip skips the next value. */
+func (d *Deserializer) Skip() bool {
+	t := trace_.Begin("")
+	defer trace_.End(t)
+
+	major, ok := d.peek()
+	for (ok) && (major == MajorTag) {
+		/* NOTE(anton2920): tags are skipped in a loop, so long chains of them cannot overflow the stack. */
+		var tag uint64
+		if d.Tag(&tag) {
+			major, ok = d.peek()
+		} else {
+			ok = false
+		}
+	}
+	if !ok {
+		return false
+	}
+
+	switch major {
+	case MajorUnsigned, MajorNegative:
+		_, _, _, ok = d.head()
+	case MajorBytes, MajorText:
+		_, ok = d.str(major)
+	case MajorArray:
+		d.ArrayBegin()
+		for d.Next() {
+			d.Skip()
+		}
+		ok = d.ArrayEnd()
+	case MajorMap:
+		d.ObjectBegin()
+		for d.element(MajorMap) {
+			/* NOTE(anton2920): keys of maps written by other encoders may be of any type. */
+			d.Skip()
+			d.Skip()
+		}
+		ok = d.ObjectEnd()
+	case MajorSimple:
+		var indefinite bool
+		_, _, indefinite, ok = d.head()
+		if indefinite {
+			d.Pos--
+			ok = d.errorf("unexpected break")
+		}
+	}
+	return ok
+}
@@ -0,0 +1,251 @@
+package cbor
+
+import (
+	"encoding/hex"
+	"math"
+	"testing"
+)
+
+func decodeHex(t testing.TB, s string) []byte {
+	buf, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("Failed to decode %q: %v", s, err)
+	}
+	return buf
+}
+
+func TestDeserializerObject(t *testing.T) {
+	var key string
+	var a int32
+	var b []int64
+	var c string
+	var bs []byte
+	var f float64
+	var tm int64
+
+	/* {_ "a": 1, "b": [_ 2, 3], "x": {"y": [h'00', 1.5]}, "c": "d", "bs": h'0102', "f": 1.5, "t": 1(1363896240)} */
+	d := Deserializer{Buffer: decodeHex(t, "bf"+"6161"+"01"+"6162"+"9f0203ff"+"6178"+"a1617982410"+"0f93e00"+"6163"+"6164"+"626273"+"420102"+"6166"+"f93e00"+"6174"+"c11a514b67b0"+"ff")}
+
+	d.Begin()
+	d.ObjectBegin()
+	for d.Key(&key) {
+		switch key {
+		case "a":
+			d.Int32(&a)
+		case "b":
+			d.ArrayBegin()
+			for d.Next() {
+				var x int64
+				d.Int64(&x)
+				b = append(b, x)
+			}
+			d.ArrayEnd()
+		case "c":
+			d.String(&c)
+		case "bs":
+			d.ByteString(&bs)
+		case "f":
+			d.Float64(&f)
+		case "t":
+			d.Time(&tm)
+		default:
+			d.Skip()
+		}
+	}
+	d.ObjectEnd()
+	if !d.End() {
+		t.Fatalf("Failed to deserialize: %v", d.Error)
+	}
+
+	if (a != 1) || (len(b) != 2) || (b[0] != 2) || (b[1] != 3) || (c != "d") || (len(bs) != 2) || (bs[1] != 2) || (f != 1.5) || (tm != 1363896240*1000000000) {
+		t.Errorf("Unexpected values: a=%d, b=%v, c=%q, bs=%v, f=%v, t=%d", a, b, c, bs, f, tm)
+	}
+}
+
+func TestDeserializerNumbers(t *testing.T) {
+	tests := [...]struct {
+		Input string
+		Value float64
+	}{
+		{"f90001", 5.960464477539063e-8},
+		{"f90400", 0.00006103515625},
+		{"f9c400", -4},
+		{"f97bff", 65504},
+		{"fa47c35000", 100000},
+		{"fb3ff199999999999a", 1.1},
+		{"f97c00", math.Inf(1)},
+		{"3903e7", -1000},
+	}
+
+	for _, test := range tests {
+		var f float64
+
+		d := Deserializer{Buffer: decodeHex(t, test.Input)}
+		if (!d.Float64(&f)) || (f != test.Value) {
+			t.Errorf("%s: expected %v, got %v (%v)", test.Input, test.Value, f, d.Error)
+		}
+	}
+
+	var f float64
+	d := Deserializer{Buffer: decodeHex(t, "f97e00")}
+	if (!d.Float64(&f)) || (!math.IsNaN(f)) {
+		t.Errorf("Expected NaN, got %v (%v)", f, d.Error)
+	}
+
+	var i32 int32
+	d = Deserializer{Buffer: decodeHex(t, "3a7fffffff")}
+	if (!d.Int32(&i32)) || (i32 != math.MinInt32) {
+		t.Errorf("Expected %d, got %d (%v)", math.MinInt32, i32, d.Error)
+	}
+	d = Deserializer{Buffer: decodeHex(t, "1a80000000")}
+	if d.Int32(&i32) {
+		t.Errorf("Expected overflow, got %d", i32)
+	}
+
+	var u32 uint32
+	d = Deserializer{Buffer: decodeHex(t, "20")}
+	if d.Uint32(&u32) {
+		t.Errorf("Expected error for negative integer, got %d", u32)
+	}
+
+	var i64 int64
+	d = Deserializer{Buffer: decodeHex(t, "3b8000000000000000")}
+	if d.Int64(&i64) {
+		t.Errorf("Expected overflow, got %d", i64)
+	}
+}
+
+func TestDeserializerErrors(t *testing.T) {
+	tests := [...]struct {
+		Input  string
+		Offset int
+	}{
+		{"", 0},
+		{"18", 0},
+		{"1c", 0},
+		{"62c3", 0},
+		{"62c328", 0},
+		{"8201", 0},
+		{"9b00000000ffffffff00", 0},
+		{"bf6161", 3},
+		{"9f01", 2},
+		{"5f4101ff", 0},
+		{"ff", 0},
+		{"0101", 1},
+		{"a10161", 2},
+	}
+
+	for _, test := range tests {
+		d := Deserializer{Buffer: decodeHex(t, test.Input)}
+		d.Begin()
+		d.Skip()
+		if d.End() {
+			t.Errorf("%s: expected error", test.Input)
+			continue
+		}
+
+		err, ok := d.Error.(*SyntaxError)
+		if !ok {
+			t.Errorf("%s: expected *SyntaxError, got %T", test.Input, d.Error)
+			continue
+		}
+		if err.Offset != test.Offset {
+			t.Errorf("%s: expected error at offset %d, got %v", test.Input, test.Offset, err)
+		}
+	}
+}
+
+func TestDeserializerSkip(t *testing.T) {
+	inputs := [...]string{
+		"d9d9f7a26161016162820203",
+		"9f018202039f0405ffff",
+		"a2016161f6f4",
+		"c1c1c1c1fb41d452d9ec200000",
+	}
+
+	for _, input := range inputs {
+		d := Deserializer{Buffer: decodeHex(t, input)}
+		d.Begin()
+		d.Skip()
+		if !d.End() {
+			t.Errorf("%s: failed to skip: %v", input, d.Error)
+		}
+	}
+}
+
+func FuzzDeserializer(f *testing.F) {
+	f.Add(decodeHex(f, "bf61610161629f0203ff6178a16179824100f93e00ff"))
+	f.Add(decodeHex(f, "8301820203820405"))
+	f.Add(decodeHex(f, "c1fb41d452d9ec200000"))
+
+	f.Fuzz(func(t *testing.T, buffer []byte) {
+		d := Deserializer{Buffer: buffer}
+		d.Begin()
+		d.Skip()
+		if !d.End() {
+			return
+		}
+
+		/* Whatever was skipped successfully must also be readable as data. */
+		var s Serializer
+		d = Deserializer{Buffer: buffer}
+		d.Begin()
+		if !copyValue(&s, &d) {
+			t.Fatalf("Failed to copy value, that was skipped: %v", d.Error)
+		}
+	})
+}
+
+/* copyValue reads value from d and writes it to s, so that fuzzer exercises typed readers. */
+func copyValue(s *Serializer, d *Deserializer) bool {
+	major, ok := d.peek()
+	if !ok {
+		return false
+	}
+
+	switch major {
+	case MajorUnsigned:
+		var x uint64
+		ok = d.Uint64(&x)
+		s.Uint64(x)
+	case MajorNegative:
+		var x int64
+		if !d.Int64(&x) {
+			/* NOTE(anton2920): negative integers below MinInt64 are valid CBOR. */
+			d.Error = nil
+			return d.Skip()
+		}
+		s.Int64(x)
+	case MajorBytes:
+		var x []byte
+		ok = d.ByteString(&x)
+		s.ByteString(x)
+	case MajorText:
+		var x string
+		ok = d.String(&x)
+		s.String(x)
+	case MajorArray:
+		d.ArrayBegin()
+		s.ArrayBegin()
+		for d.Next() {
+			if !copyValue(s, d) {
+				return false
+			}
+		}
+		s.ArrayEnd()
+		ok = d.ArrayEnd()
+	case MajorMap:
+		d.ObjectBegin()
+		for d.element(MajorMap) {
+			d.Skip()
+			d.Skip()
+		}
+		ok = d.ObjectEnd()
+	case MajorTag:
+		var tag uint64
+		ok = (d.Tag(&tag)) && (copyValue(s, d))
+	case MajorSimple:
+		ok = d.Skip()
+	}
+	return ok
+}
@@ -0,0 +1,359 @@
+package cbor
+
+import (
+	"math"
+	"sort"
+
+	"github.com/anton2920/gofa/bytes"
+	"github.com/anton2920/gofa/time"
+	"github.com/anton2920/gofa/trace/trace_"
+)
+
+/* Serializer writes CBOR (RFC 8949) into Buffer. It has the same API as json.Serializer, so the same code can produce both:
+ *     s.ObjectBegin()
+ *     s.Key("tags")
+ *     s.ArrayBegin()
+ *     s.String("a")
+ *     s.String("b")
+ *     s.ArrayEnd()
+ *     s.ObjectEnd()
+ * Maps and arrays are written with definite lengths, which are patched in ObjectEnd/ArrayEnd. Integers and floats always use the shortest form that preserves value. */
+type Serializer struct {
+	Buffer []byte
+
+	/* Canonical enables deterministic encoding (RFC 8949, section 4.2.1): map keys are sorted, so equal values produce equal bytes and can be hashed or signed. */
+	Canonical bool
+
+	Stack [MaxDepth]container
+	Depth int
+
+	/* Pairs are offsets of keys of open maps, used for sorting in canonical mode. */
+	Pairs []int
+}
+
+type container struct {
+	Offset int
+	Count  int
+	Major  byte
+
+	/* Pairs is the index of the first key of map in Serializer.Pairs. */
+	Pairs int
+}
+
+const (
+	MajorUnsigned = 0
+	MajorNegative = 1
+	MajorBytes    = 2
+	MajorText     = 3
+	MajorArray    = 4
+	MajorMap      = 5
+	MajorTag      = 6
+	MajorSimple   = 7
+)
+
+const (
+	SimpleFalse = 20
+	SimpleTrue  = 21
+	SimpleNull  = 22
+
+	InfoUint8      = 24
+	InfoUint16     = 25
+	InfoUint32     = 26
+	InfoUint64     = 27
+	InfoIndefinite = 31
+
+	Break = (MajorSimple << 5) | InfoIndefinite
+)
+
+const (
+	TagDateTime = 0
+	TagEpoch    = 1
+
+	TagSelfDescribe = 55799
+)
+
+const MaxDepth = 64
+
+/* putHead appends head of data item with major type and argument n in the shortest form. */
+func putHead(buf []byte, major byte, n uint64) []byte {
+	major <<= 5
+	switch {
+	case n < InfoUint8:
+		return append(buf, major|byte(n))
+	case n <= math.MaxUint8:
+		return append(buf, major|InfoUint8, byte(n))
+	case n <= math.MaxUint16:
+		return append(buf, major|InfoUint16, byte(n>>8), byte(n))
+	case n <= math.MaxUint32:
+		return append(buf, major|InfoUint32, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	default:
+		return append(buf, major|InfoUint64, byte(n>>56), byte(n>>48), byte(n>>40), byte(n>>32), byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+}
+
+/* float16 returns IEEE 754 half-precision representation of x, if it's exact. */
+func float16(x float32) (uint16, bool) {
+	bits := math.Float32bits(x)
+	sign := uint16(bits>>16) & 0x8000
+	exp := int((bits >> 23) & 0xFF)
+	mant := bits & 0x7FFFFF
+
+	switch {
+	case exp == 0xFF:
+		if mant != 0 {
+			/* NOTE(anton2920): NaN payload is not preserved, as required by deterministic encoding. */
+			return 0x7E00, true
+		}
+		return sign | 0x7C00, true
+	case (exp == 0) && (mant == 0):
+		return sign, true
+	}
+
+	e := exp - 127 + 15
+	if (e >= 1) && (e <= 30) {
+		if mant&0x1FFF != 0 {
+			return 0, false
+		}
+		return sign | uint16(e<<10) | uint16(mant>>13), true
+	}
+
+	/* Subnormal halves are m*2^-24 for m < 1024. */
+	if (exp >= 102) && (exp < 113) {
+		mant |= 0x800000
+		shift := uint(126 - exp)
+		if mant&((1<<shift)-1) != 0 {
+			return 0, false
+		}
+		return sign | uint16(mant>>shift), true
+	}
+
+	return 0, false
+}
+
+/* value is called before every value to count elements of arrays. */
+func (s *Serializer) value() {
+	if (s.Depth > 0) && (s.Stack[s.Depth-1].Major == MajorArray) {
+		s.Stack[s.Depth-1].Count++
+	}
+}
+
+func (s *Serializer) head(major byte, n uint64) {
+	s.Buffer = putHead(s.Buffer, major, n)
+}
+
+func (s *Serializer) push(major byte) {
+	if s.Depth == len(s.Stack) {
+		panic("cbor: nesting is too deep")
+	}
+	s.Stack[s.Depth] = container{Offset: len(s.Buffer), Major: major, Pairs: len(s.Pairs)}
+	s.Depth++
+
+	/* NOTE(anton2920): placeholder for the head, real count is known in pop. */
+	s.Buffer = append(s.Buffer, major<<5)
+}
+
+func (s *Serializer) pop(major byte) {
+	if (s.Depth == 0) || (s.Stack[s.Depth-1].Major != major) {
+		panic("cbor: unbalanced ObjectEnd/ArrayEnd")
+	}
+	s.Depth--
+	c := s.Stack[s.Depth]
+
+	if (s.Canonical) && (major == MajorMap) {
+		s.sortPairs(c.Offset+1, s.Pairs[c.Pairs:])
+	}
+	s.Pairs = s.Pairs[:c.Pairs]
+
+	var buf [9]byte
+	head := putHead(buf[:0], major, uint64(c.Count))
+	if extra := len(head) - 1; extra > 0 {
+		s.Buffer = append(s.Buffer, head[1:]...)
+		copy(s.Buffer[c.Offset+len(head):], s.Buffer[c.Offset+1:len(s.Buffer)-extra])
+	}
+	copy(s.Buffer[c.Offset:], head)
+}
+
+/* sortPairs sorts key/value pairs of map by encoded keys. Pairs are offsets of keys, the last pair ends at the end of Buffer. */
+func (s *Serializer) sortPairs(start int, pairs []int) {
+	if len(pairs) < 2 {
+		return
+	}
+
+	type pair struct {
+		Key   []byte
+		Value []byte
+	}
+	sorted := make([]pair, len(pairs))
+	for i := 0; i < len(pairs); i++ {
+		end := len(s.Buffer)
+		if i < len(pairs)-1 {
+			end = pairs[i+1]
+		}
+
+		/* Keys are always text strings written by Key. */
+		d := Deserializer{Buffer: s.Buffer[:end], Pos: pairs[i]}
+		d.str(MajorText)
+		sorted[i] = pair{Key: s.Buffer[pairs[i]:d.Pos], Value: s.Buffer[d.Pos:end]}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return bytes.AsString(sorted[i].Key) < bytes.AsString(sorted[j].Key)
+	})
+
+	body := make([]byte, 0, len(s.Buffer)-start)
+	for i := 0; i < len(sorted); i++ {
+		body = append(body, sorted[i].Key...)
+		body = append(body, sorted[i].Value...)
+	}
+	copy(s.Buffer[start:], body)
+}
+
+func (s *Serializer) Begin() {
+	s.Depth = 0
+	s.Pairs = s.Pairs[:0]
+}
+
+func (s *Serializer) End() {
+	if s.Depth != 0 {
+		panic("cbor: containers are not closed")
+	}
+}
+
+func (s *Serializer) ObjectBegin() {
+	s.value()
+	s.push(MajorMap)
+}
+
+func (s *Serializer) ObjectEnd() {
+	s.pop(MajorMap)
+}
+
+func (s *Serializer) ArrayBegin() {
+	s.value()
+	s.push(MajorArray)
+}
+
+func (s *Serializer) ArrayEnd() {
+	s.pop(MajorArray)
+}
+
+func (s *Serializer) Null() {
+	s.value()
+	s.head(MajorSimple, SimpleNull)
+}
+
+func (s *Serializer) Bool(b bool) {
+	s.value()
+	if b {
+		s.head(MajorSimple, SimpleTrue)
+	} else {
+		s.head(MajorSimple, SimpleFalse)
+	}
+}
+
+func (s *Serializer) Int32(x int32) {
+	s.Int64(int64(x))
+}
+
+func (s *Serializer) Uint32(x uint32) {
+	s.Uint64(uint64(x))
+}
+
+func (s *Serializer) Int(x int) {
+	s.Int64(int64(x))
+}
+
+func (s *Serializer) Int64(x int64) {
+	s.value()
+	if x >= 0 {
+		s.head(MajorUnsigned, uint64(x))
+	} else {
+		s.head(MajorNegative, uint64(-1-x))
+	}
+}
+
+func (s *Serializer) Uint64(x uint64) {
+	s.value()
+	s.head(MajorUnsigned, x)
+}
+
+func (s *Serializer) Float32(x float32) {
+	s.Float64(float64(x))
+}
+
+/* Float64 writes x as half, single or double precision float, whichever is the shortest exact one. */
+func (s *Serializer) Float64(x float64) {
+	s.value()
+
+	if f := float32(x); (float64(f) == x) || (math.IsNaN(x)) {
+		if h, ok := float16(f); ok {
+			s.Buffer = append(s.Buffer, (MajorSimple<<5)|InfoUint16, byte(h>>8), byte(h))
+		} else {
+			bits := math.Float32bits(f)
+			s.Buffer = append(s.Buffer, (MajorSimple<<5)|InfoUint32, byte(bits>>24), byte(bits>>16), byte(bits>>8), byte(bits))
+		}
+		return
+	}
+
+	bits := math.Float64bits(x)
+	s.Buffer = append(s.Buffer, (MajorSimple<<5)|InfoUint64, byte(bits>>56), byte(bits>>48), byte(bits>>40), byte(bits>>32), byte(bits>>24), byte(bits>>16), byte(bits>>8), byte(bits))
+}
+
+func (s *Serializer) String(str string) {
+	t := trace_.Begin("")
+
+	s.value()
+	s.head(MajorText, uint64(len(str)))
+	s.Buffer = append(s.Buffer, str...)
+
+	trace_.End(t)
+}
+
+func (s *Serializer) ByteString(b []byte) {
+	t := trace_.Begin("")
+
+	s.value()
+	s.head(MajorBytes, uint64(len(b)))
+	s.Buffer = append(s.Buffer, b...)
+
+	trace_.End(t)
+}
+
+/* Tag writes tag for the following value. */
+func (s *Serializer) Tag(tag uint64) {
+	s.head(MajorTag, tag)
+}
+
+/* Time writes t, which is the number of nanoseconds since epoch, as epoch-based date/time: integer if t is a whole number of seconds, float otherwise. */
+func (s *Serializer) Time(t int64) {
+	s.Tag(TagEpoch)
+	if t%time.Second == 0 {
+		s.Int64(t / time.Second)
+	} else {
+		s.Float64(float64(t) / time.Second)
+	}
+}
+
+func (s *Serializer) Key(key string) {
+	t := trace_.Begin("")
+
+	if (s.Depth == 0) || (s.Stack[s.Depth-1].Major != MajorMap) {
+		panic("cbor: Key outside of map")
+	}
+	s.Stack[s.Depth-1].Count++
+	if s.Canonical {
+		s.Pairs = append(s.Pairs, len(s.Buffer))
+	}
+	s.head(MajorText, uint64(len(key)))
+	s.Buffer = append(s.Buffer, key...)
+
+	trace_.End(t)
+}
+
+func (s *Serializer) Bytes() []byte {
+	return s.Buffer
+}
+
+func (s *Serializer) Reset() {
+	s.Buffer = s.Buffer[:0]
+	s.Begin()
+}
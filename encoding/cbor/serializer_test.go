@@ -0,0 +1,180 @@
+package cbor
+
+import (
+	"encoding/hex"
+	"math"
+	"testing"
+)
+
+/* Examples from RFC 8949, appendix A. */
+func TestSerializerRFC(t *testing.T) {
+	tests := [...]struct {
+		Write    func(s *Serializer)
+		Expected string
+	}{
+		{func(s *Serializer) { s.Uint64(0) }, "00"},
+		{func(s *Serializer) { s.Uint64(23) }, "17"},
+		{func(s *Serializer) { s.Uint64(24) }, "1818"},
+		{func(s *Serializer) { s.Int(1000) }, "1903e8"},
+		{func(s *Serializer) { s.Uint32(1000000) }, "1a000f4240"},
+		{func(s *Serializer) { s.Int64(1000000000000) }, "1b000000e8d4a51000"},
+		{func(s *Serializer) { s.Uint64(math.MaxUint64) }, "1bffffffffffffffff"},
+		{func(s *Serializer) { s.Int32(-1) }, "20"},
+		{func(s *Serializer) { s.Int32(-100) }, "3863"},
+		{func(s *Serializer) { s.Int64(-1000) }, "3903e7"},
+		{func(s *Serializer) { s.Int64(math.MinInt64) }, "3b7fffffffffffffff"},
+
+		{func(s *Serializer) { s.Float64(0) }, "f90000"},
+		{func(s *Serializer) { s.Float64(math.Copysign(0, -1)) }, "f98000"},
+		{func(s *Serializer) { s.Float64(1.5) }, "f93e00"},
+		{func(s *Serializer) { s.Float64(65504) }, "f97bff"},
+		{func(s *Serializer) { s.Float64(100000) }, "fa47c35000"},
+		{func(s *Serializer) { s.Float32(math.MaxFloat32) }, "fa7f7fffff"},
+		{func(s *Serializer) { s.Float64(1.1) }, "fb3ff199999999999a"},
+		{func(s *Serializer) { s.Float64(1.0e+300) }, "fb7e37e43c8800759c"},
+		{func(s *Serializer) { s.Float64(5.960464477539063e-8) }, "f90001"},
+		{func(s *Serializer) { s.Float64(0.00006103515625) }, "f90400"},
+		{func(s *Serializer) { s.Float64(-4.1) }, "fbc010666666666666"},
+		{func(s *Serializer) { s.Float64(math.Inf(1)) }, "f97c00"},
+		{func(s *Serializer) { s.Float64(math.Inf(-1)) }, "f9fc00"},
+		{func(s *Serializer) { s.Float64(math.NaN()) }, "f97e00"},
+
+		{func(s *Serializer) { s.Bool(false) }, "f4"},
+		{func(s *Serializer) { s.Bool(true) }, "f5"},
+		{func(s *Serializer) { s.Null() }, "f6"},
+
+		{func(s *Serializer) { s.String("") }, "60"},
+		{func(s *Serializer) { s.String("IETF") }, "6449455446"},
+		{func(s *Serializer) { s.String("\xc3\xbc") }, "62c3bc"},
+		{func(s *Serializer) { s.ByteString([]byte{1, 2, 3, 4}) }, "4401020304"},
+
+		{func(s *Serializer) { s.Time(1363896240 * 1000000000) }, "c11a514b67b0"},
+		{func(s *Serializer) { s.Time(1363896240*1000000000 + 500000000) }, "c1fb41d452d9ec200000"},
+
+		{func(s *Serializer) { s.ArrayBegin(); s.ArrayEnd() }, "80"},
+		{func(s *Serializer) {
+			s.ArrayBegin()
+			s.Int(1)
+			s.ArrayBegin()
+			s.Int(2)
+			s.Int(3)
+			s.ArrayEnd()
+			s.ArrayBegin()
+			s.Int(4)
+			s.Int(5)
+			s.ArrayEnd()
+			s.ArrayEnd()
+		}, "8301820203820405"},
+		{func(s *Serializer) {
+			s.ArrayBegin()
+			for i := 1; i <= 25; i++ {
+				s.Int(i)
+			}
+			s.ArrayEnd()
+		}, "98190102030405060708090a0b0c0d0e0f101112131415161718181819"},
+		{func(s *Serializer) { s.ObjectBegin(); s.ObjectEnd() }, "a0"},
+		{func(s *Serializer) {
+			s.ObjectBegin()
+			s.Key("a")
+			s.Int(1)
+			s.Key("b")
+			s.ArrayBegin()
+			s.Int(2)
+			s.Int(3)
+			s.ArrayEnd()
+			s.ObjectEnd()
+		}, "a26161016162820203"},
+		{func(s *Serializer) {
+			s.ArrayBegin()
+			s.String("a")
+			s.ObjectBegin()
+			s.Key("b")
+			s.String("c")
+			s.ObjectEnd()
+			s.ArrayEnd()
+		}, "826161a161626163"},
+	}
+
+	for i, test := range tests {
+		var s Serializer
+
+		s.Begin()
+		test.Write(&s)
+		s.End()
+
+		if got := hex.EncodeToString(s.Bytes()); got != test.Expected {
+			t.Errorf("%d: expected %s, got %s", i, test.Expected, got)
+		}
+	}
+}
+
+func TestSerializerCanonical(t *testing.T) {
+	write := func(s *Serializer, keys ...string) {
+		s.Begin()
+		s.ObjectBegin()
+		for _, key := range keys {
+			s.Key(key)
+			if key == "nested" {
+				s.ObjectBegin()
+				s.Key("z")
+				s.Null()
+				s.Key("y")
+				s.Null()
+				s.ObjectEnd()
+			} else {
+				s.String(key)
+			}
+		}
+		s.ObjectEnd()
+		s.End()
+	}
+
+	var s1, s2 Serializer
+	s1.Canonical = true
+	s2.Canonical = true
+
+	write(&s1, "b", "nested", "aa", "a")
+	write(&s2, "aa", "a", "b", "nested")
+
+	/* Shorter keys go first, because their heads are smaller. */
+	const expected = "a4" + "6161" + "6161" + "6162" + "6162" + "626161" + "626161" + "666e6573746564" + "a2" + "6179" + "f6" + "617a" + "f6"
+	if got := hex.EncodeToString(s1.Bytes()); got != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+	if got := hex.EncodeToString(s2.Bytes()); got != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+}
+
+func TestSerializerLargeContainer(t *testing.T) {
+	var s Serializer
+
+	s.ArrayBegin()
+	s.ArrayBegin()
+	for i := 0; i < 70000; i++ {
+		s.Null()
+	}
+	s.ArrayEnd()
+	s.String("end")
+	s.ArrayEnd()
+
+	d := Deserializer{Buffer: s.Bytes()}
+	d.Begin()
+	d.ArrayBegin()
+	d.Next()
+	d.ArrayBegin()
+	var n int
+	for d.Next() {
+		d.Null()
+		n++
+	}
+	d.ArrayEnd()
+
+	var str string
+	d.Next()
+	d.String(&str)
+	d.ArrayEnd()
+	if (!d.End()) || (n != 70000) || (str != "end") {
+		t.Errorf("Expected 70000 nulls and %q, got %d and %q (%v)", "end", n, str, d.Error)
+	}
+}
@@ -0,0 +1,678 @@
+/* Generates wire, JSON and CBOR serializers for annotated struct types. Usage:
+ *     //go:generate go run github.com/anton2920/gofa/encoding/cmd $GOFILE
+ *
+ *     //encoding:generate: wire json
+ *     //encoding:version: 2
+ *     type User struct {
+ *         ID    database.ID
+ *         Name  string
+ *         Tags  []string //encoding:name: tags
+ *         Cache []byte   //encoding:skip
+ *         Email string   //encoding:since: 2
+ *     }
+ *
+ * Type annotations:
+ *     generate: list of encodings ('wire', 'json', 'cbor'), wire and json if empty;
+ *     version: version written by SerializeWire, 0 by default.
+ * Field annotations:
+ *     since: version field was added in, DeserializeWire leaves it zero for older data. Fields can only be added to the end, so since can't decrease;
+ *     name: JSON/CBOR key, field name by default;
+ *     type: basic type for named types generator cannot resolve, e.g. 'int32' for 'pkg.ID';
+ *     skip: field is not serialized.
+ * Generated code for 'file.go' goes to 'file_encoding.go'. */
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	KindBasic = iota
+	KindBytes
+	KindStruct
+	KindSlice
+	KindArray
+)
+
+type Type struct {
+	Kind int
+
+	/* Name is type as written in source. */
+	Name string
+
+	/* Basic is underlying type of KindBasic. */
+	Basic string
+
+	Elem *Type
+}
+
+type Field struct {
+	Name  string
+	Key   string
+	Since int
+	Type  *Type
+}
+
+type Struct struct {
+	Name      string
+	Version   int
+	Wire      bool
+	Documents []*Document
+	Fields    []Field
+}
+
+/* Document is encoding with streaming API of json.Serializer and json.Deserializer. */
+type Document struct {
+	/* Name is a suffix of generated methods. */
+	Name    string
+	Package string
+
+	/* Bytes is method for []byte. */
+	Bytes string
+}
+
+var (
+	JSON = Document{Name: "JSON", Package: "json", Bytes: "Base64"}
+	CBOR = Document{Name: "CBOR", Package: "cbor", Bytes: "ByteString"}
+)
+
+const annotationPrefix = "//encoding:"
+
+var basicTypes = map[string]string{
+	"bool": "bool",
+
+	"int8":  "int8",
+	"int16": "int16",
+	"int32": "int32",
+	"int64": "int64",
+	"int":   "int",
+
+	"uint8":  "uint8",
+	"byte":   "uint8",
+	"uint16": "uint16",
+	"uint32": "uint32",
+	"uint64": "uint64",
+	"uint":   "uint",
+
+	"float32": "float32",
+	"float64": "float64",
+
+	"string": "string",
+}
+
+/* knownTypes are named types from other packages that are commonly used in records. */
+var knownTypes = map[string]string{
+	"database.ID": "int32",
+}
+
+/* Methods of wire.Serializer/Deserializer and types they take for every basic type. */
+var wireMethods = map[string][2]string{
+	"bool":    {"Bool", "bool"},
+	"int8":    {"Int8", "int8"},
+	"int16":   {"Int16", "int16"},
+	"int32":   {"Int32", "int32"},
+	"int64":   {"Int64", "int64"},
+	"int":     {"Int", "int"},
+	"uint8":   {"Uint8", "uint8"},
+	"uint16":  {"Uint16", "uint16"},
+	"uint32":  {"Uint32", "uint32"},
+	"uint64":  {"Uint64", "uint64"},
+	"uint":    {"Uint64", "uint64"},
+	"float32": {"Float32", "float32"},
+	"float64": {"Float64", "float64"},
+	"string":  {"String", "string"},
+}
+
+/* Methods of json.Serializer and json.Deserializer (and other documents) and types they take for every basic type. */
+var jsonMethods = map[string][3]string{
+	"bool":    {"Bool", "Bool", "bool"},
+	"int8":    {"Int32", "Int32", "int32"},
+	"int16":   {"Int32", "Int32", "int32"},
+	"int32":   {"Int32", "Int32", "int32"},
+	"int64":   {"Int64", "Int64", "int64"},
+	"int":     {"Int64", "Int64", "int64"},
+	"uint8":   {"Uint32", "Uint32", "uint32"},
+	"uint16":  {"Uint32", "Uint32", "uint32"},
+	"uint32":  {"Uint32", "Uint32", "uint32"},
+	"uint64":  {"Uint64", "Uint64", "uint64"},
+	"uint":    {"Uint64", "Uint64", "uint64"},
+	"float32": {"Float32", "Float64", "float64"},
+	"float64": {"Float64", "Float64", "float64"},
+	"string":  {"String", "String", "string"},
+}
+
+/* Methods of json.Deserializer (and other documents), types they take and bounds they are called with for basic types, which are narrower than in jsonMethods, so that out of range values are rejected instead of being truncated. */
+var jsonRanges = map[string][3]string{
+	"int8":   {"IntRange", "int64", "-128, 127"},
+	"int16":  {"IntRange", "int64", "-32768, 32767"},
+	"int":    {"Int", "int", ""},
+	"uint8":  {"UintRange", "uint64", "255"},
+	"uint16": {"UintRange", "uint64", "65535"},
+	"uint":   {"Uint", "uint", ""},
+}
+
+/* Annotations returns values of '//encoding:' annotations from comment groups. */
+func Annotations(groups ...*ast.CommentGroup) map[string]string {
+	result := make(map[string]string)
+
+	for _, group := range groups {
+		if group == nil {
+			continue
+		}
+		for _, comment := range group.List {
+			if !strings.HasPrefix(comment.Text, annotationPrefix) {
+				continue
+			}
+			annotation := comment.Text[len(annotationPrefix):]
+
+			name, value, _ := strings.Cut(annotation, ":")
+			result[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+	}
+
+	return result
+}
+
+/* NamedBasicTypes returns named types with basic underlying types declared in files of package. */
+func NamedBasicTypes(files []*ast.File) map[string]string {
+	result := make(map[string]string)
+
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if (!ok) || (gen.Tok != token.TYPE) {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				spec := spec.(*ast.TypeSpec)
+				if ident, ok := spec.Type.(*ast.Ident); ok {
+					if basic, ok := basicTypes[ident.Name]; ok {
+						result[spec.Name.Name] = basic
+					}
+				}
+			}
+		}
+	}
+
+	return result
+}
+
+func ResolveType(expr ast.Expr, named map[string]string, override string) (*Type, error) {
+	t := &Type{Name: types.ExprString(expr)}
+
+	switch expr := expr.(type) {
+	case *ast.Ident, *ast.SelectorExpr:
+		basic, ok := basicTypes[t.Name]
+		if !ok {
+			basic, ok = named[t.Name]
+		}
+		if !ok {
+			basic, ok = knownTypes[t.Name]
+		}
+		if override != "" {
+			basic, ok = basicTypes[override]
+			if !ok {
+				return nil, fmt.Errorf("unsupported type override %q", override)
+			}
+		}
+		if ok {
+			t.Kind = KindBasic
+			t.Basic = basic
+		} else {
+			/* NOTE(anton2920): everything else is assumed to be struct with generated methods. */
+			t.Kind = KindStruct
+		}
+	case *ast.ArrayType:
+		elem, err := ResolveType(expr.Elt, named, "")
+		if err != nil {
+			return nil, err
+		}
+		t.Elem = elem
+
+		switch {
+		case expr.Len != nil:
+			t.Kind = KindArray
+		case (elem.Kind == KindBasic) && (elem.Basic == "uint8") && (elem.Name == "byte" || elem.Name == "uint8"):
+			t.Kind = KindBytes
+		default:
+			t.Kind = KindSlice
+		}
+	default:
+		return nil, fmt.Errorf("unsupported type %s", t.Name)
+	}
+
+	return t, nil
+}
+
+func ParseStruct(spec *ast.TypeSpec, doc *ast.CommentGroup, named map[string]string) (*Struct, error) {
+	annotations := Annotations(doc, spec.Doc)
+	encodings, ok := annotations["generate"]
+	if !ok {
+		return nil, nil
+	}
+
+	st, ok := spec.Type.(*ast.StructType)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a struct", spec.Name.Name)
+	}
+
+	s := &Struct{Name: spec.Name.Name}
+	for _, encoding := range strings.Fields(encodings) {
+		switch encoding {
+		default:
+			return nil, fmt.Errorf("%s: unknown encoding %q", s.Name, encoding)
+		case "wire":
+			s.Wire = true
+		case "json":
+			s.Documents = append(s.Documents, &JSON)
+		case "cbor":
+			s.Documents = append(s.Documents, &CBOR)
+		}
+	}
+	if (!s.Wire) && (len(s.Documents) == 0) {
+		s.Wire = true
+		s.Documents = append(s.Documents, &JSON)
+	}
+
+	if version, ok := annotations["version"]; ok {
+		v, err := strconv.Atoi(version)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid version %q: %v", s.Name, version, err)
+		}
+		s.Version = v
+	}
+
+	for _, field := range st.Fields.List {
+		annotations := Annotations(field.Doc, field.Comment)
+		if _, ok := annotations["skip"]; ok {
+			continue
+		}
+		if len(field.Names) == 0 {
+			return nil, fmt.Errorf("%s: embedded fields are not supported", s.Name)
+		}
+
+		t, err := ResolveType(field.Type, named, annotations["type"])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", s.Name, err)
+		}
+
+		var since int
+		if value, ok := annotations["since"]; ok {
+			since, err = strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid version %q: %v", s.Name, value, err)
+			}
+			if since > s.Version {
+				return nil, fmt.Errorf("%s: field is added in version %d, but struct version is %d", s.Name, since, s.Version)
+			}
+		}
+
+		for _, name := range field.Names {
+			if !name.IsExported() {
+				continue
+			}
+			key := name.Name
+			if value, ok := annotations["name"]; ok {
+				key = value
+			}
+			/* NOTE(anton2920): wire format has no field tags, so older data can only be read if new fields are appended. */
+			if last := len(s.Fields) - 1; (last >= 0) && (since < s.Fields[last].Since) {
+				return nil, fmt.Errorf("%s: field %s is added in version %d after field %s added in version %d, new fields must be added to the end", s.Name, name.Name, since, s.Fields[last].Name, s.Fields[last].Since)
+			}
+			s.Fields = append(s.Fields, Field{Name: name.Name, Key: key, Since: since, Type: t})
+		}
+	}
+
+	return s, nil
+}
+
+/* Packages returns names of packages referenced by types of fields. */
+func (t *Type) Packages(result map[string]struct{}) {
+	if pkg, _, ok := strings.Cut(t.Name, "."); (ok) && (t.Kind != KindSlice) && (t.Kind != KindArray) {
+		result[strings.TrimLeft(pkg, "[]*0123456789")] = struct{}{}
+	}
+	if t.Elem != nil {
+		t.Elem.Packages(result)
+	}
+}
+
+/* Versioned reports whether deserializer needs to check version. */
+func (s *Struct) Versioned() bool {
+	for _, field := range s.Fields {
+		if field.Since > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func Indent(b *bytes.Buffer, depth int) {
+	for i := 0; i < depth; i++ {
+		b.WriteByte('\t')
+	}
+}
+
+func Line(b *bytes.Buffer, depth int, format string, args ...interface{}) {
+	Indent(b, depth)
+	fmt.Fprintf(b, format, args...)
+	b.WriteByte('\n')
+}
+
+func SerializeWire(b *bytes.Buffer, depth int, expr string, t *Type) {
+	switch t.Kind {
+	case KindBasic:
+		method := wireMethods[t.Basic]
+		if t.Name == method[1] {
+			Line(b, depth, "s.%s(%s)", method[0], expr)
+		} else {
+			Line(b, depth, "s.%s(%s(%s))", method[0], method[1], expr)
+		}
+	case KindBytes:
+		Line(b, depth, "s.Bytes(%s)", expr)
+	case KindStruct:
+		Line(b, depth, "%s.SerializeWire(s)", expr)
+	case KindSlice, KindArray:
+		i := fmt.Sprintf("i%d", depth)
+		if t.Kind == KindSlice {
+			Line(b, depth, "s.Uint32(uint32(len(%s)))", expr)
+		}
+		Line(b, depth, "for %s := 0; %s < len(%s); %s++ {", i, i, expr, i)
+		SerializeWire(b, depth+1, fmt.Sprintf("%s[%s]", expr, i), t.Elem)
+		Line(b, depth, "}")
+	}
+}
+
+func DeserializeWire(b *bytes.Buffer, depth int, expr string, t *Type) {
+	switch t.Kind {
+	case KindBasic:
+		method := wireMethods[t.Basic]
+		if t.Name == method[1] {
+			Line(b, depth, "%s = d.%s()", expr, method[0])
+		} else {
+			Line(b, depth, "%s = %s(d.%s())", expr, t.Name, method[0])
+		}
+	case KindBytes:
+		Line(b, depth, "%s = append(%s[:0], d.Bytes()...)", expr, expr)
+	case KindStruct:
+		Line(b, depth, "if err := %s.DeserializeWire(d); err != nil {", expr)
+		Line(b, depth+1, "return err")
+		Line(b, depth, "}")
+	case KindSlice, KindArray:
+		i := fmt.Sprintf("i%d", depth)
+		if t.Kind == KindSlice {
+			Line(b, depth, "%s = make(%s, d.Length())", expr, t.Name)
+		}
+		Line(b, depth, "for %s := 0; %s < len(%s); %s++ {", i, i, expr, i)
+		DeserializeWire(b, depth+1, fmt.Sprintf("%s[%s]", expr, i), t.Elem)
+		Line(b, depth, "}")
+	}
+}
+
+func SerializeDocument(b *bytes.Buffer, doc *Document, depth int, expr string, t *Type) {
+	switch t.Kind {
+	case KindBasic:
+		method := jsonMethods[t.Basic]
+		if (t.Name == method[2]) || (t.Basic == "float32") && (t.Name == "float32") {
+			Line(b, depth, "s.%s(%s)", method[0], expr)
+		} else if t.Basic == "float32" {
+			Line(b, depth, "s.%s(float32(%s))", method[0], expr)
+		} else {
+			Line(b, depth, "s.%s(%s(%s))", method[0], method[2], expr)
+		}
+	case KindBytes:
+		Line(b, depth, "s.%s(%s)", doc.Bytes, expr)
+	case KindStruct:
+		Line(b, depth, "%s.Serialize%s(s)", expr, doc.Name)
+	case KindSlice, KindArray:
+		i := fmt.Sprintf("i%d", depth)
+		Line(b, depth, "s.ArrayBegin()")
+		Line(b, depth, "for %s := 0; %s < len(%s); %s++ {", i, i, expr, i)
+		SerializeDocument(b, doc, depth+1, fmt.Sprintf("%s[%s]", expr, i), t.Elem)
+		Line(b, depth, "}")
+		Line(b, depth, "s.ArrayEnd()")
+	}
+}
+
+func DeserializeDocument(b *bytes.Buffer, doc *Document, depth int, expr string, t *Type) {
+	switch t.Kind {
+	case KindBasic:
+		var args string
+
+		method, typ := jsonMethods[t.Basic][1], jsonMethods[t.Basic][2]
+		if check, ok := jsonRanges[t.Basic]; ok {
+			method, typ = check[0], check[1]
+			if len(check[2]) > 0 {
+				args = ", " + check[2]
+			}
+		}
+
+		if t.Name == typ {
+			Line(b, depth, "d.%s(&%s%s)", method, expr, args)
+		} else {
+			tmp := fmt.Sprintf("tmp%d", depth)
+			Line(b, depth, "var %s %s", tmp, typ)
+			Line(b, depth, "d.%s(&%s%s)", method, tmp, args)
+			Line(b, depth, "%s = %s(%s)", expr, t.Name, tmp)
+		}
+	case KindBytes:
+		Line(b, depth, "d.%s(&%s)", doc.Bytes, expr)
+	case KindStruct:
+		Line(b, depth, "%s.Deserialize%s(d)", expr, doc.Name)
+	case KindSlice:
+		e := fmt.Sprintf("e%d", depth)
+		Line(b, depth, "%s = %s[:0]", expr, expr)
+		Line(b, depth, "d.ArrayBegin()")
+		Line(b, depth, "for d.Next() {")
+		Line(b, depth+1, "var %s %s", e, t.Elem.Name)
+		DeserializeDocument(b, doc, depth+1, e, t.Elem)
+		Line(b, depth+1, "%s = append(%s, %s)", expr, expr, e)
+		Line(b, depth, "}")
+		Line(b, depth, "d.ArrayEnd()")
+	case KindArray:
+		i := fmt.Sprintf("i%d", depth)
+		Line(b, depth, "d.ArrayBegin()")
+		Line(b, depth, "for %s := 0; d.Next(); %s++ {", i, i)
+		Line(b, depth+1, "if %s >= len(%s) {", i, expr)
+		Line(b, depth+2, "d.Skip()")
+		Line(b, depth+2, "continue")
+		Line(b, depth+1, "}")
+		DeserializeDocument(b, doc, depth+1, fmt.Sprintf("%s[%s]", expr, i), t.Elem)
+		Line(b, depth, "}")
+		Line(b, depth, "d.ArrayEnd()")
+	}
+}
+
+func Generate(b *bytes.Buffer, s *Struct) {
+	if s.Wire {
+		fmt.Fprintf(b, "\nfunc (x *%s) SerializeWire(s *wire.Serializer) {\n", s.Name)
+		Line(b, 1, "s.Begin(%d)", s.Version)
+		for _, field := range s.Fields {
+			SerializeWire(b, 1, "x."+field.Name, field.Type)
+		}
+		Line(b, 1, "s.End()")
+		b.WriteString("}\n")
+
+		fmt.Fprintf(b, "\nfunc (x *%s) DeserializeWire(d *wire.Deserializer) error {\n", s.Name)
+		/* NOTE(anton2920): fields from newer versions are skipped by d.End(). */
+		if s.Versioned() {
+			Line(b, 1, "version := d.Begin()")
+		} else {
+			Line(b, 1, "d.Begin()")
+		}
+		for _, field := range s.Fields {
+			if field.Since > 0 {
+				Line(b, 1, "if version >= %d {", field.Since)
+				DeserializeWire(b, 2, "x."+field.Name, field.Type)
+				Line(b, 1, "}")
+			} else {
+				DeserializeWire(b, 1, "x."+field.Name, field.Type)
+			}
+		}
+		Line(b, 1, "return d.End()")
+		b.WriteString("}\n")
+	}
+
+	for _, doc := range s.Documents {
+		fmt.Fprintf(b, "\nfunc (x *%s) Serialize%s(s *%s.Serializer) {\n", s.Name, doc.Name, doc.Package)
+		Line(b, 1, "s.ObjectBegin()")
+		for _, field := range s.Fields {
+			Line(b, 1, "s.Key(%q)", field.Key)
+			SerializeDocument(b, doc, 1, "x."+field.Name, field.Type)
+		}
+		Line(b, 1, "s.ObjectEnd()")
+		b.WriteString("}\n")
+
+		fmt.Fprintf(b, "\nfunc (x *%s) Deserialize%s(d *%s.Deserializer) bool {\n", s.Name, doc.Name, doc.Package)
+		Line(b, 1, "var key string")
+		b.WriteString("\n")
+		Line(b, 1, "d.ObjectBegin()")
+		Line(b, 1, "for d.Key(&key) {")
+		Line(b, 2, "switch key {")
+		for _, field := range s.Fields {
+			Line(b, 2, "case %q:", field.Key)
+			DeserializeDocument(b, doc, 3, "x."+field.Name, field.Type)
+		}
+		Line(b, 2, "default:")
+		Line(b, 3, "d.Skip()")
+		Line(b, 2, "}")
+		Line(b, 1, "}")
+		Line(b, 1, "return d.ObjectEnd()")
+		b.WriteString("}\n")
+	}
+}
+
+func GenerateFile(path string) error {
+	fset := token.NewFileSet()
+
+	/* Named types may be declared in any file of package. */
+	var files []*ast.File
+	matches, err := filepath.Glob(filepath.Join(filepath.Dir(path), "*.go"))
+	if err != nil {
+		return err
+	}
+	for _, match := range matches {
+		if (strings.HasSuffix(match, "_test.go")) || (strings.HasSuffix(match, "_encoding.go")) {
+			continue
+		}
+		file, err := parser.ParseFile(fset, match, nil, parser.ParseComments)
+		if err != nil {
+			return err
+		}
+		files = append(files, file)
+	}
+	named := NamedBasicTypes(files)
+
+	file, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+	if err != nil {
+		return err
+	}
+
+	var structs []*Struct
+	var wire bool
+	documents := make(map[string]struct{})
+	packages := make(map[string]struct{})
+
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if (!ok) || (gen.Tok != token.TYPE) {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			doc := gen.Doc
+			if len(gen.Specs) > 1 {
+				doc = nil
+			}
+
+			s, err := ParseStruct(spec.(*ast.TypeSpec), doc, named)
+			if err != nil {
+				return fmt.Errorf("%s: %v", fset.Position(spec.Pos()), err)
+			}
+			if s == nil {
+				continue
+			}
+			structs = append(structs, s)
+
+			wire = wire || s.Wire
+			for _, doc := range s.Documents {
+				documents[doc.Package] = struct{}{}
+			}
+			for _, field := range s.Fields {
+				field.Type.Packages(packages)
+			}
+		}
+	}
+	if len(structs) == 0 {
+		return fmt.Errorf("%s: no types annotated with '%sgenerate'", path, annotationPrefix)
+	}
+
+	imports := make([]string, 0, len(packages)+len(documents)+1)
+	if wire {
+		imports = append(imports, strconv.Quote("github.com/anton2920/gofa/encoding/wire"))
+	}
+	for pkg := range documents {
+		imports = append(imports, strconv.Quote("github.com/anton2920/gofa/encoding/"+pkg))
+	}
+	for _, spec := range file.Imports {
+		name := strings.Trim(spec.Path.Value, `"`)
+		name = name[strings.LastIndexByte(name, '/')+1:]
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		if _, ok := packages[name]; ok {
+			imports = append(imports, types.ExprString(spec.Path))
+			if spec.Name != nil {
+				imports[len(imports)-1] = spec.Name.Name + " " + imports[len(imports)-1]
+			}
+		}
+	}
+	sort.Strings(imports)
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "/* File generated by 'encoding/cmd/generate.go'; DO NOT EDIT! */\n")
+	fmt.Fprintf(&b, "package %s\n\n", file.Name.Name)
+	b.WriteString("import (\n")
+	for _, imp := range imports {
+		fmt.Fprintf(&b, "\t%s\n", imp)
+	}
+	b.WriteString(")\n")
+
+	for _, s := range structs {
+		Generate(&b, s)
+	}
+
+	source, err := format.Source(b.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to format generated code: %v\n%s", err, b.Bytes())
+	}
+
+	output := strings.TrimSuffix(path, ".go") + "_encoding.go"
+	if err := os.WriteFile(output, source, 0644); err != nil {
+		return fmt.Errorf("failed to write generated file: %v", err)
+	}
+
+	return nil
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		log.Fatalf("Usage: %s file.go...", os.Args[0])
+	}
+
+	for _, path := range os.Args[1:] {
+		if err := GenerateFile(path); err != nil {
+			log.Fatalf("Failed to generate serializers for %q: %v", path, err)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+func TestGenerateFile(t *testing.T) {
+	source, err := os.ReadFile(filepath.Join("testdata", "sample.go"))
+	if err != nil {
+		t.Fatalf("Failed to read sample: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "sample.go")
+	if err := os.WriteFile(path, source, 0644); err != nil {
+		t.Fatalf("Failed to write sample: %v", err)
+	}
+	if err := GenerateFile(path); err != nil {
+		t.Fatalf("Failed to generate: %v", err)
+	}
+
+	generated, err := os.ReadFile(filepath.Join(filepath.Dir(path), "sample_encoding.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated file: %v", err)
+	}
+
+	golden := filepath.Join("testdata", "sample_encoding.go")
+	if *update {
+		if err := os.WriteFile(golden, generated, 0644); err != nil {
+			t.Fatalf("Failed to update golden file: %v", err)
+		}
+	}
+	expected, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("Failed to read golden file: %v", err)
+	}
+	if !bytes.Equal(generated, expected) {
+		t.Errorf("Generated code differs from %s, run 'go test -update' if change is intended:\n%s", golden, generated)
+	}
+}
+
+func TestGenerateRange(t *testing.T) {
+	const source = `package main
+
+import (
+	"fmt"
+
+	"github.com/anton2920/gofa/encoding/cbor"
+	"github.com/anton2920/gofa/encoding/json"
+)
+
+//encoding:generate: json cbor
+type Narrow struct {
+	A int8
+	B uint8
+	C int16
+	D uint16
+}
+
+func main() {
+	var s cbor.Serializer
+
+	for _, input := range []string{
+		` + "`" + `{"A": -128, "B": 255, "C": -32768, "D": 65535}` + "`" + `,
+		` + "`" + `{"A": 128}` + "`" + `,
+		` + "`" + `{"B": 300}` + "`" + `,
+		` + "`" + `{"C": 32768}` + "`" + `,
+		` + "`" + `{"D": -1}` + "`" + `,
+	} {
+		var x Narrow
+
+		d := json.Deserializer{Buffer: []byte(input)}
+		d.Begin()
+		x.DeserializeJSON(&d)
+		fmt.Println(d.End(), x)
+	}
+
+	s.Begin()
+	s.ObjectBegin()
+	s.Key("B")
+	s.Uint64(300)
+	s.ObjectEnd()
+	s.End()
+
+	var x Narrow
+	d := cbor.Deserializer{Buffer: s.Bytes()}
+	d.Begin()
+	x.DeserializeCBOR(&d)
+	fmt.Println(d.End(), x)
+}
+`
+	const expected = "true {-128 255 -32768 65535}\nfalse {0 0 0 0}\nfalse {0 0 0 0}\nfalse {0 0 0 0}\nfalse {0 0 0 0}\nfalse {0 0 0 0}\n"
+
+	gocmd, err := exec.LookPath("go")
+	if err != nil {
+		t.Skipf("No go command: %v", err)
+	}
+
+	/* NOTE(anton2920): generated code must be built inside of module to import its packages, '_' prefix hides it from './...'. */
+	dir, err := os.MkdirTemp(".", "_range")
+	if err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(path, []byte(source), 0644); err != nil {
+		t.Fatalf("Failed to write source: %v", err)
+	}
+	if err := GenerateFile(path); err != nil {
+		t.Fatalf("Failed to generate: %v", err)
+	}
+
+	output, err := exec.Command(gocmd, "run", "./"+dir).CombinedOutput()
+	if err != nil {
+		t.Fatalf("Failed to run generated code: %v\n%s", err, output)
+	}
+	if string(output) != expected {
+		t.Errorf("Expected out of range values to be rejected:\n%s\ngot:\n%s", expected, output)
+	}
+}
+
+func TestParseStruct(t *testing.T) {
+	tests := [...]struct {
+		Source string
+		Error  string
+	}{
+		{"//encoding:version: 2\ntype T struct {\n\tA int\n\tB int //encoding:since: 1\n\tC int //encoding:since: 2\n}", ""},
+		{"//encoding:version: 2\ntype T struct {\n\tA int //encoding:since: 1\n\tB int //encoding:since: 1\n}", ""},
+		{"//encoding:version: 2\ntype T struct {\n\tA int //encoding:since: 2\n\tb int\n\tC int //encoding:skip\n}", ""},
+		{"//encoding:version: 2\ntype T struct {\n\tA int //encoding:since: 2\n\tB int //encoding:since: 1\n}", "field B is added in version 1 after field A added in version 2"},
+		{"//encoding:version: 1\ntype T struct {\n\tA int //encoding:since: 1\n\tB int\n}", "field B is added in version 0 after field A added in version 1"},
+		{"type T struct {\n\tA int //encoding:since: 1\n}", "field is added in version 1, but struct version is 0"},
+		{"type T struct {\n\tA int //encoding:since: x\n}", "invalid version"},
+		{"//encoding:generate: xml\ntype T struct {\n\tA int\n}", "unknown encoding"},
+		{"type T struct {\n\tError\n}", "embedded fields are not supported"},
+		{"type T int", "T is not a struct"},
+	}
+	for _, test := range tests {
+		source := "package p\n\n"
+		if !strings.Contains(test.Source, "encoding:generate") {
+			source += "//encoding:generate: wire\n"
+		}
+		source += test.Source + "\n"
+
+		file, err := parser.ParseFile(token.NewFileSet(), "p.go", source, parser.ParseComments)
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %v", test.Source, err)
+		}
+		gen := file.Decls[0].(*ast.GenDecl)
+
+		_, err = ParseStruct(gen.Specs[0].(*ast.TypeSpec), gen.Doc, nil)
+		if len(test.Error) == 0 {
+			if err != nil {
+				t.Errorf("%q: unexpected error %v", test.Source, err)
+			}
+		} else if (err == nil) || (!strings.Contains(err.Error(), test.Error)) {
+			t.Errorf("%q: expected error containing %q, got %v", test.Source, test.Error, err)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package sample
+
+import "github.com/anton2920/gofa/database"
+
+type Role int32
+
+//encoding:generate: wire json cbor
+type Point struct {
+	X, Y float32
+}
+
+//encoding:generate: wire json cbor
+//encoding:version: 2
+type User struct {
+	ID     database.ID
+	Name   string
+	Role   Role
+	Age    int
+	Ratio  float64
+	Flags  uint8
+	OK     bool
+	Tags   []string //encoding:name: tags
+	Cache  []byte   //encoding:skip
+	Data   []byte
+	Where  Point
+	Path   []Point
+	Grid   [2][3]int16
+	Big    uint64
+	hidden int
+	Email  string //encoding:since: 2
+}
@@ -0,0 +1,403 @@
+/* File generated by 'encoding/cmd/generate.go'; DO NOT EDIT! */
+package sample
+
+import (
+	"github.com/anton2920/gofa/database"
+	"github.com/anton2920/gofa/encoding/cbor"
+	"github.com/anton2920/gofa/encoding/json"
+	"github.com/anton2920/gofa/encoding/wire"
+)
+
+func (x *Point) SerializeWire(s *wire.Serializer) {
+	s.Begin(0)
+	s.Float32(x.X)
+	s.Float32(x.Y)
+	s.End()
+}
+
+func (x *Point) DeserializeWire(d *wire.Deserializer) error {
+	d.Begin()
+	x.X = d.Float32()
+	x.Y = d.Float32()
+	return d.End()
+}
+
+func (x *Point) SerializeJSON(s *json.Serializer) {
+	s.ObjectBegin()
+	s.Key("X")
+	s.Float32(x.X)
+	s.Key("Y")
+	s.Float32(x.Y)
+	s.ObjectEnd()
+}
+
+func (x *Point) DeserializeJSON(d *json.Deserializer) bool {
+	var key string
+
+	d.ObjectBegin()
+	for d.Key(&key) {
+		switch key {
+		case "X":
+			var tmp3 float64
+			d.Float64(&tmp3)
+			x.X = float32(tmp3)
+		case "Y":
+			var tmp3 float64
+			d.Float64(&tmp3)
+			x.Y = float32(tmp3)
+		default:
+			d.Skip()
+		}
+	}
+	return d.ObjectEnd()
+}
+
+func (x *Point) SerializeCBOR(s *cbor.Serializer) {
+	s.ObjectBegin()
+	s.Key("X")
+	s.Float32(x.X)
+	s.Key("Y")
+	s.Float32(x.Y)
+	s.ObjectEnd()
+}
+
+func (x *Point) DeserializeCBOR(d *cbor.Deserializer) bool {
+	var key string
+
+	d.ObjectBegin()
+	for d.Key(&key) {
+		switch key {
+		case "X":
+			var tmp3 float64
+			d.Float64(&tmp3)
+			x.X = float32(tmp3)
+		case "Y":
+			var tmp3 float64
+			d.Float64(&tmp3)
+			x.Y = float32(tmp3)
+		default:
+			d.Skip()
+		}
+	}
+	return d.ObjectEnd()
+}
+
+func (x *User) SerializeWire(s *wire.Serializer) {
+	s.Begin(2)
+	s.Int32(int32(x.ID))
+	s.String(x.Name)
+	s.Int32(int32(x.Role))
+	s.Int(x.Age)
+	s.Float64(x.Ratio)
+	s.Uint8(x.Flags)
+	s.Bool(x.OK)
+	s.Uint32(uint32(len(x.Tags)))
+	for i1 := 0; i1 < len(x.Tags); i1++ {
+		s.String(x.Tags[i1])
+	}
+	s.Bytes(x.Data)
+	x.Where.SerializeWire(s)
+	s.Uint32(uint32(len(x.Path)))
+	for i1 := 0; i1 < len(x.Path); i1++ {
+		x.Path[i1].SerializeWire(s)
+	}
+	for i1 := 0; i1 < len(x.Grid); i1++ {
+		for i2 := 0; i2 < len(x.Grid[i1]); i2++ {
+			s.Int16(x.Grid[i1][i2])
+		}
+	}
+	s.Uint64(x.Big)
+	s.String(x.Email)
+	s.End()
+}
+
+func (x *User) DeserializeWire(d *wire.Deserializer) error {
+	version := d.Begin()
+	x.ID = database.ID(d.Int32())
+	x.Name = d.String()
+	x.Role = Role(d.Int32())
+	x.Age = d.Int()
+	x.Ratio = d.Float64()
+	x.Flags = d.Uint8()
+	x.OK = d.Bool()
+	x.Tags = make([]string, d.Length())
+	for i1 := 0; i1 < len(x.Tags); i1++ {
+		x.Tags[i1] = d.String()
+	}
+	x.Data = append(x.Data[:0], d.Bytes()...)
+	if err := x.Where.DeserializeWire(d); err != nil {
+		return err
+	}
+	x.Path = make([]Point, d.Length())
+	for i1 := 0; i1 < len(x.Path); i1++ {
+		if err := x.Path[i1].DeserializeWire(d); err != nil {
+			return err
+		}
+	}
+	for i1 := 0; i1 < len(x.Grid); i1++ {
+		for i2 := 0; i2 < len(x.Grid[i1]); i2++ {
+			x.Grid[i1][i2] = d.Int16()
+		}
+	}
+	x.Big = d.Uint64()
+	if version >= 2 {
+		x.Email = d.String()
+	}
+	return d.End()
+}
+
+func (x *User) SerializeJSON(s *json.Serializer) {
+	s.ObjectBegin()
+	s.Key("ID")
+	s.Int32(int32(x.ID))
+	s.Key("Name")
+	s.String(x.Name)
+	s.Key("Role")
+	s.Int32(int32(x.Role))
+	s.Key("Age")
+	s.Int64(int64(x.Age))
+	s.Key("Ratio")
+	s.Float64(x.Ratio)
+	s.Key("Flags")
+	s.Uint32(uint32(x.Flags))
+	s.Key("OK")
+	s.Bool(x.OK)
+	s.Key("tags")
+	s.ArrayBegin()
+	for i1 := 0; i1 < len(x.Tags); i1++ {
+		s.String(x.Tags[i1])
+	}
+	s.ArrayEnd()
+	s.Key("Data")
+	s.Base64(x.Data)
+	s.Key("Where")
+	x.Where.SerializeJSON(s)
+	s.Key("Path")
+	s.ArrayBegin()
+	for i1 := 0; i1 < len(x.Path); i1++ {
+		x.Path[i1].SerializeJSON(s)
+	}
+	s.ArrayEnd()
+	s.Key("Grid")
+	s.ArrayBegin()
+	for i1 := 0; i1 < len(x.Grid); i1++ {
+		s.ArrayBegin()
+		for i2 := 0; i2 < len(x.Grid[i1]); i2++ {
+			s.Int32(int32(x.Grid[i1][i2]))
+		}
+		s.ArrayEnd()
+	}
+	s.ArrayEnd()
+	s.Key("Big")
+	s.Uint64(x.Big)
+	s.Key("Email")
+	s.String(x.Email)
+	s.ObjectEnd()
+}
+
+func (x *User) DeserializeJSON(d *json.Deserializer) bool {
+	var key string
+
+	d.ObjectBegin()
+	for d.Key(&key) {
+		switch key {
+		case "ID":
+			var tmp3 int32
+			d.Int32(&tmp3)
+			x.ID = database.ID(tmp3)
+		case "Name":
+			d.String(&x.Name)
+		case "Role":
+			var tmp3 int32
+			d.Int32(&tmp3)
+			x.Role = Role(tmp3)
+		case "Age":
+			d.Int(&x.Age)
+		case "Ratio":
+			d.Float64(&x.Ratio)
+		case "Flags":
+			var tmp3 uint64
+			d.UintRange(&tmp3, 255)
+			x.Flags = uint8(tmp3)
+		case "OK":
+			d.Bool(&x.OK)
+		case "tags":
+			x.Tags = x.Tags[:0]
+			d.ArrayBegin()
+			for d.Next() {
+				var e3 string
+				d.String(&e3)
+				x.Tags = append(x.Tags, e3)
+			}
+			d.ArrayEnd()
+		case "Data":
+			d.Base64(&x.Data)
+		case "Where":
+			x.Where.DeserializeJSON(d)
+		case "Path":
+			x.Path = x.Path[:0]
+			d.ArrayBegin()
+			for d.Next() {
+				var e3 Point
+				e3.DeserializeJSON(d)
+				x.Path = append(x.Path, e3)
+			}
+			d.ArrayEnd()
+		case "Grid":
+			d.ArrayBegin()
+			for i3 := 0; d.Next(); i3++ {
+				if i3 >= len(x.Grid) {
+					d.Skip()
+					continue
+				}
+				d.ArrayBegin()
+				for i4 := 0; d.Next(); i4++ {
+					if i4 >= len(x.Grid[i3]) {
+						d.Skip()
+						continue
+					}
+					var tmp5 int64
+					d.IntRange(&tmp5, -32768, 32767)
+					x.Grid[i3][i4] = int16(tmp5)
+				}
+				d.ArrayEnd()
+			}
+			d.ArrayEnd()
+		case "Big":
+			d.Uint64(&x.Big)
+		case "Email":
+			d.String(&x.Email)
+		default:
+			d.Skip()
+		}
+	}
+	return d.ObjectEnd()
+}
+
+func (x *User) SerializeCBOR(s *cbor.Serializer) {
+	s.ObjectBegin()
+	s.Key("ID")
+	s.Int32(int32(x.ID))
+	s.Key("Name")
+	s.String(x.Name)
+	s.Key("Role")
+	s.Int32(int32(x.Role))
+	s.Key("Age")
+	s.Int64(int64(x.Age))
+	s.Key("Ratio")
+	s.Float64(x.Ratio)
+	s.Key("Flags")
+	s.Uint32(uint32(x.Flags))
+	s.Key("OK")
+	s.Bool(x.OK)
+	s.Key("tags")
+	s.ArrayBegin()
+	for i1 := 0; i1 < len(x.Tags); i1++ {
+		s.String(x.Tags[i1])
+	}
+	s.ArrayEnd()
+	s.Key("Data")
+	s.ByteString(x.Data)
+	s.Key("Where")
+	x.Where.SerializeCBOR(s)
+	s.Key("Path")
+	s.ArrayBegin()
+	for i1 := 0; i1 < len(x.Path); i1++ {
+		x.Path[i1].SerializeCBOR(s)
+	}
+	s.ArrayEnd()
+	s.Key("Grid")
+	s.ArrayBegin()
+	for i1 := 0; i1 < len(x.Grid); i1++ {
+		s.ArrayBegin()
+		for i2 := 0; i2 < len(x.Grid[i1]); i2++ {
+			s.Int32(int32(x.Grid[i1][i2]))
+		}
+		s.ArrayEnd()
+	}
+	s.ArrayEnd()
+	s.Key("Big")
+	s.Uint64(x.Big)
+	s.Key("Email")
+	s.String(x.Email)
+	s.ObjectEnd()
+}
+
+func (x *User) DeserializeCBOR(d *cbor.Deserializer) bool {
+	var key string
+
+	d.ObjectBegin()
+	for d.Key(&key) {
+		switch key {
+		case "ID":
+			var tmp3 int32
+			d.Int32(&tmp3)
+			x.ID = database.ID(tmp3)
+		case "Name":
+			d.String(&x.Name)
+		case "Role":
+			var tmp3 int32
+			d.Int32(&tmp3)
+			x.Role = Role(tmp3)
+		case "Age":
+			d.Int(&x.Age)
+		case "Ratio":
+			d.Float64(&x.Ratio)
+		case "Flags":
+			var tmp3 uint64
+			d.UintRange(&tmp3, 255)
+			x.Flags = uint8(tmp3)
+		case "OK":
+			d.Bool(&x.OK)
+		case "tags":
+			x.Tags = x.Tags[:0]
+			d.ArrayBegin()
+			for d.Next() {
+				var e3 string
+				d.String(&e3)
+				x.Tags = append(x.Tags, e3)
+			}
+			d.ArrayEnd()
+		case "Data":
+			d.ByteString(&x.Data)
+		case "Where":
+			x.Where.DeserializeCBOR(d)
+		case "Path":
+			x.Path = x.Path[:0]
+			d.ArrayBegin()
+			for d.Next() {
+				var e3 Point
+				e3.DeserializeCBOR(d)
+				x.Path = append(x.Path, e3)
+			}
+			d.ArrayEnd()
+		case "Grid":
+			d.ArrayBegin()
+			for i3 := 0; d.Next(); i3++ {
+				if i3 >= len(x.Grid) {
+					d.Skip()
+					continue
+				}
+				d.ArrayBegin()
+				for i4 := 0; d.Next(); i4++ {
+					if i4 >= len(x.Grid[i3]) {
+						d.Skip()
+						continue
+					}
+					var tmp5 int64
+					d.IntRange(&tmp5, -32768, 32767)
+					x.Grid[i3][i4] = int16(tmp5)
+				}
+				d.ArrayEnd()
+			}
+			d.ArrayEnd()
+		case "Big":
+			d.Uint64(&x.Big)
+		case "Email":
+			d.String(&x.Email)
+		default:
+			d.Skip()
+		}
+	}
+	return d.ObjectEnd()
+}
@@ -0,0 +1,109 @@
+package encoding
+
+import "unicode/utf8"
+
+var hex = "0123456789abcdef"
+
+/* JSONStringLength returns number of bytes PutJSONString writes for str. */
+func JSONStringLength(str string) int {
+	n := len(`""`)
+	for i := 0; i < len(str); {
+		c := str[i]
+		if (c >= 0x20) && (c != '"') && (c != '\\') && (c < utf8.RuneSelf) {
+			n++
+			i++
+			continue
+		}
+
+		if c < utf8.RuneSelf {
+			switch c {
+			case '"', '\\', '\b', '\f', '\n', '\r', '\t':
+				n += len(`\n`)
+			default:
+				n += len(`\u0000`)
+			}
+			i++
+			continue
+		}
+
+		r, width := utf8.DecodeRuneInString(str[i:])
+		if ((r == utf8.RuneError) && (width == 1)) || (r == '\u2028') || (r == '\u2029') {
+			n += len(`\u0000`)
+		} else {
+			n += width
+		}
+		i += width
+	}
+	return n
+}
+
+/* PutJSONString writes str as JSON string into buf and returns number of bytes written. buf must be at least JSONStringLength(str) bytes long. Invalid UTF-8 is replaced with U+FFFD. */
+func PutJSONString(buf []byte, str string) int {
+	var n int
+
+	buf[n] = '"'
+	n++
+
+	var start int
+	for i := 0; i < len(str); {
+		c := str[i]
+		if (c >= 0x20) && (c != '"') && (c != '\\') && (c < utf8.RuneSelf) {
+			i++
+			continue
+		}
+
+		if c < utf8.RuneSelf {
+			n += copy(buf[n:], str[start:i])
+			switch c {
+			case '"', '\\':
+				buf[n] = '\\'
+				buf[n+1] = c
+				n += 2
+			case '\b':
+				n += copy(buf[n:], `\b`)
+			case '\f':
+				n += copy(buf[n:], `\f`)
+			case '\n':
+				n += copy(buf[n:], `\n`)
+			case '\r':
+				n += copy(buf[n:], `\r`)
+			case '\t':
+				n += copy(buf[n:], `\t`)
+			default:
+				n += copy(buf[n:], `\u00`)
+				buf[n] = hex[c>>4]
+				buf[n+1] = hex[c&0xF]
+				n += 2
+			}
+			i++
+			start = i
+			continue
+		}
+
+		r, width := utf8.DecodeRuneInString(str[i:])
+		if (r == utf8.RuneError) && (width == 1) {
+			n += copy(buf[n:], str[start:i])
+			n += copy(buf[n:], `\ufffd`)
+			i += width
+			start = i
+			continue
+		}
+		/* NOTE(anton2920): U+2028 and U+2029 are valid in JSON, but not in JavaScript. */
+		if (r == '\u2028') || (r == '\u2029') {
+			n += copy(buf[n:], str[start:i])
+			n += copy(buf[n:], `\u202`)
+			buf[n] = hex[r&0xF]
+			n++
+			i += width
+			start = i
+			continue
+		}
+		i += width
+	}
+	n += copy(buf[n:], str[start:])
+
+	buf[n] = '"'
+	n++
+
+	return n
+}
@@ -1,123 +1,749 @@
 package json
 
 import (
-	"bytes"
-
+	"encoding/base64"
+	"fmt"
+	"math"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/anton2920/gofa/bools"
+	"github.com/anton2920/gofa/bytes"
 	"github.com/anton2920/gofa/strings"
 	"github.com/anton2920/gofa/trace/trace_"
 )
 
+/* Deserializer is a pull-parser over Buffer. Every method returns false on error and sets Error; after that all methods return false. Typical usage:
+ *     d := json.Deserializer{Buffer: body}
+ *     d.Begin()
+ *     d.ObjectBegin()
+ *     for d.Key(&key) {
+ *         switch key {
+ *         case "name":
+ *             d.String(&name)
+ *         default:
+ *             d.Skip()
+ *         }
+ *     }
+ *     d.ObjectEnd()
+ *     if !d.End() {
+ *         return d.Error
+ *     }
+ * Strings are unescaped in place, so Buffer is modified and returned strings point into it. */
 type Deserializer struct {
+	Buffer []byte
+	Pos    int
+
+	/* Stack holds '{' or '[' for every container that is currently open. */
+	Stack [MaxDepth]byte
+	Depth int
+
+	/* First is set when no elements were read from the innermost container yet. */
+	First bool
+
 	Error error
 }
 
+/* SyntaxError describes invalid input. Line and Column start from 1, Column is counted in bytes. */
+type SyntaxError struct {
+	Line   int
+	Column int
+	Offset int
+
+	Message string
+}
+
+const MaxDepth = 64
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+func isSpace(c byte) bool {
+	return (c == ' ') || (c == '\t') || (c == '\n') || (c == '\r')
+}
+
+func isDigit(c byte) bool {
+	return (c >= '0') && (c <= '9')
+}
+
+func isEscape(c byte) bool {
+	switch c {
+	case '"', '\\', '/', 'b', 'f', 'n', 'r', 't', 'u':
+		return true
+	default:
+		return false
+	}
+}
+
+func hexValue(c byte) rune {
+	switch {
+	case (c >= '0') && (c <= '9'):
+		return rune(c - '0')
+	case (c >= 'a') && (c <= 'f'):
+		return rune(c - 'a' + 10)
+	case (c >= 'A') && (c <= 'F'):
+		return rune(c - 'A' + 10)
+	default:
+		return -1
+	}
+}
+
+/* hex4 decodes 4 hexadecimal digits of '\uXXXX' escape sequence. */
+func hex4(buf []byte) rune {
+	var r rune
+
+	if len(buf) < 4 {
+		return -1
+	}
+	for i := 0; i < 4; i++ {
+		v := hexValue(buf[i])
+		if v < 0 {
+			return -1
+		}
+		r = r<<4 | v
+	}
+
+	return r
+}
+
+/* unescape decodes escape sequences in buf in place and returns length of the result. If buf contains invalid escape sequence, its position is returned with ok == false, unless strict is not set, in which case it is left as is. Lone surrogates are replaced with U+FFFD. */
+func unescape(buf []byte, strict bool) (n int, pos int, ok bool) {
+	var r int
+
+	for r < len(buf) {
+		if buf[r] != '\\' {
+			buf[n] = buf[r]
+			n++
+			r++
+			continue
+		}
+		if (r+1 == len(buf)) || (!isEscape(buf[r+1])) || ((buf[r+1] == 'u') && (hex4(buf[r+2:]) < 0)) {
+			if strict {
+				return n, r, false
+			}
+			buf[n] = buf[r]
+			n++
+			r++
+			continue
+		}
+
+		switch buf[r+1] {
+		case '"', '\\', '/':
+			buf[n] = buf[r+1]
+		case 'b':
+			buf[n] = '\b'
+		case 'f':
+			buf[n] = '\f'
+		case 'n':
+			buf[n] = '\n'
+		case 'r':
+			buf[n] = '\r'
+		case 't':
+			buf[n] = '\t'
+		case 'u':
+			c := hex4(buf[r+2:])
+			r += 6
+
+			if utf8.RuneLen(c) == -1 {
+				/* NOTE(anton2920): high surrogate followed by '\uXXXX' with low surrogate encodes single code point. */
+				if (c >= 0xD800) && (c < 0xDC00) && (r+6 <= len(buf)) && (buf[r] == '\\') && (buf[r+1] == 'u') {
+					if lo := hex4(buf[r+2:]); (lo >= 0xDC00) && (lo < 0xE000) {
+						c = 0x10000 + (c-0xD800)<<10 + (lo - 0xDC00)
+						r += 6
+					}
+				}
+				if utf8.RuneLen(c) == -1 {
+					c = utf8.RuneError
+				}
+			}
+
+			/* Encoded rune is never longer than escape sequence it came from. */
+			n += utf8.EncodeRune(buf[n:], c)
+			continue
+		}
+		n++
+		r += 2
+	}
+
+	return n, len(buf), true
+}
+
+/* UnescapeJSONString decodes escape sequences in contents of JSON string. Invalid escape sequences are left as is. */
 func UnescapeJSONString(s string) string {
 	t := trace_.Begin("")
 
-	var buf bytes.Buffer
-
-	bs := strings.FindChar(s, '\\')
-	if bs == -1 {
+	if strings.FindChar(s, '\\') == -1 {
+		trace_.End(t)
 		return s
 	}
-	buf.WriteString(s[:bs])
-	s = s[bs+1:]
 
-	for {
-		bs := strings.FindChar(s, '\\')
-		if bs == -1 {
-			break
+	buf := []byte(s)
+	n, _, _ := unescape(buf, false)
+
+	trace_.End(t)
+	return string(buf[:n])
+}
+
+func (d *Deserializer) errorf(format string, args ...interface{}) bool {
+	if d.Error == nil {
+		line, column := 1, 1
+		for i := 0; (i < d.Pos) && (i < len(d.Buffer)); i++ {
+			if d.Buffer[i] == '\n' {
+				line++
+				column = 1
+			} else {
+				column++
+			}
 		}
-		buf.WriteString(s[:bs])
-		s = s[bs+1:]
+		d.Error = &SyntaxError{Line: line, Column: column, Offset: d.Pos, Message: fmt.Sprintf(format, args...)}
 	}
-	buf.WriteString(s)
+	return false
+}
 
-	trace_.End(t)
-	return buf.String()
+func (d *Deserializer) skipSpace() {
+	for (d.Pos < len(d.Buffer)) && (isSpace(d.Buffer[d.Pos])) {
+		d.Pos++
+	}
+}
+
+/* peek skips whitespace and returns next byte or 0 at the end of input. */
+func (d *Deserializer) peek() byte {
+	d.skipSpace()
+	if d.Pos == len(d.Buffer) {
+		return 0
+	}
+	return d.Buffer[d.Pos]
+}
+
+func (d *Deserializer) unexpected(what string) bool {
+	if d.Pos >= len(d.Buffer) {
+		return d.errorf("unexpected end of input, expected %s", what)
+	}
+	return d.errorf("unexpected character %q, expected %s", d.Buffer[d.Pos], what)
+}
+
+func (d *Deserializer) expect(c byte) bool {
+	if d.Error != nil {
+		return false
+	}
+	if d.peek() != c {
+		return d.unexpected(strconv.QuoteRune(rune(c)))
+	}
+	d.Pos++
+	return true
+}
+
+func (d *Deserializer) literal(lit string) bool {
+	if (len(d.Buffer)-d.Pos < len(lit)) || (bytes.AsString(d.Buffer[d.Pos:d.Pos+len(lit)]) != lit) {
+		return d.unexpected(lit)
+	}
+	d.Pos += len(lit)
+	return true
+}
+
+func (d *Deserializer) push(c byte) bool {
+	if d.Depth == len(d.Stack) {
+		return d.errorf("nesting is too deep")
+	}
+	d.Stack[d.Depth] = c
+	d.Depth++
+	d.First = true
+	return true
+}
+
+func (d *Deserializer) pop(c byte) bool {
+	if (d.Depth == 0) || (d.Stack[d.Depth-1] != c) {
+		return d.errorf("unexpected %q", c+2)
+	}
+	d.Depth--
+	/* Container itself was an element of its parent. */
+	d.First = false
+	return true
+}
+
+/* element moves to the next element of the innermost container, which must be c. It returns false at the end of container without consuming closing bracket. */
+func (d *Deserializer) element(c byte) bool {
+	if d.Error != nil {
+		return false
+	}
+	if (d.Depth == 0) || (d.Stack[d.Depth-1] != c) {
+		return d.errorf("not inside of %q", c)
+	}
+
+	/* NOTE(anton2920): '{'+2 == '}' and '['+2 == ']'. */
+	if d.peek() == c+2 {
+		return false
+	}
+	if !d.First {
+		if !d.expect(',') {
+			return false
+		}
+		d.skipSpace()
+	}
+	d.First = false
+
+	return true
+}
+
+/* number returns bytes of the next number, validated against JSON grammar. */
+func (d *Deserializer) number() ([]byte, bool, bool) {
+	isInt := true
+
+	d.skipSpace()
+	start := d.Pos
+
+	if (d.Pos < len(d.Buffer)) && (d.Buffer[d.Pos] == '-') {
+		d.Pos++
+	}
+	switch {
+	case (d.Pos < len(d.Buffer)) && (d.Buffer[d.Pos] == '0'):
+		d.Pos++
+	case (d.Pos < len(d.Buffer)) && (isDigit(d.Buffer[d.Pos])):
+		for (d.Pos < len(d.Buffer)) && (isDigit(d.Buffer[d.Pos])) {
+			d.Pos++
+		}
+	default:
+		return nil, false, d.unexpected("number")
+	}
+
+	if (d.Pos < len(d.Buffer)) && (d.Buffer[d.Pos] == '.') {
+		isInt = false
+		d.Pos++
+		if (d.Pos == len(d.Buffer)) || (!isDigit(d.Buffer[d.Pos])) {
+			return nil, false, d.unexpected("digit")
+		}
+		for (d.Pos < len(d.Buffer)) && (isDigit(d.Buffer[d.Pos])) {
+			d.Pos++
+		}
+	}
+
+	if (d.Pos < len(d.Buffer)) && ((d.Buffer[d.Pos] == 'e') || (d.Buffer[d.Pos] == 'E')) {
+		isInt = false
+		d.Pos++
+		if (d.Pos < len(d.Buffer)) && ((d.Buffer[d.Pos] == '+') || (d.Buffer[d.Pos] == '-')) {
+			d.Pos++
+		}
+		if (d.Pos == len(d.Buffer)) || (!isDigit(d.Buffer[d.Pos])) {
+			return nil, false, d.unexpected("digit")
+		}
+		for (d.Pos < len(d.Buffer)) && (isDigit(d.Buffer[d.Pos])) {
+			d.Pos++
+		}
+	}
+
+	return d.Buffer[start:d.Pos], isInt, true
+}
+
+/* integer parses the next number as integer in [min, max]. */
+func (d *Deserializer) integer(min int64, max uint64) (uint64, bool, bool) {
+	var n uint64
+
+	if d.Error != nil {
+		return 0, false, false
+	}
+
+	d.skipSpace()
+	start := d.Pos
+	num, isInt, ok := d.number()
+	if !ok {
+		return 0, false, false
+	}
+	if !isInt {
+		d.Pos = start
+		return 0, false, d.errorf("expected integer, got %s", num)
+	}
+
+	neg := num[0] == '-'
+	if neg {
+		num = num[1:]
+		if min == 0 {
+			/* NOTE(anton2920): only '-0' is representable, anything else would wrap around in 'max-digit' below. */
+			if num[0] != '0' {
+				d.Pos = start
+				return 0, false, d.errorf("integer -%s is out of range", num)
+			}
+			max = 0
+		} else {
+			max = uint64(-(min + 1)) + 1
+		}
+	}
+	for i := 0; i < len(num); i++ {
+		digit := uint64(num[i] - '0')
+		if (n > max/10) || (n*10 > max-digit) {
+			d.Pos = start
+			return 0, false, d.errorf("integer %s is out of range", d.Buffer[start:start+len(num)+bools.ToInt(neg)])
+		}
+		n = n*10 + digit
+	}
+
+	return n, neg, true
 }
 
+func (d *Deserializer) str() (string, bool) {
+	var escaped, unicode bool
+
+	if !d.expect('"') {
+		return "", false
+	}
+	start := d.Pos
+
+	for {
+		if d.Pos == len(d.Buffer) {
+			return "", d.errorf("unterminated string")
+		}
+
+		c := d.Buffer[d.Pos]
+		switch {
+		case c == '"':
+			s := d.Buffer[start:d.Pos]
+			d.Pos++
+
+			if (unicode) && (!utf8.Valid(s)) {
+				d.Pos = start
+				return "", d.errorf("invalid UTF-8 in string")
+			}
+			if escaped {
+				n, pos, ok := unescape(s, true)
+				if !ok {
+					d.Pos = start + pos
+					return "", d.errorf("invalid escape sequence")
+				}
+				s = s[:n]
+			}
+			return bytes.AsString(s), true
+		case c == '\\':
+			if d.Pos+1 >= len(d.Buffer) {
+				d.Pos = len(d.Buffer)
+				return "", d.errorf("unterminated string")
+			}
+			escaped = true
+			d.Pos++
+		case c < 0x20:
+			return "", d.errorf("control character %q in string", c)
+		case c >= utf8.RuneSelf:
+			unicode = true
+		}
+		d.Pos++
+	}
+}
+
+/* Begin prepares deserializer for reading value from Buffer[Pos:]. */
 func (d *Deserializer) Begin() bool {
+	d.Depth = 0
+	d.First = false
+	d.Error = nil
 	return true
 }
 
+/* End checks that value is complete and nothing but whitespace follows it. */
 func (d *Deserializer) End() bool {
+	if d.Error != nil {
+		return false
+	}
+	if d.Depth > 0 {
+		return d.errorf("%d containers are not closed", d.Depth)
+	}
+	if d.peek() != 0 {
+		return d.unexpected("end of input")
+	}
 	return true
 }
 
 func (d *Deserializer) ObjectBegin() bool {
 	t := trace_.Begin("")
 
+	ok := (d.expect('{')) && (d.push('{'))
+
 	trace_.End(t)
-	return false
+	return ok
 }
 
+/* Key reads key of the next member of object. It returns false at the end of object. */
 func (d *Deserializer) Key(key *string) bool {
 	t := trace_.Begin("")
 
+	if !d.element('{') {
+		trace_.End(t)
+		return false
+	}
+
+	s, ok := d.str()
+	ok = (ok) && (d.expect(':'))
+	if ok {
+		*key = s
+	}
+
 	trace_.End(t)
-	return false
+	return ok
 }
 
 func (d *Deserializer) ObjectEnd() bool {
 	t := trace_.Begin("")
 
+	ok := (d.expect('}')) && (d.pop('{'))
+
 	trace_.End(t)
-	return false
+	return ok
 }
 
 func (d *Deserializer) ArrayBegin() bool {
 	t := trace_.Begin("")
 
+	ok := (d.expect('[')) && (d.push('['))
+
 	trace_.End(t)
-	return false
+	return ok
 }
 
+/* Next moves to the next element of array. It returns false at the end of array. */
 func (d *Deserializer) Next() bool {
 	t := trace_.Begin("")
 
+	ok := d.element('[')
+
 	trace_.End(t)
-	return false
+	return ok
 }
 
 func (d *Deserializer) ArrayEnd() bool {
 	t := trace_.Begin("")
 
+	ok := (d.expect(']')) && (d.pop('['))
+
+	trace_.End(t)
+	return ok
+}
+
+/* Null consumes 'null' and returns true, if it's the next value. Otherwise nothing is consumed. */
+func (d *Deserializer) Null() bool {
+	t := trace_.Begin("")
+
+	ok := (d.Error == nil) && (d.peek() == 'n') && (d.literal("null"))
+
 	trace_.End(t)
-	return false
+	return ok
 }
 
 func (d *Deserializer) Bool(b *bool) bool {
 	t := trace_.Begin("")
 
+	var ok bool
+	if d.Error == nil {
+		switch d.peek() {
+		case 't':
+			if ok = d.literal("true"); ok {
+				*b = true
+			}
+		case 'f':
+			if ok = d.literal("false"); ok {
+				*b = false
+			}
+		default:
+			d.unexpected("boolean")
+		}
+	}
+
 	trace_.End(t)
-	return false
+	return ok
 }
 
 func (d *Deserializer) Int32(i *int32) bool {
 	t := trace_.Begin("")
 
+	n, neg, ok := d.integer(math.MinInt32, math.MaxInt32)
+	if ok {
+		*i = int32(n)
+		if neg {
+			*i = -*i
+		}
+	}
+
 	trace_.End(t)
-	return false
+	return ok
 }
 
 func (d *Deserializer) Uint32(i *uint32) bool {
 	t := trace_.Begin("")
 
+	n, _, ok := d.integer(0, math.MaxUint32)
+	if ok {
+		*i = uint32(n)
+	}
+
 	trace_.End(t)
-	return false
+	return ok
 }
 
 func (d *Deserializer) Int64(i *int64) bool {
 	t := trace_.Begin("")
 
+	n, neg, ok := d.integer(math.MinInt64, math.MaxInt64)
+	if ok {
+		/* NOTE(anton2920): -2^63 wraps to itself. */
+		*i = int64(n)
+		if neg {
+			*i = -*i
+		}
+	}
+
 	trace_.End(t)
-	return false
+	return ok
+}
+
+func (d *Deserializer) Uint64(i *uint64) bool {
+	t := trace_.Begin("")
+
+	n, _, ok := d.integer(0, math.MaxUint64)
+	if ok {
+		*i = n
+	}
+
+	trace_.End(t)
+	return ok
+}
+
+/* Int reads integer in range of int, which is narrower than int64 on 32-bit platforms. */
+func (d *Deserializer) Int(i *int) bool {
+	var n int64
+
+	ok := d.IntRange(&n, math.MinInt, math.MaxInt)
+	if ok {
+		*i = int(n)
+	}
+	return ok
+}
+
+func (d *Deserializer) Uint(u *uint) bool {
+	var n uint64
+
+	ok := d.UintRange(&n, math.MaxUint)
+	if ok {
+		*u = uint(n)
+	}
+	return ok
+}
+
+/* IntRange reads integer in [min, max], where min <= 0 <= max. It's meant for types narrower than int32. */
+func (d *Deserializer) IntRange(i *int64, min, max int64) bool {
+	t := trace_.Begin("")
+
+	n, neg, ok := d.integer(min, uint64(max))
+	if ok {
+		*i = int64(n)
+		if neg {
+			*i = -*i
+		}
+	}
+
+	trace_.End(t)
+	return ok
+}
+
+/* UintRange reads integer in [0, max]. */
+func (d *Deserializer) UintRange(u *uint64, max uint64) bool {
+	t := trace_.Begin("")
+
+	n, _, ok := d.integer(0, max)
+	if ok {
+		*u = n
+	}
+
+	trace_.End(t)
+	return ok
+}
+
+func (d *Deserializer) Float64(f *float64) bool {
+	t := trace_.Begin("")
+
+	if d.Error != nil {
+		trace_.End(t)
+		return false
+	}
+
+	d.skipSpace()
+	start := d.Pos
+	num, _, ok := d.number()
+	if ok {
+		x, err := strconv.ParseFloat(bytes.AsString(num), 64)
+		if err != nil {
+			d.Pos = start
+			ok = d.errorf("number %s is out of range", num)
+		} else {
+			*f = x
+		}
+	}
+
+	trace_.End(t)
+	return ok
 }
 
 func (d *Deserializer) String(s *string) bool {
 	t := trace_.Begin("")
 
+	str, ok := d.str()
+	if ok {
+		*s = str
+	}
+
 	trace_.End(t)
-	return false
+	return ok
+}
+
+/* Base64 reads string in standard base64 encoding. */
+func (d *Deserializer) Base64(b *[]byte) bool {
+	t := trace_.Begin("")
+
+	start := d.Pos
+	s, ok := d.str()
+	if ok {
+		buf := make([]byte, base64.StdEncoding.DecodedLen(len(s)))
+		n, err := base64.StdEncoding.Decode(buf, strings.AsBytes(s))
+		if err != nil {
+			d.Pos = start
+			ok = d.errorf("invalid base64 string: %v", err)
+		} else {
+			*b = buf[:n]
+		}
+	}
+
+	trace_.End(t)
+	return ok
+}
+
+/* Skip consumes the next value, whatever it is. */
+func (d *Deserializer) Skip() bool {
+	t := trace_.Begin("")
+
+	var ok bool
+	if d.Error == nil {
+		switch d.peek() {
+		case '{':
+			var key string
+
+			d.ObjectBegin()
+			for d.Key(&key) {
+				d.Skip()
+			}
+			ok = d.ObjectEnd()
+		case '[':
+			d.ArrayBegin()
+			for d.Next() {
+				d.Skip()
+			}
+			ok = d.ArrayEnd()
+		case '"':
+			_, ok = d.str()
+		case 't', 'f':
+			var b bool
+			ok = d.Bool(&b)
+		case 'n':
+			ok = d.Null()
+		default:
+			_, _, ok = d.number()
+		}
+	}
+
+	trace_.End(t)
+	return ok
 }
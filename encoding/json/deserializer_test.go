@@ -0,0 +1,259 @@
+package json
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDeserializerObject(t *testing.T) {
+	type Item struct {
+		Name  string
+		Count int32
+		Tags  []string
+	}
+
+	var key string
+	var items []Item
+	var total int64
+	var ok bool
+	var ratio float64
+
+	d := Deserializer{Buffer: []byte(`{
+		"items": [
+			{"name": "a\"b\\c\u00e9\ud83d\ude00", "count": -12, "tags": ["x", "y"]},
+			{"name": "", "count": 0, "tags": [], "extra": {"nested": [1, 2.5e3, null, true]}}
+		],
+		"total": 9223372036854775807,
+		"ok": false,
+		"ratio": -0.5
+	}`)}
+
+	d.Begin()
+	d.ObjectBegin()
+	for d.Key(&key) {
+		switch key {
+		case "items":
+			d.ArrayBegin()
+			for d.Next() {
+				var item Item
+
+				d.ObjectBegin()
+				for d.Key(&key) {
+					switch key {
+					case "name":
+						d.String(&item.Name)
+					case "count":
+						d.Int32(&item.Count)
+					case "tags":
+						d.ArrayBegin()
+						for d.Next() {
+							var tag string
+							d.String(&tag)
+							item.Tags = append(item.Tags, tag)
+						}
+						d.ArrayEnd()
+					default:
+						d.Skip()
+					}
+				}
+				d.ObjectEnd()
+
+				items = append(items, item)
+			}
+			d.ArrayEnd()
+		case "total":
+			d.Int64(&total)
+		case "ok":
+			d.Bool(&ok)
+		case "ratio":
+			d.Float64(&ratio)
+		}
+	}
+	d.ObjectEnd()
+	if !d.End() {
+		t.Fatalf("Failed to deserialize: %v", d.Error)
+	}
+
+	if len(items) != 2 {
+		t.Fatalf("Expected 2 items, got %d", len(items))
+	}
+	if items[0].Name != "a\"b\\c\u00e9\U0001F600" {
+		t.Errorf("Expected unescaped name, got %q", items[0].Name)
+	}
+	if (items[0].Count != -12) || (len(items[0].Tags) != 2) || (items[0].Tags[1] != "y") {
+		t.Errorf("Unexpected first item %+v", items[0])
+	}
+	if (items[1].Name != "") || (len(items[1].Tags) != 0) {
+		t.Errorf("Unexpected second item %+v", items[1])
+	}
+	if (total != math.MaxInt64) || (ok) || (ratio != -0.5) {
+		t.Errorf("Unexpected values: total=%d, ok=%v, ratio=%v", total, ok, ratio)
+	}
+}
+
+func TestDeserializerIntegers(t *testing.T) {
+	tests := [...]struct {
+		Input string
+		Value int64
+		OK    bool
+	}{
+		{"0", 0, true},
+		{"-2147483648", math.MinInt32, true},
+		{"2147483647", math.MaxInt32, true},
+		{"2147483648", 0, false},
+		{"-2147483649", 0, false},
+		{"01", 0, false},
+		{"1.0", 0, false},
+		{"-", 0, false},
+		{"+1", 0, false},
+	}
+
+	for _, test := range tests {
+		var x int32
+
+		d := Deserializer{Buffer: []byte(test.Input)}
+		ok := (d.Begin()) && (d.Int32(&x)) && (d.End())
+		if ok != test.OK {
+			t.Errorf("%q: expected ok=%v, got %v (%v)", test.Input, test.OK, ok, d.Error)
+		} else if (ok) && (int64(x) != test.Value) {
+			t.Errorf("%q: expected %d, got %d", test.Input, test.Value, x)
+		}
+	}
+
+	var x int64
+	d := Deserializer{Buffer: []byte("-9223372036854775808")}
+	if (!d.Int64(&x)) || (x != math.MinInt64) {
+		t.Errorf("Expected %d, got %d (%v)", int64(math.MinInt64), x, d.Error)
+	}
+
+	var u uint64
+	d = Deserializer{Buffer: []byte("18446744073709551615")}
+	if (!d.Uint64(&u)) || (u != math.MaxUint64) {
+		t.Errorf("Expected %d, got %d (%v)", uint64(math.MaxUint64), u, d.Error)
+	}
+
+	unsigned := [...]struct {
+		Input string
+		Value uint64
+		OK32  bool
+		OK64  bool
+	}{
+		{"0", 0, true, true},
+		{"-0", 0, true, true},
+		{"4294967295", math.MaxUint32, true, true},
+		{"4294967296", math.MaxUint32 + 1, false, true},
+		{"-1", 0, false, false},
+		{"-5", 0, false, false},
+	}
+
+	for _, test := range unsigned {
+		var x32 uint32
+		var x64 uint64
+
+		d = Deserializer{Buffer: []byte(test.Input)}
+		ok := (d.Begin()) && (d.Uint32(&x32)) && (d.End())
+		if ok != test.OK32 {
+			t.Errorf("%q: expected ok=%v for uint32, got %v (%v)", test.Input, test.OK32, ok, d.Error)
+		} else if (ok) && (uint64(x32) != test.Value) {
+			t.Errorf("%q: expected %d, got %d", test.Input, test.Value, x32)
+		}
+
+		d = Deserializer{Buffer: []byte(test.Input)}
+		ok = (d.Begin()) && (d.Uint64(&x64)) && (d.End())
+		if ok != test.OK64 {
+			t.Errorf("%q: expected ok=%v for uint64, got %v (%v)", test.Input, test.OK64, ok, d.Error)
+		} else if (ok) && (x64 != test.Value) {
+			t.Errorf("%q: expected %d, got %d", test.Input, test.Value, x64)
+		}
+	}
+}
+
+func TestDeserializerErrors(t *testing.T) {
+	tests := [...]struct {
+		Input  string
+		Line   int
+		Column int
+	}{
+		{`{"a": 1,}`, 1, 9},
+		{"[1,\n 2\n 3]", 3, 2},
+		{`{"a" 1}`, 1, 6},
+		{`["a\x"]`, 1, 4},
+		{"[\"a\tb\"]", 1, 4},
+		{`[tru]`, 1, 2},
+		{`[1] 2`, 1, 5},
+		{`[1`, 1, 3},
+		{`[1}`, 1, 3},
+		{`"\`, 1, 3},
+		{`{"a":"\`, 1, 8},
+	}
+
+	for _, test := range tests {
+		d := Deserializer{Buffer: []byte(test.Input)}
+		d.Begin()
+		d.Skip()
+		if d.End() {
+			t.Errorf("%q: expected error", test.Input)
+			continue
+		}
+
+		err, ok := d.Error.(*SyntaxError)
+		if !ok {
+			t.Errorf("%q: expected *SyntaxError, got %T", test.Input, d.Error)
+			continue
+		}
+		if (err.Line != test.Line) || (err.Column != test.Column) {
+			t.Errorf("%q: expected error at %d:%d, got %v", test.Input, test.Line, test.Column, err)
+		}
+	}
+}
+
+func TestUnescapeJSONString(t *testing.T) {
+	tests := [...]struct {
+		Input    string
+		Expected string
+	}{
+		{`plain`, "plain"},
+		{`a\nb\tc\/d`, "a\nb\tc/d"},
+		{`\u0041\u00df\u6771`, "A\u00df\u6771"},
+		{`\ud834\udd1e`, "\U0001D11E"},
+		{`\ud834x`, "\uFFFDx"},
+		{`\q\u12`, `\q\u12`},
+	}
+
+	for _, test := range tests {
+		if got := UnescapeJSONString(test.Input); got != test.Expected {
+			t.Errorf("%q: expected %q, got %q", test.Input, test.Expected, got)
+		}
+	}
+}
+
+func FuzzDeserializer(f *testing.F) {
+	f.Add([]byte(`{"a": [1, -2.5e3, "x\\u00e9", null, true], "b": {}}`))
+	f.Add([]byte(`{"a":"\`))
+	f.Add([]byte(`["\ud834\udd1e", 18446744073709551615, -0]`))
+
+	f.Fuzz(func(t *testing.T, buffer []byte) {
+		var key, str string
+
+		/* NOTE(anton2920): strings are unescaped in place, so every pass gets its own copy. */
+		d := Deserializer{Buffer: append([]byte(nil), buffer...)}
+		d.Begin()
+		d.Skip()
+		d.End()
+
+		d = Deserializer{Buffer: append([]byte(nil), buffer...)}
+		d.Begin()
+		if d.ObjectBegin() {
+			for d.Key(&key) {
+				d.String(&str)
+			}
+			d.ObjectEnd()
+		}
+		d.End()
+
+		d = Deserializer{Buffer: append([]byte(nil), buffer...)}
+		d.Begin()
+		d.String(&str)
+		d.End()
+	})
+}
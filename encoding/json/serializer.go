@@ -1,115 +1,274 @@
 package json
 
 import (
-	"github.com/anton2920/gofa/bools"
-	"github.com/anton2920/gofa/slices"
-	"github.com/anton2920/gofa/strings"
+	"encoding/base64"
+	"math"
+	"strconv"
+	"unsafe"
+
+	"github.com/anton2920/gofa/encoding"
+	"github.com/anton2920/gofa/mem"
+	"github.com/anton2920/gofa/trace/trace_"
 )
 
+/* Serializer writes JSON into Buffer[Pos:], growing Buffer when it's full. If Arena is set, Buffer is grown inside of it, otherwise it is reallocated on heap.
+ * Commas are inserted automatically, so values are written in the same order as Deserializer reads them:
+ *     s.ObjectBegin()
+ *     s.Key("tags")
+ *     s.ArrayBegin()
+ *     s.String("a")
+ *     s.String("b")
+ *     s.ArrayEnd()
+ *     s.ObjectEnd() */
 type Serializer struct {
-	Buffer    []byte
-	Pos       int
+	Buffer []byte
+	Pos    int
+
+	Arena *mem.Arena
+
+	/* Indent enables pretty-printing: every element is written on its own line, indented with Indent repeated for every level of nesting. */
+	Indent string
+	Depth  int
+
+	/* NeedComma is set when current container is not empty. AfterKey is set between Key and its value. */
 	NeedComma bool
+	AfterKey  bool
+}
+
+const serializerMinBufferSize = 64
+
+func (s *Serializer) grow(n int) {
+	if s.Pos+n <= len(s.Buffer) {
+		return
+	}
+
+	size := 2 * len(s.Buffer)
+	if size < s.Pos+n {
+		size = s.Pos + n
+	}
+	if size < serializerMinBufferSize {
+		size = serializerMinBufferSize
+	}
+
+	if s.Arena != nil {
+		if (len(s.Buffer) > 0) && (s.Arena.AllocationComesFromHere(unsafe.Pointer(&s.Buffer[0]), uintptr(len(s.Buffer)))) {
+			s.Buffer = s.Arena.RepushByteArray(s.Buffer, size)
+		} else {
+			buffer := s.Arena.PushByteArray(size)
+			copy(buffer, s.Buffer[:s.Pos])
+			s.Buffer = buffer
+		}
+	} else {
+		buffer := make([]byte, size)
+		copy(buffer, s.Buffer[:s.Pos])
+		s.Buffer = buffer
+	}
+}
+
+func (s *Serializer) writeByte(c byte) {
+	s.grow(1)
+	s.Buffer[s.Pos] = c
+	s.Pos++
+}
+
+func (s *Serializer) writeString(str string) {
+	s.grow(len(str))
+	s.Pos += copy(s.Buffer[s.Pos:], str)
+}
+
+func (s *Serializer) newline() {
+	if len(s.Indent) == 0 {
+		return
+	}
+	s.writeByte('\n')
+	for i := 0; i < s.Depth; i++ {
+		s.writeString(s.Indent)
+	}
+}
+
+/* value is called before every value and key to write separators. */
+func (s *Serializer) value() {
+	if s.AfterKey {
+		s.AfterKey = false
+		return
+	}
+
+	s.Comma()
+	if s.Depth > 0 {
+		s.newline()
+	}
+}
+
+/* quote writes str as JSON string. Invalid UTF-8 is replaced with U+FFFD. */
+func (s *Serializer) quote(str string) {
+	s.grow(encoding.JSONStringLength(str))
+	s.Pos += encoding.PutJSONString(s.Buffer[s.Pos:], str)
+}
+
+/* float writes x in the shortest form that reads back as the same value. JSON has no representation for NaN and infinities, so they are written as null. */
+func (s *Serializer) float(x float64, bits int) {
+	if (math.IsNaN(x)) || (math.IsInf(x, 0)) {
+		s.writeString("null")
+		return
+	}
+
+	/* NOTE(anton2920): same cutoffs as ES6 Number.prototype.toString. */
+	format := byte('f')
+	if abs := math.Abs(x); abs != 0 {
+		if ((bits == 64) && ((abs < 1e-6) || (abs >= 1e21))) || ((bits == 32) && ((float32(abs) < 1e-6) || (float32(abs) >= 1e21))) {
+			format = 'e'
+		}
+	}
+
+	s.grow(32)
+	b := strconv.AppendFloat(s.Buffer[s.Pos:s.Pos], x, format, -1, bits)
+	if format == 'e' {
+		/* Clean up e-09 to e-9. */
+		if n := len(b); (n >= 4) && (b[n-4] == 'e') && (b[n-3] == '-') && (b[n-2] == '0') {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	s.Pos += len(b)
 }
 
 func (s *Serializer) Comma() {
 	if s.NeedComma {
 		s.NeedComma = false
-		s.Buffer[s.Pos] = ','
-		s.Pos++
+		s.writeByte(',')
 	}
 }
 
 func (s *Serializer) Begin() {
-
+	s.Depth = 0
+	s.NeedComma = false
+	s.AfterKey = false
 }
 
 func (s *Serializer) End() {
-
+	s.newline()
 }
 
 func (s *Serializer) ObjectBegin() {
-	s.Comma()
-	s.Buffer[s.Pos] = '{'
-	s.Pos++
+	s.value()
+	s.writeByte('{')
+	s.Depth++
 	s.NeedComma = false
 }
 
 func (s *Serializer) ObjectEnd() {
-	s.Buffer[s.Pos] = '}'
-	s.Pos++
+	s.Depth--
+	if s.NeedComma {
+		s.newline()
+	}
+	s.writeByte('}')
+	s.NeedComma = true
 }
 
 func (s *Serializer) ArrayBegin() {
-	s.Comma()
-	s.Buffer[s.Pos] = '['
-	s.Pos++
+	s.value()
+	s.writeByte('[')
+	s.Depth++
 	s.NeedComma = false
 }
 
 func (s *Serializer) ArrayEnd() {
-	s.Buffer[s.Pos] = ']'
-	s.Pos++
+	s.Depth--
+	if s.NeedComma {
+		s.newline()
+	}
+	s.writeByte(']')
+	s.NeedComma = true
 }
 
-func (s *Serializer) Bool(b bool) {
-	values := []string{"false", "true"}
+func (s *Serializer) Null() {
+	s.value()
+	s.writeString("null")
+	s.NeedComma = true
+}
 
-	s.Comma()
-	s.Pos += copy(s.Buffer[s.Pos:], values[bools.ToInt(b)])
+func (s *Serializer) Bool(b bool) {
+	s.value()
+	if b {
+		s.writeString("true")
+	} else {
+		s.writeString("false")
+	}
+	s.NeedComma = true
 }
 
 func (s *Serializer) Int32(x int32) {
-	s.Comma()
-	s.NeedComma = true
-	s.Pos += slices.PutInt(s.Buffer[s.Pos:], int(x))
+	s.Int64(int64(x))
 }
 
 func (s *Serializer) Uint32(x uint32) {
-	s.Comma()
-	s.NeedComma = true
-	s.Pos += slices.PutInt(s.Buffer[s.Pos:], int(x))
+	s.Uint64(uint64(x))
+}
+
+func (s *Serializer) Int(x int) {
+	s.Int64(int64(x))
 }
 
-/* TODO(anton2920): this is incorrect on i386. */
 func (s *Serializer) Int64(x int64) {
-	s.Comma()
+	s.value()
+	s.grow(20)
+	s.Pos += len(strconv.AppendInt(s.Buffer[s.Pos:s.Pos], x, 10))
 	s.NeedComma = true
-	s.Pos += slices.PutInt(s.Buffer[s.Pos:], int(x))
 }
 
-func (s *Serializer) String(str string) {
-	s.Comma()
+func (s *Serializer) Uint64(x uint64) {
+	s.value()
+	s.grow(20)
+	s.Pos += len(strconv.AppendUint(s.Buffer[s.Pos:s.Pos], x, 10))
 	s.NeedComma = true
+}
 
-	s.Buffer[s.Pos] = '"'
-	s.Pos++
+func (s *Serializer) Float32(x float32) {
+	s.value()
+	s.float(float64(x), 32)
+	s.NeedComma = true
+}
 
-	for {
-		quote := strings.FindChar(str, '"')
-		if quote == -1 {
-			s.Pos += copy(s.Buffer[s.Pos:], str)
-			break
-		}
-		s.Pos += copy(s.Buffer[s.Pos:], str[:quote])
-		s.Pos += copy(s.Buffer[s.Pos:], `\"`)
-		if quote == len(str)-1 {
-			break
-		}
-		str = str[quote+1:]
-	}
+func (s *Serializer) Float64(x float64) {
+	s.value()
+	s.float(x, 64)
+	s.NeedComma = true
+}
 
+/* Base64 writes b as string in standard base64 encoding. */
+func (s *Serializer) Base64(b []byte) {
+	s.value()
+	n := base64.StdEncoding.EncodedLen(len(b))
+	s.grow(n + 2)
 	s.Buffer[s.Pos] = '"'
-	s.Pos++
+	base64.StdEncoding.Encode(s.Buffer[s.Pos+1:], b)
+	s.Buffer[s.Pos+n+1] = '"'
+	s.Pos += n + 2
+	s.NeedComma = true
+}
+
+func (s *Serializer) String(str string) {
+	t := trace_.Begin("")
+
+	s.value()
+	s.quote(str)
+	s.NeedComma = true
+
+	trace_.End(t)
 }
 
 func (s *Serializer) Key(key string) {
-	s.Comma()
-	s.String(key)
+	t := trace_.Begin("")
 
-	s.Buffer[s.Pos] = ':'
-	s.Pos++
-	s.NeedComma = false
+	s.value()
+	s.quote(key)
+	s.writeByte(':')
+	if len(s.Indent) > 0 {
+		s.writeByte(' ')
+	}
+	s.AfterKey = true
+
+	trace_.End(t)
 }
 
 func (s *Serializer) Bytes() []byte {
@@ -118,5 +277,5 @@ func (s *Serializer) Bytes() []byte {
 
 func (s *Serializer) Reset() {
 	s.Pos = 0
-	s.NeedComma = false
+	s.Begin()
 }
@@ -0,0 +1,171 @@
+package json
+
+import (
+	stdjson "encoding/json"
+	"math"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/anton2920/gofa/mem"
+)
+
+func TestSerializerString(t *testing.T) {
+	tests := [...]struct {
+		Input    string
+		Expected string
+	}{
+		{"", `""`},
+		{"plain", `"plain"`},
+		{"a\"b\\c", `"a\"b\\c"`},
+		{"\b\f\n\r\t\x00\x1f", `"\b\f\n\r\t\u0000\u001f"`},
+		{"caf\u00e9 \U0001F600", "\"caf\u00e9 \U0001F600\""},
+		{"bad\xff\xfeutf8", `"bad\ufffd\ufffdutf8"`},
+		{"line\u2028sep\u2029", `"line\u2028sep\u2029"`},
+	}
+
+	for _, test := range tests {
+		var s Serializer
+
+		s.String(test.Input)
+		if got := string(s.Bytes()); got != test.Expected {
+			t.Errorf("%q: expected %s, got %s", test.Input, test.Expected, got)
+		}
+	}
+}
+
+func TestSerializerNumbers(t *testing.T) {
+	var s Serializer
+
+	s.ArrayBegin()
+	s.Int32(math.MinInt32)
+	s.Uint32(math.MaxUint32)
+	s.Int64(math.MinInt64)
+	s.Uint64(math.MaxUint64)
+	s.Float64(0)
+	s.Float64(-1.5)
+	s.Float64(1e21)
+	s.Float64(1e-7)
+	s.Float64(0.1)
+	s.Float32(0.1)
+	s.Float64(math.NaN())
+	s.Float64(math.Inf(-1))
+	s.ArrayEnd()
+
+	expected := `[-2147483648,4294967295,-9223372036854775808,18446744073709551615,0,-1.5,1e+21,1e-7,0.1,0.1,null,null]`
+	if got := string(s.Bytes()); got != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+}
+
+func writeNested(s *Serializer) {
+	s.Begin()
+	s.ObjectBegin()
+	s.Key("a")
+	s.ObjectBegin()
+	s.Key("b")
+	s.ArrayBegin()
+	s.ArrayBegin()
+	s.ArrayEnd()
+	s.ObjectBegin()
+	s.ObjectEnd()
+	s.Null()
+	s.ArrayEnd()
+	s.Key("c")
+	s.Bool(true)
+	s.ObjectEnd()
+	s.Key("d")
+	s.Bool(false)
+	s.ObjectEnd()
+	s.End()
+}
+
+func TestSerializerNested(t *testing.T) {
+	var s Serializer
+
+	writeNested(&s)
+	expected := `{"a":{"b":[[],{},null],"c":true},"d":false}`
+	if got := string(s.Bytes()); got != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+
+	s.Reset()
+	s.Indent = "  "
+	writeNested(&s)
+	expected = `{
+  "a": {
+    "b": [
+      [],
+      {},
+      null
+    ],
+    "c": true
+  },
+  "d": false
+}
+`
+	if got := string(s.Bytes()); got != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+}
+
+func TestSerializerGrow(t *testing.T) {
+	var arena mem.Arena
+
+	arena.InitWithByteSlice(make([]byte, 1<<16))
+
+	initial := make([]byte, 4)
+	for _, s := range [...]*Serializer{{Buffer: initial}, {Buffer: initial, Arena: &arena}} {
+		s.ArrayBegin()
+		for i := 0; i < 1000; i++ {
+			s.Int(i)
+		}
+		s.ArrayEnd()
+
+		var xs []int
+		if err := stdjson.Unmarshal(s.Bytes(), &xs); err != nil {
+			t.Fatalf("Failed to unmarshal: %v", err)
+		}
+		if (len(xs) != 1000) || (xs[999] != 999) {
+			t.Errorf("Unexpected contents: %v", xs)
+		}
+	}
+}
+
+func FuzzSerializerString(f *testing.F) {
+	f.Add("")
+	f.Add("hello, world")
+	f.Add("\"\\\x00\x7f\u2028")
+	f.Add("\xed\xa0\x80\xff")
+
+	f.Fuzz(func(t *testing.T, str string) {
+		var s Serializer
+		var got string
+
+		s.ArrayBegin()
+		s.String(str)
+		s.ObjectBegin()
+		s.Key(str)
+		s.String(str)
+		s.ObjectEnd()
+		s.ArrayEnd()
+		if !stdjson.Valid(s.Bytes()) {
+			t.Fatalf("Invalid JSON for %q: %s", str, s.Bytes())
+		}
+
+		s.Reset()
+		s.String(str)
+
+		expected := str
+		if !utf8.ValidString(str) {
+			expected = string([]rune(str))
+		}
+
+		d := Deserializer{Buffer: s.Bytes()}
+		if (!d.String(&got)) || (!d.End()) {
+			t.Fatalf("Failed to deserialize %s: %v", s.Bytes(), d.Error)
+		}
+		if got != expected {
+			t.Fatalf("Expected %q, got %q", expected, got)
+		}
+	})
+}
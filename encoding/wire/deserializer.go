@@ -2,25 +2,128 @@ package wire
 
 import (
 	"fmt"
+	"hash/crc32"
 	"unsafe"
 
 	"github.com/anton2920/gofa/bytes"
 	"github.com/anton2920/gofa/trace/trace_"
 )
 
+/* Frame is value, for which Begin was called, but End was not. */
+type Frame struct {
+	/* Data is the whole frame, including header and trailer. */
+	Data []byte
+
+	/* Rest is what follows the frame. */
+	Rest []byte
+}
+
+/* Deserializer reads frames written by Serializer. Between Begin and End Buffer contains only unread fields of the current value.
+ * Input is not trusted: reads past the end of value or lengths above MaxLength set Error, after which all reads return zero values, and End returns Error. */
 type Deserializer struct {
 	Buffer []byte
+
+	Frames [MaxDepth]Frame
+	Depth  int
+
+	/* MaxLength limits lengths of strings, byte slices and slices, DefaultMaxLength is used if it's zero. */
+	MaxLength int
+
+	Error error
+}
+
+/* Migration upgrades value deserialized with version Version-1 to Version. */
+type Migration struct {
+	Version int
+	Migrate func(t unsafe.Pointer)
+}
+
+const DefaultMaxLength = 16 * 1024 * 1024
+
+func getUint32(buf []byte) uint32 {
+	var n uint32
+	n = uint32(buf[0]) << 0
+	n |= uint32(buf[1]) << 8
+	n |= uint32(buf[2]) << 16
+	n |= uint32(buf[3]) << 24
+	return n
+}
+
+/* Migrate applies migrations to value t deserialized with specified version, so it looks as if it was written with the latest version. Migrations must be sorted by version. */
+func Migrate(t unsafe.Pointer, version int, migrations []Migration) {
+	for i := 0; i < len(migrations); i++ {
+		if migrations[i].Version > version {
+			migrations[i].Migrate(t)
+		}
+	}
 }
 
+/* errorf sets Error, unless it's already set, and discards unread fields. */
+func (d *Deserializer) errorf(format string, args ...interface{}) {
+	if d.Error == nil {
+		d.Error = fmt.Errorf(format, args...)
+	}
+	d.Buffer = nil
+}
+
+/* read returns next n bytes or nil, if there are not enough of them. */
+func (d *Deserializer) read(n int) []byte {
+	if d.Error != nil {
+		return nil
+	}
+	if len(d.Buffer) < n {
+		d.errorf("unexpected end of input: expected %d bytes, got %d", n, len(d.Buffer))
+		return nil
+	}
+
+	buf := d.Buffer[:n]
+	d.Buffer = d.Buffer[n:]
+	return buf
+}
+
+/* Begin starts reading frame and returns its version. Fields must be read in the same order they were written. */
 func (d *Deserializer) Begin() int {
 	t := trace_.Begin("")
 
-	version := int(d.Int32())
+	if d.Depth == MaxDepth {
+		panic("wire: values are nested too deep")
+	}
+
+	var version int
+	if d.Error != nil {
+		d.Frames[d.Depth] = Frame{}
+	} else if len(d.Buffer) < FrameHeaderSize+FrameTrailerSize {
+		d.Frames[d.Depth] = Frame{}
+		d.errorf("frame is truncated: expected at least %d bytes, got %d", FrameHeaderSize+FrameTrailerSize, len(d.Buffer))
+	} else {
+		version = int(int32(getUint32(d.Buffer[0:])))
+		length := getUint32(d.Buffer[4:])
+
+		if uint64(length) > uint64(len(d.Buffer)-FrameHeaderSize-FrameTrailerSize) {
+			d.Frames[d.Depth] = Frame{}
+			d.errorf("frame is truncated: expected %d bytes of fields, got %d", length, len(d.Buffer)-FrameHeaderSize-FrameTrailerSize)
+			version = 0
+		} else {
+			size := FrameHeaderSize + int(length) + FrameTrailerSize
+			d.Frames[d.Depth] = Frame{Data: d.Buffer[:size], Rest: d.Buffer[size:]}
+			d.Buffer = d.Buffer[FrameHeaderSize : FrameHeaderSize+int(length)]
+		}
+	}
+	d.Depth++
 
 	trace_.End(t)
 	return version
 }
 
+/* More reports whether current value has unread fields. It allows to read fields appended in newer versions without checking version. */
+func (d *Deserializer) More() bool {
+	return len(d.Buffer) > 0
+}
+
+func (d *Deserializer) Bool() bool {
+	return d.Uint8() != 0
+}
+
 func (d *Deserializer) Int8() int8 {
 	return int8(d.Uint8())
 }
@@ -29,8 +132,9 @@ func (d *Deserializer) Uint8() uint8 {
 	t := trace_.Begin("")
 
 	var n uint8
-	n = uint8(d.Buffer[0]) << 0
-	d.Buffer = d.Buffer[unsafe.Sizeof(n):]
+	if buf := d.read(int(unsafe.Sizeof(n))); buf != nil {
+		n = uint8(buf[0]) << 0
+	}
 
 	trace_.End(t)
 	return n
@@ -44,9 +148,10 @@ func (d *Deserializer) Uint16() uint16 {
 	t := trace_.Begin("")
 
 	var n uint16
-	n = uint16(d.Buffer[0]) << 0
-	n |= uint16(d.Buffer[1]) << 8
-	d.Buffer = d.Buffer[unsafe.Sizeof(n):]
+	if buf := d.read(int(unsafe.Sizeof(n))); buf != nil {
+		n = uint16(buf[0]) << 0
+		n |= uint16(buf[1]) << 8
+	}
 
 	trace_.End(t)
 	return n
@@ -60,11 +165,9 @@ func (d *Deserializer) Uint32() uint32 {
 	t := trace_.Begin("")
 
 	var n uint32
-	n = uint32(d.Buffer[0]) << 0
-	n |= uint32(d.Buffer[1]) << 8
-	n |= uint32(d.Buffer[2]) << 16
-	n |= uint32(d.Buffer[3]) << 24
-	d.Buffer = d.Buffer[unsafe.Sizeof(n):]
+	if buf := d.read(int(unsafe.Sizeof(n))); buf != nil {
+		n = getUint32(buf)
+	}
 
 	trace_.End(t)
 	return n
@@ -78,15 +181,16 @@ func (d *Deserializer) Uint64() uint64 {
 	t := trace_.Begin("")
 
 	var n uint64
-	n = uint64(d.Buffer[0]) << 0
-	n |= uint64(d.Buffer[1]) << 8
-	n |= uint64(d.Buffer[2]) << 16
-	n |= uint64(d.Buffer[3]) << 24
-	n |= uint64(d.Buffer[4]) << 32
-	n |= uint64(d.Buffer[5]) << 40
-	n |= uint64(d.Buffer[6]) << 48
-	n |= uint64(d.Buffer[7]) << 56
-	d.Buffer = d.Buffer[unsafe.Sizeof(n):]
+	if buf := d.read(int(unsafe.Sizeof(n))); buf != nil {
+		n = uint64(buf[0]) << 0
+		n |= uint64(buf[1]) << 8
+		n |= uint64(buf[2]) << 16
+		n |= uint64(buf[3]) << 24
+		n |= uint64(buf[4]) << 32
+		n |= uint64(buf[5]) << 40
+		n |= uint64(buf[6]) << 48
+		n |= uint64(buf[7]) << 56
+	}
 
 	trace_.End(t)
 	return n
@@ -96,17 +200,82 @@ func (d *Deserializer) Int() int {
 	return int(d.Int64())
 }
 
+/* Uvarint reads integer in unsigned LEB128 encoding. */
+func (d *Deserializer) Uvarint() uint64 {
+	t := trace_.Begin("")
+	defer trace_.End(t)
+
+	if d.Error != nil {
+		return 0
+	}
+
+	var n uint64
+	for i := 0; i < MaxVarintSize; i++ {
+		if i == len(d.Buffer) {
+			d.errorf("unexpected end of input: varint is truncated")
+			return 0
+		}
+
+		c := d.Buffer[i]
+		if (i == MaxVarintSize-1) && (c > 1) {
+			break
+		}
+		n |= uint64(c&0x7F) << (7 * i)
+		if c < 0x80 {
+			d.Buffer = d.Buffer[i+1:]
+			return n
+		}
+	}
+
+	d.errorf("varint overflows 64-bit integer")
+	return 0
+}
+
+/* Varint reads integer in zigzag encoding. */
+func (d *Deserializer) Varint() int64 {
+	n := d.Uvarint()
+	return int64(n>>1) ^ -int64(n&1)
+}
+
+func (d *Deserializer) Float32() float32 {
+	x := d.Uint32()
+	return *(*float32)(unsafe.Pointer(&x))
+}
+
 func (d *Deserializer) Float64() float64 {
 	x := d.Uint64()
 	return *(*float64)(unsafe.Pointer(&x))
 }
 
+/* Length reads length of string, byte slice or slice and checks it against MaxLength and the rest of the value. Every element takes at least one byte, so valid length never exceeds the number of unread bytes. */
+func (d *Deserializer) Length() int {
+	t := trace_.Begin("")
+	defer trace_.End(t)
+
+	maxLength := d.MaxLength
+	if maxLength == 0 {
+		maxLength = DefaultMaxLength
+	}
+
+	l := d.Uint32()
+	if d.Error != nil {
+		return 0
+	}
+	if uint64(l) > uint64(maxLength) {
+		d.errorf("length %d exceeds limit %d", l, maxLength)
+		return 0
+	}
+	if uint64(l) > uint64(len(d.Buffer)) {
+		d.errorf("unexpected end of input: expected at least %d bytes, got %d", l, len(d.Buffer))
+		return 0
+	}
+	return int(l)
+}
+
 func (d *Deserializer) String() string {
 	t := trace_.Begin("")
 
-	l := int(d.Int32())
-	s := bytes.AsString(d.Buffer[:l])
-	d.Buffer = d.Buffer[l:]
+	s := bytes.AsString(d.read(d.Length()))
 
 	trace_.End(t)
 	return s
@@ -115,22 +284,35 @@ func (d *Deserializer) String() string {
 func (d *Deserializer) Bytes() []byte {
 	t := trace_.Begin("")
 
-	l := int(d.Int32())
-	bs := d.Buffer[:l]
-	d.Buffer = d.Buffer[l:]
+	bs := d.read(d.Length())
 
 	trace_.End(t)
 	return bs
 }
 
+/* End finishes frame started by the last Begin. Unread fields are skipped, because they were added in newer version. After End Buffer contains what follows the frame, e.g. the next value. */
 func (d *Deserializer) End() error {
 	t := trace_.Begin("")
+	defer trace_.End(t)
 
-	/* TODO(anton2920): check for CRC. */
-	if len(d.Buffer) > 0 {
-		return fmt.Errorf("%d bytes are left unconsumed", len(d.Buffer))
+	if d.Depth == 0 {
+		d.errorf("End is called without Begin")
+		return d.Error
+	}
+	d.Depth--
+	frame := d.Frames[d.Depth]
+
+	if d.Error != nil {
+		d.Buffer = nil
+		return d.Error
+	}
+	d.Buffer = frame.Rest
+
+	n := len(frame.Data) - FrameTrailerSize
+	if sum, expected := crc32.Checksum(frame.Data[:n], castagnoli), getUint32(frame.Data[n:]); sum != expected {
+		d.errorf("checksum mismatch: expected %08x, got %08x", expected, sum)
+		return d.Error
 	}
 
-	trace_.End(t)
 	return nil
 }
@@ -0,0 +1,238 @@
+package wire
+
+import (
+	"testing"
+	"unsafe"
+)
+
+type testRecordV1 struct {
+	ID   int32
+	Name string
+}
+
+type testRecordV2 struct {
+	testRecordV1
+	Email string
+}
+
+func serializeV2(s *Serializer, r *testRecordV2) {
+	s.Begin(2)
+	s.Int32(r.ID)
+	s.String(r.Name)
+	s.String(r.Email)
+	s.End()
+}
+
+func deserializeV1(d *Deserializer, r *testRecordV1) error {
+	d.Begin()
+	r.ID = d.Int32()
+	r.Name = d.String()
+	return d.End()
+}
+
+func TestDeserializerNested(t *testing.T) {
+	var s Serializer
+
+	s.Begin(1)
+	s.Int64(-1)
+	s.Begin(3)
+	s.String("inner")
+	s.Bool(true)
+	s.End()
+	s.Float64(0.5)
+	s.End()
+
+	d := Deserializer{Buffer: s.Buffer}
+	if version := d.Begin(); version != 1 {
+		t.Fatalf("Expected outer version 1, got %d", version)
+	}
+	if n := d.Int64(); n != -1 {
+		t.Errorf("Expected -1, got %d", n)
+	}
+	if version := d.Begin(); version != 3 {
+		t.Fatalf("Expected inner version 3, got %d", version)
+	}
+	if str := d.String(); str != "inner" {
+		t.Errorf("Expected %q, got %q", "inner", str)
+	}
+	if !d.Bool() {
+		t.Errorf("Expected true")
+	}
+	if err := d.End(); err != nil {
+		t.Fatalf("Failed to end inner value: %v", err)
+	}
+	if f := d.Float64(); f != 0.5 {
+		t.Errorf("Expected 0.5, got %v", f)
+	}
+	if err := d.End(); err != nil {
+		t.Fatalf("Failed to end outer value: %v", err)
+	}
+}
+
+func TestDeserializerSkipNewerFields(t *testing.T) {
+	var s Serializer
+	var r testRecordV1
+
+	serializeV2(&s, &testRecordV2{testRecordV1{ID: 7, Name: "name"}, "email"})
+	serializeV2(&s, &testRecordV2{testRecordV1{ID: 8, Name: "other"}, "email"})
+
+	d := Deserializer{Buffer: s.Buffer}
+	if err := deserializeV1(&d, &r); err != nil {
+		t.Fatalf("Failed to deserialize first record: %v", err)
+	}
+	if (r.ID != 7) || (r.Name != "name") {
+		t.Errorf("Unexpected first record %+v", r)
+	}
+	if err := deserializeV1(&d, &r); err != nil {
+		t.Fatalf("Failed to deserialize second record: %v", err)
+	}
+	if (r.ID != 8) || (r.Name != "other") {
+		t.Errorf("Unexpected second record %+v", r)
+	}
+	if len(d.Buffer) > 0 {
+		t.Errorf("Expected buffer to be consumed, %d bytes left", len(d.Buffer))
+	}
+}
+
+func TestDeserializerCorrupted(t *testing.T) {
+	var s Serializer
+	var r testRecordV1
+
+	serializeV2(&s, &testRecordV2{testRecordV1{ID: 7, Name: "name"}, "email"})
+
+	for i := 0; i < len(s.Buffer)*8; i++ {
+		buffer := make([]byte, len(s.Buffer))
+		copy(buffer, s.Buffer)
+		buffer[i/8] ^= 1 << (i % 8)
+
+		d := Deserializer{Buffer: buffer}
+		if err := deserializeV1(&d, &r); err == nil {
+			t.Errorf("Expected error after flipping bit %d", i)
+		}
+	}
+
+	for i := 0; i < len(s.Buffer); i++ {
+		d := Deserializer{Buffer: s.Buffer[:i]}
+		d.Begin()
+		if err := d.End(); err == nil {
+			t.Errorf("Expected error for buffer truncated to %d bytes", i)
+		}
+	}
+}
+
+func TestDeserializerSticky(t *testing.T) {
+	var s Serializer
+
+	s.Begin(1)
+	s.Uint16(0xFFFF)
+	s.String("string")
+	s.End()
+
+	d := Deserializer{Buffer: s.Buffer}
+	d.Begin()
+	d.Uint16()
+	_ = d.String()
+	if n := d.Uint64(); n != 0 {
+		t.Errorf("Expected zero value after reading past the end, got %d", n)
+	}
+	if d.Error == nil {
+		t.Fatalf("Expected error after reading past the end")
+	}
+	if (d.Uint16() != 0) || (d.String() != "") {
+		t.Errorf("Expected zero values after error")
+	}
+	if err := d.End(); err != d.Error {
+		t.Errorf("Expected End to return %v, got %v", d.Error, err)
+	}
+
+	d = Deserializer{Buffer: s.Buffer, MaxLength: 5}
+	d.Begin()
+	d.Uint16()
+	if str := d.String(); (str != "") || (d.Error == nil) {
+		t.Errorf("Expected string above MaxLength to fail, got %q", str)
+	}
+
+	/* Length prefix claims more bytes than there are in the frame. */
+	s = Serializer{}
+	s.Begin(1)
+	s.Uint32(1 << 20)
+	s.End()
+
+	d = Deserializer{Buffer: s.Buffer}
+	d.Begin()
+	if bs := d.Bytes(); (bs != nil) || (d.End() == nil) {
+		t.Errorf("Expected error for length beyond the end of frame, got %d bytes", len(bs))
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	type Record struct {
+		Cents int64
+		Name  string
+	}
+
+	migrations := [...]Migration{
+		{2, func(t unsafe.Pointer) { (*Record)(t).Cents *= 100 }},
+		{3, func(t unsafe.Pointer) { (*Record)(t).Name = "<" + (*Record)(t).Name + ">" }},
+	}
+
+	tests := [...]struct {
+		Version  int
+		Input    Record
+		Expected Record
+	}{
+		{1, Record{5, "a"}, Record{500, "<a>"}},
+		{2, Record{5, "a"}, Record{5, "<a>"}},
+		{3, Record{5, "a"}, Record{5, "a"}},
+	}
+
+	for _, test := range tests {
+		r := test.Input
+		Migrate(unsafe.Pointer(&r), test.Version, migrations[:])
+		if r != test.Expected {
+			t.Errorf("Version %d: expected %+v, got %+v", test.Version, test.Expected, r)
+		}
+	}
+}
+
+func FuzzDeserializer(f *testing.F) {
+	var s Serializer
+
+	s.Begin(2)
+	s.Int32(7)
+	s.Varint(-300)
+	s.String("name")
+	s.Begin(1)
+	s.Bytes([]byte{1, 2, 3})
+	s.Float64(0.5)
+	s.End()
+	s.End()
+
+	f.Add(s.Buffer)
+	f.Add([]byte{})
+	f.Add(s.Buffer[:len(s.Buffer)/2])
+
+	f.Fuzz(func(t *testing.T, buffer []byte) {
+		d := Deserializer{Buffer: buffer, MaxLength: 1024}
+
+		d.Begin()
+		d.Int32()
+		d.Varint()
+		str := d.String()
+		d.Begin()
+		bs := d.Bytes()
+		d.Float64()
+		err1 := d.End()
+		err2 := d.End()
+
+		if (len(str) > d.MaxLength) || (len(bs) > d.MaxLength) {
+			t.Errorf("Lengths %d and %d exceed limit", len(str), len(bs))
+		}
+		if (err1 != nil) && (err2 == nil) {
+			t.Errorf("Error is not sticky: %v, %v", err1, err2)
+		}
+		if (err2 == nil) && (d.Error != nil) {
+			t.Errorf("End returned nil, but Error is %v", d.Error)
+		}
+	})
+}
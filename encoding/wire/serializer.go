@@ -1,23 +1,68 @@
 package wire
 
 import (
+	"hash/crc32"
 	"unsafe"
 
+	"github.com/anton2920/gofa/bools"
 	"github.com/anton2920/gofa/trace/trace_"
 )
 
+/* Every value is written as frame:
+ *     version uint32
+ *     length  uint32 (of fields)
+ *     fields  [length]byte
+ *     crc     uint32 (CRC32C of version, length and fields)
+ * Length allows older code to skip fields appended by newer versions, CRC detects corrupted records. */
 type Serializer struct {
 	Buffer []byte
+
+	/* Frames are offsets of frames, for which Begin was called, but End was not. */
+	Frames [MaxDepth]int
+	Depth  int
 }
 
+const (
+	/* MaxDepth is the maximum number of nested values. */
+	MaxDepth = 16
+
+	FrameHeaderSize  = 8
+	FrameTrailerSize = 4
+
+	/* MaxVarintSize is the maximum size of 64-bit integer in LEB128 encoding. */
+	MaxVarintSize = 10
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+func putUint32(buf []byte, n uint32) {
+	buf[0] = byte((n >> 0) & 0xFF)
+	buf[1] = byte((n >> 8) & 0xFF)
+	buf[2] = byte((n >> 16) & 0xFF)
+	buf[3] = byte((n >> 24) & 0xFF)
+}
+
+/* Begin starts frame of value with specified version. Values may be nested, e.g. struct inside of struct has its own version. */
 func (s *Serializer) Begin(version int) {
 	t := trace_.Begin("")
 
-	s.Int32(int32(version))
+	if s.Depth == MaxDepth {
+		panic("wire: values are nested too deep")
+	}
+	s.Frames[s.Depth] = len(s.Buffer)
+	s.Depth++
+
+	s.Uint32(uint32(version))
+	/* NOTE(anton2920): length is filled in End. */
+	s.Uint32(0)
 
 	trace_.End(t)
 }
 
+func (s *Serializer) Bool(b bool) {
+	s.Uint8(uint8(bools.ToInt(b)))
+}
+
 func (s *Serializer) Int8(n int8) {
 	s.Uint8(uint8(n))
 }
@@ -71,6 +116,28 @@ func (s *Serializer) Int(n int) {
 	s.Int64(int64(n))
 }
 
+/* Uvarint writes n in unsigned LEB128 encoding: 7 bits per byte, least significant first, high bit is set on all bytes except the last one. */
+func (s *Serializer) Uvarint(n uint64) {
+	t := trace_.Begin("")
+
+	for n >= 0x80 {
+		s.Buffer = append(s.Buffer, byte(n)|0x80)
+		n >>= 7
+	}
+	s.Buffer = append(s.Buffer, byte(n))
+
+	trace_.End(t)
+}
+
+/* Varint writes n in zigzag encoding, so integers with small absolute values take few bytes. */
+func (s *Serializer) Varint(n int64) {
+	s.Uvarint(uint64(n<<1) ^ uint64(n>>63))
+}
+
+func (s *Serializer) Float32(f float32) {
+	s.Uint32(*(*uint32)(unsafe.Pointer(&f)))
+}
+
 func (s *Serializer) Float64(f float64) {
 	s.Uint64(*(*uint64)(unsafe.Pointer(&f)))
 }
@@ -93,6 +160,14 @@ func (s *Serializer) Bytes(bytes []byte) {
 	trace_.End(t)
 }
 
+/* End finishes frame started by the last Begin. */
 func (s *Serializer) End() {
-	/* TODO(anton2920): calculate CRC32 or something... */
+	t := trace_.Begin("")
+
+	s.Depth--
+	frame := s.Buffer[s.Frames[s.Depth]:]
+	putUint32(frame[4:], uint32(len(frame)-FrameHeaderSize))
+	s.Uint32(crc32.Checksum(frame, castagnoli))
+
+	trace_.End(t)
 }
@@ -0,0 +1,76 @@
+package wire
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestSerializerVarint(t *testing.T) {
+	tests := [...]struct {
+		Value    int64
+		Expected []byte
+	}{
+		{0, []byte{0x00}},
+		{-1, []byte{0x01}},
+		{1, []byte{0x02}},
+		{-64, []byte{0x7F}},
+		{64, []byte{0x80, 0x01}},
+		{300, []byte{0xD8, 0x04}},
+		{math.MaxInt64, []byte{0xFE, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x01}},
+		{math.MinInt64, []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x01}},
+	}
+
+	for _, test := range tests {
+		var s Serializer
+
+		s.Varint(test.Value)
+		if !bytes.Equal(s.Buffer, test.Expected) {
+			t.Errorf("%d: expected % x, got % x", test.Value, test.Expected, s.Buffer)
+		}
+
+		d := Deserializer{Buffer: s.Buffer}
+		if n := d.Varint(); (n != test.Value) || (d.Error != nil) || (len(d.Buffer) != 0) {
+			t.Errorf("%d: got %d back (%v)", test.Value, n, d.Error)
+		}
+	}
+
+	invalid := [...][]byte{
+		{},
+		{0x80},
+		{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x02},
+		{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x01},
+	}
+	for _, buffer := range invalid {
+		d := Deserializer{Buffer: buffer}
+		if n := d.Uvarint(); (n != 0) || (d.Error == nil) {
+			t.Errorf("% x: expected error, got %d", buffer, n)
+		}
+	}
+}
+
+func FuzzSerializerVarint(f *testing.F) {
+	f.Add(int64(0), uint64(0))
+	f.Add(int64(math.MinInt64), uint64(math.MaxUint64))
+
+	f.Fuzz(func(t *testing.T, i int64, u uint64) {
+		var s Serializer
+
+		s.Varint(i)
+		s.Uvarint(u)
+		if len(s.Buffer) > 2*MaxVarintSize {
+			t.Errorf("Encoding is too long: % x", s.Buffer)
+		}
+
+		d := Deserializer{Buffer: s.Buffer}
+		if n := d.Varint(); n != i {
+			t.Errorf("Expected %d, got %d", i, n)
+		}
+		if n := d.Uvarint(); n != u {
+			t.Errorf("Expected %d, got %d", u, n)
+		}
+		if (d.Error != nil) || (len(d.Buffer) != 0) {
+			t.Errorf("Unexpected state after decoding: %v, %d bytes left", d.Error, len(d.Buffer))
+		}
+	})
+}
@@ -0,0 +1,405 @@
+/* Checks arguments of Formatter.Printf calls against compiled formats, like 'go vet' does for standard printf. Usage:
+ *     go run github.com/anton2920/gofa/fmt/cmd/check [packages]
+ * Packages are directories, 'dir/...' checks all packages under dir. Format must be known statically: either fmt.MustCompile or fmt.Compile call with string constant, or variable initialized with one. Exit status is 1 if problems are found. */
+package main
+
+import (
+	"errors"
+	stdfmt "fmt"
+	"go/ast"
+	"go/build"
+	"go/constant"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/anton2920/gofa/fmt"
+)
+
+const fmtPath = "github.com/anton2920/gofa/fmt"
+
+var printfs = [...]string{
+	"(*" + fmtPath + ".Formatter).Printf",
+	"(*github.com/anton2920/gofa/log.Formatter).Printf",
+}
+
+type Checker struct {
+	Fset *token.FileSet
+	Info *types.Info
+
+	/* Formats maps variables to constant formats they are initialized with. Invalid are variables initialized with formats, which were already reported. */
+	Formats map[types.Object]string
+	Invalid map[types.Object]struct{}
+
+	Stringer *types.Interface
+	Error    *types.Interface
+
+	/* Output receives problems, one per line. */
+	Output   io.Writer
+	Problems int
+}
+
+func NewChecker(fset *token.FileSet) *Checker {
+	var c Checker
+
+	c.Fset = fset
+	c.Formats = make(map[types.Object]string)
+	c.Invalid = make(map[types.Object]struct{})
+	c.Output = os.Stderr
+	c.Error = types.Universe.Lookup("error").Type().Underlying().(*types.Interface)
+
+	sig := types.NewSignatureType(nil, nil, nil, nil, types.NewTuple(types.NewVar(token.NoPos, nil, "", types.Typ[types.String])), false)
+	c.Stringer = types.NewInterfaceType([]*types.Func{types.NewFunc(token.NoPos, nil, "String", sig)}, nil).Complete()
+
+	return &c
+}
+
+func Unparen(e ast.Expr) ast.Expr {
+	for {
+		p, ok := e.(*ast.ParenExpr)
+		if !ok {
+			return e
+		}
+		e = p.X
+	}
+}
+
+func (c *Checker) Report(pos token.Pos, format string, args ...interface{}) {
+	stdfmt.Fprintf(c.Output, "%s: %s\n", c.Fset.Position(pos), stdfmt.Sprintf(format, args...))
+	c.Problems++
+}
+
+func (c *Checker) FuncName(call *ast.CallExpr) string {
+	var id *ast.Ident
+
+	switch fn := Unparen(call.Fun).(type) {
+	case *ast.Ident:
+		id = fn
+	case *ast.SelectorExpr:
+		id = fn.Sel
+	default:
+		return ""
+	}
+
+	if f, ok := c.Info.Uses[id].(*types.Func); ok {
+		return f.FullName()
+	}
+	return ""
+}
+
+/* IsCompile reports whether expr is fmt.Compile or fmt.MustCompile call. */
+func (c *Checker) IsCompile(expr ast.Expr) bool {
+	call, ok := Unparen(expr).(*ast.CallExpr)
+	if (!ok) || (len(call.Args) != 1) {
+		return false
+	}
+	name := c.FuncName(call)
+	return (name == fmtPath+".Compile") || (name == fmtPath+".MustCompile")
+}
+
+/* CompileFormat returns constant format, if call is fmt.Compile or fmt.MustCompile. */
+func (c *Checker) CompileFormat(expr ast.Expr) (string, bool) {
+	if !c.IsCompile(expr) {
+		return "", false
+	}
+	call := Unparen(expr).(*ast.CallExpr)
+
+	tv := c.Info.Types[call.Args[0]]
+	if (tv.Value == nil) || (tv.Value.Kind() != constant.String) {
+		c.Report(call.Pos(), "format is not a constant")
+		return "", false
+	}
+	format := constant.StringVal(tv.Value)
+
+	if _, err := fmt.Compile(format); err != nil {
+		c.Report(call.Args[0].Pos(), "%v", err)
+		return "", false
+	}
+	return format, true
+}
+
+/* collectFormat remembers, that obj is initialized with expr, if it is compiled format. */
+func (c *Checker) collectFormat(obj types.Object, expr ast.Expr) {
+	if obj == nil {
+		return
+	}
+	if format, ok := c.CompileFormat(expr); ok {
+		c.Formats[obj] = format
+	} else if c.IsCompile(expr) {
+		c.Invalid[obj] = struct{}{}
+	}
+}
+
+/* CollectFormats remembers variables initialized with compiled formats. */
+func (c *Checker) CollectFormats(file *ast.File) {
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.ValueSpec:
+			for i := 0; i < IntMin(len(n.Names), len(n.Values)); i++ {
+				c.collectFormat(c.Info.Defs[n.Names[i]], n.Values[i])
+			}
+		case *ast.AssignStmt:
+			if len(n.Lhs) != len(n.Rhs) {
+				break
+			}
+			for i := 0; i < len(n.Lhs); i++ {
+				id, ok := n.Lhs[i].(*ast.Ident)
+				if !ok {
+					continue
+				}
+				obj := c.Info.Defs[id]
+				if obj == nil {
+					obj = c.Info.Uses[id]
+				}
+				c.collectFormat(obj, n.Rhs[i])
+			}
+		}
+		return true
+	})
+}
+
+func IntMin(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+/* FormatObject returns variable, which is Printf argument. */
+func (c *Checker) FormatObject(expr ast.Expr) types.Object {
+	expr = Unparen(expr)
+	if u, ok := expr.(*ast.UnaryExpr); (ok) && (u.Op == token.AND) {
+		expr = Unparen(u.X)
+	}
+
+	var id *ast.Ident
+	switch e := expr.(type) {
+	case *ast.Ident:
+		id = e
+	case *ast.SelectorExpr:
+		id = e.Sel
+	default:
+		return nil
+	}
+	return c.Info.Uses[id]
+}
+
+/* Format returns constant format of Printf argument. */
+func (c *Checker) Format(expr ast.Expr) (string, bool) {
+	format, ok := c.Formats[c.FormatObject(expr)]
+	return format, ok
+}
+
+func (c *Checker) IsInteger(t types.Type) bool {
+	b, ok := t.Underlying().(*types.Basic)
+	return (ok) && ((b.Info()&types.IsInteger != 0) || (b.Kind() == types.Invalid))
+}
+
+func (c *Checker) IsBytes(t types.Type) bool {
+	s, ok := t.Underlying().(*types.Slice)
+	if !ok {
+		return false
+	}
+	b, ok := s.Elem().Underlying().(*types.Basic)
+	return (ok) && (b.Kind() == types.Byte)
+}
+
+/* Matches reports whether argument of type t can be printed with verb. It must agree with Formatter.printArg. */
+func (c *Checker) Matches(verb byte, t types.Type) bool {
+	if b, ok := t.(*types.Basic); (ok) && ((b.Kind() == types.UntypedNil) || (b.Kind() == types.Invalid)) {
+		return true
+	}
+
+	stringer := types.Implements(t, c.Stringer) || types.Implements(t, c.Error)
+	var info types.BasicInfo
+	var pointer bool
+	if b, ok := t.Underlying().(*types.Basic); ok {
+		info = b.Info()
+		pointer = b.Kind() == types.UnsafePointer
+	}
+	str := (info&types.IsString != 0) || (c.IsBytes(t))
+
+	switch verb {
+	case 'd', 'c':
+		return info&types.IsInteger != 0
+	case 'x', 'X':
+		return (info&types.IsInteger != 0) || (str) || ((pointer) && (verb == 'x'))
+	case 's', 'q':
+		return (str) || (stringer)
+	case 'f', 'e', 'g':
+		return info&types.IsFloat != 0
+	case 't':
+		return info&types.IsBoolean != 0
+	case 'v':
+		return (info&(types.IsInteger|types.IsFloat|types.IsBoolean|types.IsString) != 0) || (str) || (stringer) || (pointer)
+	}
+	return false
+}
+
+func (c *Checker) CheckPrintf(call *ast.CallExpr) {
+	/* NOTE(anton2920): wrappers forward args, so they cannot be checked. */
+	if (len(call.Args) == 0) || (call.Ellipsis != token.NoPos) {
+		return
+	}
+	if _, ok := c.Invalid[c.FormatObject(call.Args[0])]; ok {
+		/* NOTE(anton2920): bad format is reported, where it is compiled. */
+		return
+	}
+	format, ok := c.Format(call.Args[0])
+	if !ok {
+		if _, ok := c.CompileFormat(call.Args[0]); !ok {
+			c.Report(call.Args[0].Pos(), "format is not known statically")
+		}
+		return
+	}
+	compiled := fmt.MustCompile(format)
+
+	args := call.Args[1:]
+	if len(args) != compiled.Args {
+		c.Report(call.Pos(), "format %q wants %d args, got %d", format, compiled.Args, len(args))
+		return
+	}
+
+	/* NOTE(anton2920): types of arguments are unknown, if package or its imports do not type check, so such arguments are skipped. */
+	typeOf := func(e ast.Expr) types.Type {
+		if t := c.Info.TypeOf(e); t != nil {
+			return t
+		}
+		return types.Typ[types.Invalid]
+	}
+
+	var argn int
+	for _, op := range compiled.Ops {
+		if op.Verb == 0 {
+			continue
+		}
+		if op.WidthArg {
+			if t := typeOf(args[argn]); !c.IsInteger(t) {
+				c.Report(args[argn].Pos(), "format %q has width arg #%d of wrong type %v", format, argn+1, t)
+			}
+			argn++
+		}
+		if op.PrecArg {
+			if t := typeOf(args[argn]); !c.IsInteger(t) {
+				c.Report(args[argn].Pos(), "format %q has precision arg #%d of wrong type %v", format, argn+1, t)
+			}
+			argn++
+		}
+		if t := typeOf(args[argn]); !c.Matches(op.Verb, t) {
+			c.Report(args[argn].Pos(), "format %q has %%%c arg #%d of wrong type %v", format, op.Verb, argn+1, t)
+		}
+		argn++
+	}
+}
+
+func (c *Checker) CheckFile(file *ast.File) {
+	ast.Inspect(file, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok {
+			name := c.FuncName(call)
+			for _, printf := range printfs {
+				if name == printf {
+					c.CheckPrintf(call)
+				}
+			}
+		}
+		return true
+	})
+}
+
+func (c *Checker) CheckDir(imp types.Importer, dir string) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return stdfmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	pkg, err := build.ImportDir(dir, 0)
+	if err != nil {
+		var noGo *build.NoGoError
+		if errors.As(err, &noGo) {
+			return nil
+		}
+		return stdfmt.Errorf("failed to import %q: %w", dir, err)
+	}
+
+	var files []*ast.File
+	for _, name := range pkg.GoFiles {
+		file, err := parser.ParseFile(c.Fset, filepath.Join(dir, name), nil, 0)
+		if err != nil {
+			return stdfmt.Errorf("failed to parse file: %w", err)
+		}
+		files = append(files, file)
+	}
+	c.CheckFiles(imp, pkg.ImportPath, files)
+
+	return nil
+}
+
+/* CheckFiles type checks files of package with path and checks Printf calls in them. */
+func (c *Checker) CheckFiles(imp types.Importer, path string, files []*ast.File) {
+	c.Info = &types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+		Defs:  make(map[*ast.Ident]types.Object),
+		Uses:  make(map[*ast.Ident]types.Object),
+	}
+	conf := types.Config{Importer: imp, Error: func(error) {}}
+	conf.Check(path, c.Fset, files, c.Info)
+
+	for _, file := range files {
+		c.CollectFormats(file)
+	}
+	for _, file := range files {
+		c.CheckFile(file)
+	}
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("fmt/cmd/check: ")
+
+	patterns := os.Args[1:]
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+
+	var dirs []string
+	for _, pattern := range patterns {
+		if !strings.HasSuffix(pattern, "/...") {
+			dirs = append(dirs, pattern)
+			continue
+		}
+		root := strings.TrimSuffix(pattern, "/...")
+		if err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				if name := info.Name(); (path != root) && ((strings.HasPrefix(name, ".")) || (strings.HasPrefix(name, "_")) || (name == "testdata")) {
+					return filepath.SkipDir
+				}
+				dirs = append(dirs, path)
+			}
+			return nil
+		}); err != nil {
+			log.Fatalf("Failed to walk %q: %v", root, err)
+		}
+	}
+
+	fset := token.NewFileSet()
+	c := NewChecker(fset)
+	imp := importer.ForCompiler(fset, "source", nil)
+	for _, dir := range dirs {
+		if err := c.CheckDir(imp, dir); err != nil {
+			log.Fatalf("Failed to check package: %v", err)
+		}
+	}
+
+	if c.Problems > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	stdfmt "fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+)
+
+/* testFmtSource declares what checker needs from fmt package, so tests do not depend on how packages are found. */
+const testFmtSource = `package fmt
+
+type Format struct{ Args int }
+
+type Formatter struct{}
+
+func Compile(format string) (Format, error) { return Format{}, nil }
+
+func MustCompile(format string) Format { return Format{} }
+
+func (f *Formatter) Printf(format *Format, args ...interface{}) *Formatter { return f }
+`
+
+const testSource = `package test
+
+import (
+	"unsafe"
+
+	"github.com/anton2920/gofa/fmt"
+)
+
+type ID int32
+
+type S struct{}
+
+func (S) String() string { return "" }
+
+var format = fmt.MustCompile(%q)
+
+func test(f *fmt.Formatter, i int, id ID, u uint8, x float64, b bool, s string, bs []byte, st S, err error, p unsafe.Pointer) {
+	%s
+}
+`
+
+type testImporter struct {
+	Fmt *types.Package
+}
+
+func (imp testImporter) Import(path string) (*types.Package, error) {
+	switch path {
+	case fmtPath:
+		return imp.Fmt, nil
+	case "unsafe":
+		return types.Unsafe, nil
+	}
+	return nil, stdfmt.Errorf("package %q is not available", path)
+}
+
+/* testCheck checks stmt in function with arguments of different types, while format variable is initialized with format. */
+func testCheck(t *testing.T, format string, stmt string) (int, string) {
+	fset := token.NewFileSet()
+
+	file, err := parser.ParseFile(fset, "fmt.go", testFmtSource, 0)
+	if err != nil {
+		t.Fatalf("Failed to parse fmt package: %v", err)
+	}
+	pkg, err := new(types.Config).Check(fmtPath, fset, []*ast.File{file}, nil)
+	if err != nil {
+		t.Fatalf("Failed to check fmt package: %v", err)
+	}
+
+	file, err = parser.ParseFile(fset, "test.go", stdfmt.Sprintf(testSource, format, stmt), 0)
+	if err != nil {
+		t.Fatalf("Failed to parse test package: %v", err)
+	}
+
+	var out bytes.Buffer
+	c := NewChecker(fset)
+	c.Output = &out
+	c.CheckFiles(testImporter{pkg}, "test", []*ast.File{file})
+
+	return c.Problems, out.String()
+}
+
+func TestCheckMatching(t *testing.T) {
+	tests := [...]struct {
+		Format string
+		Args   string
+	}{
+		{"%d", "i"},
+		{"%d", "id"},
+		{"%5d %-3d %03d", "i, u, id"},
+		{"%c", "u"},
+		{"%x %X", "i, id"},
+		{"%x %X", "s, bs"},
+		{"%x", "p"},
+		{"%s %q", "s, bs"},
+		{"%s", "st"},
+		{"%s", "err"},
+		{"%s", "nil"},
+		{"%f %e %g", "x, x, x"},
+		{"%.2f", "x"},
+		{"%t", "b"},
+		{"%v %v %v %v %v %v %v", "i, x, b, s, bs, st, p"},
+		{"%*d", "u, i"},
+		{"%-*.*f", "i, id, x"},
+		{"%.*s", "i, s"},
+		{"100%% %d", "i"},
+		{"no verbs", ""},
+	}
+	for _, test := range tests {
+		args := test.Args
+		if len(args) > 0 {
+			args = ", " + args
+		}
+		if problems, out := testCheck(t, test.Format, "f.Printf(&format"+args+")"); problems != 0 {
+			t.Errorf("%q with %q: expected no problems, got %d:\n%s", test.Format, test.Args, problems, out)
+		}
+	}
+}
+
+func TestCheckMismatched(t *testing.T) {
+	tests := [...]struct {
+		Format  string
+		Stmt    string
+		Problem string
+	}{
+		{"%d", "f.Printf(&format, s)", `%d arg #1 of wrong type string`},
+		{"%d", "f.Printf(&format, x)", `%d arg #1 of wrong type float64`},
+		{"%c", "f.Printf(&format, b)", `%c arg #1 of wrong type bool`},
+		{"%X", "f.Printf(&format, p)", `%X arg #1 of wrong type unsafe.Pointer`},
+		{"%x", "f.Printf(&format, x)", `%x arg #1 of wrong type float64`},
+		{"%s", "f.Printf(&format, i)", `%s arg #1 of wrong type int`},
+		{"%q", "f.Printf(&format, id)", `%q arg #1 of wrong type test.ID`},
+		{"%f", "f.Printf(&format, i)", `%f arg #1 of wrong type int`},
+		{"%t", "f.Printf(&format, s)", `%t arg #1 of wrong type string`},
+		{"%v", "f.Printf(&format, f)", `%v arg #1 of wrong type *`},
+		{"%d %s", "f.Printf(&format, i, i)", `%s arg #2 of wrong type int`},
+		{"%*d", "f.Printf(&format, x, i)", `width arg #1 of wrong type float64`},
+		{"%.*f", "f.Printf(&format, s, x)", `precision arg #1 of wrong type string`},
+		{"%d", "f.Printf(&format, i, i)", `wants 1 args, got 2`},
+		{"%d %d", "f.Printf(&format, i)", `wants 2 args, got 1`},
+		{"%d", "other := fmt.MustCompile(s); f.Printf(&other, i)", `format is not a constant`},
+		{"%d", "var other fmt.Format; f.Printf(&other, i)", `format is not known statically`},
+		{"%d", `f.Printf(&format, i); bad := fmt.MustCompile("%y"); _ = bad`, `unknown verb y`},
+	}
+	for _, test := range tests {
+		problems, out := testCheck(t, test.Format, test.Stmt)
+		if (problems != 1) || (!strings.Contains(out, test.Problem)) {
+			t.Errorf("%q with %q: expected problem %q, got %d:\n%s", test.Format, test.Stmt, test.Problem, problems, out)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+/* Generates table of 128-bit mantissas of powers of 10 used for formatting of floats. Usage:
+ *     //go:generate go run github.com/anton2920/gofa/fmt/cmd
+ * Table goes to 'pow10.go' in the current directory. */
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"log"
+	"math/big"
+	"os"
+)
+
+const (
+	minExp = -348
+	maxExp = 347
+)
+
+const header = `/* Code generated by 'go run github.com/anton2920/gofa/fmt/cmd'. DO NOT EDIT. */
+
+package fmt
+
+//go:generate go run github.com/anton2920/gofa/fmt/cmd
+
+const (
+	pow10Min = %d
+	pow10Max = %d
+)
+
+/* pow10Tab holds 128-bit mantissas of powers of 10, scaled so the high bit is set. Value is Hi<<64 - Lo, rounded up. */
+var pow10Tab = [...]pow10{
+`
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("fmt/cmd: ")
+
+	one := big.NewInt(1)
+	ten := big.NewInt(10)
+	two := big.NewRat(2, 1)
+	b64 := new(big.Int).Lsh(one, 64)
+	r128 := new(big.Rat).SetInt(new(big.Int).Lsh(one, 128))
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, header, minExp, maxExp)
+	for e := int64(minExp); e <= maxExp; e++ {
+		var r *big.Rat
+		if e >= 0 {
+			r = new(big.Rat).SetInt(new(big.Int).Exp(ten, big.NewInt(e), nil))
+		} else {
+			r = new(big.Rat).SetFrac(one, new(big.Int).Exp(ten, big.NewInt(-e), nil))
+		}
+
+		var be int
+		for r.Cmp(r128) < 0 {
+			r.Mul(r, two)
+			be++
+		}
+		for r.Cmp(r128) >= 0 {
+			r.Quo(r, two)
+			be--
+		}
+
+		/* NOTE: mantissa is rounded up and stored as hi<<64 - lo, so lo can be subtracted without borrow handling in the common case. */
+		d := new(big.Int).Div(r.Num(), r.Denom())
+		hi, lo := new(big.Int).DivMod(d, b64, new(big.Int))
+		uhi, ulo := hi.Uint64(), lo.Uint64()
+		if !r.IsInt() {
+			ulo++
+			if ulo == 0 {
+				uhi++
+			}
+		}
+		if ulo != 0 {
+			uhi++
+			ulo = -ulo
+		}
+		fmt.Fprintf(&buf, "\t{%#016x, %#016x}, /* 1e%d * 2^%d */\n", uhi, ulo, e, be)
+	}
+	buf.WriteString("}\n")
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatalf("Failed to format source: %v", err)
+	}
+	if err := os.WriteFile("pow10.go", src, 0644); err != nil {
+		log.Fatalf("Failed to write table: %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package fmt
+
+/* putFrac puts fraction of v with prec digits at the end of buf, omitting trailing zeroes, and returns new start of buf and integer part of v. */
+func putFrac(buf []byte, v uint64, prec int) (int, uint64) {
+	var print bool
+
+	w := len(buf)
+	for i := 0; i < prec; i++ {
+		digit := v % 10
+		print = (print) || (digit != 0)
+		if print {
+			w--
+			buf[w] = byte(digit) + '0'
+		}
+		v /= 10
+	}
+	if print {
+		w--
+		buf[w] = '.'
+	}
+	return w, v
+}
+
+/* putUint puts v at the end of buf and returns new start of buf. */
+func putUint(buf []byte, v uint64) int {
+	w := len(buf)
+	for {
+		w--
+		buf[w] = byte(v%10) + '0'
+		v /= 10
+		if v == 0 {
+			break
+		}
+	}
+	return w
+}
+
+/* putDuration puts d nanoseconds at the end of buf in format of Go's time.Duration, e.g. '1h2m3.5s' or '1.5ms', but with 'us' for microseconds. Buffer must have at least 32 bytes. */
+func putDuration(buf []byte, d int64) int {
+	w := len(buf)
+
+	u := uint64(d)
+	neg := d < 0
+	if neg {
+		u = -u
+	}
+
+	if u < 1000000000 {
+		var prec int
+
+		if u == 0 {
+			w -= 2
+			copy(buf[w:], "0s")
+			return w
+		}
+
+		w--
+		buf[w] = 's'
+		w--
+		switch {
+		case u < 1000:
+			buf[w] = 'n'
+		case u < 1000000:
+			prec = 3
+			buf[w] = 'u'
+		default:
+			prec = 6
+			buf[w] = 'm'
+		}
+		w, u = putFrac(buf[:w], u, prec)
+		w = putUint(buf[:w], u)
+	} else {
+		w--
+		buf[w] = 's'
+		w, u = putFrac(buf[:w], u, 9)
+
+		w = putUint(buf[:w], u%60)
+		u /= 60
+		if u > 0 {
+			w--
+			buf[w] = 'm'
+			w = putUint(buf[:w], u%60)
+			u /= 60
+			if u > 0 {
+				w--
+				buf[w] = 'h'
+				w = putUint(buf[:w], u)
+			}
+		}
+	}
+
+	if neg {
+		w--
+		buf[w] = '-'
+	}
+	return w
+}
@@ -0,0 +1,28 @@
+package fmt
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDur(t *testing.T) {
+	var f Formatter
+
+	f.InitWithByteSlice(make([]byte, 64))
+	durations := []int64{0, 1, -1, 999, 1000, 1500, 999999, 1000000, 1500000, 999999999, 1000000000, 1500000000, 59999999999, 3600000000000, 3723500000000, math.MaxInt64, math.MinInt64, math.MinInt64 + 1}
+
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		durations = append(durations, rnd.Int63()>>uint(rnd.Intn(63))-rnd.Int63()>>uint(rnd.Intn(63)))
+	}
+
+	for _, d := range durations {
+		expected := strings.Replace(time.Duration(d).String(), "µs", "us", 1)
+		if got := f.Reset().Dur(d).String(); got != expected {
+			t.Errorf("%d: expected %q, got %q", d, expected, got)
+		}
+	}
+}
@@ -0,0 +1,330 @@
+package fmt
+
+import (
+	"math"
+	"math/bits"
+
+	"github.com/anton2920/gofa/ints"
+)
+
+/* floatType describes IEEE 754 binary format. */
+type floatType struct {
+	MantBits int
+	ExpBits  int
+	Bias     int
+	MinExp   int
+}
+
+var (
+	float32Type = floatType{MantBits: 23, ExpBits: 8, Bias: -127, MinExp: -189}
+	float64Type = floatType{MantBits: 52, ExpBits: 11, Bias: -1023, MinExp: -1085}
+)
+
+/* decimal is exact decimal representation of float, used when more than 18 digits are needed. */
+type decimal struct {
+	D  [800]byte
+	Nd int
+	Dp int
+}
+
+/* mul multiplies little-endian digits by x, x * 9 + carry must fit in uint64. */
+func (d *decimal) mul(x uint64) {
+	var carry uint64
+
+	for i := 0; i < d.Nd; i++ {
+		carry += uint64(d.D[i]) * x
+		d.D[i] = byte(carry % 10)
+		carry /= 10
+	}
+	for carry > 0 {
+		d.D[d.Nd] = byte(carry % 10)
+		d.Nd++
+		carry /= 10
+	}
+}
+
+/* Assign sets d to mant * 2^exp exactly. Negative powers of 2 are computed as mant * 5^-exp * 10^exp. */
+func (d *decimal) Assign(mant uint64, exp int) {
+	d.Nd = 0
+	for ; mant > 0; mant /= 10 {
+		d.D[d.Nd] = byte(mant % 10)
+		d.Nd++
+	}
+
+	if exp >= 0 {
+		for ; exp > 0; exp -= 28 {
+			d.mul(1 << uint(ints.Min(exp, 28)))
+		}
+		d.Dp = d.Nd
+	} else {
+		for k := -exp; k > 0; k -= 13 {
+			d.mul(pow5(ints.Min(k, 13)))
+		}
+		d.Dp = d.Nd + exp
+	}
+
+	for i := 0; i < d.Nd/2; i++ {
+		d.D[i], d.D[d.Nd-1-i] = d.D[d.Nd-1-i], d.D[i]
+	}
+	for i := 0; i < d.Nd; i++ {
+		d.D[i] += '0'
+	}
+	for (d.Nd > 0) && (d.D[d.Nd-1] == '0') {
+		d.Nd--
+	}
+}
+
+func pow5(n int) uint64 {
+	x := uint64(1)
+	for i := 0; i < n; i++ {
+		x *= 5
+	}
+	return x
+}
+
+/* Round rounds d to nd digits, half to even. */
+func (d *decimal) Round(nd int) {
+	if (nd < 0) || (nd >= d.Nd) {
+		return
+	}
+
+	var up bool
+	if (d.D[nd] == '5') && (nd+1 == d.Nd) {
+		up = (nd > 0) && ((d.D[nd-1]-'0')%2 == 1)
+	} else {
+		up = d.D[nd] >= '5'
+	}
+
+	if up {
+		i := nd - 1
+		for (i >= 0) && (d.D[i] == '9') {
+			i--
+		}
+		if i < 0 {
+			d.D[0] = '1'
+			d.Nd = 1
+			d.Dp++
+		} else {
+			d.D[i]++
+			d.Nd = i + 1
+		}
+	} else {
+		d.Nd = nd
+		for (d.Nd > 0) && (d.D[d.Nd-1] == '0') {
+			d.Nd--
+		}
+	}
+}
+
+func (f *Formatter) putZeroes(n int) {
+	for i := 0; i < n; i++ {
+		f.putByte('0')
+	}
+}
+
+/* putEFG writes nd digits with decimal point at dp in format fmt, which is 'e', 'f' or 'g', like strconv.AppendFloat. */
+func (f *Formatter) putEFG(neg bool, digits []byte, dp, nd, prec int, fmt byte, shortest bool) {
+	if fmt == 'g' {
+		/* NOTE(anton2920): trailing zeros in 'e' form are trimmed, shortest form uses precision 6 for choosing format. */
+		eprec := prec
+		if (eprec > nd) && (nd >= dp) {
+			eprec = nd
+		}
+		if shortest {
+			eprec = 6
+		}
+		if exp := dp - 1; (exp < -4) || (exp >= eprec) {
+			prec = ints.Min(prec, nd) - 1
+			fmt = 'e'
+		} else {
+			if prec > dp {
+				prec = nd
+			}
+			prec = ints.Max(prec-dp, 0)
+			fmt = 'f'
+		}
+	}
+
+	n := int(b2u(neg))
+	if prec > 0 {
+		n += 1 + prec
+	}
+
+	switch fmt {
+	case 'e':
+		exp := dp - 1
+		if nd == 0 {
+			exp = 0
+		}
+		n += 1 + len("e+00") + int(b2u((exp >= 100) || (exp <= -100)))
+
+		f.applyWidth(n, false)
+		if neg {
+			f.putByte('-')
+		}
+		if nd > 0 {
+			f.putByte(digits[0])
+		} else {
+			f.putByte('0')
+		}
+		if prec > 0 {
+			f.putByte('.')
+			m := ints.Min(nd, prec+1)
+			for i := 1; i < m; i++ {
+				f.putByte(digits[i])
+			}
+			f.putZeroes(prec + 1 - ints.Max(m, 1))
+		}
+
+		f.putByte('e')
+		if exp < 0 {
+			f.putByte('-')
+			exp = -exp
+		} else {
+			f.putByte('+')
+		}
+		if exp >= 100 {
+			f.putByte(byte(exp/100) + '0')
+		}
+		f.putByte(byte(exp/10%10) + '0')
+		f.putByte(byte(exp%10) + '0')
+	case 'f':
+		n += ints.Max(dp, 1)
+
+		f.applyWidth(n, false)
+		if neg {
+			f.putByte('-')
+		}
+		if dp > 0 {
+			m := ints.Min(nd, dp)
+			for i := 0; i < m; i++ {
+				f.putByte(digits[i])
+			}
+			f.putZeroes(dp - m)
+		} else {
+			f.putByte('0')
+		}
+		if prec > 0 {
+			f.putByte('.')
+			lz := ints.Min(prec, ints.Max(0, -dp))
+			off := dp + lz
+			m := ints.Min(prec-lz, ints.Max(0, nd-off))
+			f.putZeroes(lz)
+			for i := 0; i < m; i++ {
+				f.putByte(digits[off+i])
+			}
+			f.putZeroes(prec - lz - m)
+		}
+	}
+	f.applyWidth(n, true)
+}
+
+/* putFloatDecimal is slow path for more than 18 digits. */
+func (f *Formatter) putFloatDecimal(neg bool, mant uint64, exp int, prec int, fmt byte) {
+	var d decimal
+
+	d.Assign(mant, exp)
+	switch fmt {
+	case 'e':
+		d.Round(prec + 1)
+	case 'f':
+		d.Round(d.Dp + prec)
+	case 'g':
+		d.Round(prec)
+	}
+	f.putEFG(neg, d.D[:], d.Dp, d.Nd, prec, fmt, false)
+}
+
+/* putFloat writes x of type t in format fmt ('e', 'f' or 'g') with prec digits, like strconv.AppendFloat. Negative prec means the shortest representation, which parses back to x. */
+func (f *Formatter) putFloat(x uint64, t *floatType, fmt byte, prec int) {
+	var digits [32]byte
+	var dp, nd int
+
+	neg := x>>uint(t.ExpBits+t.MantBits) != 0
+	exp := int(x>>uint(t.MantBits)) & (1<<uint(t.ExpBits) - 1)
+	mant := x & (1<<uint(t.MantBits) - 1)
+	if exp == 1<<uint(t.ExpBits)-1 {
+		var s string
+		switch {
+		case mant != 0:
+			s = "NaN"
+		case neg:
+			s = "-Inf"
+		default:
+			s = "+Inf"
+		}
+		f.S(s)
+		return
+	}
+	if exp == 0 {
+		exp++
+	} else {
+		mant |= 1 << uint(t.MantBits)
+	}
+	exp += t.Bias
+
+	if (fmt == 'g') && (prec == 0) {
+		prec = 1
+	}
+
+	if mant == 0 {
+		f.putEFG(neg, nil, 0, 0, prec, fmt, prec < 0)
+		return
+	}
+
+	s := 64 - bits.Len64(mant)
+	m := mant << uint(s)
+	e := exp - s - t.MantBits
+
+	if prec < 0 {
+		d, p := shortestDecimal(m, e, t.MantBits, t.MinExp)
+		dp, nd = putDigits(digits[:], d, p, numDigits(d))
+		switch fmt {
+		case 'e':
+			prec = ints.Max(nd-1, 0)
+		case 'f':
+			prec = ints.Max(nd-dp, 0)
+		case 'g':
+			prec = nd
+		}
+		f.putEFG(neg, digits[:], dp, nd, prec, fmt, true)
+		return
+	}
+
+	n := prec
+	switch fmt {
+	case 'e':
+		n++
+	case 'f':
+		/* NOTE(anton2920): upper estimate, fixedDecimal drops extra digits. */
+		if exp >= 0 {
+			n = 1 + log10Pow2(1+exp) + prec
+		} else {
+			n = 1 + prec - log10Pow2(-exp)
+		}
+	}
+	if n > 18 {
+		f.putFloatDecimal(neg, mant, exp-t.MantBits, prec, fmt)
+		return
+	}
+
+	/* NOTE(anton2920): n <= 0 happens for 'f' on small numbers, which are all zeroes. */
+	if n > 0 {
+		if d, p := fixedDecimal(m, e, n, prec, fmt); d != 0 {
+			dp, nd = putDigits(digits[:], d, p, numDigits(d))
+		}
+	}
+	f.putEFG(neg, digits[:], dp, nd, prec, fmt, false)
+}
+
+func (f *Formatter) putFloat32(x float32, fmt byte, prec int) *Formatter {
+	f.putFloat(uint64(math.Float32bits(x)), &float32Type, fmt, prec)
+	f.Precision = 0
+	return f
+}
+
+func (f *Formatter) putFloat64(x float64, fmt byte, prec int) *Formatter {
+	f.putFloat(math.Float64bits(x), &float64Type, fmt, prec)
+	f.Precision = 0
+	return f
+}
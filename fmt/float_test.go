@@ -0,0 +1,134 @@
+package fmt
+
+import (
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"testing"
+)
+
+var formats = [...]byte{'e', 'f', 'g'}
+
+func testFloat(t *testing.T, f *Formatter, buf []byte, x uint64, bitSize int, fmt byte, prec int) {
+	t.Helper()
+
+	var v float64
+	f.Reset()
+	if bitSize == 32 {
+		v = float64(math.Float32frombits(uint32(x)))
+		f.putFloat(x, &float32Type, fmt, prec)
+	} else {
+		v = math.Float64frombits(x)
+		f.putFloat(x, &float64Type, fmt, prec)
+	}
+
+	expected := strconv.AppendFloat(buf[:0], v, fmt, prec, bitSize)
+	if got := f.Bytes(); string(got) != string(expected) {
+		t.Fatalf("%#x ('%c', %d): expected %q, got %q", x, fmt, prec, expected, got)
+	}
+}
+
+func TestFloatSpecial(t *testing.T) {
+	var f Formatter
+
+	buf := make([]byte, 2048)
+	f.InitWithByteSlice(make([]byte, 2048))
+
+	values := [...]float64{0, math.Copysign(0, -1), 1, -1, 0.1, 0.5, 1.5, 2.5, 123456789, 1e21, 1e-7, 9.5, 0.95, 99.99, math.MaxFloat64, math.SmallestNonzeroFloat64, math.MaxFloat32, math.SmallestNonzeroFloat32, 5e-324, 1<<53 + 1, math.Inf(1), math.Inf(-1), math.NaN()}
+	for _, v := range values {
+		for _, fmt := range formats {
+			for _, prec := range [...]int{-1, 0, 1, 2, 5, 6, 15, 16, 17, 18, 19, 20, 30, 60, 400, 1100} {
+				testFloat(t, &f, buf, math.Float64bits(v), 64, fmt, prec)
+				testFloat(t, &f, buf, uint64(math.Float32bits(float32(v))), 32, fmt, prec)
+			}
+		}
+	}
+}
+
+func TestFloat64(t *testing.T) {
+	var f Formatter
+
+	buf := make([]byte, 2048)
+	f.InitWithByteSlice(make([]byte, 2048))
+
+	n := 200_000
+	if testing.Short() {
+		n /= 100
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < n; i++ {
+		x := rnd.Uint64()
+		if i%2 == 0 {
+			/* NOTE(anton2920): typical values have small exponents. */
+			x = math.Float64bits(rnd.NormFloat64() * math.Pow(10, float64(rnd.Intn(40)-20)))
+		}
+		for _, fmt := range formats {
+			testFloat(t, &f, buf, x, 64, fmt, -1)
+			testFloat(t, &f, buf, x, 64, fmt, rnd.Intn(25))
+		}
+	}
+}
+
+/* TestFloat32 compares all float32 values with strconv, if GOFA_EXHAUSTIVE is set. Otherwise values are sampled. */
+func TestFloat32(t *testing.T) {
+	var f Formatter
+
+	buf := make([]byte, 2048)
+	f.InitWithByteSlice(make([]byte, 2048))
+
+	step := uint64(7919)
+	if len(os.Getenv("GOFA_EXHAUSTIVE")) > 0 {
+		step = 1
+	} else if testing.Short() {
+		step *= 100
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	for x := uint64(0); x < 1<<32; x += step {
+		testFloat(t, &f, buf, x, 32, 'g', -1)
+		testFloat(t, &f, buf, x, 32, 'e', -1)
+		if step > 1 {
+			for _, fmt := range formats {
+				testFloat(t, &f, buf, x, 32, fmt, rnd.Intn(20))
+			}
+		}
+	}
+}
+
+func TestFloatWidth(t *testing.T) {
+	var f Formatter
+
+	f.InitWithByteSlice(make([]byte, 64))
+	tests := [...]struct {
+		Width    int
+		Prec     int
+		Value    float64
+		Expected string
+	}{
+		{10, 2, 3.14159, "      3.14"},
+		{-10, 2, -3.14159, "-3.14     "},
+		{3, 3, 2.5, "2.500"},
+		{0, 0, 1.0 / 3, "0.333333"},
+	}
+	for _, test := range tests {
+		if got := f.Reset().W(test.Width).Prec(test.Prec).F(test.Value).S("|").String(); got != test.Expected+"|" {
+			t.Errorf("Expected %q, got %q", test.Expected+"|", got)
+		}
+	}
+
+	if got := f.Reset().W(12).E32(1.5).String(); got != "1.500000e+00" {
+		t.Errorf("Expected %q, got %q", "1.500000e+00", got)
+	}
+	if got := f.Reset().W(-6).G(0.1).S("|").String(); got != "0.1   |" {
+		t.Errorf("Expected %q, got %q", "0.1   |", got)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		f.Reset().E(math.Pi).F(1e300).Prec(30).F32(1.1).G64(5e-324)
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations, got %v", allocs)
+	}
+}
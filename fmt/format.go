@@ -0,0 +1,415 @@
+package fmt
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"unicode/utf8"
+	"unsafe"
+
+	"github.com/anton2920/gofa/bytes"
+	"github.com/anton2920/gofa/ints"
+	"github.com/anton2920/gofa/pointers"
+)
+
+/* Format is printf-style format string compiled into Formatter operations. Supported verbs are:
+ *     %d     integer;
+ *     %x %X  integer, string or []byte in hex;
+ *     %s     string, []byte, error or value with String() method;
+ *     %q     quoted string or []byte;
+ *     %c     integer as rune;
+ *     %t     bool;
+ *     %f %e %g  float, %g without precision is the shortest representation;
+ *     %v     any of the above in default format, []byte is written as string;
+ *     %%     percent sign.
+ * Flags are '-' for left alignment and '0' for padding integers with zeroes. Width and precision are numbers or '*', which takes int argument. Precision of %s and %q limits number of bytes. */
+type Format struct {
+	Ops  []FormatOp
+	Args int
+}
+
+type FormatOp struct {
+	/* Literal is written as is, if Verb is 0. */
+	Literal string
+
+	Verb  byte
+	Left  bool
+	Zero  bool
+	Width int
+	Prec  int /* -1 if not set. */
+
+	WidthArg bool
+	PrecArg  bool
+}
+
+type FormatError struct {
+	Format string
+	Pos    int
+	Msg    string
+}
+
+func (e *FormatError) Error() string {
+	return "bad format " + strconv.Quote(e.Format) + " at " + strconv.Itoa(e.Pos) + ": " + e.Msg
+}
+
+func parseFormatInt(s string, i int) (int, int) {
+	var n int
+	for (i < len(s)) && (s[i] >= '0') && (s[i] <= '9') {
+		n = n*10 + int(s[i]-'0')
+		i++
+	}
+	return n, i
+}
+
+/* Compile parses format. Result should be kept and reused, e.g. in package-level variable. */
+func Compile(format string) (Format, error) {
+	var f Format
+
+	start := 0
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		pos := i
+
+		if i == len(format)-1 {
+			return Format{}, &FormatError{Format: format, Pos: pos, Msg: "missing verb"}
+		}
+		if format[i+1] == '%' {
+			f.Ops = append(f.Ops, FormatOp{Literal: format[start : i+1]})
+			i++
+			start = i + 1
+			continue
+		}
+		if start < i {
+			f.Ops = append(f.Ops, FormatOp{Literal: format[start:i]})
+		}
+		i++
+
+		op := FormatOp{Prec: -1}
+		for ; (i < len(format)) && ((format[i] == '-') || (format[i] == '0')); i++ {
+			if format[i] == '-' {
+				op.Left = true
+			} else {
+				op.Zero = true
+			}
+		}
+
+		if (i < len(format)) && (format[i] == '*') {
+			op.WidthArg = true
+			f.Args++
+			i++
+		} else {
+			op.Width, i = parseFormatInt(format, i)
+		}
+
+		if (i < len(format)) && (format[i] == '.') {
+			i++
+			if (i < len(format)) && (format[i] == '*') {
+				op.PrecArg = true
+				f.Args++
+				i++
+			} else {
+				op.Prec, i = parseFormatInt(format, i)
+			}
+		}
+
+		if i == len(format) {
+			return Format{}, &FormatError{Format: format, Pos: pos, Msg: "missing verb"}
+		}
+		switch op.Verb = format[i]; op.Verb {
+		default:
+			return Format{}, &FormatError{Format: format, Pos: pos, Msg: "unknown verb " + format[i:i+1]}
+		case 'd', 'x', 'X', 's', 'q', 'c', 't', 'f', 'e', 'g', 'v':
+		}
+		f.Args++
+		f.Ops = append(f.Ops, op)
+		start = i + 1
+	}
+	if start < len(format) {
+		f.Ops = append(f.Ops, FormatOp{Literal: format[start:]})
+	}
+
+	return f, nil
+}
+
+/* MustCompile is like Compile, but panics on error. */
+func MustCompile(format string) Format {
+	f, err := Compile(format)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+/* zeroPad writes s padded to width with zeroes after sign. */
+func (f *Formatter) zeroPad(s string, width int) {
+	var sign int
+	if (len(s) > 0) && (s[0] == '-') {
+		f.putByte('-')
+		sign = 1
+	}
+	for i := len(s); i < width; i++ {
+		f.putByte('0')
+	}
+	f.put(s[sign:])
+}
+
+func (f *Formatter) printUint(op *FormatOp, neg bool, x uint64) {
+	var buf [65]byte
+
+	base := uint64(10)
+	digits := "0123456789abcdef"
+	switch op.Verb {
+	case 'x':
+		base = 16
+	case 'X':
+		base = 16
+		digits = "0123456789ABCDEF"
+	}
+
+	n := len(buf)
+	for {
+		n--
+		buf[n] = digits[x%base]
+		x /= base
+		if x == 0 {
+			break
+		}
+	}
+	if neg {
+		n--
+		buf[n] = '-'
+	}
+
+	s := bytes.AsString(buf[n:])
+	if (op.Zero) && (!op.Left) && (f.Width > len(s)) {
+		width := f.Width
+		f.Width = 0
+		f.zeroPad(s, width)
+	} else {
+		f.S(s)
+	}
+}
+
+func (f *Formatter) printInt(op *FormatOp, x int64) {
+	if x < 0 {
+		f.printUint(op, true, uint64(-x))
+	} else {
+		f.printUint(op, false, uint64(x))
+	}
+}
+
+func (f *Formatter) printHex(op *FormatOp, s string) {
+	digits := "0123456789abcdef"
+	if op.Verb == 'X' {
+		digits = "0123456789ABCDEF"
+	}
+
+	f.applyWidth(2*len(s), false)
+	for i := 0; i < len(s); i++ {
+		f.putByte(digits[s[i]>>4])
+		f.putByte(digits[s[i]&0xF])
+	}
+	f.applyWidth(2*len(s), true)
+}
+
+func (f *Formatter) printString(op *FormatOp, s string) {
+	if (op.Prec >= 0) && (len(s) > op.Prec) {
+		s = s[:op.Prec]
+	}
+	switch op.Verb {
+	case 'x', 'X':
+		f.printHex(op, s)
+	case 'q':
+		f.Q(s)
+	default:
+		f.S(s)
+	}
+}
+
+func (f *Formatter) printFloat(op *FormatOp, x float64, bitSize int) {
+	verb := op.Verb
+	prec := op.Prec
+	if verb == 'v' {
+		verb = 'g'
+	}
+	if (prec < 0) && (verb != 'g') {
+		prec = 6
+	}
+
+	if bitSize == 32 {
+		f.putFloat32(float32(x), verb, prec)
+	} else {
+		f.putFloat64(x, verb, prec)
+	}
+}
+
+func (f *Formatter) printRune(r rune) {
+	var buf [utf8.UTFMax]byte
+	n := utf8.EncodeRune(buf[:], r)
+	f.S(bytes.AsString(buf[:n]))
+}
+
+func (f *Formatter) printBad(op *FormatOp, what string) {
+	f.Width = 0
+	f.S("%!").printRune(rune(op.Verb))
+	f.S("(").S(what).S(")")
+}
+
+/* printArg writes arg according to op. Named types are handled by their kind through reflect, which does not allocate. */
+func (f *Formatter) printArg(op *FormatOp, arg interface{}) {
+	verb := op.Verb
+
+	switch v := arg.(type) {
+	case nil:
+		f.S("<nil>")
+		return
+	case string:
+		if (verb == 's') || (verb == 'q') || (verb == 'v') || (verb == 'x') || (verb == 'X') {
+			f.printString(op, v)
+			return
+		}
+	case []byte:
+		if (verb == 's') || (verb == 'q') || (verb == 'v') || (verb == 'x') || (verb == 'X') {
+			f.printString(op, bytes.AsString(v))
+			return
+		}
+	case error:
+		if (verb == 's') || (verb == 'v') || (verb == 'q') {
+			f.printString(op, v.Error())
+			return
+		}
+	case interface{ String() string }:
+		if (verb == 's') || (verb == 'v') || (verb == 'q') {
+			f.printString(op, v.String())
+			return
+		}
+	case unsafe.Pointer:
+		if (verb == 'v') || (verb == 'x') {
+			f.P(v)
+			return
+		}
+	}
+
+	rv := reflect.ValueOf(arg)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch verb {
+		case 'd', 'x', 'X', 'v':
+			f.printInt(op, rv.Int())
+			return
+		case 'c':
+			f.printRune(rune(rv.Int()))
+			return
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		switch verb {
+		case 'd', 'x', 'X', 'v':
+			f.printUint(op, false, rv.Uint())
+			return
+		case 'c':
+			f.printRune(rune(rv.Uint()))
+			return
+		}
+	case reflect.Float32, reflect.Float64:
+		switch verb {
+		case 'f', 'e', 'g', 'v':
+			f.printFloat(op, rv.Float(), rv.Type().Bits())
+			return
+		}
+	case reflect.Bool:
+		if (verb == 't') || (verb == 'v') {
+			if rv.Bool() {
+				f.S("true")
+			} else {
+				f.S("false")
+			}
+			return
+		}
+	case reflect.String:
+		if (verb == 's') || (verb == 'q') || (verb == 'v') || (verb == 'x') || (verb == 'X') {
+			f.printString(op, rv.String())
+			return
+		}
+	}
+	f.printBad(op, "BADTYPE")
+}
+
+/* argInt returns int argument for '*' width or precision. */
+func argInt(arg interface{}) (int, bool) {
+	rv := reflect.ValueOf(arg)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if rv.Uint() <= math.MaxInt32 {
+			return int(rv.Uint()), true
+		}
+	}
+	return 0, false
+}
+
+func (f *Formatter) printf(format *Format, args_ []interface{}) {
+	var argn int
+
+	/* NOTE(anton2920): args never outlive the call, so hiding them from escape analysis lets caller keep boxed values on stack. */
+	args := *(*[]interface{})(pointers.UnsafeNoescape(unsafe.Pointer(&args_)))
+
+	for i := 0; i < len(format.Ops); i++ {
+		op := format.Ops[i]
+		if op.Verb == 0 {
+			f.put(op.Literal)
+			continue
+		}
+
+		if op.WidthArg {
+			if argn == len(args) {
+				f.printBad(&op, "MISSING")
+				return
+			}
+			width, ok := argInt(args[argn])
+			argn++
+			if !ok {
+				f.printBad(&op, "BADWIDTH")
+				argn = ints.Min(argn+1, len(args))
+				continue
+			}
+			if width < 0 {
+				op.Left = true
+				width = -width
+			}
+			op.Width = width
+		}
+		if op.PrecArg {
+			if argn == len(args) {
+				f.printBad(&op, "MISSING")
+				return
+			}
+			prec, ok := argInt(args[argn])
+			argn++
+			if (!ok) || (prec < 0) {
+				f.printBad(&op, "BADPREC")
+				argn = ints.Min(argn+1, len(args))
+				continue
+			}
+			op.Prec = prec
+		}
+		if argn == len(args) {
+			f.printBad(&op, "MISSING")
+			return
+		}
+
+		f.Width = op.Width
+		if op.Left {
+			f.Width = -op.Width
+		}
+		f.printArg(&op, args[argn])
+		f.Width = 0
+		argn++
+	}
+
+	if argn < len(args) {
+		f.S("%!(EXTRA)")
+	}
+}
@@ -0,0 +1,108 @@
+package fmt
+
+import (
+	"errors"
+	stdfmt "fmt"
+	"math"
+	"testing"
+)
+
+type testStatus int
+
+func (s testStatus) String() string {
+	return "status"
+}
+
+type testID int32
+
+func TestPrintf(t *testing.T) {
+	var f Formatter
+
+	f.InitWithByteSlice(make([]byte, 256))
+	tests := [...]struct {
+		Format string
+		Args   []interface{}
+	}{
+		{"plain", nil},
+		{"100%% done", nil},
+		{"%d %d %d %d", []interface{}{0, -1, math.MaxInt64, math.MinInt64}},
+		{"%d %d %d", []interface{}{int8(-5), uint16(65535), uint64(math.MaxUint64)}},
+		{"[%5d] [%-5d] [%05d] [%05d]", []interface{}{42, 42, 42, -42}},
+		{"%x %X %x %08x", []interface{}{255, 255, -255, uint32(0xbeef)}},
+		{"%x %X", []interface{}{"hi", []byte{0xDE, 0xAD}}},
+		{"[%s] [%10s] [%-10s] [%.2s]", []interface{}{"abc", "abc", "abc", "abc"}},
+		{"%s %5s", []interface{}{[]byte("bytes"), []byte("s")}},
+		{"%q %q", []interface{}{"a\"b\n", []byte("c")}},
+		{"%c%c", []interface{}{'x', 'ж'}},
+		{"%t %v", []interface{}{true, false}},
+		{"%f %.2f %8.3f %-8.1f|", []interface{}{math.Pi, math.Pi, -math.Pi, 2.25}},
+		{"%e %.0e %g %.3g %v", []interface{}{1234.5678, 1234.5678, 0.1, math.Pi, 1e21}},
+		{"%v %v", []interface{}{float32(0.1), 0.1}},
+		{"%s %v %d", []interface{}{errors.New("failed"), testStatus(1), testStatus(1)}},
+		{"%d %v", []interface{}{testID(7), testID(-7)}},
+		{"%v", []interface{}{nil}},
+		{"[%*d] [%-*d] [%.*f] [%*s]", []interface{}{5, 1, 5, 2, 1, 2.0, -4, "ab"}},
+	}
+	for _, test := range tests {
+		format, err := Compile(test.Format)
+		if err != nil {
+			t.Errorf("%q: failed to compile: %v", test.Format, err)
+			continue
+		}
+		if format.Args != len(test.Args) {
+			t.Errorf("%q: expected %d args, got %d", test.Format, len(test.Args), format.Args)
+		}
+
+		expected := stdfmt.Sprintf(test.Format, test.Args...)
+		if got := f.Reset().Printf(&format, test.Args...).String(); got != expected {
+			t.Errorf("%q: expected %q, got %q", test.Format, expected, got)
+		}
+	}
+}
+
+func TestPrintfBad(t *testing.T) {
+	var f Formatter
+
+	f.InitWithByteSlice(make([]byte, 256))
+	tests := [...]struct {
+		Format   string
+		Args     []interface{}
+		Expected string
+	}{
+		{"%d %d", []interface{}{1}, "1 %!d(MISSING)"},
+		{"%d", []interface{}{1, 2}, "1%!(EXTRA)"},
+		{"%d", []interface{}{"s"}, "%!d(BADTYPE)"},
+		{"%t", []interface{}{1}, "%!t(BADTYPE)"},
+		{"%*d", []interface{}{"w", 1}, "%!d(BADWIDTH)"},
+	}
+	for _, test := range tests {
+		format := MustCompile(test.Format)
+		if got := f.Reset().Printf(&format, test.Args...).String(); got != test.Expected {
+			t.Errorf("%q: expected %q, got %q", test.Format, test.Expected, got)
+		}
+	}
+
+	for _, format := range [...]string{"%", "abc%", "%5", "%.*", "%y", "%-"} {
+		if _, err := Compile(format); err == nil {
+			t.Errorf("%q: expected error", format)
+		}
+	}
+}
+
+func TestPrintfAllocs(t *testing.T) {
+	var f Formatter
+
+	f.InitWithByteSlice(make([]byte, 256))
+	format := MustCompile("[%21s] %7s %s -> %v (%v), %4dus %.2f %t %x")
+
+	path := []byte("/index.html")
+	method := "GET"
+	elapsed := int64(123456)
+	ratio := 0.75
+	allocs := testing.AllocsPerRun(100, func() {
+		f.Reset().Printf(&format, "127.0.0.1:1234", method, path, testStatus(200), nil, elapsed, ratio, true, uint64(elapsed))
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations, got %v", allocs)
+	}
+}
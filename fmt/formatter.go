@@ -11,12 +11,21 @@ import (
 	"github.com/anton2920/gofa/time"
 )
 
+/* Formatter writes into Buffer[Pos:]. What happens, when Buffer is full, is decided by Overflow, see OverflowPolicy. */
 type Formatter struct {
 	Buffer []byte
 	Pos    int
 
 	Width     int
 	Precision int
+
+	Overflow  OverflowPolicy
+	Marker    string
+	Allocator Allocator
+	Sink      Sink
+
+	/* Truncated is set, when Marker was written. It is cleared by Reset. */
+	Truncated bool
 }
 
 func (f *Formatter) applyWidth(n int, after bool) {
@@ -34,8 +43,7 @@ func (f *Formatter) applyWidth(n int, after bool) {
 
 	if leftAlign == after {
 		for i := 0; i < width-n; i++ {
-			f.Buffer[f.Pos] = ' '
-			f.Pos++
+			f.putByte(' ')
 		}
 		f.Width = 0
 	}
@@ -88,6 +96,13 @@ func (f *Formatter) DateTime(t int64) *Formatter {
 	return f.S(bytes.AsString(buf))
 }
 
+/* Dur writes d nanoseconds like Go's time.Duration, e.g. '1h2m3.5s', '1.5ms' or '20us'. */
+func (f *Formatter) Dur(d int64) *Formatter {
+	var buf [32]byte
+	n := putDuration(buf[:], d)
+	return f.S(bytes.AsString(buf[n:]))
+}
+
 func (f *Formatter) Err(err error) *Formatter {
 	s := "<nil>"
 	if err != nil {
@@ -101,17 +116,11 @@ func (f *Formatter) E(e float64) *Formatter {
 }
 
 func (f *Formatter) E32(e float32) *Formatter {
-	buf := make([]byte, 0, 128)
-	buf = strconv.AppendFloat(buf, float64(e), 'e', ints.Or(f.Precision, 6), int(unsafe.Sizeof(e)*8))
-	f.Precision = 0
-	return f.S(bytes.AsString(buf))
+	return f.putFloat32(e, 'e', ints.Or(f.Precision, 6))
 }
 
 func (f *Formatter) E64(e float64) *Formatter {
-	buf := make([]byte, 0, 128)
-	buf = strconv.AppendFloat(buf, e, 'e', ints.Or(f.Precision, 6), int(unsafe.Sizeof(e)*8))
-	f.Precision = 0
-	return f.S(bytes.AsString(buf))
+	return f.putFloat64(e, 'e', ints.Or(f.Precision, 6))
 }
 
 func (f *Formatter) F(f_ float64) *Formatter {
@@ -119,17 +128,11 @@ func (f *Formatter) F(f_ float64) *Formatter {
 }
 
 func (f *Formatter) F32(f_ float32) *Formatter {
-	buf := make([]byte, 0, 128)
-	buf = strconv.AppendFloat(buf, float64(f_), 'f', ints.Or(f.Precision, 6), int(unsafe.Sizeof(f_)*8))
-	f.Precision = 0
-	return f.S(bytes.AsString(buf))
+	return f.putFloat32(f_, 'f', ints.Or(f.Precision, 6))
 }
 
 func (f *Formatter) F64(f_ float64) *Formatter {
-	buf := make([]byte, 0, 128)
-	buf = strconv.AppendFloat(buf, f_, 'f', ints.Or(f.Precision, 6), int(unsafe.Sizeof(f_)*8))
-	f.Precision = 0
-	return f.S(bytes.AsString(buf))
+	return f.putFloat64(f_, 'f', ints.Or(f.Precision, 6))
 }
 
 func (f *Formatter) G(g float64) *Formatter {
@@ -137,17 +140,11 @@ func (f *Formatter) G(g float64) *Formatter {
 }
 
 func (f *Formatter) G32(g float32) *Formatter {
-	buf := make([]byte, 0, 128)
-	buf = strconv.AppendFloat(buf, float64(g), 'g', ints.Or(f.Precision, -1), int(unsafe.Sizeof(g)*8))
-	f.Precision = 0
-	return f.S(bytes.AsString(buf))
+	return f.putFloat32(g, 'g', ints.Or(f.Precision, -1))
 }
 
 func (f *Formatter) G64(g float64) *Formatter {
-	buf := make([]byte, 0, 128)
-	buf = strconv.AppendFloat(buf, g, 'g', ints.Or(f.Precision, -1), int(unsafe.Sizeof(g)*8))
-	f.Precision = 0
-	return f.S(bytes.AsString(buf))
+	return f.putFloat64(g, 'g', ints.Or(f.Precision, -1))
 }
 
 func (f *Formatter) I(i int) *Formatter {
@@ -226,7 +223,7 @@ func (f *Formatter) quoteString(s string) {
 	var runeTmp [utf8.UTFMax]byte
 	quote := '"'
 
-	f.Pos += copy(f.Buffer[f.Pos:], "\"")
+	f.put("\"")
 	for width := 0; len(s) > 0; s = s[width:] {
 		r := rune(s[0])
 		width = 1
@@ -234,59 +231,65 @@ func (f *Formatter) quoteString(s string) {
 			r, width = utf8.DecodeRuneInString(s)
 		}
 		if width == 1 && r == utf8.RuneError {
-			f.Pos += copy(f.Buffer[f.Pos:], `\x`)
-			f.Pos += copy(f.Buffer[f.Pos:], lowerhex[s[0]>>4])
-			f.Pos += copy(f.Buffer[f.Pos:], lowerhex[s[0]&0xF])
+			f.put(`\x`)
+			f.put(lowerhex[s[0]>>4])
+			f.put(lowerhex[s[0]&0xF])
 			continue
 		}
 		if r == rune(quote) || r == '\\' {
-			f.Pos += copy(f.Buffer[f.Pos:], "\\")
-			f.Pos += copy(f.Buffer[f.Pos:], s[:1])
+			f.put("\\")
+			f.put(s[:1])
 			continue
 		}
 		if strconv.IsPrint(r) {
 			n := utf8.EncodeRune(runeTmp[:], r)
-			f.Pos += copy(f.Buffer[f.Pos:], runeTmp[:n])
+			f.put(bytes.AsString(runeTmp[:n]))
 			continue
 		}
 		switch r {
 		case '\a':
-			f.Pos += copy(f.Buffer[f.Pos:], `\a`)
+			f.put(`\a`)
 		case '\b':
-			f.Pos += copy(f.Buffer[f.Pos:], `\b`)
+			f.put(`\b`)
 		case '\f':
-			f.Pos += copy(f.Buffer[f.Pos:], `\f`)
+			f.put(`\f`)
 		case '\n':
-			f.Pos += copy(f.Buffer[f.Pos:], `\n`)
+			f.put(`\n`)
 		case '\r':
-			f.Pos += copy(f.Buffer[f.Pos:], `\r`)
+			f.put(`\r`)
 		case '\t':
-			f.Pos += copy(f.Buffer[f.Pos:], `\t`)
+			f.put(`\t`)
 		case '\v':
-			f.Pos += copy(f.Buffer[f.Pos:], `\v`)
+			f.put(`\v`)
 		default:
 			switch {
 			case r < ' ':
-				f.Pos += copy(f.Buffer[f.Pos:], `\x`)
-				f.Pos += copy(f.Buffer[f.Pos:], lowerhex[s[0]>>4])
-				f.Pos += copy(f.Buffer[f.Pos:], lowerhex[s[0]&0xF])
+				f.put(`\x`)
+				f.put(lowerhex[s[0]>>4])
+				f.put(lowerhex[s[0]&0xF])
 			case r > utf8.MaxRune:
 				r = 0xFFFD
 				fallthrough
 			case r < 0x10000:
-				f.Pos += copy(f.Buffer[f.Pos:], `\u`)
+				f.put(`\u`)
 				for s := 12; s >= 0; s -= 4 {
-					f.Pos += copy(f.Buffer[f.Pos:], lowerhex[r>>uint(s)&0xF])
+					f.put(lowerhex[r>>uint(s)&0xF])
 				}
 			default:
-				f.Pos += copy(f.Buffer[f.Pos:], `\U`)
+				f.put(`\U`)
 				for s := 28; s >= 0; s -= 4 {
-					f.Pos += copy(f.Buffer[f.Pos:], lowerhex[r>>uint(s)&0xF])
+					f.put(lowerhex[r>>uint(s)&0xF])
 				}
 			}
 		}
 	}
-	f.Pos += copy(f.Buffer[f.Pos:], "\"")
+	f.put("\"")
+}
+
+/* Printf writes args according to compiled format. It does not allocate for basic types. */
+func (f *Formatter) Printf(format *Format, args ...interface{}) *Formatter {
+	f.printf(format, args)
+	return f
 }
 
 func (f *Formatter) Q(q string) *Formatter {
@@ -301,7 +304,7 @@ func (f *Formatter) Q(q string) *Formatter {
 
 func (f *Formatter) S(s string) *Formatter {
 	f.applyWidth(len(s), false)
-	f.Pos += copy(f.Buffer[f.Pos:], s)
+	f.put(s)
 	f.applyWidth(len(s), true)
 	return f
 }
@@ -328,5 +331,6 @@ func (f *Formatter) Reset() *Formatter {
 	f.Pos = 0
 	f.Width = 0
 	f.Precision = 0
+	f.Truncated = false
 	return f
 }
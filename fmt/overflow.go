@@ -0,0 +1,169 @@
+package fmt
+
+import (
+	"unsafe"
+
+	"github.com/anton2920/gofa/bytes"
+	"github.com/anton2920/gofa/ints"
+	"github.com/anton2920/gofa/pointers"
+	"github.com/anton2920/gofa/strings"
+)
+
+/* OverflowPolicy decides what Formatter does, when Buffer is full. */
+type OverflowPolicy int
+
+const (
+	/* OverflowDiscard silently drops everything that does not fit. */
+	OverflowDiscard OverflowPolicy = iota
+
+	/* OverflowTruncate drops everything that does not fit and ends Buffer with Marker. */
+	OverflowTruncate
+
+	/* OverflowGrow reallocates Buffer inside of Allocator, or on heap, if Allocator is nil. */
+	OverflowGrow
+
+	/* OverflowFlush passes Buffer to Sink and continues writing from its beginning. */
+	OverflowFlush
+)
+
+/* DefaultMarker is written at the end of truncated Buffer, if Marker is empty. */
+const DefaultMarker = "..."
+
+const formatterMinBufferSize = 64
+
+/* Allocator provides memory for OverflowGrow. *mem.Arena implements it. */
+type Allocator interface {
+	AllocationComesFromHere(ptr unsafe.Pointer, n uintptr) bool
+	PushByteArray(n int) []byte
+	RepushByteArray(old []byte, n int) []byte
+}
+
+/* Sink receives contents of full Buffer for OverflowFlush. buf must not be retained after return. */
+type Sink func(buf []byte)
+
+/* DiscardOnOverflow restores default policy. */
+func (f *Formatter) DiscardOnOverflow() *Formatter {
+	f.Overflow = OverflowDiscard
+	return f
+}
+
+/* TruncateOnOverflow ends Buffer with marker, if output does not fit. Empty marker means DefaultMarker. */
+func (f *Formatter) TruncateOnOverflow(marker string) *Formatter {
+	f.Overflow = OverflowTruncate
+	f.Marker = marker
+	return f
+}
+
+/* GrowOnOverflow reallocates Buffer inside of a, if output does not fit. Heap is used, if a is nil. */
+func (f *Formatter) GrowOnOverflow(a Allocator) *Formatter {
+	f.Overflow = OverflowGrow
+	f.Allocator = a
+	return f
+}
+
+/* FlushOnOverflow passes full Buffer to sink. Flush must be called to pass the rest of output. */
+func (f *Formatter) FlushOnOverflow(sink Sink) *Formatter {
+	f.Overflow = OverflowFlush
+	f.Sink = sink
+	return f
+}
+
+/* Flush passes pending output to Sink and empties Buffer. It does nothing without Sink. */
+func (f *Formatter) Flush() *Formatter {
+	if (f.Sink != nil) && (f.Pos > 0) {
+		f.Sink(f.Buffer[:f.Pos])
+		f.Pos = 0
+	}
+	return f
+}
+
+/* Reserve grows Buffer for n more bytes, if Overflow is OverflowGrow, and returns number of bytes available after Pos. */
+func (f *Formatter) Reserve(n int) int {
+	if (f.Pos+n > len(f.Buffer)) && (f.Overflow == OverflowGrow) {
+		f.grow(n)
+	}
+	return len(f.Buffer) - f.Pos
+}
+
+func (f *Formatter) grow(n int) {
+	size := ints.Max(ints.Max(2*len(f.Buffer), f.Pos+n), formatterMinBufferSize)
+
+	if f.Allocator != nil {
+		if (len(f.Buffer) > 0) && (f.Allocator.AllocationComesFromHere(unsafe.Pointer(&f.Buffer[0]), uintptr(len(f.Buffer)))) {
+			f.Buffer = f.Allocator.RepushByteArray(f.Buffer, size)
+		} else {
+			buffer := f.Allocator.PushByteArray(size)
+			copy(buffer, f.Buffer[:f.Pos])
+			f.Buffer = buffer
+		}
+	} else {
+		buffer := make([]byte, size)
+		copy(buffer, f.Buffer[:f.Pos])
+		f.Buffer = buffer
+	}
+}
+
+func (f *Formatter) truncate(s string) {
+	if f.Truncated {
+		return
+	}
+	f.Truncated = true
+
+	marker := f.Marker
+	if len(marker) == 0 {
+		marker = DefaultMarker
+	}
+
+	end := ints.Max(len(f.Buffer)-len(marker), 0)
+	if f.Pos < end {
+		f.Pos += copy(f.Buffer[f.Pos:end], s)
+	} else {
+		f.Pos = end
+	}
+	f.Pos += copy(f.Buffer[f.Pos:], marker)
+}
+
+func (f *Formatter) flush(s string) {
+	for f.Pos+len(s) > len(f.Buffer) {
+		n := copy(f.Buffer[f.Pos:], s)
+		f.Pos += n
+		s = s[n:]
+
+		if f.Pos == 0 {
+			/* NOTE(anton2920): Buffer has zero length, so s is passed as is. Sink does not retain it, so s is hidden from escape analysis to keep callers' temporary buffers on stack. */
+			f.Sink(bytes.SliceFromUnsafePointer(pointers.UnsafeNoescape(unsafe.Pointer(strings.Data(s))), len(s)))
+			return
+		}
+		f.Flush()
+	}
+	f.Pos += copy(f.Buffer[f.Pos:], s)
+}
+
+/* put is where all output goes to, so overflow is handled in one place. */
+func (f *Formatter) put(s string) {
+	if f.Pos+len(s) > len(f.Buffer) {
+		switch f.Overflow {
+		case OverflowTruncate:
+			f.truncate(s)
+			return
+		case OverflowGrow:
+			f.grow(len(s))
+		case OverflowFlush:
+			if f.Sink != nil {
+				f.flush(s)
+				return
+			}
+		}
+	}
+	f.Pos += copy(f.Buffer[f.Pos:], s)
+}
+
+func (f *Formatter) putByte(c byte) {
+	if f.Pos < len(f.Buffer) {
+		f.Buffer[f.Pos] = c
+		f.Pos++
+	} else if f.Overflow != OverflowDiscard {
+		buf := [1]byte{c}
+		f.put(bytes.AsString(buf[:]))
+	}
+}
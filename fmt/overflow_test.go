@@ -0,0 +1,141 @@
+package fmt
+
+import (
+	"testing"
+	"unsafe"
+)
+
+/* testAllocator is bump allocator like mem.Arena, which cannot be imported here. */
+type testAllocator struct {
+	Buffer []byte
+	Pos    int
+	Last   int
+}
+
+func (a *testAllocator) AllocationComesFromHere(ptr unsafe.Pointer, n uintptr) bool {
+	base := uintptr(unsafe.Pointer(&a.Buffer[0]))
+	return (uintptr(ptr) >= base) && (uintptr(ptr)+n <= base+uintptr(a.Pos))
+}
+
+func (a *testAllocator) PushByteArray(n int) []byte {
+	a.Last = a.Pos
+	a.Pos += n
+	return a.Buffer[a.Last:a.Pos:a.Pos]
+}
+
+func (a *testAllocator) RepushByteArray(old []byte, n int) []byte {
+	if &old[0] == &a.Buffer[a.Last] {
+		a.Pos = a.Last + n
+		return a.Buffer[a.Last:a.Pos:a.Pos]
+	}
+	buf := a.PushByteArray(n)
+	copy(buf, old)
+	return buf
+}
+
+func TestOverflowDiscard(t *testing.T) {
+	var f Formatter
+
+	f.InitWithByteSlice(make([]byte, 8))
+	if got := f.S("hello, ").W(5).D(42).S("world").String(); got != "hello,  " {
+		t.Errorf("Expected %q, got %q", "hello,  ", got)
+	}
+}
+
+func TestOverflowTruncate(t *testing.T) {
+	var f Formatter
+
+	f.InitWithByteSlice(make([]byte, 10))
+	f.TruncateOnOverflow("")
+
+	tests := [...]struct {
+		Write     func(f *Formatter)
+		Expected  string
+		Truncated bool
+	}{
+		{func(f *Formatter) { f.S("hello") }, "hello", false},
+		{func(f *Formatter) { f.S("0123456789") }, "0123456789", false},
+		{func(f *Formatter) { f.S("hello, world") }, "hello, ...", true},
+		{func(f *Formatter) { f.S("hello, ").S("world").S("!") }, "hello, ...", true},
+		{func(f *Formatter) { f.S("012345678").S("9a") }, "0123456...", true},
+		{func(f *Formatter) { f.S("pi = ").F(3.14159265) }, "pi = 3....", true},
+		{func(f *Formatter) { f.Q("\x00\x00\x00") }, `"\x00\x...`, true},
+		{func(f *Formatter) { f.W(12).S("x") }, "       ...", true},
+	}
+	for i, test := range tests {
+		test.Write(f.Reset())
+		if got := f.String(); got != test.Expected {
+			t.Errorf("%d: expected %q, got %q", i, test.Expected, got)
+		}
+		if f.Truncated != test.Truncated {
+			t.Errorf("%d: expected Truncated to be %v", i, test.Truncated)
+		}
+	}
+
+	f.InitWithByteSlice(make([]byte, 4))
+	if got := f.Reset().TruncateOnOverflow(" [truncated]").S("hello").String(); got != " [tr" {
+		t.Errorf("Expected %q, got %q", " [tr", got)
+	}
+
+	f.InitWithByteSlice(make([]byte, 16))
+	f.TruncateOnOverflow("")
+	allocs := testing.AllocsPerRun(100, func() {
+		f.Reset().S("elapsed: ").D64(123456789).S("us").F(1e100)
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations, got %v", allocs)
+	}
+}
+
+func TestOverflowGrow(t *testing.T) {
+	var f Formatter
+	var a testAllocator
+
+	f.InitWithByteSlice(make([]byte, 4))
+	f.GrowOnOverflow(nil)
+	if got := f.S("hello, ").D(1000000).S(", world").String(); got != "hello, 1000000, world" {
+		t.Errorf("Expected %q, got %q", "hello, 1000000, world", got)
+	}
+
+	a.Buffer = make([]byte, 1024)
+	f.InitWithByteSlice(make([]byte, 4))
+	f.Reset().GrowOnOverflow(&a)
+	for i := 0; i < 100; i++ {
+		f.D(i % 10)
+	}
+	if (len(f.Bytes()) != 100) || (f.Bytes()[99] != '9') {
+		t.Errorf("Expected 100 digits, got %q", f.Bytes())
+	}
+	if !a.AllocationComesFromHere(unsafe.Pointer(&f.Buffer[0]), uintptr(len(f.Buffer))) {
+		t.Errorf("Expected buffer to come from allocator")
+	}
+	if a.Pos != 128 {
+		t.Errorf("Expected buffer to grow in place up to 128 bytes, allocator used %d bytes", a.Pos)
+	}
+}
+
+func TestOverflowFlush(t *testing.T) {
+	var f Formatter
+	var out []byte
+	var flushes int
+
+	sink := func(buf []byte) {
+		out = append(out, buf...)
+		flushes++
+	}
+
+	f.InitWithByteSlice(make([]byte, 8))
+	f.FlushOnOverflow(sink)
+	f.S("hello, ").Q("world").S("!").W(10).D(42).S(" 0123456789abcdef").Flush()
+
+	const expected = `hello, "world"!        42 0123456789abcdef`
+	if string(out) != expected {
+		t.Errorf("Expected %q, got %q", expected, out)
+	}
+	if flushes != (len(expected)+7)/8 {
+		t.Errorf("Expected %d flushes, got %d", (len(expected)+7)/8, flushes)
+	}
+	if f.Pos != 0 {
+		t.Errorf("Expected empty buffer after flush, got %q", f.Bytes())
+	}
+}
@@ -0,0 +1,161 @@
+package fmt
+
+import "math/bits"
+
+/* Binary to decimal conversion by fast unrounded scaling, see 'Floating-Point Printing and Parsing Can Be Simple And Fast' by Russ Cox, https://research.swtch.com/fp. */
+
+type pow10 struct {
+	Hi uint64
+	Lo uint64
+}
+
+/* unrounded is value multiplied by 4 with the lowest bit set, if any of discarded bits was set. */
+type unrounded uint64
+
+/* scaler holds scaling constants for m * 2^e * 10^p. */
+type scaler struct {
+	PmHi uint64
+	PmLo uint64
+	S    int
+}
+
+var uint64pow10 = [...]uint64{
+	1, 1e1, 1e2, 1e3, 1e4, 1e5, 1e6, 1e7, 1e8, 1e9,
+	1e10, 1e11, 1e12, 1e13, 1e14, 1e15, 1e16, 1e17, 1e18, 1e19,
+}
+
+func b2u(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (u unrounded) Floor() uint64 {
+	return uint64(u >> 2)
+}
+
+func (u unrounded) Ceil() uint64 {
+	return uint64((u + 3) >> 2)
+}
+
+/* Round rounds half to even. */
+func (u unrounded) Round() uint64 {
+	return uint64((u + 1 + (u>>2)&1) >> 2)
+}
+
+func (u unrounded) Div(d uint64) unrounded {
+	x := uint64(u)
+	return unrounded(x/d) | u&1 | unrounded(b2u(x%d != 0))
+}
+
+/* unmin returns the minimum unrounded that rounds to x. */
+func unmin(x uint64) unrounded {
+	return unrounded(x<<2 - 2)
+}
+
+/* log10Pow2 returns floor(x * log10(2)). */
+func log10Pow2(x int) int {
+	return (x * 78913) >> 18
+}
+
+/* log2Pow10 returns floor(x * log2(10)). */
+func log2Pow10(x int) int {
+	return (x * 108853) >> 15
+}
+
+/* skewed returns floor(log10(3/4 * 2^e)). */
+func skewed(e int) int {
+	return (e*631305 - 261663) >> 21
+}
+
+func prescale(pre *scaler, e, p int) {
+	pre.PmHi = pow10Tab[p-pow10Min].Hi
+	pre.PmLo = pow10Tab[p-pow10Min].Lo
+	pre.S = -(e + log2Pow10(p) + 3)
+}
+
+/* uscale returns unrounded x * 2^e * 10^p for constants from prescale. High bit of x must be set. */
+func uscale(x uint64, pre *scaler) unrounded {
+	hi, mid := bits.Mul64(x, pre.PmHi)
+	s := uint(pre.S) & 63
+	if hi>>s<<s != hi {
+		return unrounded(hi>>s | 1)
+	}
+	mid2, _ := bits.Mul64(x, pre.PmLo)
+	hi -= b2u(mid < mid2)
+	return unrounded(hi>>s | b2u(mid-mid2 > 1))
+}
+
+/* shortestDecimal returns the shortest d * 10^p, which rounds back to m * 2^e. High bit of m must be set, mantBits and minExp describe float type. */
+func shortestDecimal(m uint64, e int, mantBits int, minExp int) (d uint64, p int) {
+	var min, max uint64
+	var pre scaler
+
+	z := 63 - mantBits
+	if (m == 1<<63) && (e > minExp) {
+		/* NOTE(anton2920): power of 2 has closer lower neighbour. */
+		p = -skewed(e + z)
+		min = m - 1<<uint(z-2)
+		max = m + 1<<uint(z-1)
+	} else {
+		if e < minExp {
+			z += minExp - e
+		}
+		p = -log10Pow2(e + z)
+		min = m - 1<<uint(z-1)
+		max = m + 1<<uint(z-1)
+	}
+	odd := unrounded(m>>uint(z)) & 1
+
+	prescale(&pre, e, p)
+	dmin := (uscale(min, &pre) + odd).Ceil()
+	dmax := (uscale(max, &pre) - odd).Floor()
+
+	if d = dmax / 10; d*10 >= dmin {
+		return d, -(p - 1)
+	}
+	if d = dmin; d < dmax {
+		d = uscale(m, &pre).Round()
+	}
+	return d, -p
+}
+
+/* fixedDecimal returns n-digit (n <= 18) decimal form of m * 2^e as d * 10^p. For 'f' n is an upper estimate and digits after prec are dropped. */
+func fixedDecimal(m uint64, e, n, prec int, fmt byte) (d uint64, p int) {
+	var pre scaler
+
+	p = n - 1 - log10Pow2(e+63)
+	prescale(&pre, e, p)
+	u := uscale(m, &pre)
+	if u >= unmin(uint64pow10[n]) {
+		u = u.Div(10)
+		p--
+	}
+	if fmt == 'f' {
+		for p > prec {
+			u = u.Div(10)
+			p--
+		}
+	}
+	return u.Round(), -p
+}
+
+/* numDigits returns number of decimal digits in d >= 1. */
+func numDigits(d uint64) int {
+	nd := log10Pow2(bits.Len64(d))
+	return nd + int(b2u(d >= uint64pow10[nd]))
+}
+
+/* putDigits puts nd digits of d into buf and returns position of decimal point and number of digits without trailing zeros. */
+func putDigits(buf []byte, d uint64, p, nd int) (int, int) {
+	for i := nd - 1; i >= 0; i-- {
+		buf[i] = byte(d%10) + '0'
+		d /= 10
+	}
+	dp := nd + p
+	for (nd > 0) && (buf[nd-1] == '0') {
+		nd--
+	}
+	return dp, nd
+}
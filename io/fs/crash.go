@@ -0,0 +1,94 @@
+package fs
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/anton2920/gofa/ints"
+)
+
+type CrashTestOptions struct {
+	Seed int
+
+	/* Variants is the number of random recoveries checked for every crash point. */
+	Variants int
+
+	SectorSize int
+}
+
+var CrashTestDefaultOptions = CrashTestOptions{
+	Seed:       1,
+	Variants:   4,
+	SectorSize: DefaultSectorSize,
+}
+
+func MergeCrashTestOptions(opts ...CrashTestOptions) CrashTestOptions {
+	result := CrashTestDefaultOptions
+
+	for i := 0; i < len(opts); i++ {
+		opt := &opts[i]
+
+		ints.Replace(&result.Seed, opt.Seed)
+		ints.Replace(&result.Variants, opt.Variants)
+		ints.Replace(&result.SectorSize, opt.SectorSize)
+	}
+
+	return result
+}
+
+/* RunCrashTest runs workload once to count mutating operations. Then, for every operation N, it runs workload again with
+ *     1) operation N failing with ErrInjected; check is called on file system as workload left it and on its recovery;
+ *     2) crash at operation N, with and without torn write; check is called on every recovery mode and on 'Variants' random recoveries.
+ * Workload must stop as soon as it gets ErrCrashed. Check is called with fresh file system and must verify invariants that have to survive crash, e.g. by comparing data with what workload recorded as committed. */
+func RunCrashTest(workload func(fs VFS) error, check func(fs VFS) error, opts ...CrashTestOptions) error {
+	opt := MergeCrashTestOptions(opts...)
+	rng := rand.New(rand.NewSource(int64(opt.Seed)))
+
+	newFS := func() *FaultFS {
+		fs := NewFaultFS()
+		fs.SectorSize = opt.SectorSize
+		fs.Rand = rng
+		return fs
+	}
+
+	fs := newFS()
+	if err := workload(fs); err != nil {
+		return fmt.Errorf("workload failed without faults: %v", err)
+	}
+	if err := check(fs); err != nil {
+		return fmt.Errorf("check failed without faults: %v", err)
+	}
+	ops := fs.Ops
+
+	for n := 1; n <= ops; n++ {
+		fs := newFS()
+		fs.FailAt = n
+		workload(fs)
+		if err := check(fs); err != nil {
+			return fmt.Errorf("check failed after fault at operation %d/%d: %v", n, ops, err)
+		}
+		if err := check(fs.Recover(RecoverDropUnsynced)); err != nil {
+			return fmt.Errorf("check failed after fault at operation %d/%d and crash: %v", n, ops, err)
+		}
+
+		for _, torn := range [...]bool{false, true} {
+			fs := newFS()
+			fs.CrashAt = n
+			fs.TornWrites = torn
+			workload(fs)
+
+			for mode := RecoverDropUnsynced; mode <= RecoverKeepUnsynced; mode++ {
+				if err := check(fs.Recover(mode)); err != nil {
+					return fmt.Errorf("check failed after crash at operation %d/%d (torn=%v, mode=%d): %v", n, ops, torn, mode, err)
+				}
+			}
+			for v := 0; v < opt.Variants; v++ {
+				if err := check(fs.Recover(RecoverRandom)); err != nil {
+					return fmt.Errorf("check failed after crash at operation %d/%d (torn=%v, random variant %d): %v", n, ops, torn, v, err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
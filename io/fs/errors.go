@@ -3,5 +3,25 @@ package fs
 import "github.com/anton2920/gofa/errors"
 
 var (
-	ErrNotExist = errors.New("no such file or directory")
+	ErrNotExist          = errors.New("no such file or directory")
+	ErrExist             = errors.New("file exists")
+	ErrIsDirectory       = errors.New("is a directory")
+	ErrNotOpenForWriting = errors.New("file is not open for writing")
+
+	/* Errors returned by FaultFS. */
+	ErrInjected = errors.New("injected fault")
+	ErrCrashed  = errors.New("file system crashed")
 )
+
+/* PathError is an error reported by OS for operation on file at path. */
+type PathError struct {
+	Op   string
+	Path string
+
+	Code    int
+	Message string
+}
+
+func (e *PathError) Error() string {
+	return e.Op + " " + e.Path + ": " + e.Message
+}
@@ -0,0 +1,469 @@
+package fs
+
+import (
+	"math/rand"
+	"path"
+	"sort"
+	"sync"
+)
+
+/* FaultFS is an in-memory VFS that remembers which writes and renames were synced. It can fail or crash at the Nth mutating operation (WriteAt, Truncate, Sync or Rename) and produce states file system may be left in after crash. */
+type FaultFS struct {
+	Mem *MemoryFS
+
+	/* Mutex protects everything below. */
+	sync.Mutex
+
+	/* Durable holds contents of files as of their last Sync, Pending holds operations done since. */
+	Durable map[string][]byte
+	Pending map[string][]FaultOp
+
+	/* Names maps opened files to paths they are known by in Durable and Pending. Files replaced by Rename are removed, so their writes are lost. */
+	Names map[*MemoryFileData]string
+
+	/* Renames are done since the last Sync of directory they are done in. */
+	Renames []FaultRename
+
+	/* Ops is the number of mutating operations performed so far. */
+	Ops int
+
+	/* FailAt is the operation that returns ErrInjected without doing anything. */
+	FailAt int
+
+	/* CrashAt is the operation that crashes file system. Crashing write is torn, if TornWrites is set. After crash every operation returns ErrCrashed. */
+	CrashAt    int
+	TornWrites bool
+	Crashed    bool
+
+	/* SectorSize is the unit of atomic writes. */
+	SectorSize int
+	Rand       *rand.Rand
+}
+
+type FaultFile struct {
+	FS   *FaultFS
+	File *MemoryFile
+}
+
+/* FaultOp is either write of Data at Pos or truncate to Pos. */
+type FaultOp struct {
+	Pos      int64
+	Data     []byte
+	Truncate bool
+}
+
+/* FaultRename remembers file replaced by rename, so it can be undone, if directory is not synced. */
+type FaultRename struct {
+	From string
+	To   string
+
+	Replaced bool
+	Durable  []byte
+	Pending  []FaultOp
+}
+
+/* Recovery modes. */
+const (
+	/* RecoverDropUnsynced loses every write and rename that was not synced. */
+	RecoverDropUnsynced = iota
+
+	/* RecoverKeepUnsynced keeps every write, as if only process crashed. */
+	RecoverKeepUnsynced
+
+	/* RecoverRandom keeps random subset of unsynced operations, some of kept writes are torn at sector boundaries. This is equivalent to unsynced writes reaching disk in any order. Renames are kept in order they were done. */
+	RecoverRandom
+)
+
+const DefaultSectorSize = 512
+
+var _ VFS = new(FaultFS)
+var _ VFile = new(FaultFile)
+
+func NewFaultFS() *FaultFS {
+	fs := new(FaultFS)
+	fs.Mem = NewMemoryFS()
+	fs.Durable = make(map[string][]byte)
+	fs.Pending = make(map[string][]FaultOp)
+	fs.Names = make(map[*MemoryFileData]string)
+	fs.SectorSize = DefaultSectorSize
+	fs.Rand = rand.New(rand.NewSource(1))
+	return fs
+}
+
+func (op *FaultOp) Apply(data []byte) []byte {
+	if op.Truncate {
+		if op.Pos <= int64(len(data)) {
+			return data[:op.Pos]
+		}
+		return append(data, make([]byte, op.Pos-int64(len(data)))...)
+	}
+
+	if end := op.Pos + int64(len(op.Data)); end > int64(len(data)) {
+		data = append(data, make([]byte, end-int64(len(data)))...)
+	}
+	copy(data[op.Pos:], op.Data)
+	return data
+}
+
+/* tear splits write into sectors and returns random subset of them. */
+func (fs *FaultFS) tear(op FaultOp) []FaultOp {
+	var ops []FaultOp
+
+	for pos := op.Pos; pos < op.Pos+int64(len(op.Data)); {
+		end := (pos/int64(fs.SectorSize) + 1) * int64(fs.SectorSize)
+		if end > op.Pos+int64(len(op.Data)) {
+			end = op.Pos + int64(len(op.Data))
+		}
+		if fs.Rand.Intn(2) == 0 {
+			ops = append(ops, FaultOp{Pos: pos, Data: op.Data[pos-op.Pos : end-op.Pos]})
+		}
+		pos = end
+	}
+
+	return ops
+}
+
+/* fault counts operation and returns error it must fail with. fs must be locked. */
+func (fs *FaultFS) fault() error {
+	if fs.Crashed {
+		return ErrCrashed
+	}
+
+	fs.Ops++
+	switch fs.Ops {
+	case fs.FailAt:
+		return ErrInjected
+	case fs.CrashAt:
+		fs.Crashed = true
+		return ErrCrashed
+	}
+
+	return nil
+}
+
+func (fs *FaultFS) wrap(f VFile, err error) (VFile, error) {
+	if err != nil {
+		return nil, err
+	}
+	mf := f.(*MemoryFile)
+
+	fs.Lock()
+	if mf.File != nil {
+		fs.Names[mf.File] = mf.Path
+		if _, ok := fs.Durable[mf.Path]; !ok {
+			/* NOTE(anton2920): directory entries are durable as soon as they are created. */
+			fs.Durable[mf.Path] = []byte{}
+		}
+		if mf.Flags&TruncateSizeToZero != 0 {
+			fs.Pending[mf.Path] = append(fs.Pending[mf.Path], FaultOp{Truncate: true})
+		}
+	}
+	fs.Unlock()
+
+	return &FaultFile{FS: fs, File: mf}, nil
+}
+
+func (fs *FaultFS) crashed() bool {
+	fs.Lock()
+	defer fs.Unlock()
+	return fs.Crashed
+}
+
+func (fs *FaultFS) Open(path string, flags int32, perms ...uint16) (VFile, error) {
+	if fs.crashed() {
+		return nil, ErrCrashed
+	}
+	return fs.wrap(fs.Mem.Open(path, flags, perms...))
+}
+
+func (fs *FaultFS) OpenAt(f VFile, path string, flags int32, perms ...uint16) (VFile, error) {
+	if fs.crashed() {
+		return nil, ErrCrashed
+	}
+	if ff, ok := f.(*FaultFile); ok {
+		f = ff.File
+	}
+	return fs.wrap(fs.Mem.OpenAt(f, path, flags, perms...))
+}
+
+func (fs *FaultFS) CreateDirectory(path string, perms uint16) error {
+	if fs.crashed() {
+		return ErrCrashed
+	}
+	return fs.Mem.CreateDirectory(path, perms)
+}
+
+func (fs *FaultFS) Rename(from string, to string) error {
+	from = path.Clean(from)
+	to = path.Clean(to)
+
+	fs.Lock()
+	defer fs.Unlock()
+
+	if err := fs.fault(); err != nil {
+		return err
+	}
+	if err := fs.Mem.Rename(from, to); err != nil {
+		return err
+	}
+
+	r := FaultRename{From: from, To: to}
+	if durable, ok := fs.Durable[to]; ok {
+		r.Replaced = true
+		r.Durable = durable
+		r.Pending = fs.Pending[to]
+	}
+	fs.Renames = append(fs.Renames, r)
+
+	fs.Durable[to] = fs.Durable[from]
+	fs.Pending[to] = fs.Pending[from]
+	delete(fs.Durable, from)
+	delete(fs.Pending, from)
+
+	for data, name := range fs.Names {
+		if name == to {
+			delete(fs.Names, data)
+		}
+	}
+	for data, name := range fs.Names {
+		if name == from {
+			fs.Names[data] = to
+		}
+	}
+
+	return nil
+}
+
+/* Recover returns file system as it would be after reboot, if crash happened now. */
+func (fs *FaultFS) Recover(mode int) *FaultFS {
+	fs.Lock()
+	defer fs.Unlock()
+
+	rfs := NewFaultFS()
+	rfs.SectorSize = fs.SectorSize
+	rfs.Rand = fs.Rand
+
+	for p := range fs.Mem.Directories {
+		rfs.Mem.Directories[p] = struct{}{}
+	}
+
+	durable := make(map[string][]byte, len(fs.Durable))
+	pending := make(map[string][]FaultOp, len(fs.Pending))
+	for p := range fs.Durable {
+		durable[p] = fs.Durable[p]
+		pending[p] = fs.Pending[p]
+	}
+
+	var keep int
+	switch {
+	case mode == RecoverKeepUnsynced:
+		keep = len(fs.Renames)
+	case (mode == RecoverRandom) && (len(fs.Renames) > 0):
+		keep = fs.Rand.Intn(len(fs.Renames) + 1)
+	}
+	for i := len(fs.Renames) - 1; i >= keep; i-- {
+		r := &fs.Renames[i]
+
+		durable[r.From] = durable[r.To]
+		pending[r.From] = pending[r.To]
+		if r.Replaced {
+			durable[r.To] = r.Durable
+			pending[r.To] = r.Pending
+		} else {
+			delete(durable, r.To)
+			delete(pending, r.To)
+		}
+	}
+
+	/* NOTE(anton2920): paths are sorted, so the same seed gives the same states. */
+	paths := make([]string, 0, len(durable))
+	for p := range durable {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	for _, p := range paths {
+		data := append([]byte{}, durable[p]...)
+
+		for _, op := range pending[p] {
+			switch mode {
+			case RecoverKeepUnsynced:
+				data = op.Apply(data)
+			case RecoverRandom:
+				if fs.Rand.Intn(2) == 0 {
+					break
+				}
+				if (op.Truncate) || (fs.Rand.Intn(2) == 0) {
+					data = op.Apply(data)
+				} else {
+					for _, op := range fs.tear(op) {
+						data = op.Apply(data)
+					}
+				}
+			}
+		}
+
+		rfs.Durable[p] = append([]byte{}, data...)
+		rfs.Mem.Files[p] = &MemoryFileData{Data: data}
+	}
+
+	return rfs
+}
+
+func (f *FaultFile) Read(buf []byte) (int, error) {
+	if f.FS.crashed() {
+		return 0, ErrCrashed
+	}
+	return f.File.Read(buf)
+}
+
+func (f *FaultFile) Write(buf []byte) (int, error) {
+	if f.File.Flags&OpenForAppending != 0 {
+		f.Lock()
+		defer f.Unlock()
+
+		size, _ := f.File.SizeEx(true)
+		f.File.Pos = int64(size)
+	}
+
+	n, err := f.WriteAtEx(buf, f.File.Pos, f.File.Flags&OpenForAppending != 0)
+	f.File.Pos += int64(n)
+	return n, err
+}
+
+func (f *FaultFile) Close() error {
+	return f.File.Close()
+}
+
+func (f *FaultFile) ReadAt(buf []byte, pos int64) (int, error) {
+	if f.FS.crashed() {
+		return 0, ErrCrashed
+	}
+	return f.File.ReadAt(buf, pos)
+}
+
+func (f *FaultFile) WriteAt(buf []byte, pos int64) (int, error) {
+	return f.WriteAtEx(buf, pos, false)
+}
+
+func (f *FaultFile) WriteAtEx(buf []byte, pos int64, lockHeld bool) (int, error) {
+	if f.File.File == nil {
+		return 0, ErrIsDirectory
+	}
+	if f.File.Flags&(OpenForWriting|OpenForAppending) == 0 {
+		return 0, ErrNotOpenForWriting
+	}
+
+	if !lockHeld {
+		f.Lock()
+		defer f.Unlock()
+	}
+
+	fs := f.FS
+	fs.Lock()
+	defer fs.Unlock()
+
+	op := FaultOp{Pos: pos, Data: append([]byte{}, buf...)}
+	p, linked := fs.Names[f.File.File]
+	if err := fs.fault(); err != nil {
+		if (err == ErrCrashed) && (fs.TornWrites) && (fs.Ops == fs.CrashAt) {
+			for _, op := range fs.tear(op) {
+				if linked {
+					fs.Pending[p] = append(fs.Pending[p], op)
+				}
+				f.File.WriteAtEx(op.Data, op.Pos, true)
+			}
+		}
+		return 0, err
+	}
+	if linked {
+		fs.Pending[p] = append(fs.Pending[p], op)
+	}
+
+	return f.File.WriteAtEx(buf, pos, true)
+}
+
+func (f *FaultFile) Size() (int, error) {
+	return f.SizeEx(false)
+}
+
+func (f *FaultFile) SizeEx(lockHeld bool) (int, error) {
+	if f.FS.crashed() {
+		return 0, ErrCrashed
+	}
+	return f.File.SizeEx(lockHeld)
+}
+
+func (f *FaultFile) Sync() error {
+	fs := f.FS
+	fs.Lock()
+	defer fs.Unlock()
+
+	if err := fs.fault(); err != nil {
+		return err
+	}
+
+	if f.File.File == nil {
+		/* NOTE(anton2920): syncing directory makes renames into it durable. */
+		var n int
+		for _, r := range fs.Renames {
+			if path.Dir(r.To) != f.File.Path {
+				fs.Renames[n] = r
+				n++
+			}
+		}
+		fs.Renames = fs.Renames[:n]
+		return nil
+	}
+
+	p, linked := fs.Names[f.File.File]
+	if !linked {
+		return nil
+	}
+	data := fs.Durable[p]
+	for _, op := range fs.Pending[p] {
+		data = op.Apply(data)
+	}
+	fs.Durable[p] = data
+	fs.Pending[p] = fs.Pending[p][:0]
+
+	return nil
+}
+
+func (f *FaultFile) Truncate(n int64) error {
+	if f.File.File == nil {
+		return ErrIsDirectory
+	}
+	if f.File.Flags&(OpenForWriting|OpenForAppending) == 0 {
+		return ErrNotOpenForWriting
+	}
+
+	f.Lock()
+	defer f.Unlock()
+
+	fs := f.FS
+	fs.Lock()
+	defer fs.Unlock()
+
+	if err := fs.fault(); err != nil {
+		return err
+	}
+	if p, linked := fs.Names[f.File.File]; linked {
+		fs.Pending[p] = append(fs.Pending[p], FaultOp{Pos: n, Truncate: true})
+	}
+	f.File.truncate(n)
+
+	return nil
+}
+
+func (f *FaultFile) Lock() {
+	f.File.Lock()
+}
+
+func (f *FaultFile) Unlock() {
+	f.File.Unlock()
+}
+
+func (f *FaultFile) VFS() VFS {
+	return f.FS
+}
@@ -0,0 +1,77 @@
+package fs
+
+import (
+	"io"
+	"testing"
+)
+
+func readAll(t *testing.T, fs VFS, path string) string {
+	f, err := fs.Open(path, OpenForReading)
+	if err != nil {
+		return "<" + err.Error() + ">"
+	}
+	defer f.Close()
+
+	buf := make([]byte, 64)
+	n, err := f.ReadAt(buf, 0)
+	if (err != nil) && (err != io.EOF) {
+		t.Fatalf("failed to read %s: %v", path, err)
+	}
+	return string(buf[:n])
+}
+
+func writeFile(t *testing.T, fs VFS, path string, data string) VFile {
+	f, err := fs.Open(path, OpenForReading|OpenForWriting|CreateFileIfItDoesNotExist|TruncateSizeToZero)
+	if err != nil {
+		t.Fatalf("failed to create %s: %v", path, err)
+	}
+	if _, err := f.WriteAt([]byte(data), 0); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+	if err := f.Sync(); err != nil {
+		t.Fatalf("failed to sync %s: %v", path, err)
+	}
+	return f
+}
+
+func TestFaultFSRename(t *testing.T) {
+	fs := NewFaultFS()
+	fs.Mem.CreateDirectory("/db", 0755)
+
+	writeFile(t, fs, "/db/log", "old")
+	f := writeFile(t, fs, "/db/log.tmp", "new")
+	if err := fs.Rename("/db/log.tmp", "/db/log"); err != nil {
+		t.Fatalf("failed to rename: %v", err)
+	}
+
+	/* Writes through handle opened before rename belong to renamed file. */
+	f.WriteAt([]byte("!"), 3)
+	f.Sync()
+
+	if got := readAll(t, fs, "/db/log"); got != "new!" {
+		t.Errorf("expected renamed file, got %q", got)
+	}
+	if got := readAll(t, fs, "/db/log.tmp"); got != "<"+ErrNotExist.Error()+">" {
+		t.Errorf("expected source to be removed, got %q", got)
+	}
+
+	rfs := fs.Recover(RecoverDropUnsynced)
+	if got := readAll(t, rfs, "/db/log"); got != "old" {
+		t.Errorf("expected rename to be lost without syncing directory, got %q", got)
+	}
+	if got := readAll(t, rfs, "/db/log.tmp"); got != "new!" {
+		t.Errorf("expected source to be back, got %q", got)
+	}
+
+	dir, err := fs.Open("/db", OpenForReading)
+	if err != nil {
+		t.Fatalf("failed to open directory: %v", err)
+	}
+	if err := dir.Sync(); err != nil {
+		t.Fatalf("failed to sync directory: %v", err)
+	}
+	rfs = fs.Recover(RecoverDropUnsynced)
+	if got := readAll(t, rfs, "/db/log"); got != "new!" {
+		t.Errorf("expected rename to be durable after syncing directory, got %q", got)
+	}
+}
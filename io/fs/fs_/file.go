@@ -0,0 +1,256 @@
+package fs_
+
+import (
+	"io"
+	"sync"
+
+	"github.com/anton2920/gofa/bits"
+	"github.com/anton2920/gofa/context"
+	"github.com/anton2920/gofa/io/fs"
+	"github.com/anton2920/gofa/os"
+)
+
+/* FS is a VFS backed by OS files. */
+type FS struct{}
+
+type File struct {
+	sync.Mutex
+
+	Handle os.Handle
+	Path   string
+}
+
+var _ fs.VFS = FS{}
+var _ fs.VFile = new(File)
+
+func osError(ctx *context.Context, op string, path string) error {
+	switch ctx.ErrorCode() {
+	case os.ErrorCodeFileDoesNotExist:
+		return fs.ErrNotExist
+	case os.ErrorCodeFileExists:
+		return fs.ErrExist
+	default:
+		return &fs.PathError{Op: op, Path: path, Code: ctx.ErrorCode(), Message: os.ErrorCodeString(ctx.ErrorCode())}
+	}
+}
+
+func osFlags(flags int32) (bits.Flags, bits.Flags) {
+	var rw, creat bits.Flags
+
+	if flags&fs.OpenForReading != 0 {
+		rw.Set(os.OpenForReading)
+	}
+	if flags&fs.OpenForWriting != 0 {
+		rw.Set(os.OpenForWriting)
+	}
+	if flags&fs.OpenForAppending != 0 {
+		rw.Set(os.OpenForAppending)
+	}
+
+	if flags&fs.CreateFileIfItDoesNotExist != 0 {
+		creat.Set(os.CreateFileIfItDoesNotExist)
+	}
+	if flags&fs.FailCreationIfFileExists != 0 {
+		creat.Set(os.FailCreationIfFileExists)
+	}
+	if flags&fs.TruncateSizeToZero != 0 {
+		creat.Set(os.TruncateSizeToZero)
+	}
+
+	return rw, creat
+}
+
+func openFile(dir *File, path string, flags int32, perms []uint16) (os.Handle, error) {
+	var ctx context.Context
+	var h os.Handle
+	var ok bool
+
+	rw, creat := osFlags(flags)
+	if dir == nil {
+		h, ok = os.OpenOrCreateFile(&ctx, path, rw, creat, uint(fs.Perms(perms)))
+	} else {
+		h, ok = os.OpenOrCreateFileAt(&ctx, dir.Handle, path, rw, creat, uint(fs.Perms(perms)))
+	}
+	if !ok {
+		return -1, osError(&ctx, "open", path)
+	}
+
+	return h, nil
+}
+
+/* directoryOf returns OS file f was opened with, if any. */
+func directoryOf(f fs.VFile) *File {
+	switch f := f.(type) {
+	case *File:
+		return f
+	case *MmapFile:
+		return &f.File
+	}
+	return nil
+}
+
+func (FS) Open(path string, flags int32, perms ...uint16) (fs.VFile, error) {
+	h, err := openFile(nil, path, flags, perms)
+	if err != nil {
+		return nil, err
+	}
+	return &File{Handle: h, Path: path}, nil
+}
+
+func (FS) OpenAt(f fs.VFile, path string, flags int32, perms ...uint16) (fs.VFile, error) {
+	dir := directoryOf(f)
+	if dir == nil {
+		return nil, &fs.PathError{Op: "openat", Path: path, Message: "directory is not an OS file"}
+	}
+
+	h, err := openFile(dir, path, flags, perms)
+	if err != nil {
+		return nil, err
+	}
+	return &File{Handle: h, Path: path}, nil
+}
+
+func (FS) CreateDirectory(path string, perms uint16) error {
+	var ctx context.Context
+
+	if !os.CreateDirectory(&ctx, path, uint(perms)) {
+		return osError(&ctx, "mkdir", path)
+	}
+	return nil
+}
+
+func (FS) Rename(from string, to string) error {
+	var ctx context.Context
+
+	if !os.RenameFile(&ctx, from, to) {
+		return osError(&ctx, "rename", from)
+	}
+	return nil
+}
+
+func (f *File) Read(buf []byte) (int, error) {
+	var ctx context.Context
+
+	n, ok := os.ReadFromFile(&ctx, f.Handle, buf)
+	if !ok {
+		return 0, osError(&ctx, "read", f.Path)
+	}
+	if (n == 0) && (len(buf) > 0) {
+		return 0, io.EOF
+	}
+
+	return n, nil
+}
+
+func (f *File) Write(buf []byte) (int, error) {
+	var ctx context.Context
+
+	n, ok := os.WriteToFile(&ctx, f.Handle, buf)
+	if !ok {
+		return 0, osError(&ctx, "write", f.Path)
+	}
+
+	return n, nil
+}
+
+func (f *File) Close() error {
+	var ctx context.Context
+
+	if !os.CloseHandle(&ctx, f.Handle) {
+		return osError(&ctx, "close", f.Path)
+	}
+	f.Handle = -1
+
+	return nil
+}
+
+func (f *File) ReadAt(buf []byte, pos int64) (int, error) {
+	var ctx context.Context
+	var n int
+
+	for n < len(buf) {
+		m, ok := os.ReadFromFileAt(&ctx, f.Handle, buf[n:], pos+int64(n))
+		if !ok {
+			return n, osError(&ctx, "pread", f.Path)
+		}
+		if m == 0 {
+			return n, io.EOF
+		}
+		n += m
+	}
+
+	return n, nil
+}
+
+func (f *File) WriteAt(buf []byte, pos int64) (int, error) {
+	return f.WriteAtEx(buf, pos, false)
+}
+
+func (f *File) WriteAtEx(buf []byte, pos int64, lockHeld bool) (int, error) {
+	var ctx context.Context
+	var n int
+
+	if !lockHeld {
+		f.Lock()
+		defer f.Unlock()
+	}
+
+	for n < len(buf) {
+		m, ok := os.WriteToFileAt(&ctx, f.Handle, buf[n:], pos+int64(n))
+		if !ok {
+			return n, osError(&ctx, "pwrite", f.Path)
+		}
+		n += m
+	}
+
+	return n, nil
+}
+
+func (f *File) Size() (int, error) {
+	return f.SizeEx(false)
+}
+
+func (f *File) SizeEx(lockHeld bool) (int, error) {
+	var ctx context.Context
+
+	if !lockHeld {
+		f.Lock()
+		defer f.Unlock()
+	}
+
+	size, ok := os.GetFileSize(&ctx, f.Handle)
+	if !ok {
+		return 0, osError(&ctx, "fstat", f.Path)
+	}
+
+	return size, nil
+}
+
+func (f *File) Sync() error {
+	var ctx context.Context
+
+	if !os.SyncFile(&ctx, f.Handle) {
+		return osError(&ctx, "fsync", f.Path)
+	}
+	return nil
+}
+
+func (f *File) Truncate(n int64) error {
+	f.Lock()
+	defer f.Unlock()
+
+	return f.truncate(n)
+}
+
+func (f *File) truncate(n int64) error {
+	var ctx context.Context
+
+	if !os.ResizeFile(&ctx, f.Handle, int(n)) {
+		return osError(&ctx, "ftruncate", f.Path)
+	}
+	return nil
+}
+
+func (f *File) VFS() fs.VFS {
+	return FS{}
+}
@@ -0,0 +1,182 @@
+package fs_
+
+import (
+	"io"
+	"sync"
+	"unsafe"
+
+	"github.com/anton2920/gofa/bytes"
+	"github.com/anton2920/gofa/context"
+	"github.com/anton2920/gofa/ints"
+	"github.com/anton2920/gofa/io/fs"
+	"github.com/anton2920/gofa/os"
+)
+
+/* MmapFS is a VFS for read-heavy files. ReadAt copies directly from read-only shared mapping of the file, writes go through the file handle and are visible in the mapping. */
+type MmapFS struct{}
+
+type MmapFile struct {
+	File
+
+	/* MapLock protects Mapping and FileSize. Mapping is replaced with a bigger one, when file grows past it. */
+	MapLock  sync.RWMutex
+	Mapping  []byte
+	FileSize int
+}
+
+var _ fs.VFS = MmapFS{}
+var _ fs.VFile = new(MmapFile)
+
+func newMmapFile(h os.Handle, path string) (*MmapFile, error) {
+	var ctx context.Context
+
+	f := &MmapFile{File: File{Handle: h, Path: path}}
+
+	size, ok := os.GetFileSize(&ctx, h)
+	if !ok {
+		os.CloseHandle(&ctx, h)
+		return nil, osError(&ctx, "fstat", path)
+	}
+	if err := f.remap(size); err != nil {
+		os.CloseHandle(&ctx, h)
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func (MmapFS) Open(path string, flags int32, perms ...uint16) (fs.VFile, error) {
+	h, err := openFile(nil, path, flags, perms)
+	if err != nil {
+		return nil, err
+	}
+	return newMmapFile(h, path)
+}
+
+func (MmapFS) OpenAt(f fs.VFile, path string, flags int32, perms ...uint16) (fs.VFile, error) {
+	dir := directoryOf(f)
+	if dir == nil {
+		return nil, &fs.PathError{Op: "openat", Path: path, Message: "directory is not an OS file"}
+	}
+
+	h, err := openFile(dir, path, flags, perms)
+	if err != nil {
+		return nil, err
+	}
+	return newMmapFile(h, path)
+}
+
+func (MmapFS) CreateDirectory(path string, perms uint16) error {
+	return FS{}.CreateDirectory(path, perms)
+}
+
+func (MmapFS) Rename(from string, to string) error {
+	return FS{}.Rename(from, to)
+}
+
+/* remap makes sure at least size bytes of file are mapped and sets FileSize. MapLock must be held for writing or not shared yet. */
+func (f *MmapFile) remap(size int) error {
+	var ctx context.Context
+
+	f.FileSize = size
+	if size <= len(f.Mapping) {
+		return nil
+	}
+
+	if f.Mapping != nil {
+		if !os.DeallocateVirtualMemory(&ctx, unsafe.Pointer(&f.Mapping[0]), len(f.Mapping)) {
+			return osError(&ctx, "munmap", f.Path)
+		}
+		f.Mapping = nil
+	}
+
+	/* NOTE(anton2920): mapping past the end of file is allowed, as long as nobody touches pages there. */
+	n := ints.AlignUpPow2(2*size, os.PageSize)
+	ptr, ok := os.AllocateFileBackedReadOnlyVirtualMemory(&ctx, n, f.Handle)
+	if !ok {
+		return osError(&ctx, "mmap", f.Path)
+	}
+	f.Mapping = bytes.SliceFromUnsafePointer(ptr, n)
+
+	return nil
+}
+
+func (f *MmapFile) Close() error {
+	var ctx context.Context
+
+	f.MapLock.Lock()
+	if f.Mapping != nil {
+		os.DeallocateVirtualMemory(&ctx, unsafe.Pointer(&f.Mapping[0]), len(f.Mapping))
+		f.Mapping = nil
+	}
+	f.FileSize = 0
+	f.MapLock.Unlock()
+
+	return f.File.Close()
+}
+
+func (f *MmapFile) ReadAt(buf []byte, pos int64) (int, error) {
+	f.MapLock.RLock()
+	defer f.MapLock.RUnlock()
+
+	if pos >= int64(f.FileSize) {
+		return 0, io.EOF
+	}
+	n := copy(buf, f.Mapping[pos:f.FileSize])
+	if n < len(buf) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+func (f *MmapFile) WriteAt(buf []byte, pos int64) (int, error) {
+	return f.WriteAtEx(buf, pos, false)
+}
+
+func (f *MmapFile) WriteAtEx(buf []byte, pos int64, lockHeld bool) (int, error) {
+	if !lockHeld {
+		f.Lock()
+		defer f.Unlock()
+	}
+
+	n, err := f.File.WriteAtEx(buf, pos, true)
+	if end := int(pos) + n; end > f.FileSize {
+		f.MapLock.Lock()
+		if err := f.remap(end); err != nil {
+			f.MapLock.Unlock()
+			return n, err
+		}
+		f.MapLock.Unlock()
+	}
+
+	return n, err
+}
+
+func (f *MmapFile) Size() (int, error) {
+	return f.SizeEx(false)
+}
+
+func (f *MmapFile) SizeEx(lockHeld bool) (int, error) {
+	f.MapLock.RLock()
+	defer f.MapLock.RUnlock()
+
+	return f.FileSize, nil
+}
+
+func (f *MmapFile) Truncate(n int64) error {
+	f.Lock()
+	defer f.Unlock()
+
+	f.MapLock.Lock()
+	defer f.MapLock.Unlock()
+
+	if err := f.File.truncate(n); err != nil {
+		return err
+	}
+	return f.remap(int(n))
+}
+
+func (f *MmapFile) VFS() fs.VFS {
+	return MmapFS{}
+}
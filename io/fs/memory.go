@@ -0,0 +1,291 @@
+package fs
+
+import (
+	"io"
+	"path"
+	"sync"
+)
+
+/* MemoryFS is a VFS that keeps all files in memory. It is intended for tests. */
+type MemoryFS struct {
+	sync.Mutex
+	Files       map[string]*MemoryFileData
+	Directories map[string]struct{}
+}
+
+/* MemoryFileData is shared by all opened instances of the same file. */
+type MemoryFileData struct {
+	/* Lock is returned to users by MemoryFile.Lock. */
+	Lock sync.Mutex
+
+	DataLock sync.RWMutex
+	Data     []byte
+}
+
+type MemoryFile struct {
+	FS   *MemoryFS
+	Path string
+
+	/* File is nil for directories. */
+	File  *MemoryFileData
+	Flags int32
+	Pos   int64
+}
+
+var _ VFS = new(MemoryFS)
+var _ VFile = new(MemoryFile)
+
+func NewMemoryFS() *MemoryFS {
+	fs := new(MemoryFS)
+	fs.Files = make(map[string]*MemoryFileData)
+	fs.Directories = make(map[string]struct{})
+	return fs
+}
+
+func (fs *MemoryFS) isDirectory(p string) bool {
+	if (p == "/") || (p == ".") {
+		return true
+	}
+	_, ok := fs.Directories[p]
+	return ok
+}
+
+func (fs *MemoryFS) open(p string, flags int32) (VFile, error) {
+	p = path.Clean(p)
+
+	fs.Lock()
+	defer fs.Unlock()
+
+	if fs.isDirectory(p) {
+		if flags&(OpenForWriting|OpenForAppending) != 0 {
+			return nil, ErrIsDirectory
+		}
+		return &MemoryFile{FS: fs, Path: p, Flags: flags}, nil
+	}
+
+	data, ok := fs.Files[p]
+	if !ok {
+		if flags&CreateFileIfItDoesNotExist == 0 {
+			return nil, ErrNotExist
+		}
+		if !fs.isDirectory(path.Dir(p)) {
+			return nil, ErrNotExist
+		}
+		data = new(MemoryFileData)
+		fs.Files[p] = data
+	} else if flags&(CreateFileIfItDoesNotExist|FailCreationIfFileExists) == CreateFileIfItDoesNotExist|FailCreationIfFileExists {
+		return nil, ErrExist
+	}
+
+	if flags&TruncateSizeToZero != 0 {
+		data.DataLock.Lock()
+		data.Data = nil
+		data.DataLock.Unlock()
+	}
+
+	return &MemoryFile{FS: fs, Path: p, File: data, Flags: flags}, nil
+}
+
+func (fs *MemoryFS) Open(path string, flags int32, perms ...uint16) (VFile, error) {
+	return fs.open(path, flags)
+}
+
+func (fs *MemoryFS) OpenAt(f VFile, p string, flags int32, perms ...uint16) (VFile, error) {
+	if !path.IsAbs(p) {
+		dir, ok := f.(*MemoryFile)
+		if (!ok) || (dir.FS != fs) {
+			return nil, ErrNotExist
+		}
+		p = path.Join(dir.Path, p)
+	}
+	return fs.open(p, flags)
+}
+
+func (fs *MemoryFS) CreateDirectory(p string, perms uint16) error {
+	p = path.Clean(p)
+
+	fs.Lock()
+	defer fs.Unlock()
+
+	if _, ok := fs.Files[p]; (ok) || (fs.isDirectory(p)) {
+		return ErrExist
+	}
+	if !fs.isDirectory(path.Dir(p)) {
+		return ErrNotExist
+	}
+	fs.Directories[p] = struct{}{}
+
+	return nil
+}
+
+func (fs *MemoryFS) Rename(from string, to string) error {
+	from = path.Clean(from)
+	to = path.Clean(to)
+
+	fs.Lock()
+	defer fs.Unlock()
+
+	data, ok := fs.Files[from]
+	if !ok {
+		if fs.isDirectory(from) {
+			return ErrIsDirectory
+		}
+		return ErrNotExist
+	}
+	if fs.isDirectory(to) {
+		return ErrIsDirectory
+	}
+	if !fs.isDirectory(path.Dir(to)) {
+		return ErrNotExist
+	}
+	delete(fs.Files, from)
+	fs.Files[to] = data
+
+	return nil
+}
+
+func (f *MemoryFile) Read(buf []byte) (int, error) {
+	n, err := f.ReadAt(buf, f.Pos)
+	f.Pos += int64(n)
+	if (err == io.EOF) && (n > 0) {
+		err = nil
+	}
+	return n, err
+}
+
+func (f *MemoryFile) Write(buf []byte) (int, error) {
+	if f.Flags&OpenForAppending != 0 {
+		f.Lock()
+		defer f.Unlock()
+
+		size, _ := f.SizeEx(true)
+		f.Pos = int64(size)
+		n, err := f.WriteAtEx(buf, f.Pos, true)
+		f.Pos += int64(n)
+		return n, err
+	}
+
+	n, err := f.WriteAt(buf, f.Pos)
+	f.Pos += int64(n)
+	return n, err
+}
+
+func (f *MemoryFile) Close() error {
+	f.File = nil
+	f.FS = nil
+	return nil
+}
+
+func (f *MemoryFile) ReadAt(buf []byte, pos int64) (int, error) {
+	if f.File == nil {
+		return 0, ErrIsDirectory
+	}
+
+	f.File.DataLock.RLock()
+	defer f.File.DataLock.RUnlock()
+
+	if pos >= int64(len(f.File.Data)) {
+		return 0, io.EOF
+	}
+	n := copy(buf, f.File.Data[pos:])
+	if n < len(buf) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *MemoryFile) WriteAt(buf []byte, pos int64) (int, error) {
+	return f.WriteAtEx(buf, pos, false)
+}
+
+func (f *MemoryFile) WriteAtEx(buf []byte, pos int64, lockHeld bool) (int, error) {
+	if f.File == nil {
+		return 0, ErrIsDirectory
+	}
+	if f.Flags&(OpenForWriting|OpenForAppending) == 0 {
+		return 0, ErrNotOpenForWriting
+	}
+
+	if !lockHeld {
+		f.Lock()
+		defer f.Unlock()
+	}
+
+	f.File.DataLock.Lock()
+	defer f.File.DataLock.Unlock()
+
+	if end := pos + int64(len(buf)); end > int64(len(f.File.Data)) {
+		if end > int64(cap(f.File.Data)) {
+			data := make([]byte, end, 2*end)
+			copy(data, f.File.Data)
+			f.File.Data = data
+		} else {
+			f.File.Data = f.File.Data[:end]
+		}
+	}
+
+	return copy(f.File.Data[pos:], buf), nil
+}
+
+func (f *MemoryFile) Size() (int, error) {
+	return f.SizeEx(false)
+}
+
+func (f *MemoryFile) SizeEx(lockHeld bool) (int, error) {
+	if f.File == nil {
+		return 0, ErrIsDirectory
+	}
+
+	f.File.DataLock.RLock()
+	defer f.File.DataLock.RUnlock()
+
+	return len(f.File.Data), nil
+}
+
+func (f *MemoryFile) Sync() error {
+	return nil
+}
+
+func (f *MemoryFile) Truncate(n int64) error {
+	if f.File == nil {
+		return ErrIsDirectory
+	}
+	if f.Flags&(OpenForWriting|OpenForAppending) == 0 {
+		return ErrNotOpenForWriting
+	}
+
+	f.Lock()
+	defer f.Unlock()
+
+	f.truncate(n)
+	return nil
+}
+
+func (f *MemoryFile) truncate(n int64) {
+	f.File.DataLock.Lock()
+	defer f.File.DataLock.Unlock()
+
+	if n > int64(len(f.File.Data)) {
+		data := make([]byte, n)
+		copy(data, f.File.Data)
+		f.File.Data = data
+	} else {
+		/* Zero truncated part, so it reads as zeroes if file grows again. */
+		for i := n; i < int64(len(f.File.Data)); i++ {
+			f.File.Data[i] = 0
+		}
+		f.File.Data = f.File.Data[:n]
+	}
+}
+
+func (f *MemoryFile) Lock() {
+	f.File.Lock.Lock()
+}
+
+func (f *MemoryFile) Unlock() {
+	f.File.Lock.Unlock()
+}
+
+func (f *MemoryFile) VFS() VFS {
+	return f.FS
+}
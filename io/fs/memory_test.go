@@ -0,0 +1,102 @@
+package fs
+
+import (
+	"io"
+	"sync"
+	"testing"
+)
+
+func TestMemoryFSOpen(t *testing.T) {
+	fs := NewMemoryFS()
+
+	if _, err := fs.Open("/db/tree", OpenForReading); err != ErrNotExist {
+		t.Errorf("expected ErrNotExist, got %v", err)
+	}
+	if _, err := fs.Open("/db/tree", OpenForReading|OpenForWriting|CreateFileIfItDoesNotExist); err != ErrNotExist {
+		t.Errorf("expected ErrNotExist for missing directory, got %v", err)
+	}
+	if err := fs.CreateDirectory("/db", 0755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+
+	dir, err := fs.Open("/db", OpenForReading)
+	if err != nil {
+		t.Fatalf("failed to open directory: %v", err)
+	}
+	f, err := fs.OpenAt(dir, "tree", OpenForReading|OpenForWriting|CreateFileIfItDoesNotExist)
+	if err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	if _, err := f.WriteAt([]byte("hello"), 3); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+
+	g, err := fs.Open("/db/tree", OpenForReading)
+	if err != nil {
+		t.Fatalf("failed to open file: %v", err)
+	}
+	if _, err := fs.Open("/db/tree", OpenForReading|CreateFileIfItDoesNotExist|FailCreationIfFileExists); err != ErrExist {
+		t.Errorf("expected ErrExist, got %v", err)
+	}
+	if _, err := g.Write([]byte("x")); err != ErrNotOpenForWriting {
+		t.Errorf("expected ErrNotOpenForWriting, got %v", err)
+	}
+
+	buf := make([]byte, 8)
+	n, err := g.ReadAt(buf, 0)
+	if (n != 8) || (err != nil) || (string(buf) != "\x00\x00\x00hello") {
+		t.Errorf("expected full read, got %d, %v, %q", n, err, buf)
+	}
+	if n, err := g.ReadAt(buf, 4); (n != 4) || (err != io.EOF) {
+		t.Errorf("expected short read with io.EOF, got %d, %v", n, err)
+	}
+}
+
+func TestMemoryFSAppend(t *testing.T) {
+	const (
+		writers = 8
+		pages   = 100
+		size    = 16
+	)
+
+	fs := NewMemoryFS()
+	f, err := fs.Open("file", OpenForReading|OpenForWriting|CreateFileIfItDoesNotExist)
+	if err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+
+			page := make([]byte, size)
+			for i := range page {
+				page[i] = byte(w)
+			}
+			for i := 0; i < pages; i++ {
+				f.Lock()
+				n, _ := f.SizeEx(true)
+				f.WriteAtEx(page, int64(n), true)
+				f.Unlock()
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	n, _ := f.Size()
+	if n != writers*pages*size {
+		t.Fatalf("expected size %d, got %d", writers*pages*size, n)
+	}
+
+	page := make([]byte, size)
+	for off := 0; off < n; off += size {
+		f.ReadAt(page, int64(off))
+		for i := range page {
+			if page[i] != page[0] {
+				t.Fatalf("page at %d is torn: %v", off, page)
+			}
+		}
+	}
+}
@@ -1,11 +1,35 @@
 package fs
 
+/* Open flags. */
+const (
+	OpenForReading = int32(1 << iota)
+	OpenForWriting
+	OpenForAppending
+
+	CreateFileIfItDoesNotExist
+	FailCreationIfFileExists
+	TruncateSizeToZero
+)
+
+/* DefaultPerms are used by Open and OpenAt when perms are omitted. */
+const DefaultPerms = 0644
+
 type VFS interface {
 	Open(path string, flags int32, perms ...uint16) (VFile, error)
 	OpenAt(f VFile, path string, flags int32, perms ...uint16) (VFile, error)
 	CreateDirectory(path string, perms uint16) error
+
+	/* Rename atomically replaces file at 'to' with file at 'from'. Rename is durable after directory of 'to' is synced. */
+	Rename(from string, to string) error
 }
 
+/* VFile is a file opened by VFS. ReadAt, WriteAt and Size may be called from multiple goroutines at once.
+ * Lock and Unlock guard sequences of operations that must be atomic, e.g. appending a page at the end of file:
+ *     f.Lock()
+ *     size, _ := f.SizeEx(true)
+ *     f.WriteAtEx(page, int64(size), true)
+ *     f.Unlock()
+ * Methods with 'Ex' suffix must be called with lockHeld == true only if caller holds Lock. */
 type VFile interface {
 	Read(buf []byte) (int, error)
 	Write(buf []byte) (int, error)
@@ -26,3 +50,10 @@ type VFile interface {
 
 	VFS() VFS
 }
+
+func Perms(perms []uint16) uint16 {
+	if len(perms) > 0 {
+		return perms[0]
+	}
+	return DefaultPerms
+}
@@ -0,0 +1,53 @@
+package l10n
+
+import "github.com/anton2920/gofa/time"
+
+var Language2TimeNames = [LanguageCount]time.Names{
+	LanguageEnglish: {
+		Wdays:                 [...]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+		WdaysShort:            [...]string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"},
+		Months:                [...]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+		MonthsShort:           [...]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"},
+		MonthsStandalone:      [...]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+		MonthsStandaloneShort: [...]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"},
+		AM:                    "AM",
+		PM:                    "PM",
+		DateFormat:            "%b %-d, %Y",
+		TimeFormat:            "%H:%M:%S",
+		DateTimeFormat:        "%b %-d, %Y, %H:%M:%S",
+	},
+	LanguageRussian: {
+		Wdays:                 [...]string{"воскресенье", "понедельник", "вторник", "среда", "четверг", "пятница", "суббота"},
+		WdaysShort:            [...]string{"вс", "пн", "вт", "ср", "чт", "пт", "сб"},
+		Months:                [...]string{"января", "февраля", "марта", "апреля", "мая", "июня", "июля", "августа", "сентября", "октября", "ноября", "декабря"},
+		MonthsShort:           [...]string{"янв.", "февр.", "мар.", "апр.", "мая", "июн.", "июл.", "авг.", "сент.", "окт.", "нояб.", "дек."},
+		MonthsStandalone:      [...]string{"январь", "февраль", "март", "апрель", "май", "июнь", "июль", "август", "сентябрь", "октябрь", "ноябрь", "декабрь"},
+		MonthsStandaloneShort: [...]string{"янв.", "февр.", "март", "апр.", "май", "июнь", "июль", "авг.", "сент.", "окт.", "нояб.", "дек."},
+		AM:                    "AM",
+		PM:                    "PM",
+		DateFormat:            "%-d %b %Y г.",
+		TimeFormat:            "%H:%M:%S",
+		DateTimeFormat:        "%-d %b %Y г., %H:%M:%S",
+	},
+	LanguageFrench: {
+		Wdays:                 [...]string{"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi"},
+		WdaysShort:            [...]string{"dim.", "lun.", "mar.", "mer.", "jeu.", "ven.", "sam."},
+		Months:                [...]string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		MonthsShort:           [...]string{"janv.", "févr.", "mars", "avr.", "mai", "juin", "juil.", "août", "sept.", "oct.", "nov.", "déc."},
+		MonthsStandalone:      [...]string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		MonthsStandaloneShort: [...]string{"janv.", "févr.", "mars", "avr.", "mai", "juin", "juil.", "août", "sept.", "oct.", "nov.", "déc."},
+		AM:                    "AM",
+		PM:                    "PM",
+		DateFormat:            "%-d %b %Y",
+		TimeFormat:            "%H:%M:%S",
+		DateTimeFormat:        "%-d %b %Y, %H:%M:%S",
+	},
+}
+
+/* TimeNames returns names of months and days of week and date formats for time.StrftimeNames. */
+func (l Language) TimeNames() *time.Names {
+	if (l < 0) || (l >= LanguageCount) {
+		l = LanguageEnglish
+	}
+	return &Language2TimeNames[l]
+}
@@ -23,9 +23,42 @@ func StringSliceContains(xs []string, x string) bool {
 	return false
 }
 
+/* Params qualifies types declared in package fmt and returns arguments for call, e.g. for 'format *Format, args ...interface{}' it returns 'format *fmt.Format, args ...interface{}' and 'format, args...'. */
+func Params(params string) (string, string) {
+	var qualified, args []string
+
+	for _, param := range strings.Split(params, ", ") {
+		sp := strings.IndexByte(param, ' ')
+		if sp <= 0 {
+			return "", ""
+		}
+		name, typ := param[:sp], param[sp+1:]
+
+		prefix := typ[:len(typ)-len(strings.TrimLeft(typ, ".*[]"))]
+		base := typ[len(prefix):]
+		if (unicode.IsUpper(rune(base[0]))) && (strings.IndexByte(base, '.') == -1) {
+			typ = prefix + "fmt." + base
+		}
+		qualified = append(qualified, name+" "+typ)
+
+		if strings.HasPrefix(typ, "...") {
+			name += "..."
+		}
+		args = append(args, name)
+	}
+
+	return strings.Join(qualified, ", "), strings.Join(args, ", ")
+}
+
 func main() {
 	blacklist := [...]string{"InitWithUnsafePointer", "InitWithBytePointer", "InitWithByteSlice", "Reset"}
 
+	/* NOTE(anton2920): values of these verbs are written as is in structured encodings, other values are strings. */
+	numeric := [...]string{"D", "D32", "D64", "E", "E32", "E64", "F", "F32", "F64", "G", "G32", "G64", "I", "I32", "I64"}
+
+	/* NOTE(anton2920): these do not write values, so they do not get keyed variants. */
+	modifiers := [...]string{"Backspace", "W", "Prec"}
+
 	const path = "formatter_helpers.go"
 	f, err := os.Create(path)
 	if err != nil {
@@ -41,7 +74,10 @@ func main() {
 
 	String(b, "/* File generated by 'cmd/generate.go'; DO NOT EDIT! */")
 	String(b, "package log\n")
-	String(b, `import "unsafe"`)
+	String(b, "import (")
+	String(b, "\t\"unsafe\"\n")
+	String(b, "\t\"github.com/anton2920/gofa/fmt\"")
+	String(b, ")")
 
 	scanner := bufio.NewScanner(src)
 	for scanner.Scan() {
@@ -56,18 +92,33 @@ func main() {
 				if (unicode.IsUpper(rune(fn[0]))) && (!StringSliceContains(blacklist[:], fn)) {
 					rparen := strings.IndexByte(line, ')')
 					if rparen > 0 {
-						params := line[lparen+1 : rparen]
-						sp := strings.IndexByte(params, ' ')
-						if sp > 0 {
-							name := params[:sp]
+						params, args := Params(line[lparen+1 : rparen])
+						if len(args) > 0 {
+							var quote string
+							if (!StringSliceContains(numeric[:], fn)) && (!StringSliceContains(modifiers[:], fn)) {
+								quote = "\n\t\tf.Quote = true"
+							}
+
 							fmt.Fprintf(b, `
 func (f *Formatter) %s(%s) *Formatter {
 	if f != nil {
-		f.Fmt.%s(%s)
+		f.Fmt.%s(%s)%s
+	}
+	return f
+}
+`, fn, params, fn, args, quote)
+
+							if !StringSliceContains(modifiers[:], fn) {
+								fmt.Fprintf(b, `
+func (f *Formatter) K%s(key string, %s) *Formatter {
+	if f != nil {
+		f.K(key)
+		f.Fmt.%s(%s)%s
 	}
 	return f
 }
-`, fn, params, fn, name)
+`, fn, params, fn, args, quote)
+							}
 						}
 					}
 				}
@@ -7,6 +7,19 @@ type Formatter struct {
 
 	MinimumLevel Level
 	CurrentLevel Level
+
+	Encoding Encoding
+
+	/* Fields is the number of fields written in structured encoding. Field, which value is being written, starts at KeyStart, and its value starts at ValueStart. Quote is set, if value must be written as string. Message is set for implicit 'msg' field. */
+	Fields     int
+	InValue    bool
+	KeyStart   int
+	ValueStart int
+	Quote      bool
+	Message    bool
+
+	/* FlushOnEnd is set, if Fmt flushes on overflow. Value must be in Buffer to be quoted, so structured line is not flushed until End. */
+	FlushOnEnd bool
 }
 
 func (f *Formatter) InitWithByteSlice(buf []byte) {
@@ -21,6 +34,11 @@ func (f *Formatter) InitWithMinimumLevelAndByteSlice(level Level, buf []byte) {
 func (f *Formatter) Reset(t int64) *Formatter {
 	f.Fmt.Reset()
 	f.CurrentLevel = LevelLast
+
+	if f.Encoding != EncodingText {
+		f.begin(t)
+		return f
+	}
 	return f.DateTime(t).S(" ")
 }
 
@@ -29,6 +47,10 @@ func (f *Formatter) Log(level Level, t int64) *Formatter {
 	f.CurrentLevel = level
 
 	if f.CurrentLevel >= f.MinimumLevel {
+		if f.Encoding != EncodingText {
+			f.begin(t)
+			return f
+		}
 		f.DateTime(t).S(" ").W(5).S(Level2String[f.CurrentLevel]).S(" ")
 		return f
 	}
@@ -1,7 +1,11 @@
 /* File generated by 'cmd/generate.go'; DO NOT EDIT! */
 package log
 
-import "unsafe"
+import (
+	"unsafe"
+
+	"github.com/anton2920/gofa/fmt"
+)
 
 func (f *Formatter) Backspace(n int) *Formatter {
 	if f != nil {
@@ -17,6 +21,14 @@ func (f *Formatter) D(d int) *Formatter {
 	return f
 }
 
+func (f *Formatter) KD(key string, d int) *Formatter {
+	if f != nil {
+		f.K(key)
+		f.Fmt.D(d)
+	}
+	return f
+}
+
 func (f *Formatter) D32(d int32) *Formatter {
 	if f != nil {
 		f.Fmt.D32(d)
@@ -24,6 +36,14 @@ func (f *Formatter) D32(d int32) *Formatter {
 	return f
 }
 
+func (f *Formatter) KD32(key string, d int32) *Formatter {
+	if f != nil {
+		f.K(key)
+		f.Fmt.D32(d)
+	}
+	return f
+}
+
 func (f *Formatter) D64(d int64) *Formatter {
 	if f != nil {
 		f.Fmt.D64(d)
@@ -31,9 +51,27 @@ func (f *Formatter) D64(d int64) *Formatter {
 	return f
 }
 
+func (f *Formatter) KD64(key string, d int64) *Formatter {
+	if f != nil {
+		f.K(key)
+		f.Fmt.D64(d)
+	}
+	return f
+}
+
 func (f *Formatter) Date(t int64) *Formatter {
 	if f != nil {
 		f.Fmt.Date(t)
+		f.Quote = true
+	}
+	return f
+}
+
+func (f *Formatter) KDate(key string, t int64) *Formatter {
+	if f != nil {
+		f.K(key)
+		f.Fmt.Date(t)
+		f.Quote = true
 	}
 	return f
 }
@@ -41,6 +79,33 @@ func (f *Formatter) Date(t int64) *Formatter {
 func (f *Formatter) DateTime(t int64) *Formatter {
 	if f != nil {
 		f.Fmt.DateTime(t)
+		f.Quote = true
+	}
+	return f
+}
+
+func (f *Formatter) KDateTime(key string, t int64) *Formatter {
+	if f != nil {
+		f.K(key)
+		f.Fmt.DateTime(t)
+		f.Quote = true
+	}
+	return f
+}
+
+func (f *Formatter) Dur(d int64) *Formatter {
+	if f != nil {
+		f.Fmt.Dur(d)
+		f.Quote = true
+	}
+	return f
+}
+
+func (f *Formatter) KDur(key string, d int64) *Formatter {
+	if f != nil {
+		f.K(key)
+		f.Fmt.Dur(d)
+		f.Quote = true
 	}
 	return f
 }
@@ -48,6 +113,16 @@ func (f *Formatter) DateTime(t int64) *Formatter {
 func (f *Formatter) Err(err error) *Formatter {
 	if f != nil {
 		f.Fmt.Err(err)
+		f.Quote = true
+	}
+	return f
+}
+
+func (f *Formatter) KErr(key string, err error) *Formatter {
+	if f != nil {
+		f.K(key)
+		f.Fmt.Err(err)
+		f.Quote = true
 	}
 	return f
 }
@@ -59,6 +134,14 @@ func (f *Formatter) E(e float64) *Formatter {
 	return f
 }
 
+func (f *Formatter) KE(key string, e float64) *Formatter {
+	if f != nil {
+		f.K(key)
+		f.Fmt.E(e)
+	}
+	return f
+}
+
 func (f *Formatter) E32(e float32) *Formatter {
 	if f != nil {
 		f.Fmt.E32(e)
@@ -66,6 +149,14 @@ func (f *Formatter) E32(e float32) *Formatter {
 	return f
 }
 
+func (f *Formatter) KE32(key string, e float32) *Formatter {
+	if f != nil {
+		f.K(key)
+		f.Fmt.E32(e)
+	}
+	return f
+}
+
 func (f *Formatter) E64(e float64) *Formatter {
 	if f != nil {
 		f.Fmt.E64(e)
@@ -73,6 +164,14 @@ func (f *Formatter) E64(e float64) *Formatter {
 	return f
 }
 
+func (f *Formatter) KE64(key string, e float64) *Formatter {
+	if f != nil {
+		f.K(key)
+		f.Fmt.E64(e)
+	}
+	return f
+}
+
 func (f *Formatter) F(f_ float64) *Formatter {
 	if f != nil {
 		f.Fmt.F(f_)
@@ -80,6 +179,14 @@ func (f *Formatter) F(f_ float64) *Formatter {
 	return f
 }
 
+func (f *Formatter) KF(key string, f_ float64) *Formatter {
+	if f != nil {
+		f.K(key)
+		f.Fmt.F(f_)
+	}
+	return f
+}
+
 func (f *Formatter) F32(f_ float32) *Formatter {
 	if f != nil {
 		f.Fmt.F32(f_)
@@ -87,6 +194,14 @@ func (f *Formatter) F32(f_ float32) *Formatter {
 	return f
 }
 
+func (f *Formatter) KF32(key string, f_ float32) *Formatter {
+	if f != nil {
+		f.K(key)
+		f.Fmt.F32(f_)
+	}
+	return f
+}
+
 func (f *Formatter) F64(f_ float64) *Formatter {
 	if f != nil {
 		f.Fmt.F64(f_)
@@ -94,6 +209,14 @@ func (f *Formatter) F64(f_ float64) *Formatter {
 	return f
 }
 
+func (f *Formatter) KF64(key string, f_ float64) *Formatter {
+	if f != nil {
+		f.K(key)
+		f.Fmt.F64(f_)
+	}
+	return f
+}
+
 func (f *Formatter) G(g float64) *Formatter {
 	if f != nil {
 		f.Fmt.G(g)
@@ -101,6 +224,14 @@ func (f *Formatter) G(g float64) *Formatter {
 	return f
 }
 
+func (f *Formatter) KG(key string, g float64) *Formatter {
+	if f != nil {
+		f.K(key)
+		f.Fmt.G(g)
+	}
+	return f
+}
+
 func (f *Formatter) G32(g float32) *Formatter {
 	if f != nil {
 		f.Fmt.G32(g)
@@ -108,6 +239,14 @@ func (f *Formatter) G32(g float32) *Formatter {
 	return f
 }
 
+func (f *Formatter) KG32(key string, g float32) *Formatter {
+	if f != nil {
+		f.K(key)
+		f.Fmt.G32(g)
+	}
+	return f
+}
+
 func (f *Formatter) G64(g float64) *Formatter {
 	if f != nil {
 		f.Fmt.G64(g)
@@ -115,6 +254,14 @@ func (f *Formatter) G64(g float64) *Formatter {
 	return f
 }
 
+func (f *Formatter) KG64(key string, g float64) *Formatter {
+	if f != nil {
+		f.K(key)
+		f.Fmt.G64(g)
+	}
+	return f
+}
+
 func (f *Formatter) I(i int) *Formatter {
 	if f != nil {
 		f.Fmt.I(i)
@@ -122,6 +269,14 @@ func (f *Formatter) I(i int) *Formatter {
 	return f
 }
 
+func (f *Formatter) KI(key string, i int) *Formatter {
+	if f != nil {
+		f.K(key)
+		f.Fmt.I(i)
+	}
+	return f
+}
+
 func (f *Formatter) I32(i int32) *Formatter {
 	if f != nil {
 		f.Fmt.I32(i)
@@ -129,6 +284,14 @@ func (f *Formatter) I32(i int32) *Formatter {
 	return f
 }
 
+func (f *Formatter) KI32(key string, i int32) *Formatter {
+	if f != nil {
+		f.K(key)
+		f.Fmt.I32(i)
+	}
+	return f
+}
+
 func (f *Formatter) I64(i int64) *Formatter {
 	if f != nil {
 		f.Fmt.I64(i)
@@ -136,9 +299,44 @@ func (f *Formatter) I64(i int64) *Formatter {
 	return f
 }
 
+func (f *Formatter) KI64(key string, i int64) *Formatter {
+	if f != nil {
+		f.K(key)
+		f.Fmt.I64(i)
+	}
+	return f
+}
+
 func (f *Formatter) P(p unsafe.Pointer) *Formatter {
 	if f != nil {
 		f.Fmt.P(p)
+		f.Quote = true
+	}
+	return f
+}
+
+func (f *Formatter) KP(key string, p unsafe.Pointer) *Formatter {
+	if f != nil {
+		f.K(key)
+		f.Fmt.P(p)
+		f.Quote = true
+	}
+	return f
+}
+
+func (f *Formatter) Printf(format *fmt.Format, args ...interface{}) *Formatter {
+	if f != nil {
+		f.Fmt.Printf(format, args...)
+		f.Quote = true
+	}
+	return f
+}
+
+func (f *Formatter) KPrintf(key string, format *fmt.Format, args ...interface{}) *Formatter {
+	if f != nil {
+		f.K(key)
+		f.Fmt.Printf(format, args...)
+		f.Quote = true
 	}
 	return f
 }
@@ -146,6 +344,16 @@ func (f *Formatter) P(p unsafe.Pointer) *Formatter {
 func (f *Formatter) Q(q string) *Formatter {
 	if f != nil {
 		f.Fmt.Q(q)
+		f.Quote = true
+	}
+	return f
+}
+
+func (f *Formatter) KQ(key string, q string) *Formatter {
+	if f != nil {
+		f.K(key)
+		f.Fmt.Q(q)
+		f.Quote = true
 	}
 	return f
 }
@@ -153,6 +361,16 @@ func (f *Formatter) Q(q string) *Formatter {
 func (f *Formatter) S(s string) *Formatter {
 	if f != nil {
 		f.Fmt.S(s)
+		f.Quote = true
+	}
+	return f
+}
+
+func (f *Formatter) KS(key string, s string) *Formatter {
+	if f != nil {
+		f.K(key)
+		f.Fmt.S(s)
+		f.Quote = true
 	}
 	return f
 }
@@ -0,0 +1,205 @@
+package log_
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/anton2920/gofa/context"
+	"github.com/anton2920/gofa/fmt"
+	"github.com/anton2920/gofa/log"
+	"github.com/anton2920/gofa/os"
+	"github.com/anton2920/gofa/time/time_"
+)
+
+/* Pipeline writes log lines asynchronously. Threads push finished lines from their own log.Formatters into lock-free Ring, and background writer passes them to every Sink with sufficient level. Usage:
+ *     var p log_.Pipeline
+ *     p.Init(1024, 512)
+ *     p.AddSink(&log_.HandleSink{Handle: os.StandardErrorStream}, log.LevelWarn)
+ *     p.AddSink(&fileSink, log.LevelDebug)
+ *     p.Start()
+ *     defer p.Stop()
+ *     ...
+ *     p.Println(ctx.Log.Info(time_.NowInNanoseconds()).S("Hello, world!"))
+ * Lines with LevelFatal and LevelPanic are written synchronously after everything pushed before them, and sinks are synced before exit or panic. */
+type Pipeline struct {
+	Ring  log.Ring
+	Sinks []PipelineSink
+
+	/* Lock is held by consumer of Ring, which is either background writer or Drain. */
+	Lock    sync.Mutex
+	Context context.Context
+	Log     log.Formatter
+
+	/* Sleeping is set by writer before waiting on Wakeup, so producers signal only when writer may be asleep. */
+	Sleeping int32
+	Wakeup   chan struct{}
+	Done     chan struct{}
+	Stopped  chan struct{}
+}
+
+type PipelineSink struct {
+	Sink         Sink
+	MinimumLevel log.Level
+
+	/* Failed is set after the first error, so it is reported only once until sink recovers. */
+	Failed bool
+}
+
+/* Init allocates ring of n lines of size bytes each. Longer lines are truncated. */
+func (p *Pipeline) Init(n int, size int) {
+	p.Ring.Init(n, size)
+	p.Context.InitWithEvenlySplitByteSlice(make([]byte, 4*512))
+	p.Log.InitWithByteSlice(make([]byte, 256))
+	p.Wakeup = make(chan struct{}, 1)
+	p.Done = make(chan struct{})
+	p.Stopped = make(chan struct{})
+}
+
+/* AddSink adds sink, which receives lines with level greater or equal to minimum. It must be called before Start. */
+func (p *Pipeline) AddSink(sink Sink, minimum log.Level) *Pipeline {
+	p.Sinks = append(p.Sinks, PipelineSink{Sink: sink, MinimumLevel: minimum})
+	return p
+}
+
+/* report writes current error to standard error stream, because there is no other place for it. */
+func (p *Pipeline) report() {
+	var f fmt.Formatter
+	var buf [512]byte
+
+	f.InitWithByteSlice(buf[:])
+	f.TruncateOnOverflow("")
+	os.WriteToFile(&p.Context, os.StandardErrorStream, f.S("log_: ").S(p.Context.Error()).Ln().Bytes())
+}
+
+/* write passes line to sinks, which accept its level. */
+func (p *Pipeline) write(level log.Level, line []byte) {
+	ctx := &p.Context
+
+	for i := 0; i < len(p.Sinks); i++ {
+		sink := &p.Sinks[i]
+		if level < sink.MinimumLevel {
+			continue
+		}
+
+		if sink.Sink.Write(ctx, level, line) {
+			sink.Failed = false
+		} else if !sink.Failed {
+			sink.Failed = true
+			p.report()
+		}
+	}
+}
+
+/* consume writes all lines from ring. p.Lock must be held. */
+func (p *Pipeline) consume() {
+	for {
+		level, line, ok := p.Ring.Peek()
+		if !ok {
+			break
+		}
+		p.write(level, line)
+		p.Ring.Pop()
+	}
+
+	if dropped := p.Ring.TakeDropped(); dropped > 0 {
+		f := p.Log.Warn(time_.NowInNanoseconds()).S("log_: dropped ").D64(int64(dropped)).S(" lines, because ring was full")
+		p.write(log.LevelWarn, f.End().Fmt.Ln().Bytes())
+	}
+}
+
+func (p *Pipeline) sync() {
+	ctx := &p.Context
+
+	for i := 0; i < len(p.Sinks); i++ {
+		if !p.Sinks[i].Sink.Sync(ctx) {
+			p.report()
+		}
+	}
+}
+
+/* Drain synchronously writes all pushed lines and syncs sinks. */
+func (p *Pipeline) Drain() {
+	p.Lock.Lock()
+	p.consume()
+	p.sync()
+	p.Lock.Unlock()
+}
+
+func (p *Pipeline) wake() {
+	if atomic.CompareAndSwapInt32(&p.Sleeping, 1, 0) {
+		/* NOTE(anton2920): signal may already be pending, if writer has not consumed it yet, so producer must not block. */
+		select {
+		case p.Wakeup <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (p *Pipeline) run() {
+	defer close(p.Stopped)
+
+	for {
+		p.Lock.Lock()
+		p.consume()
+		p.Lock.Unlock()
+
+		/* NOTE(anton2920): line may be pushed between consume and setting Sleeping, so ring is checked again. If producer has already reset Sleeping, its signal is left in Wakeup and next wait returns immediately. */
+		atomic.StoreInt32(&p.Sleeping, 1)
+		p.Lock.Lock()
+		empty := p.Ring.Empty()
+		p.Lock.Unlock()
+		if (!empty) && (atomic.CompareAndSwapInt32(&p.Sleeping, 1, 0)) {
+			continue
+		}
+
+		select {
+		case <-p.Wakeup:
+		case <-p.Done:
+			return
+		}
+	}
+}
+
+/* Start starts background writer. */
+func (p *Pipeline) Start() {
+	go p.run()
+}
+
+/* Stop stops background writer, writes remaining lines and closes sinks. */
+func (p *Pipeline) Stop() {
+	close(p.Done)
+	<-p.Stopped
+
+	p.Drain()
+
+	ctx := &p.Context
+	for i := 0; i < len(p.Sinks); i++ {
+		if !p.Sinks[i].Sink.Close(ctx) {
+			p.report()
+		}
+	}
+}
+
+/* Println is like log_.Println, but pushes line into pipeline. */
+func (p *Pipeline) Println(f *log.Formatter) {
+	if f != nil {
+		line := f.End().Fmt.Ln().Bytes()
+		switch f.CurrentLevel {
+		default:
+			p.Ring.Push(f.CurrentLevel, line)
+			p.wake()
+		case log.LevelFatal, log.LevelPanic:
+			/* NOTE(anton2920): line is written directly, because it may not fit into full ring, and it must not be lost. */
+			p.Lock.Lock()
+			p.consume()
+			p.write(f.CurrentLevel, line)
+			p.sync()
+			p.Lock.Unlock()
+
+			if f.CurrentLevel == log.LevelFatal {
+				os.Exit(1)
+			}
+			panic(panicMsg)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package log_
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/anton2920/gofa/context"
+	"github.com/anton2920/gofa/log"
+)
+
+type testSink struct {
+	Lines  []string
+	Levels []log.Level
+	Syncs  int
+	Closed bool
+}
+
+func (s *testSink) Write(ctx *context.Context, level log.Level, line []byte) bool {
+	s.Lines = append(s.Lines, string(line))
+	s.Levels = append(s.Levels, level)
+	return true
+}
+
+func (s *testSink) Sync(ctx *context.Context) bool {
+	s.Syncs++
+	return true
+}
+
+func (s *testSink) Close(ctx *context.Context) bool {
+	s.Closed = true
+	return true
+}
+
+func TestPipeline(t *testing.T) {
+	const (
+		producers = 4
+		lines     = 200
+	)
+
+	var wg sync.WaitGroup
+	var all, warn testSink
+	var p Pipeline
+
+	p.Init(producers*lines, 64)
+	p.AddSink(&all, log.LevelDebug).AddSink(&warn, log.LevelWarn)
+	p.Start()
+
+	for i := 0; i < producers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			var l log.Formatter
+			l.InitWithByteSlice(make([]byte, 64))
+			for j := 0; j < lines; j++ {
+				level := log.LevelInfo
+				if j%10 == 0 {
+					level = log.LevelWarn
+				}
+				p.Println(l.Log(level, 0).D(i).S(" ").D(j))
+			}
+		}(i)
+	}
+	wg.Wait()
+	p.Stop()
+
+	if (!all.Closed) || (!warn.Closed) || (all.Syncs == 0) {
+		t.Errorf("Expected sinks to be synced and closed")
+	}
+	if len(all.Lines) != producers*lines {
+		t.Fatalf("Expected %d lines, got %d", producers*lines, len(all.Lines))
+	}
+	if len(warn.Lines) != producers*lines/10 {
+		t.Errorf("Expected %d warnings, got %d", producers*lines/10, len(warn.Lines))
+	}
+	for _, level := range warn.Levels {
+		if level < log.LevelWarn {
+			t.Errorf("Expected only warnings, got %s", log.Level2String[level])
+		}
+	}
+
+	/* Lines of one producer keep their order. */
+	var next [producers]int
+	for _, line := range all.Lines {
+		fields := strings.Fields(line)
+		i, _ := strconv.Atoi(fields[len(fields)-2])
+		j, _ := strconv.Atoi(fields[len(fields)-1])
+		if j != next[i] {
+			t.Fatalf("Expected line %d of producer %d, got %q", next[i], i, line)
+		}
+		next[i]++
+	}
+}
+
+func TestPipelineWake(t *testing.T) {
+	var p Pipeline
+
+	p.Init(4, 64)
+
+	/* Writer was woken up, but has not consumed signal yet. */
+	p.Wakeup <- struct{}{}
+	p.Sleeping = 1
+	p.wake()
+
+	if p.Sleeping != 0 {
+		t.Errorf("Expected wake to reset Sleeping")
+	}
+}
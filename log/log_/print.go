@@ -14,7 +14,7 @@ func Println(f *log.Formatter) {
 		if f.CurrentLevel > log.LevelWarn {
 			h = os.StandardErrorStream
 		}
-		os.WriteToFile(&context.Context{}, h, f.Fmt.Ln().Bytes())
+		os.WriteToFile(&context.Context{}, h, f.End().Fmt.Ln().Bytes())
 		switch f.CurrentLevel {
 		case log.LevelFatal:
 			os.Exit(1)
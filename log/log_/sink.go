@@ -0,0 +1,167 @@
+package log_
+
+import (
+	"github.com/anton2920/gofa/context"
+	"github.com/anton2920/gofa/fmt"
+	"github.com/anton2920/gofa/log"
+	"github.com/anton2920/gofa/os"
+	"github.com/anton2920/gofa/time/time_"
+)
+
+/* Sink is destination of log lines. Sinks are used only by Pipeline's writer, so they do not need to be thread-safe. Errors are reported through ctx. */
+type Sink interface {
+	Write(ctx *context.Context, level log.Level, line []byte) bool
+	Sync(ctx *context.Context) bool
+	Close(ctx *context.Context) bool
+}
+
+/* HandleSink writes lines to already opened handle, e.g. os.StandardErrorStream. */
+type HandleSink struct {
+	Handle os.Handle
+}
+
+/* FileSink appends lines to file at Path. File is rotated before line, which would make it larger than MaxSize, and before the first line written later than MaxAge after file was opened. Zero disables corresponding check.
+ * On rotation files are renamed: Path.N-1 to Path.N, ..., Path to Path.1, where N is Keep. Path.N is replaced. If Keep is 0, file is just removed. */
+type FileSink struct {
+	Path    string
+	Perms   uint
+	MaxSize int
+	MaxAge  int64
+	Keep    int
+
+	Handle os.Handle
+	Size   int
+	Opened int64
+}
+
+var _ Sink = new(HandleSink)
+var _ Sink = new(FileSink)
+
+func (s *HandleSink) Write(ctx *context.Context, level log.Level, line []byte) bool {
+	if _, ok := os.WriteToFile(ctx, s.Handle, line); !ok {
+		ctx.NewError().S("failed to write to handle ").D(int(s.Handle)).S(": ").S(ctx.OldError())
+		return false
+	}
+	return true
+}
+
+func (s *HandleSink) Sync(ctx *context.Context) bool {
+	return true
+}
+
+func (s *HandleSink) Close(ctx *context.Context) bool {
+	return true
+}
+
+/* Open opens or creates file at s.Path for appending. It must be called before the first Write. */
+func (s *FileSink) Open(ctx *context.Context) bool {
+	perms := s.Perms
+	if perms == 0 {
+		perms = 0644
+	}
+
+	h, ok := os.OpenOrCreateFile(ctx, s.Path, os.OpenForAppending, os.CreateFileIfItDoesNotExist, perms)
+	if !ok {
+		ctx.NewError().S("failed to open log file ").Q(s.Path).S(": ").S(ctx.OldError())
+		return false
+	}
+
+	size, ok := os.GetFileSize(ctx, h)
+	if !ok {
+		os.CloseHandle(ctx, h)
+		ctx.NewError().S("failed to get size of log file ").Q(s.Path).S(": ").S(ctx.OldError())
+		return false
+	}
+
+	s.Handle = h
+	s.Size = size
+	s.Opened = time_.NowInNanoseconds()
+	return true
+}
+
+/* rotatedPath returns path of n-th rotated file in buf. */
+func (s *FileSink) rotatedPath(buf []byte, n int) string {
+	var f fmt.Formatter
+
+	f.InitWithByteSlice(buf)
+	return f.S(s.Path).S(".").D(n).String()
+}
+
+/* Rotate closes current file, shifts rotated files and opens new file. If it fails, file stays closed until the next Write opens it again. */
+func (s *FileSink) Rotate(ctx *context.Context) bool {
+	var fromBuf, toBuf [1024]byte
+
+	if s.Handle != os.InvalidHandle {
+		h := s.Handle
+		s.Handle = os.InvalidHandle
+		if !os.CloseHandle(ctx, h) {
+			ctx.NewError().S("failed to close log file ").Q(s.Path).S(": ").S(ctx.OldError())
+			return false
+		}
+	}
+
+	if s.Keep == 0 {
+		if !os.RemoveFile(ctx, s.Path) {
+			ctx.NewError().S("failed to remove log file ").Q(s.Path).S(": ").S(ctx.OldError())
+			return false
+		}
+	} else {
+		for n := s.Keep - 1; n > 0; n-- {
+			/* NOTE(anton2920): there are fewer than Keep files, until enough rotations happened. */
+			from := s.rotatedPath(fromBuf[:], n)
+			if (!os.RenameFile(ctx, from, s.rotatedPath(toBuf[:], n+1))) && (ctx.ErrorCode() != os.ErrorCodeFileDoesNotExist) {
+				ctx.NewError().S("failed to rename rotated log file ").Q(from).S(": ").S(ctx.OldError())
+				return false
+			}
+		}
+		if !os.RenameFile(ctx, s.Path, s.rotatedPath(toBuf[:], 1)) {
+			ctx.NewError().S("failed to rename log file ").Q(s.Path).S(": ").S(ctx.OldError())
+			return false
+		}
+	}
+
+	return s.Open(ctx)
+}
+
+func (s *FileSink) Write(ctx *context.Context, level log.Level, line []byte) bool {
+	if (s.Handle == os.InvalidHandle) && (!s.Open(ctx)) {
+		return false
+	}
+	if ((s.MaxSize > 0) && (s.Size > 0) && (s.Size+len(line) > s.MaxSize)) || ((s.MaxAge > 0) && (time_.NowInNanoseconds()-s.Opened >= s.MaxAge)) {
+		if !s.Rotate(ctx) {
+			return false
+		}
+	}
+
+	n, ok := os.WriteToFile(ctx, s.Handle, line)
+	s.Size += n
+	if !ok {
+		ctx.NewError().S("failed to write to log file ").Q(s.Path).S(": ").S(ctx.OldError())
+		return false
+	}
+	return true
+}
+
+func (s *FileSink) Sync(ctx *context.Context) bool {
+	if s.Handle == os.InvalidHandle {
+		return true
+	}
+	if !os.SyncFile(ctx, s.Handle) {
+		ctx.NewError().S("failed to sync log file ").Q(s.Path).S(": ").S(ctx.OldError())
+		return false
+	}
+	return true
+}
+
+func (s *FileSink) Close(ctx *context.Context) bool {
+	if s.Handle == os.InvalidHandle {
+		return true
+	}
+	h := s.Handle
+	s.Handle = os.InvalidHandle
+	if !os.CloseHandle(ctx, h) {
+		ctx.NewError().S("failed to close log file ").Q(s.Path).S(": ").S(ctx.OldError())
+		return false
+	}
+	return true
+}
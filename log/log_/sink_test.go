@@ -0,0 +1,135 @@
+package log_
+
+import (
+	"net"
+	stdos "os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/anton2920/gofa/context"
+	"github.com/anton2920/gofa/log"
+	"github.com/anton2920/gofa/os"
+)
+
+func testContext() *context.Context {
+	var ctx context.Context
+	ctx.InitWithEvenlySplitByteSlice(make([]byte, 4*512))
+	return &ctx
+}
+
+func testReadFile(t *testing.T, path string) string {
+	data, err := stdos.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	return string(data)
+}
+
+func TestFileSink(t *testing.T) {
+	ctx := testContext()
+	path := filepath.Join(t.TempDir(), "log")
+
+	s := FileSink{Path: path, MaxSize: 10, Keep: 2}
+	if !s.Open(ctx) {
+		t.Fatalf("Failed to open sink: %v", ctx.Error())
+	}
+	for _, line := range [...]string{"one\n", "two\n", "three\n", "four\n", "five\n"} {
+		if !s.Write(ctx, log.LevelInfo, []byte(line)) {
+			t.Fatalf("Failed to write: %v", ctx.Error())
+		}
+	}
+	if !s.Sync(ctx) {
+		t.Fatalf("Failed to sync: %v", ctx.Error())
+	}
+
+	/* Rotated before "three" and "four". */
+	for _, test := range [...]struct{ Path, Expected string }{
+		{path, "four\nfive\n"},
+		{path + ".1", "three\n"},
+		{path + ".2", "one\ntwo\n"},
+	} {
+		if got := testReadFile(t, test.Path); got != test.Expected {
+			t.Errorf("%s: expected %q, got %q", test.Path, test.Expected, got)
+		}
+	}
+
+	/* Failed rotation leaves file closed, next Write opens it again. */
+	stdos.Remove(path + ".1")
+	if err := stdos.Mkdir(path+".1", 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := stdos.WriteFile(filepath.Join(path+".1", "file"), nil, 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	if s.Rotate(ctx) {
+		t.Fatalf("Expected rotation to fail")
+	}
+	if s.Handle != os.InvalidHandle {
+		t.Errorf("Expected handle to be invalid after failed rotation")
+	}
+	if !s.Sync(ctx) {
+		t.Errorf("Expected Sync of closed file to succeed")
+	}
+	s.MaxSize = 0
+	if !s.Write(ctx, log.LevelInfo, []byte("six\n")) {
+		t.Fatalf("Failed to write after failed rotation: %v", ctx.Error())
+	}
+	if got := testReadFile(t, path); got != "four\nfive\nsix\n" {
+		t.Errorf("Expected %q, got %q", "four\nfive\nsix\n", got)
+	}
+
+	if !s.Close(ctx) {
+		t.Fatalf("Failed to close sink: %v", ctx.Error())
+	}
+	if !s.Close(ctx) {
+		t.Errorf("Expected second Close to succeed")
+	}
+}
+
+func testSyslogListen(t *testing.T, path string) *net.UnixConn {
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("Failed to listen on %s: %v", path, err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return conn
+}
+
+func testSyslogReceive(t *testing.T, conn *net.UnixConn, expected string) {
+	buf := make([]byte, syslogMaxMessageSize)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("Failed to receive message: %v", err)
+	}
+	if got := string(buf[:n]); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestSyslogSink(t *testing.T) {
+	ctx := testContext()
+	path := filepath.Join(t.TempDir(), "log")
+
+	conn := testSyslogListen(t, path)
+	s := SyslogSink{Path: path, Facility: SyslogFacilityLocal0, Tag: "test"}
+	if !s.Write(ctx, log.LevelWarn, []byte("hello\n")) {
+		t.Fatalf("Failed to write: %v", ctx.Error())
+	}
+	testSyslogReceive(t, conn, "<132>test: hello")
+
+	/* syslogd is restarted. */
+	conn.Close()
+	stdos.Remove(path)
+	conn = testSyslogListen(t, path)
+	defer conn.Close()
+
+	if !s.Write(ctx, log.LevelDebug, []byte("again\n")) {
+		t.Fatalf("Failed to write after restart: %v", ctx.Error())
+	}
+	testSyslogReceive(t, conn, "<135>test: again")
+
+	if !s.Close(ctx) {
+		t.Errorf("Failed to close sink: %v", ctx.Error())
+	}
+}
@@ -0,0 +1,136 @@
+package log_
+
+import (
+	"unsafe"
+
+	"github.com/anton2920/gofa/bytes"
+	"github.com/anton2920/gofa/context"
+	"github.com/anton2920/gofa/fmt"
+	"github.com/anton2920/gofa/log"
+	"github.com/anton2920/gofa/os"
+)
+
+/* Syslog facilities from <syslog.h>. */
+const (
+	SyslogFacilityKernel = 0
+	SyslogFacilityUser   = 1
+	SyslogFacilityMail   = 2
+	SyslogFacilityDaemon = 3
+	SyslogFacilityAuth   = 4
+	SyslogFacilityLocal0 = 16
+)
+
+/* Syslog severities from <syslog.h>. */
+const (
+	syslogAlert   = 1
+	syslogCrit    = 2
+	syslogErr     = 3
+	syslogWarning = 4
+	syslogInfo    = 6
+	syslogDebug   = 7
+)
+
+const (
+	SyslogDefaultPath = "/var/run/log"
+
+	syslogMaxMessageSize = 2048
+)
+
+/* SyslogSink sends lines as datagrams to syslogd listening on Unix socket at Path, SyslogDefaultPath if empty. Message is '<PRI>Tag: line', where PRI is combined from Facility and level. */
+type SyslogSink struct {
+	Path     string
+	Facility int
+	Tag      string
+
+	Socket    os.Handle
+	Connected bool
+
+	Fmt    fmt.Formatter
+	Buffer [syslogMaxMessageSize]byte
+}
+
+var _ Sink = new(SyslogSink)
+
+var Level2SyslogSeverity = map[log.Level]int{
+	log.LevelDebug: syslogDebug,
+	log.LevelInfo:  syslogInfo,
+	log.LevelWarn:  syslogWarning,
+	log.LevelError: syslogErr,
+	log.LevelFatal: syslogCrit,
+	log.LevelPanic: syslogAlert,
+}
+
+/* Connect creates socket and connects it to syslogd. It is called by Write, if socket is not connected. */
+func (s *SyslogSink) Connect(ctx *context.Context) bool {
+	var addr os.UnixAddress
+
+	path := s.Path
+	if len(path) == 0 {
+		path = SyslogDefaultPath
+	}
+	addr.InitWithPath(path)
+
+	socket, ok := os.CreateNetworkSocket(ctx, os.ProtocolFamilyUnix, os.SocketTypeDatagram, 0)
+	if !ok {
+		ctx.NewError().S("failed to create syslog socket: ").S(ctx.OldError())
+		return false
+	}
+	if !os.ConnectToAddress(ctx, socket, addr.AsNetworkAddress(), uint32(unsafe.Sizeof(addr))) {
+		os.CloseHandle(ctx, socket)
+		ctx.NewError().S("failed to connect to syslog socket ").Q(path).S(": ").S(ctx.OldError())
+		return false
+	}
+
+	s.Socket = socket
+	s.Connected = true
+	return true
+}
+
+func (s *SyslogSink) Write(ctx *context.Context, level log.Level, line []byte) bool {
+	severity, ok := Level2SyslogSeverity[level]
+	if !ok {
+		severity = syslogInfo
+	}
+	if (len(line) > 0) && (line[len(line)-1] == '\n') {
+		line = line[:len(line)-1]
+	}
+
+	s.Fmt.InitWithByteSlice(s.Buffer[:])
+	s.Fmt.TruncateOnOverflow("")
+	s.Fmt.Reset().S("<").D(s.Facility<<3 | severity).S(">")
+	if len(s.Tag) > 0 {
+		s.Fmt.S(s.Tag).S(": ")
+	}
+	s.Fmt.S(bytes.AsString(line))
+
+	/* NOTE(anton2920): syslogd may be restarted, so connection is retried once. */
+	for i := 0; i < 2; i++ {
+		if (!s.Connected) && (!s.Connect(ctx)) {
+			return false
+		}
+		if _, ok := os.WriteToFile(ctx, s.Socket, s.Fmt.Bytes()); ok {
+			return true
+		}
+		os.CloseHandle(ctx, s.Socket)
+		s.Connected = false
+	}
+
+	ctx.NewError().S("failed to send message to syslog: ").S(ctx.OldError())
+	return false
+}
+
+func (s *SyslogSink) Sync(ctx *context.Context) bool {
+	return true
+}
+
+func (s *SyslogSink) Close(ctx *context.Context) bool {
+	if !s.Connected {
+		return true
+	}
+	s.Connected = false
+	if !os.CloseHandle(ctx, s.Socket) {
+		ctx.NewError().S("failed to close syslog socket: ").S(ctx.OldError())
+		return false
+	}
+	return true
+}
@@ -0,0 +1,123 @@
+package log
+
+import (
+	"sync/atomic"
+)
+
+/* Ring is bounded lock-free queue of finished lines, see https://www.1024cores.net/home/lock-free-algorithms/queues/bounded-mpmc-queue. Any number of goroutines may Push, but only one at a time may Peek and Pop.
+ * Every slot has fixed size, longer lines are truncated and end with RingTruncated and newline. Push never blocks: if Ring is full, line is dropped and counted in Dropped. */
+type Ring struct {
+	/* Head is position of the next Push, Tail is position of the next Pop. They are on different cache lines, so producers and consumer do not contend. */
+	Head uint64
+	_    [56]byte
+	Tail uint64
+	_    [56]byte
+
+	Dropped uint64
+
+	Slots    []RingSlot
+	Data     []byte
+	SlotSize int
+	Mask     uint64
+}
+
+/* RingTruncated replaces the end of line that did not fit into slot. */
+const RingTruncated = "..."
+
+/* RingSlot is ready for Push at position p, when Seq == p, and for Pop, when Seq == p+1. */
+type RingSlot struct {
+	Seq   uint64
+	Level Level
+	Len   int32
+}
+
+/* Init allocates ring of at least n slots of size bytes each. Number of slots is rounded up to power of two, size is rounded up to fit RingTruncated and newline. */
+func (r *Ring) Init(n int, size int) {
+	if size < len(RingTruncated)+1 {
+		size = len(RingTruncated) + 1
+	}
+
+	slots := 1
+	for slots < n {
+		slots <<= 1
+	}
+
+	r.Slots = make([]RingSlot, slots)
+	r.Data = make([]byte, slots*size)
+	r.SlotSize = size
+	r.Mask = uint64(slots - 1)
+	for i := 0; i < len(r.Slots); i++ {
+		r.Slots[i].Seq = uint64(i)
+	}
+}
+
+func (r *Ring) slot(pos uint64) []byte {
+	i := int(pos&r.Mask) * r.SlotSize
+	return r.Data[i : i+r.SlotSize]
+}
+
+/* put copies line into slot and returns its length. */
+func (r *Ring) put(slot []byte, line []byte) int32 {
+	if len(line) <= len(slot) {
+		return int32(copy(slot, line))
+	}
+
+	/* NOTE(anton2920): the last byte is reserved for newline, so truncated line does not merge with the next one. */
+	n := copy(slot[:len(slot)-len(RingTruncated)-1], line)
+	n += copy(slot[n:], RingTruncated)
+	slot[n] = '\n'
+	return int32(n + 1)
+}
+
+/* Push copies line into the ring. It returns false, if the ring is full. */
+func (r *Ring) Push(level Level, line []byte) bool {
+	pos := atomic.LoadUint64(&r.Head)
+	for {
+		slot := &r.Slots[pos&r.Mask]
+		seq := atomic.LoadUint64(&slot.Seq)
+
+		switch diff := int64(seq - pos); {
+		case diff == 0:
+			if atomic.CompareAndSwapUint64(&r.Head, pos, pos+1) {
+				slot.Level = level
+				slot.Len = r.put(r.slot(pos), line)
+				atomic.StoreUint64(&slot.Seq, pos+1)
+				return true
+			}
+			pos = atomic.LoadUint64(&r.Head)
+		case diff < 0:
+			atomic.AddUint64(&r.Dropped, 1)
+			return false
+		default:
+			/* NOTE(anton2920): other producer took this position. */
+			pos = atomic.LoadUint64(&r.Head)
+		}
+	}
+}
+
+/* Peek returns the oldest line without removing it. Line is valid until Pop. */
+func (r *Ring) Peek() (Level, []byte, bool) {
+	slot := &r.Slots[r.Tail&r.Mask]
+	if atomic.LoadUint64(&slot.Seq) != r.Tail+1 {
+		return 0, nil, false
+	}
+	return slot.Level, r.slot(r.Tail)[:slot.Len], true
+}
+
+/* Pop removes line returned by Peek. */
+func (r *Ring) Pop() {
+	slot := &r.Slots[r.Tail&r.Mask]
+	atomic.StoreUint64(&slot.Seq, r.Tail+r.Mask+1)
+	r.Tail++
+}
+
+/* Empty reports whether there are no lines to Pop. Like Peek, it may be called only by consumer. */
+func (r *Ring) Empty() bool {
+	_, _, ok := r.Peek()
+	return !ok
+}
+
+/* TakeDropped returns number of lines dropped since previous call. */
+func (r *Ring) TakeDropped() uint64 {
+	return atomic.SwapUint64(&r.Dropped, 0)
+}
@@ -0,0 +1,108 @@
+package log
+
+import (
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestRing(t *testing.T) {
+	var r Ring
+
+	r.Init(3, 8)
+	if len(r.Slots) != 4 {
+		t.Fatalf("Expected 4 slots, got %d", len(r.Slots))
+	}
+	if !r.Empty() {
+		t.Errorf("Expected ring to be empty")
+	}
+
+	for i, line := range [...]string{"one", "two", "three", "four", "five"} {
+		if ok := r.Push(LevelInfo, []byte(line)); ok != (i < 4) {
+			t.Errorf("%d: expected Push to return %v", i, i < 4)
+		}
+	}
+	if dropped := r.TakeDropped(); dropped != 1 {
+		t.Errorf("Expected 1 dropped line, got %d", dropped)
+	}
+
+	level, line, ok := r.Peek()
+	if (!ok) || (level != LevelInfo) || (string(line) != "one") {
+		t.Errorf("Expected %q, got %q", "one", line)
+	}
+	r.Pop()
+
+	r.Push(LevelError, []byte("long line is truncated\n"))
+	for _, expected := range [...]string{"two", "three", "four", "long...\n"} {
+		_, line, ok := r.Peek()
+		if (!ok) || (string(line) != expected) {
+			t.Errorf("Expected %q, got %q", expected, line)
+		}
+		r.Pop()
+	}
+	if !r.Empty() {
+		t.Errorf("Expected ring to be empty")
+	}
+
+	/* Lines that fit exactly are not truncated. */
+	r.Push(LevelInfo, []byte("exactly\n"))
+	if _, line, _ := r.Peek(); string(line) != "exactly\n" {
+		t.Errorf("Expected %q, got %q", "exactly\n", line)
+	}
+	r.Pop()
+
+	var tiny Ring
+	tiny.Init(1, 1)
+	tiny.Push(LevelInfo, []byte("tiny slot"))
+	if _, line, _ := tiny.Peek(); string(line) != RingTruncated+"\n" {
+		t.Errorf("Expected %q, got %q", RingTruncated+"\n", line)
+	}
+}
+
+func TestRingConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	var r Ring
+
+	const (
+		producers = 8
+		lines     = 2000
+	)
+
+	r.Init(64, 16)
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+
+			buf := make([]byte, 0, 16)
+			for i := 0; i < lines; i++ {
+				buf = strconv.AppendInt(append(buf[:0], byte('0'+p), ' '), int64(i), 10)
+				for !r.Push(Level(p), buf) {
+					runtime.Gosched()
+				}
+			}
+		}(p)
+	}
+
+	var next [producers]int
+	for n := 0; n < producers*lines; {
+		level, line, ok := r.Peek()
+		if !ok {
+			runtime.Gosched()
+			continue
+		}
+		p := int(level)
+		if expected := string(byte('0'+p)) + " " + strconv.Itoa(next[p]); string(line) != expected {
+			t.Fatalf("Expected %q, got %q", expected, line)
+		}
+		next[p]++
+		r.Pop()
+		n++
+	}
+	wg.Wait()
+
+	if !r.Empty() {
+		t.Errorf("Expected ring to be empty")
+	}
+}
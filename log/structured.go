@@ -0,0 +1,260 @@
+package log
+
+import (
+	"github.com/anton2920/gofa/bytes"
+	"github.com/anton2920/gofa/fmt"
+	"github.com/anton2920/gofa/time"
+)
+
+/* Encoding selects how Formatter renders lines. In structured encodings time, level and message are fields too:
+ *     EncodingText:   2006/01/02 15:04:05  INFO User logged in user=42 elapsed=1.5ms
+ *     EncodingLogfmt: time=2006-01-02T15:04:05Z level=INFO msg="User logged in" user=42 elapsed=1.5ms
+ *     EncodingJSON:   {"time":"2006-01-02T15:04:05Z","level":"INFO","msg":"User logged in","user":42,"elapsed":"1.5ms"}
+ * Field is started with K, value is everything written after it until the next K or End. Value is quoted, if it is written with non-numeric verb or, for logfmt, if it contains spaces or special characters. */
+type Encoding int32
+
+const (
+	EncodingText Encoding = iota
+	EncodingLogfmt
+	EncodingJSON
+)
+
+const hex = "0123456789abcdef"
+
+/* begin starts new line in structured encoding. */
+func (f *Formatter) begin(t int64) {
+	var buf [32]byte
+
+	f.Fields = 0
+	f.InValue = false
+	if f.Fmt.Overflow == fmt.OverflowFlush {
+		/* NOTE(anton2920): values, which do not fit, are cut like with OverflowDiscard. */
+		f.Fmt.Overflow = fmt.OverflowDiscard
+		f.FlushOnEnd = true
+	}
+	if f.Encoding == EncodingJSON {
+		f.Fmt.S("{")
+	}
+
+	n := time.Strftime(buf[:], "%Y-%m-%dT%H:%M:%SZ", time.ToTm(t))
+	f.K("time").S(bytes.AsString(buf[:n]))
+	if f.CurrentLevel != LevelLast {
+		f.K("level").S(Level2String[f.CurrentLevel])
+	}
+	f.K("msg")
+	f.Message = true
+}
+
+/* K starts field with key. Key is written as is, so it must be safe for used encoding. */
+func (f *Formatter) K(key string) *Formatter {
+	if f == nil {
+		return f
+	}
+	f.endValue()
+
+	f.KeyStart = f.Fmt.Pos
+	switch f.Encoding {
+	case EncodingText:
+		if (f.Fmt.Pos > 0) && (f.Fmt.Buffer[f.Fmt.Pos-1] != ' ') {
+			f.Fmt.S(" ")
+		}
+		f.Fmt.S(key).S("=")
+	case EncodingLogfmt:
+		if f.Fields > 0 {
+			f.Fmt.S(" ")
+		}
+		f.Fmt.S(key).S("=")
+	case EncodingJSON:
+		if f.Fields > 0 {
+			f.Fmt.S(",")
+		}
+		f.Fmt.S(`"`).S(key).S(`":`)
+	}
+
+	f.Fields++
+	f.InValue = true
+	f.Quote = false
+	f.ValueStart = f.Fmt.Pos
+	return f
+}
+
+/* End finishes value of the last field and, for JSON, the object. It is called by log_.Println. Line, which does not fit into Buffer, is cut even with OverflowFlush, which is restored here. */
+func (f *Formatter) End() *Formatter {
+	if f == nil {
+		return f
+	}
+	if (f.Encoding != EncodingText) && (f.Fields > 0) {
+		f.endValue()
+		if f.Encoding == EncodingJSON {
+			f.Fmt.S("}")
+		}
+		f.Fields = 0
+	}
+	if f.FlushOnEnd {
+		f.Fmt.Overflow = fmt.OverflowFlush
+		f.FlushOnEnd = false
+	}
+	return f
+}
+
+/* escapedLength returns length of c inside of quoted string. */
+func escapedLength(c byte) int {
+	switch {
+	case (c == '"') || (c == '\\') || (c == '\n') || (c == '\r') || (c == '\t'):
+		return 2
+	case c < ' ':
+		return len(`\u0000`)
+	}
+	return 1
+}
+
+func needsQuoteLogfmt(v []byte) bool {
+	if len(v) == 0 {
+		return true
+	}
+	for i := 0; i < len(v); i++ {
+		if (v[i] <= ' ') || (v[i] == '=') || (v[i] == '"') || (v[i] == '\\') || (v[i] == 0x7F) {
+			return true
+		}
+	}
+	return false
+}
+
+func isDigit(c byte) bool {
+	return (c >= '0') && (c <= '9')
+}
+
+/* isJSONNumber reports whether v is number in JSON grammar, e.g. '+Inf' and 'NaN' are not. */
+func isJSONNumber(v []byte) bool {
+	var i int
+
+	if (i < len(v)) && (v[i] == '-') {
+		i++
+	}
+	if (i == len(v)) || (!isDigit(v[i])) {
+		return false
+	}
+	if v[i] == '0' {
+		i++
+	} else {
+		for (i < len(v)) && (isDigit(v[i])) {
+			i++
+		}
+	}
+
+	if (i < len(v)) && (v[i] == '.') {
+		i++
+		if (i == len(v)) || (!isDigit(v[i])) {
+			return false
+		}
+		for (i < len(v)) && (isDigit(v[i])) {
+			i++
+		}
+	}
+
+	if (i < len(v)) && ((v[i] == 'e') || (v[i] == 'E')) {
+		i++
+		if (i < len(v)) && ((v[i] == '+') || (v[i] == '-')) {
+			i++
+		}
+		if (i == len(v)) || (!isDigit(v[i])) {
+			return false
+		}
+		for (i < len(v)) && (isDigit(v[i])) {
+			i++
+		}
+	}
+
+	return i == len(v)
+}
+
+/* quoteValue quotes and escapes value in place. Value is cut, if quoted value does not fit into buffer and it cannot grow. */
+func (f *Formatter) quoteValue() {
+	v := f.Fmt.Buffer[f.ValueStart:f.Fmt.Pos]
+
+	n := 2
+	for i := 0; i < len(v); i++ {
+		n += escapedLength(v[i])
+	}
+	if extra := n - len(v); f.Fmt.Reserve(extra) < extra {
+		avail := len(f.Fmt.Buffer) - f.ValueStart
+		if f.Encoding == EncodingJSON {
+			/* NOTE(anton2920): closing brace must fit, so line stays valid JSON. */
+			avail--
+		}
+
+		n = 2
+		k := 0
+		for (k < len(v)) && (n+escapedLength(v[k]) <= avail) {
+			n += escapedLength(v[k])
+			k++
+		}
+		if n > avail {
+			/* NOTE(anton2920): there is no space even for quotes. */
+			f.Fmt.Pos = f.ValueStart
+			return
+		}
+		v = v[:k]
+	}
+	buf := f.Fmt.Buffer[f.ValueStart:]
+
+	/* NOTE(anton2920): escaping only makes value longer, so writing from the end does not overwrite bytes, which are not read yet. */
+	w := n - 1
+	buf[w] = '"'
+	for i := len(v) - 1; i >= 0; i-- {
+		c := v[i]
+		switch l := escapedLength(c); l {
+		case 1:
+			w--
+			buf[w] = c
+		case 2:
+			switch c {
+			case '\n':
+				c = 'n'
+			case '\r':
+				c = 'r'
+			case '\t':
+				c = 't'
+			}
+			w -= 2
+			buf[w] = '\\'
+			buf[w+1] = c
+		default:
+			w -= l
+			copy(buf[w:], `\u00`)
+			buf[w+4] = hex[c>>4]
+			buf[w+5] = hex[c&0xF]
+		}
+	}
+	buf[0] = '"'
+
+	f.Fmt.Pos = f.ValueStart + n
+}
+
+/* endValue finishes value of current field, quoting it, if needed. Empty message is removed. */
+func (f *Formatter) endValue() {
+	if !f.InValue {
+		return
+	}
+	f.InValue = false
+
+	message := f.Message
+	f.Message = false
+	if (message) && (f.Fmt.Pos == f.ValueStart) {
+		f.Fmt.Pos = f.KeyStart
+		f.Fields--
+		return
+	}
+
+	v := f.Fmt.Buffer[f.ValueStart:f.Fmt.Pos]
+	switch f.Encoding {
+	case EncodingLogfmt:
+		if needsQuoteLogfmt(v) {
+			f.quoteValue()
+		}
+	case EncodingJSON:
+		if (f.Quote) || (!isJSONNumber(v)) {
+			f.quoteValue()
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package log
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/anton2920/gofa/fmt"
+)
+
+func TestStructured(t *testing.T) {
+	var f Formatter
+
+	const now = 1136214245 * 1000000000 /* 2006-01-02 15:04:05 UTC. */
+
+	f.InitWithByteSlice(make([]byte, 512))
+	write := func(f *Formatter) {
+		f.Info(now).S("User ").S("logged in").KD("user", 42).K("elapsed").Dur(1500000).KS("name", `"Bob" \ Smith`).KErr("err", errors.New("line\nbreak")).KF("ratio", 0.5).KF("inf", math.Inf(1)).K("empty").KS("num", "123").K("mixed").D(5).S("ms").End()
+	}
+
+	tests := [...]struct {
+		Encoding Encoding
+		Expected string
+	}{
+		{EncodingText, `2006/01/02 15:04:05  INFO User logged in user=42 elapsed=1.5ms name="Bob" \ Smith err=line` + "\n" + `break ratio=0.500000 inf=+Inf empty= num=123 mixed=5ms`},
+		{EncodingLogfmt, `time=2006-01-02T15:04:05Z level=INFO msg="User logged in" user=42 elapsed=1.5ms name="\"Bob\" \\ Smith" err="line\nbreak" ratio=0.500000 inf=+Inf empty="" num=123 mixed=5ms`},
+		{EncodingJSON, `{"time":"2006-01-02T15:04:05Z","level":"INFO","msg":"User logged in","user":42,"elapsed":"1.5ms","name":"\"Bob\" \\ Smith","err":"line\nbreak","ratio":0.500000,"inf":"+Inf","empty":"","num":"123","mixed":"5ms"}`},
+	}
+	for _, test := range tests {
+		f.Encoding = test.Encoding
+		write(&f)
+		if got := f.Fmt.String(); got != test.Expected {
+			t.Errorf("%d: expected\n%s\ngot\n%s", test.Encoding, test.Expected, got)
+		}
+	}
+
+	var v map[string]interface{}
+	if err := json.Unmarshal(f.Fmt.Bytes(), &v); err != nil {
+		t.Errorf("Failed to parse JSON: %v", err)
+	}
+	if (v["user"] != 42.0) || (v["err"] != "line\nbreak") || (v["name"] != `"Bob" \ Smith`) {
+		t.Errorf("Unexpected JSON fields: %v", v)
+	}
+
+	f.Encoding = EncodingJSON
+	if got := f.Warn(now).KS("ctl", "\x01").End().Fmt.String(); got != `{"time":"2006-01-02T15:04:05Z","level":"WARN","ctl":"\u0001"}` {
+		t.Errorf("Unexpected output without message: %s", got)
+	}
+	if got := f.Reset(now).S("no level").End().Fmt.String(); got != `{"time":"2006-01-02T15:04:05Z","msg":"no level"}` {
+		t.Errorf("Unexpected output without level: %s", got)
+	}
+
+	f.MinimumLevel = LevelWarn
+	if f.Info(now).KD("ignored", 1).End() != nil {
+		t.Errorf("Expected nil formatter below minimum level")
+	}
+}
+
+func TestStructuredTruncate(t *testing.T) {
+	var f Formatter
+
+	f.InitWithByteSlice(make([]byte, 64))
+	f.Encoding = EncodingJSON
+	f.Info(0).KS("long", "\"\"\"\"\"\"\"\"\"\"\"\"\"\"\"\"\"\"\"\"").End()
+
+	/* NOTE(anton2920): value is cut, but line is still valid JSON. */
+	var v map[string]interface{}
+	if err := json.Unmarshal(f.Fmt.Bytes(), &v); (err != nil) || (len(v["long"].(string)) >= 20) {
+		t.Errorf("Expected valid JSON with cut value, got %s: %v", f.Fmt.Bytes(), err)
+	}
+
+	f.Fmt.GrowOnOverflow(nil)
+	f.Info(0).KS("long", "\"\"\"\"\"\"\"\"\"\"\"\"\"\"\"\"\"\"\"\"").End()
+	if err := json.Unmarshal(f.Fmt.Bytes(), &v); (err != nil) || (len(v["long"].(string)) != 20) {
+		t.Errorf("Expected valid JSON after growing, got %s: %v", f.Fmt.Bytes(), err)
+	}
+}
+
+func TestStructuredFlush(t *testing.T) {
+	var f Formatter
+	var out []byte
+
+	f.InitWithByteSlice(make([]byte, 64))
+	f.Fmt.FlushOnOverflow(func(buf []byte) { out = append(out, buf...) })
+	f.Encoding = EncodingLogfmt
+
+	for i := 0; i < 2; i++ {
+		out = out[:0]
+		f.Info(0).S("message, which does not fit").KS("long", "value with spaces, which does not fit either").End()
+		f.Fmt.Flush()
+
+		/* NOTE(anton2920): line is not flushed in the middle of value, so it is cut, but stays valid logfmt. */
+		const expected = `time=1970-01-01T00:00:00Z level=INFO msg="message, which does n"`
+		if string(out) != expected {
+			t.Errorf("%d: expected\n%s\ngot\n%s", i, expected, out)
+		}
+		if f.Fmt.Overflow != fmt.OverflowFlush {
+			t.Errorf("%d: expected OverflowFlush to be restored, got %d", i, f.Fmt.Overflow)
+		}
+	}
+}
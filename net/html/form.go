@@ -111,6 +111,9 @@ func (h *HTML) TimezoneSelect(selected time.Timezone, attrs ...Attributes) {
 	for i := time.TimezoneNone + 1; i < time.TimezoneCount; i++ {
 		h.Option(time.Timezone2String[i], Attributes{Value: h.Itoa(int(i)), Selected: i == selected})
 	}
+	for _, tz := range time.ZoneIDs {
+		h.Option(time.Timezone2String[tz], Attributes{Value: h.Itoa(int(tz)), Selected: tz == selected})
+	}
 	h.SelectEnd()
 }
 
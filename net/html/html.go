@@ -113,13 +113,9 @@ func (h *HTML) TString(s string) *HTML {
 	return h
 }
 
+/* Dtoa formats date in user's time zone and language. */
 func (h *HTML) Dtoa(d int64) string {
-	v := d + int64(h.Timezone)*time.Hour
-
-	buf := h.Response.Arena.NewSlice(len("2006-01-02"))
-	n := time.PutTmDate(buf, time.ToTm(v))
-
-	return bytes.AsString(buf[:n])
+	return h.Strftime(h.Language.TimeNames().DateFormat, d)
 }
 
 func (h *HTML) Dtoa1(d int64) string {
@@ -142,13 +138,29 @@ func (h *HTML) Itoa1(x int) string {
 	return h.Itoa(x)
 }
 
+/* Ttoa formats date and time in user's time zone and language. */
 func (h *HTML) Ttoa(t int64) string {
-	v := t + int64(h.Timezone)*time.Hour
+	return h.Strftime(h.Language.TimeNames().DateTimeFormat, t)
+}
 
-	buf := h.Response.Arena.NewSlice(len("2006-01-02 15:04:05"))
-	n := time.PutTmDateTime(buf, time.ToTm(v))
+/* Strftime formats t in user's time zone with names of months and days of week in user's language. */
+func (h *HTML) Strftime(format string, t int64) string {
+	var scratch [256]byte
 
-	return bytes.AsString(buf[:n])
+	tm := time.ToTmIn(t, h.Timezone)
+	names := h.Language.TimeNames()
+
+	/* NOTE(anton2920): result is formatted in scratch buffer, so only what fits is copied to arena. 0 is returned both for too small buffer and for empty result, so size is limited. */
+	buf := scratch[:]
+	n := time.StrftimeNames(buf, format, tm, names)
+	if n == 0 {
+		buf = make([]byte, 4096)
+		n = time.StrftimeNames(buf, format, tm, names)
+	}
+	if n == 0 {
+		return ""
+	}
+	return h.Response.Arena.CopyString(bytes.AsString(buf[:n]))
 }
 
 func (h *HTML) IndexedName(name string, indicies ...int) string {
@@ -16,6 +16,7 @@ import (
 	"github.com/anton2920/gofa/session"
 	"github.com/anton2920/gofa/slices"
 	"github.com/anton2920/gofa/strings"
+	"github.com/anton2920/gofa/time"
 	"github.com/anton2920/gofa/trace/trace_"
 )
 
@@ -23,6 +24,8 @@ type Router func(*Response, *Request) error
 
 const Pipeline = 16
 
+var accessLogFormat = fmt.MustCompile("[%21s] %7s %s -> %v (%v), %4dus")
+
 func RequestHandler(w *Response, r *Request, router Router) (err error) {
 	t := trace_.Begin("")
 
@@ -65,6 +68,7 @@ func RequestsHandler(ws []Response, rs []Request, router Router) {
 	t := trace_.Begin("")
 
 	const cookie = "Token"
+	const customizationCookie = "Customization"
 
 	for i := 0; i < len(rs); i++ {
 		w := &ws[i]
@@ -85,7 +89,6 @@ func RequestsHandler(ws []Response, rs []Request, router Router) {
 		w.Headers.Set("Content-Type", `text/html; charset="UTF-8"`)
 		level := log.LevelInfo
 
-		/* TODO(anton2920): store session.Customization on client. */
 		r.Session = session.Get(r.Cookie(cookie))
 		if len(r.Token) == 0 {
 			r.Session = session.New(0)
@@ -96,7 +99,42 @@ func RequestsHandler(ws []Response, rs []Request, router Router) {
 			}
 		}
 
+		/* NOTE(anton2920): Customization from cookie overrides the one from Store, so it's also available to clients with new session. */
+		var customization session.Session
+		now := time.Now()
+		codec := session.DefaultCookieCodec
+		if codec != nil {
+			customization, _ = codec.Decode(r.Cookie(customizationCookie), now)
+			if customization.Expiry != 0 {
+				r.Customization = customization.Customization
+			}
+		}
+
+		token := r.Token
 		err := RequestHandler(w, r, router)
+
+		/* NOTE(anton2920): token is rotated by router on login and logout. */
+		if r.Token != token {
+			if len(r.Token) == 0 {
+				w.DelCookie(cookie)
+			} else if debug.Debug {
+				w.SetCookieUnsafe(cookie, r.Token, r.Expiry)
+			} else {
+				w.SetCookie(cookie, r.Token, r.Expiry)
+			}
+		}
+
+		if expiry := now + session.Lifetime; (codec != nil) && ((r.Customization != customization.Customization) || (expiry-customization.Expiry >= session.SlideInterval)) {
+			value, cerr := codec.Encode(session.Session{Customization: r.Customization, Expiry: expiry}, nil)
+			if cerr != nil {
+				log.Warnf("Failed to encode customization cookie: %v", cerr)
+			} else if debug.Debug {
+				w.SetCookieUnsafe(customizationCookie, value, expiry)
+			} else {
+				w.SetCookie(customizationCookie, value, expiry)
+			}
+		}
+
 		if err != nil {
 			if (w.Status >= StatusBadRequest) && (w.Status < StatusInternalServerError) {
 				level = log.LevelWarn
@@ -122,8 +160,7 @@ func RequestsHandler(ws []Response, rs []Request, router Router) {
 		var f fmt.Formatter
 		f.InitWithByteSlice(make([]byte, 1024))
 
-		//log.Logf(level, "[%21s] %7s %s -> %v (%v), %4dus", strings.Or(r.Headers.Get("X-Forwarded-For"), r.RemoteAddr), r.Method, r.URL.Path, w.Status, err, elapsed.ToMicroseconds())
-		log.Logf(level, f.S("[").W(21).S(strings.Or(r.Headers.Get("X-Forwarded-For"), r.RemoteAddr)).S("] ").W(7).S(r.Method).S(" ").S(string(r.URL.Path)).S(" -> ").S(w.Status.String()).S(" (").Err(err).S("), ").W(4).D64(elapsed.ToMicroseconds()).S("us").String())
+		log.Logf(level, f.Printf(&accessLogFormat, strings.Or(r.Headers.Get("X-Forwarded-For"), r.RemoteAddr), r.Method, r.URL.Path, w.Status, err, elapsed.ToMicrosecondsTruncated()).String())
 	}
 
 	trace_.End(t)
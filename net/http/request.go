@@ -32,20 +32,20 @@ type Request struct {
 	Error error
 }
 
+/* Cookie returns value of cookie with name. Browsers send all cookies in one header separated by "; ". */
 func (r *Request) Cookie(name string) string {
 	t := trace_.Begin("")
 
 	cookies := r.Headers.GetMany("Cookie")
 	for i := 0; i < len(cookies); i++ {
-		cookie := cookies[i]
-		if strings.StartsWith(cookie, name) {
-			cookie = cookie[len(name):]
-			if !strings.StartsWith(cookie, "=") {
+		rest := cookies[i]
+		for len(rest) > 0 {
+			var cookie string
+			cookie, rest, _ = strings.Cut(rest, "; ")
+			if (strings.StartsWith(cookie, name)) && (strings.StartsWith(cookie[len(name):], "=")) {
 				trace_.End(t)
-				return ""
+				return cookie[len(name)+1:]
 			}
-			trace_.End(t)
-			return cookie[1:]
 		}
 	}
 
@@ -70,7 +70,7 @@ func (w *Response) DelCookie(name string) {
 	n += copy(cookie[n:], name)
 	n += copy(cookie[n:], finisher)
 
-	w.Headers.Set("Set-Cookie", bytes.AsString(cookie[:n]))
+	w.Headers.Add("Set-Cookie", bytes.AsString(cookie[:n]))
 
 	trace_.End(t)
 }
@@ -94,7 +94,7 @@ func (w *Response) SetCookie(name, value string, expiry int64) {
 	n += time.PutTmRFC822(cookie[n:], time.ToTm(expiry))
 	n += copy(cookie[n:], secure)
 
-	w.Headers.Set("Set-Cookie", bytes.AsString(cookie[:n]))
+	w.Headers.Add("Set-Cookie", bytes.AsString(cookie[:n]))
 
 	trace_.End(t)
 }
@@ -117,7 +117,7 @@ func (w *Response) SetCookieUnsafe(name, value string, expiry int64) {
 	n += copy(cookie[n:], expires)
 	n += time.PutTmRFC822(cookie[n:], time.ToTm(expiry))
 
-	w.Headers.Set("Set-Cookie", bytes.AsString(cookie[:n]))
+	w.Headers.Add("Set-Cookie", bytes.AsString(cookie[:n]))
 
 	trace_.End(t)
 }
@@ -277,6 +277,18 @@ func FillResponses(c *Conn, ws []Response) {
 
 		for i := 0; i < len(w.Headers.Keys); i++ {
 			key := w.Headers.Keys[i]
+
+			/* NOTE(anton2920): Set-Cookie cannot be combined, because Expires contains comma. */
+			if key == "Set-Cookie" {
+				for j := 0; j < len(w.Headers.Values[i]); j++ {
+					c.ResponseBuffer = append(c.ResponseBuffer, key...)
+					c.ResponseBuffer = append(c.ResponseBuffer, ": "...)
+					c.ResponseBuffer = append(c.ResponseBuffer, w.Headers.Values[i][j]...)
+					c.ResponseBuffer = append(c.ResponseBuffer, "\r\n"...)
+				}
+				continue
+			}
+
 			c.ResponseBuffer = append(c.ResponseBuffer, key...)
 			c.ResponseBuffer = append(c.ResponseBuffer, ": "...)
 			for j := 0; j < len(w.Headers.Values[i]); j++ {
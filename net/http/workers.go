@@ -7,6 +7,7 @@ import (
 	"github.com/anton2920/gofa/ints"
 	"github.com/anton2920/gofa/log"
 	"github.com/anton2920/gofa/syscall"
+	"github.com/anton2920/gofa/trace/trace_"
 )
 
 type Workers struct {
@@ -15,6 +16,8 @@ type Workers struct {
 }
 
 func Worker(q *event.Queue, router Router) {
+	trace_.BeginThread()
+
 	rs := make([]Request, Pipeline)
 	ws := make([]Response, Pipeline)
 
@@ -23,7 +23,13 @@ const (
 	TruncateSizeToZero
 )
 
-func OpenOrCreateFile(ctx *context.Context, path string, rw bits.Flags, creat bits.Flags, perms uint) (Handle, bool) {
+/* File error codes. */
+const (
+	ErrorCodeFileDoesNotExist = int(freebsd.ENOENT)
+	ErrorCodeFileExists       = int(freebsd.EEXIST)
+)
+
+func fileFlags(rw bits.Flags, creat bits.Flags) int32 {
 	var rwFlags, creatFlags uint
 
 	/* Read/Write/Append flags. */
@@ -51,10 +57,33 @@ func OpenOrCreateFile(ctx *context.Context, path string, rw bits.Flags, creat bi
 		creatFlags |= freebsd.O_TRUNC
 	}
 
-	f, ok := freebsd.Open(ctx, path, int32(rwFlags|creatFlags), uint16(perms))
+	return int32(rwFlags | creatFlags)
+}
+
+func OpenOrCreateFile(ctx *context.Context, path string, rw bits.Flags, creat bits.Flags, perms uint) (Handle, bool) {
+	f, ok := freebsd.Open(ctx, path, fileFlags(rw, creat), uint16(perms))
+	return Handle(f), ok
+}
+
+/* OpenOrCreateFileAt is like OpenOrCreateFile, but relative paths are resolved against directory dir. */
+func OpenOrCreateFileAt(ctx *context.Context, dir Handle, path string, rw bits.Flags, creat bits.Flags, perms uint) (Handle, bool) {
+	f, ok := freebsd.OpenAt(ctx, int32(dir), path, fileFlags(rw, creat), uint16(perms))
 	return Handle(f), ok
 }
 
+func CreateDirectory(ctx *context.Context, path string, perms uint) bool {
+	return freebsd.Mkdir(ctx, path, int16(perms))
+}
+
+/* RenameFile moves file from one path to another. Existing file at destination is replaced atomically. */
+func RenameFile(ctx *context.Context, from string, to string) bool {
+	return freebsd.Rename(ctx, from, to)
+}
+
+func RemoveFile(ctx *context.Context, path string) bool {
+	return freebsd.Unlink(ctx, path)
+}
+
 //go:nosplit
 func CloseHandle(ctx *context.Context, f Handle) bool {
 	return freebsd.Close(ctx, int32(f))
@@ -84,3 +113,21 @@ func WriteToFileAt(ctx *context.Context, f Handle, buf []byte, offt int64) (int,
 func ResizeFile(ctx *context.Context, f Handle, size int) bool {
 	return freebsd.Ftruncate(ctx, int32(f), int64(size))
 }
+
+//go:nosplit
+func SyncFile(ctx *context.Context, f Handle) bool {
+	return freebsd.Fsync(ctx, int32(f))
+}
+
+func GetFileSize(ctx *context.Context, f Handle) (int, bool) {
+	var sb freebsd.Stat_t
+	if !freebsd.Fstat(ctx, int32(f), &sb) {
+		return 0, false
+	}
+	return int(sb.Size), true
+}
+
+/* ErrorCodeString returns description of error code set by functions in this package. */
+func ErrorCodeString(code int) string {
+	return freebsd.Errno(code).String()
+}
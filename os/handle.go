@@ -7,3 +7,6 @@ const (
 	StandardOutputStream
 	StandardErrorStream
 )
+
+/* InvalidHandle is never returned by successful open, so it marks handles that are closed. */
+const InvalidHandle = Handle(-1)
@@ -40,6 +40,11 @@ func AllocateFileBackedVirtualMemory(ctx *context.Context, size int, fd Handle)
 	return freebsd.Mmap(ctx, nil, uint(size), int32(AllocateForReading|AllocateForWriting), freebsd.MAP_SHARED, int32(fd), 0)
 }
 
+/* AllocateFileBackedReadOnlyVirtualMemory maps first size bytes of file fd. Writes to the file through its handle are visible in the mapping. */
+func AllocateFileBackedReadOnlyVirtualMemory(ctx *context.Context, size int, fd Handle) (unsafe.Pointer, bool) {
+	return freebsd.Mmap(ctx, nil, uint(size), int32(AllocateForReading), freebsd.MAP_SHARED, int32(fd), 0)
+}
+
 func DeallocateVirtualMemory(ctx *context.Context, addr unsafe.Pointer, size int) bool {
 	return freebsd.Munmap(ctx, addr, uint(size))
 }
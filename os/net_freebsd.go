@@ -13,6 +13,7 @@ import (
 type AddressFamily uint8
 
 const (
+	AddressFamilyUnix     = AddressFamily(freebsd.AF_UNIX)
 	AddressFamilyInternet = AddressFamily(freebsd.AF_INET)
 )
 
@@ -30,16 +31,24 @@ type InternetAddress struct {
 	_       [8]byte
 }
 
+type UnixAddress struct {
+	Len    uint8
+	Family AddressFamily
+	Path   [104]byte
+}
+
 type ProtocolFamily int32
 
 const (
+	ProtocolFamilyUnix     = ProtocolFamily(freebsd.PF_UNIX)
 	ProtocolFamilyInternet = ProtocolFamily(freebsd.PF_INET)
 )
 
 type SocketType int32
 
 const (
-	SocketTypeStream = SocketType(freebsd.SOCK_STREAM)
+	SocketTypeStream   = SocketType(freebsd.SOCK_STREAM)
+	SocketTypeDatagram = SocketType(freebsd.SOCK_DGRAM)
 )
 
 type Protocol int32
@@ -73,6 +82,17 @@ func (ia *InternetAddress) AsNetworkAddress() *NetworkAddress {
 	return (*NetworkAddress)(unsafe.Pointer(ia))
 }
 
+/* InitWithPath sets path of socket, path longer than sizeof(Path)-1 bytes is truncated. */
+func (ua *UnixAddress) InitWithPath(path string) {
+	ua.Len = uint8(unsafe.Sizeof(*ua))
+	ua.Family = AddressFamilyUnix
+	ua.Path[copy(ua.Path[:len(ua.Path)-1], path)] = 0
+}
+
+func (ua *UnixAddress) AsNetworkAddress() *NetworkAddress {
+	return (*NetworkAddress)(unsafe.Pointer(ua))
+}
+
 func CreateNetworkSocket(ctx *context.Context, pf ProtocolFamily, typ SocketType, proto Protocol) (Handle, bool) {
 	s, ok := freebsd.Socket(ctx, int32(pf), int32(typ), int32(proto))
 	return Handle(s), ok
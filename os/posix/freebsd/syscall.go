@@ -52,6 +52,7 @@ const (
 	SYS_rctl_add_rule    = 528
 	SYS_rctl_remove_rule = 529
 	SYS_read             = 3
+	SYS_rename           = 128
 	SYS_rmdir            = 137
 	SYS_setsockopt       = 105
 	SYS_shm_open2        = 571
@@ -314,6 +315,17 @@ func Read(ctx *context.Context, fd int32, buf []byte) (int, bool) {
 	return int(r1), ReportPotentialError(ctx, errno)
 }
 
+func Rename(ctx *context.Context, from string, to string) bool {
+	fromBuffer := make([]byte, PATH_MAX+1)
+	copy(fromBuffer[:PATH_MAX], from)
+
+	toBuffer := make([]byte, PATH_MAX+1)
+	copy(toBuffer[:PATH_MAX], to)
+
+	_, _, errno := RawSyscall(SYS_rename, uintptr(unsafe.Pointer(&fromBuffer[0])), uintptr(unsafe.Pointer(&toBuffer[0])), 0)
+	return ReportPotentialError(ctx, errno)
+}
+
 func Rmdir(ctx *context.Context, path string) bool {
 	buffer := make([]byte, PATH_MAX+1)
 	copy(buffer[:PATH_MAX], path)
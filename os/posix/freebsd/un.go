@@ -0,0 +1,8 @@
+package freebsd
+
+/* From <sys/un.h>. */
+type SockaddrUn struct {
+	Len    uint8
+	Family uint8
+	Path   [104]byte
+}
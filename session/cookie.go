@@ -0,0 +1,233 @@
+package session
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/anton2920/gofa/database"
+	"github.com/anton2920/gofa/encoding/wire"
+	"github.com/anton2920/gofa/l10n"
+	"github.com/anton2920/gofa/time"
+	"github.com/anton2920/gofa/trace/trace_"
+)
+
+/* CookieCodec stores session on client. Cookie is base64 of:
+ *     kind   uint8 (signed or sealed)
+ *     key ID uint8
+ *     nonce  [12]byte, if sealed
+ *     wire frame with expiry, user ID, customization, token and data
+ *     HMAC-SHA256 of everything above, if signed; AES-GCM tag, if sealed.
+ * Signed cookies can be read by client, but cannot be changed. Sealed cookies cannot be read either. */
+type CookieCodec struct {
+	/* Keys[0] is used for new cookies, all keys are accepted. Old keys should be kept for at least Lifetime after rotation. */
+	Keys []CookieKey
+
+	Encrypt bool
+}
+
+type CookieKey struct {
+	ID uint8
+
+	MAC  []byte
+	AEAD cipher.AEAD
+}
+
+const (
+	cookieSigned = 1
+	cookieSealed = 2
+
+	cookieHeaderSize = 2
+	cookieNonceSize  = 12
+	cookieMACSize    = sha256.Size
+
+	/* MaxCookieSize is the size limit of cookie value most browsers agree on. */
+	MaxCookieSize = 4096
+
+	/* MinCookieSecretSize is the minimal size of secret for NewCookieKey. */
+	MinCookieSecretSize = 32
+)
+
+/* DefaultCookieCodec is used by net/http to keep Customization on client. It's nil by default, so Customization is kept in Store. */
+var DefaultCookieCodec *CookieCodec
+
+/* NewCookieKey derives independent keys for signing and encryption from secret, so the same secret may be used with both. */
+func NewCookieKey(id uint8, secret []byte) (CookieKey, error) {
+	if len(secret) < MinCookieSecretSize {
+		return CookieKey{}, fmt.Errorf("secret must be at least %d bytes long, got %d", MinCookieSecretSize, len(secret))
+	}
+
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte("gofa/session: cookie MAC"))
+	mac := h.Sum(nil)
+
+	h.Reset()
+	h.Write([]byte("gofa/session: cookie AEAD"))
+	block, err := aes.NewCipher(h.Sum(nil))
+	if err != nil {
+		return CookieKey{}, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return CookieKey{}, fmt.Errorf("failed to create AEAD: %w", err)
+	}
+
+	return CookieKey{ID: id, MAC: mac, AEAD: aead}, nil
+}
+
+func NewCookieCodec(encrypt bool, keys ...CookieKey) (*CookieCodec, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("at least one key is required")
+	}
+	for i := 0; i < len(keys); i++ {
+		for j := i + 1; j < len(keys); j++ {
+			if keys[i].ID == keys[j].ID {
+				return nil, fmt.Errorf("duplicate key ID %d", keys[i].ID)
+			}
+		}
+	}
+	return &CookieCodec{Keys: keys, Encrypt: encrypt}, nil
+}
+
+/* Rotate returns new codec, which uses key for new cookies and still accepts up to keep previous keys. Codec itself is not modified, so it's safe to use concurrently. */
+func (codec *CookieCodec) Rotate(key CookieKey, keep int) (*CookieCodec, error) {
+	if keep > len(codec.Keys) {
+		keep = len(codec.Keys)
+	}
+
+	keys := make([]CookieKey, 0, 1+keep)
+	keys = append(keys, key)
+	keys = append(keys, codec.Keys[:keep]...)
+
+	return NewCookieCodec(codec.Encrypt, keys...)
+}
+
+func (codec *CookieCodec) key(id uint8) *CookieKey {
+	for i := 0; i < len(codec.Keys); i++ {
+		if codec.Keys[i].ID == id {
+			return &codec.Keys[i]
+		}
+	}
+	return nil
+}
+
+/* Encode returns cookie value with session and arbitrary data. Session.Expiry is embedded, so cookie is rejected after it, regardless of what client does with cookie's own expiry. */
+func (codec *CookieCodec) Encode(session Session, data []byte) (string, error) {
+	defer trace_.End(trace_.Begin(""))
+
+	var s wire.Serializer
+
+	key := &codec.Keys[0]
+
+	kind := uint8(cookieSigned)
+	if codec.Encrypt {
+		kind = cookieSealed
+	}
+	s.Buffer = append(s.Buffer, kind, key.ID)
+	if codec.Encrypt {
+		s.Buffer = append(s.Buffer, make([]byte, cookieNonceSize)...)
+		if _, err := rand.Read(s.Buffer[cookieHeaderSize:]); err != nil {
+			return "", fmt.Errorf("failed to generate nonce: %w", err)
+		}
+	}
+	header := len(s.Buffer)
+
+	s.Begin(1)
+	s.Varint(session.Expiry)
+	s.Varint(int64(session.ID))
+	s.Varint(int64(session.Language))
+	s.Varint(int64(session.Timezone))
+	s.Varint(int64(session.ColorScheme))
+	s.String(session.Token)
+	s.Bytes(data)
+	s.End()
+
+	var cookie []byte
+	if codec.Encrypt {
+		cookie = key.AEAD.Seal(s.Buffer[:header], s.Buffer[cookieHeaderSize:header], s.Buffer[header:], s.Buffer[:cookieHeaderSize])
+	} else {
+		h := hmac.New(sha256.New, key.MAC)
+		h.Write(s.Buffer)
+		cookie = h.Sum(s.Buffer)
+	}
+
+	if base64.RawURLEncoding.EncodedLen(len(cookie)) > MaxCookieSize {
+		return "", fmt.Errorf("cookie is too large: %d bytes", base64.RawURLEncoding.EncodedLen(len(cookie)))
+	}
+	return base64.RawURLEncoding.EncodeToString(cookie), nil
+}
+
+/* Decode returns session and data from cookie value. If cookie is malformed, tampered with, signed by unknown key or expired, empty session is returned. */
+func (codec *CookieCodec) Decode(value string, now int64) (Session, []byte) {
+	defer trace_.End(trace_.Begin(""))
+
+	var session Session
+
+	if (len(value) == 0) || (len(value) > MaxCookieSize) {
+		return Session{}, nil
+	}
+	cookie, err := base64.RawURLEncoding.DecodeString(value)
+	if (err != nil) || (len(cookie) < cookieHeaderSize) {
+		return Session{}, nil
+	}
+
+	key := codec.key(cookie[1])
+	if key == nil {
+		return Session{}, nil
+	}
+
+	var body []byte
+	switch cookie[0] {
+	default:
+		return Session{}, nil
+	case cookieSigned:
+		if len(cookie) < cookieHeaderSize+cookieMACSize {
+			return Session{}, nil
+		}
+		mac := cookie[len(cookie)-cookieMACSize:]
+		body = cookie[:len(cookie)-cookieMACSize]
+
+		h := hmac.New(sha256.New, key.MAC)
+		h.Write(body)
+		if !hmac.Equal(mac, h.Sum(nil)) {
+			return Session{}, nil
+		}
+		body = body[cookieHeaderSize:]
+	case cookieSealed:
+		if len(cookie) < cookieHeaderSize+cookieNonceSize+key.AEAD.Overhead() {
+			return Session{}, nil
+		}
+		nonce := cookie[cookieHeaderSize : cookieHeaderSize+cookieNonceSize]
+		body, err = key.AEAD.Open(cookie[cookieHeaderSize+cookieNonceSize:cookieHeaderSize+cookieNonceSize], nonce, cookie[cookieHeaderSize+cookieNonceSize:], cookie[:cookieHeaderSize])
+		if err != nil {
+			return Session{}, nil
+		}
+	}
+
+	d := wire.Deserializer{Buffer: body}
+	d.Begin()
+	session.Expiry = d.Varint()
+	session.ID = database.ID(d.Varint())
+	session.Language = l10n.Language(d.Varint())
+	session.Timezone = time.Timezone(d.Varint())
+	session.ColorScheme = ColorScheme(d.Varint())
+	session.Token = d.String()
+	data := d.Bytes()
+	if err := d.End(); err != nil {
+		return Session{}, nil
+	}
+
+	if now-session.Expiry > 0 {
+		return Session{}, nil
+	}
+	if len(data) == 0 {
+		data = nil
+	}
+
+	return session, data
+}
@@ -0,0 +1,153 @@
+package session
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func testCookieKey(t *testing.T, id uint8) CookieKey {
+	key, err := NewCookieKey(id, bytes.Repeat([]byte{id}, MinCookieSecretSize))
+	if err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	return key
+}
+
+func testCookieCodec(t *testing.T, encrypt bool, ids ...uint8) *CookieCodec {
+	keys := make([]CookieKey, len(ids))
+	for i, id := range ids {
+		keys[i] = testCookieKey(t, id)
+	}
+	codec, err := NewCookieCodec(encrypt, keys...)
+	if err != nil {
+		t.Fatalf("Failed to create codec: %v", err)
+	}
+	return codec
+}
+
+func TestCookieCodec(t *testing.T) {
+	session := Session{ID: 42, Expiry: 1000, Token: "token"}
+	session.Language = 1
+	session.Timezone = -3
+	session.ColorScheme = ColorSchemeDark
+
+	for _, encrypt := range [...]bool{false, true} {
+		codec := testCookieCodec(t, encrypt, 1)
+
+		value, err := codec.Encode(session, []byte("data"))
+		if err != nil {
+			t.Fatalf("Failed to encode cookie: %v", err)
+		}
+		if strings.ContainsAny(value, ";, =\"\\") {
+			t.Errorf("Cookie %q contains characters not allowed in cookie value", value)
+		}
+
+		got, data := codec.Decode(value, 1000)
+		if (got != session) || (string(data) != "data") {
+			t.Errorf("Expected %+v with data, got %+v and %q", session, got, data)
+		}
+
+		if got, _ := codec.Decode(value, 1001); got != (Session{}) {
+			t.Errorf("Expected expired cookie to be rejected, got %+v", got)
+		}
+
+		/* Every modified byte must be detected. */
+		cookie, _ := base64.RawURLEncoding.DecodeString(value)
+		for i := 0; i < len(cookie); i++ {
+			cookie[i] ^= 0x10
+			if got, data := codec.Decode(base64.RawURLEncoding.EncodeToString(cookie), 0); (got != Session{}) || (data != nil) {
+				t.Errorf("Expected tampered cookie (byte %d) to be rejected, got %+v", i, got)
+			}
+			cookie[i] ^= 0x10
+		}
+		for i := 0; i < len(cookie); i++ {
+			if got, _ := codec.Decode(base64.RawURLEncoding.EncodeToString(cookie[:i]), 0); got != (Session{}) {
+				t.Errorf("Expected truncated cookie (%d bytes) to be rejected, got %+v", i, got)
+			}
+		}
+
+		for _, value := range [...]string{"", "!", strings.Repeat("A", MaxCookieSize+1)} {
+			if got, _ := codec.Decode(value, 0); got != (Session{}) {
+				t.Errorf("Expected %q to be rejected, got %+v", value, got)
+			}
+		}
+	}
+}
+
+func TestCookieCodecSigned(t *testing.T) {
+	signed := testCookieCodec(t, false, 1)
+	sealed := testCookieCodec(t, true, 1)
+
+	value, err := signed.Encode(Session{Expiry: 1, Token: "visible"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to encode cookie: %v", err)
+	}
+	cookie, _ := base64.RawURLEncoding.DecodeString(value)
+	if !bytes.Contains(cookie, []byte("visible")) {
+		t.Errorf("Expected signed cookie to contain plaintext")
+	}
+
+	/* Kind of cookie is decided by cookie itself, so sealed codec accepts signed cookies with the same key. */
+	if got, _ := sealed.Decode(value, 0); got.Token != "visible" {
+		t.Errorf("Expected signed cookie to be accepted, got %+v", got)
+	}
+
+	value, err = sealed.Encode(Session{Expiry: 1, Token: "hidden"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to encode cookie: %v", err)
+	}
+	cookie, _ = base64.RawURLEncoding.DecodeString(value)
+	if bytes.Contains(cookie, []byte("hidden")) {
+		t.Errorf("Expected sealed cookie to not contain plaintext")
+	}
+}
+
+func TestCookieCodecRotate(t *testing.T) {
+	old := testCookieCodec(t, true, 1)
+	value, err := old.Encode(Session{Expiry: 1, Token: "old"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to encode cookie: %v", err)
+	}
+
+	rotated, err := old.Rotate(testCookieKey(t, 2), 1)
+	if err != nil {
+		t.Fatalf("Failed to rotate keys: %v", err)
+	}
+	if got, _ := rotated.Decode(value, 0); got.Token != "old" {
+		t.Errorf("Expected cookie with previous key to be accepted, got %+v", got)
+	}
+
+	value, err = rotated.Encode(Session{Expiry: 1, Token: "new"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to encode cookie: %v", err)
+	}
+	if got, _ := old.Decode(value, 0); got != (Session{}) {
+		t.Errorf("Expected cookie with unknown key to be rejected, got %+v", got)
+	}
+
+	/* Key with the same ID but different secret. */
+	impostor := testCookieKey(t, 3)
+	impostor.ID = 2
+	forged, _ := NewCookieCodec(true, impostor)
+	value, _ = forged.Encode(Session{Expiry: 1, Token: "forged"}, nil)
+	if got, _ := rotated.Decode(value, 0); got != (Session{}) {
+		t.Errorf("Expected forged cookie to be rejected, got %+v", got)
+	}
+
+	dropped, err := rotated.Rotate(testCookieKey(t, 3), 0)
+	if err != nil {
+		t.Fatalf("Failed to rotate keys: %v", err)
+	}
+	if len(dropped.Keys) != 1 {
+		t.Errorf("Expected only new key, got %d keys", len(dropped.Keys))
+	}
+
+	if _, err := rotated.Rotate(testCookieKey(t, 1), 2); err == nil {
+		t.Errorf("Expected error for duplicate key ID")
+	}
+	if _, err := NewCookieKey(1, make([]byte, MinCookieSecretSize-1)); err == nil {
+		t.Errorf("Expected error for short secret")
+	}
+}
@@ -0,0 +1,303 @@
+package session
+
+import (
+	"fmt"
+	"path"
+	"sync"
+
+	"github.com/anton2920/gofa/database"
+	"github.com/anton2920/gofa/encoding/wire"
+	"github.com/anton2920/gofa/io/fs"
+	"github.com/anton2920/gofa/trace/trace_"
+)
+
+/* LogStore keeps sessions in memory and appends every change to file, which is replayed on open. Records are wire frames:
+ *     generation uint32
+ *     op         uint8
+ *     arguments of op
+ * Compact writes live sessions with incremented generation into new file, which atomically replaces the old one. */
+type LogStore struct {
+	*MemoryStore
+
+	VFS  fs.VFS
+	Path string
+	File fs.VFile
+
+	/* Lock serializes appends and compaction. */
+	Lock       sync.Mutex
+	Generation uint32
+	Size       int64
+
+	/* Records is the number of records in file, used to decide when to compact. */
+	Records int
+}
+
+const (
+	logStoreSet = iota
+	logStoreTouch
+	logStoreDel
+	logStoreDelUser
+	logStoreExpire
+)
+
+var _ Store = new(LogStore)
+
+/* NewLogStore opens or creates log at path and replays it. Incomplete or corrupted records at the end of file, left after crash, are truncated. */
+func NewLogStore(vfs fs.VFS, path string) (*LogStore, error) {
+	f, err := vfs.Open(path, fs.OpenForReading|fs.OpenForWriting|fs.CreateFileIfItDoesNotExist)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sessions log: %w", err)
+	}
+
+	store, err := replayLog(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	store.VFS = vfs
+	store.Path = path
+
+	return store, nil
+}
+
+func replayLog(f fs.VFile) (*LogStore, error) {
+	store := &LogStore{MemoryStore: NewMemoryStore(), File: f}
+
+	size, err := f.Size()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get size of sessions log: %w", err)
+	}
+	buffer := make([]byte, size)
+	if size > 0 {
+		if _, err := f.ReadAt(buffer, 0); err != nil {
+			return nil, fmt.Errorf("failed to read sessions log: %w", err)
+		}
+	}
+
+	d := wire.Deserializer{Buffer: buffer}
+	for len(d.Buffer) > 0 {
+		var session Session
+
+		d.Begin()
+		generation := d.Uint32()
+		op := d.Uint8()
+
+		/* NOTE(anton2920): the first record always has the latest generation. */
+		if store.Records == 0 {
+			store.Generation = generation
+		}
+
+		switch op {
+		default:
+			d.Error = fmt.Errorf("unknown operation %d", op)
+		case logStoreSet:
+			getSession(&d, &session)
+		case logStoreTouch, logStoreDel:
+			session.Token = string(d.Bytes())
+			session.Expiry = d.Int64()
+		case logStoreDelUser:
+			session.ID = database.ID(d.Int32())
+		case logStoreExpire:
+			session.Expiry = d.Int64()
+		}
+		if err := d.End(); err != nil {
+			break
+		}
+		store.Size = int64(len(buffer) - len(d.Buffer))
+
+		if generation != store.Generation {
+			continue
+		}
+		store.Records++
+		store.apply(op, &session)
+	}
+
+	if store.Size < int64(len(buffer)) {
+		if err := f.Truncate(store.Size); err != nil {
+			return nil, fmt.Errorf("failed to truncate sessions log: %w", err)
+		}
+	}
+
+	return store, nil
+}
+
+func (store *LogStore) apply(op uint8, session *Session) {
+	switch op {
+	case logStoreSet:
+		store.MemoryStore.Set(*session)
+	case logStoreTouch:
+		store.MemoryStore.Touch(session.Token, session.Expiry)
+	case logStoreDel:
+		store.MemoryStore.Del(session.Token)
+	case logStoreDelUser:
+		store.MemoryStore.DelUser(session.ID)
+	case logStoreExpire:
+		store.MemoryStore.Expire(session.Expiry)
+	}
+}
+
+/* record serializes operation on session. */
+func (store *LogStore) record(s *wire.Serializer, generation uint32, op uint8, session *Session) {
+	s.Begin(1)
+	s.Uint32(generation)
+	s.Uint8(op)
+	switch op {
+	case logStoreSet:
+		putSession(s, session)
+	case logStoreTouch, logStoreDel:
+		s.String(session.Token)
+		s.Int64(session.Expiry)
+	case logStoreDelUser:
+		s.Int32(int32(session.ID))
+	case logStoreExpire:
+		s.Int64(session.Expiry)
+	}
+	s.End()
+}
+
+/* write appends record to the end of file. Lock must be held. */
+func (store *LogStore) write(op uint8, session *Session, durable bool) error {
+	var s wire.Serializer
+
+	store.record(&s, store.Generation, op, session)
+	if _, err := store.File.WriteAt(s.Buffer, store.Size); err != nil {
+		return fmt.Errorf("failed to append to sessions log: %w", err)
+	}
+	if durable {
+		if err := store.File.Sync(); err != nil {
+			return fmt.Errorf("failed to sync sessions log: %w", err)
+		}
+	}
+	store.Size += int64(len(s.Buffer))
+	store.Records++
+
+	return nil
+}
+
+/* append writes record and applies it to memory. Memory is updated under the same lock, so the order of records matches the order of changes. */
+func (store *LogStore) append(op uint8, session *Session, durable bool) error {
+	store.Lock.Lock()
+	defer store.Lock.Unlock()
+
+	if err := store.write(op, session, durable); err != nil {
+		return err
+	}
+	store.apply(op, session)
+	return nil
+}
+
+func (store *LogStore) Set(session Session) error {
+	return store.append(logStoreSet, &session, true)
+}
+
+/* Touch is not synced to disk: losing it after crash only makes session expire earlier. */
+func (store *LogStore) Touch(token string, expiry int64) error {
+	defer trace_.End(trace_.Begin(""))
+
+	if _, ok := store.MemoryStore.Get(token); !ok {
+		return nil
+	}
+	return store.append(logStoreTouch, &Session{Token: token, Expiry: expiry}, false)
+}
+
+func (store *LogStore) Del(token string) error {
+	return store.append(logStoreDel, &Session{Token: token}, true)
+}
+
+func (store *LogStore) DelUser(id database.ID) error {
+	return store.append(logStoreDelUser, &Session{ID: id}, true)
+}
+
+/* Expire also compacts log, if it mostly consists of stale records. */
+func (store *LogStore) Expire(now int64) (int, error) {
+	var live int
+
+	store.Lock.Lock()
+	n, err := store.MemoryStore.Expire(now)
+	if (err == nil) && (n > 0) {
+		err = store.write(logStoreExpire, &Session{Expiry: now}, false)
+	}
+	records := store.Records
+	store.Lock.Unlock()
+	if (err != nil) || (n == 0) {
+		return n, err
+	}
+
+	store.MemoryStore.Range(func(Session) bool {
+		live++
+		return true
+	})
+	if records > 2*live+64 {
+		if err := store.Compact(); err != nil {
+			return n, err
+		}
+	}
+
+	return n, nil
+}
+
+/* Close closes log file. Store must not be used after. */
+func (store *LogStore) Close() error {
+	store.Lock.Lock()
+	defer store.Lock.Unlock()
+
+	return store.File.Close()
+}
+
+/* syncDirectory makes renames in directory of file at p durable. */
+func syncDirectory(vfs fs.VFS, p string) error {
+	dir, err := vfs.Open(path.Dir(p), fs.OpenForReading)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+
+	return dir.Sync()
+}
+
+/* Compact rewrites log with live sessions only. New log is written to temporary file, synced and renamed over the old one, so crash at any point leaves either old or new log intact. */
+func (store *LogStore) Compact() error {
+	var s wire.Serializer
+	var records int
+
+	store.Lock.Lock()
+	defer store.Lock.Unlock()
+
+	generation := store.Generation + 1
+	store.MemoryStore.Range(func(session Session) bool {
+		store.record(&s, generation, logStoreSet, &session)
+		records++
+		return true
+	})
+
+	tmp := store.Path + ".tmp"
+	f, err := store.VFS.Open(tmp, fs.OpenForReading|fs.OpenForWriting|fs.CreateFileIfItDoesNotExist|fs.TruncateSizeToZero)
+	if err != nil {
+		return fmt.Errorf("failed to create compacted sessions log: %w", err)
+	}
+	if _, err := f.WriteAt(s.Buffer, 0); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write compacted sessions log: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to sync compacted sessions log: %w", err)
+	}
+	if err := store.VFS.Rename(tmp, store.Path); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to replace sessions log: %w", err)
+	}
+
+	/* NOTE(anton2920): from now on new log is used, even if syncing directory fails, because old file is no longer reachable by path. */
+	store.File.Close()
+	store.File = f
+	store.Generation = generation
+	store.Records = records
+	store.Size = int64(len(s.Buffer))
+
+	if err := syncDirectory(store.VFS, store.Path); err != nil {
+		return fmt.Errorf("failed to sync directory of sessions log: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package session
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/anton2920/gofa/database"
+	"github.com/anton2920/gofa/trace/trace_"
+)
+
+/* MemoryStore keeps sessions in memory. Sessions are spread over shards by token, so requests for different sessions rarely contend for the same lock. */
+type MemoryStore struct {
+	Shards [MemoryStoreShards]MemoryStoreShard
+}
+
+type MemoryStoreShard struct {
+	sync.RWMutex
+	Sessions map[string]*MemoryStoreEntry
+
+	/* NOTE(anton2920): padding to prevent false sharing of locks. */
+	_ [64]byte
+}
+
+type MemoryStoreEntry struct {
+	Session
+
+	/* Expiry is updated atomically by Touch under read lock, it overrides Session.Expiry. */
+	Expiry int64
+}
+
+const MemoryStoreShards = 64
+
+var _ Store = new(MemoryStore)
+
+func NewMemoryStore() *MemoryStore {
+	store := new(MemoryStore)
+	for i := 0; i < len(store.Shards); i++ {
+		store.Shards[i].Sessions = make(map[string]*MemoryStoreEntry)
+	}
+	return store
+}
+
+/* shard returns shard for token using FNV-1a hash. */
+func (store *MemoryStore) shard(token string) *MemoryStoreShard {
+	h := uint32(2166136261)
+	for i := 0; i < len(token); i++ {
+		h ^= uint32(token[i])
+		h *= 16777619
+	}
+	return &store.Shards[h%MemoryStoreShards]
+}
+
+func (store *MemoryStore) Get(token string) (Session, bool) {
+	defer trace_.End(trace_.Begin(""))
+
+	shard := store.shard(token)
+	shard.RLock()
+	entry, ok := shard.Sessions[token]
+	if !ok {
+		shard.RUnlock()
+		return Session{}, false
+	}
+	session := entry.Session
+	session.Expiry = atomic.LoadInt64(&entry.Expiry)
+	shard.RUnlock()
+
+	return session, true
+}
+
+func (store *MemoryStore) Set(session Session) error {
+	shard := store.shard(session.Token)
+	shard.Lock()
+	shard.Sessions[session.Token] = &MemoryStoreEntry{Session: session, Expiry: session.Expiry}
+	shard.Unlock()
+	return nil
+}
+
+func (store *MemoryStore) Touch(token string, expiry int64) error {
+	defer trace_.End(trace_.Begin(""))
+
+	shard := store.shard(token)
+	shard.RLock()
+	if entry, ok := shard.Sessions[token]; ok {
+		atomic.StoreInt64(&entry.Expiry, expiry)
+	}
+	shard.RUnlock()
+	return nil
+}
+
+func (store *MemoryStore) Del(token string) error {
+	shard := store.shard(token)
+	shard.Lock()
+	delete(shard.Sessions, token)
+	shard.Unlock()
+	return nil
+}
+
+func (store *MemoryStore) DelUser(id database.ID) error {
+	for i := 0; i < len(store.Shards); i++ {
+		shard := &store.Shards[i]
+		shard.Lock()
+		for token, entry := range shard.Sessions {
+			if entry.ID == id {
+				delete(shard.Sessions, token)
+			}
+		}
+		shard.Unlock()
+	}
+	return nil
+}
+
+func (store *MemoryStore) Expire(now int64) (int, error) {
+	var n int
+
+	for i := 0; i < len(store.Shards); i++ {
+		shard := &store.Shards[i]
+		shard.Lock()
+		for token, entry := range shard.Sessions {
+			if now-atomic.LoadInt64(&entry.Expiry) > 0 {
+				delete(shard.Sessions, token)
+				n++
+			}
+		}
+		shard.Unlock()
+	}
+
+	return n, nil
+}
+
+/* Range calls f for every session until it returns false. Store must not be modified from f. */
+func (store *MemoryStore) Range(f func(session Session) bool) {
+	for i := 0; i < len(store.Shards); i++ {
+		shard := &store.Shards[i]
+		shard.RLock()
+		for _, entry := range shard.Sessions {
+			session := entry.Session
+			session.Expiry = atomic.LoadInt64(&entry.Expiry)
+			if !f(session) {
+				shard.RUnlock()
+				return
+			}
+		}
+		shard.RUnlock()
+	}
+}
@@ -26,67 +26,66 @@ type Session struct {
 	/* Expiry is Unix time in seconds when session is no longer valid. */
 	Expiry int64
 
-	/* Token is key in Sessions map. */
+	/* Token is key in Store. */
 	Token string
 }
 
 var (
-	Sessions     = make(map[string]Session)
+	/* Deprecated: use DefaultStore. Sessions is a snapshot of DefaultStore taken by LoadFromFile and StoreToFile, it's not updated by other functions of this package. */
+	Sessions = make(map[string]Session)
+
+	/* Deprecated: protects Sessions snapshot only. */
 	SessionsLock sync.RWMutex
 )
 
 func New(userID database.ID) Session {
 	token := GenerateToken()
-	expiry := time.Now() + time.Week
+	expiry := time.Now() + Lifetime
 
 	session := Session{ID: userID, Expiry: expiry, Token: token}
-	SessionsLock.Lock()
-	Sessions[token] = session
-	SessionsLock.Unlock()
+	if err := DefaultStore.Set(session); err != nil {
+		log.Warnf("Failed to store new session: %v", err)
+	}
 
 	return session
 }
 
+/* Get returns session for token or empty session, if it does not exist or is expired. Expiry of active sessions is extended at most once per SlideInterval. */
 func Get(token string) Session {
 	defer trace_.End(trace_.Begin(""))
 
-	SessionsLock.RLock()
-	session, ok := Sessions[token]
-	SessionsLock.RUnlock()
+	session, ok := DefaultStore.Get(token)
 	if !ok {
 		return Session{}
 	}
 
 	now := time.Now()
 	if now-session.Expiry > 0 {
-		SessionsLock.Lock()
-		delete(Sessions, token)
-		SessionsLock.Unlock()
+		if err := DefaultStore.Del(token); err != nil {
+			log.Warnf("Failed to remove expired session: %v", err)
+		}
 		return Session{}
 	}
-	session.Expiry = now + time.Week
-
-	SessionsLock.Lock()
-	Sessions[session.Token] = session
-	SessionsLock.Unlock()
+	if expiry := now + Lifetime; expiry-session.Expiry >= SlideInterval {
+		session.Expiry = expiry
+		if err := DefaultStore.Touch(token, expiry); err != nil {
+			log.Warnf("Failed to extend session: %v", err)
+		}
+	}
 
 	return session
 }
 
 func (session Session) RemoveAllForThisUser() {
-	SessionsLock.Lock()
-	for k, v := range Sessions {
-		if v.ID == session.ID {
-			delete(Sessions, k)
-		}
+	if err := DefaultStore.DelUser(session.ID); err != nil {
+		log.Warnf("Failed to remove sessions of user %d: %v", session.ID, err)
 	}
-	SessionsLock.Unlock()
 }
 
 func (session Session) Update() {
-	SessionsLock.Lock()
-	Sessions[session.Token] = session
-	SessionsLock.Unlock()
+	if err := DefaultStore.Set(session); err != nil {
+		log.Warnf("Failed to update session: %v", err)
+	}
 }
 
 func GenerateToken() string {
@@ -113,15 +112,13 @@ func GenerateToken() string {
 		base64.StdEncoding.Encode(token, buffer)
 
 		/* Making sure that it's unique. */
-		SessionsLock.RLock()
-		_, ok := Sessions[bytes.AsString(token)]
-		SessionsLock.RUnlock()
-		if !ok {
+		if _, ok := DefaultStore.Get(bytes.AsString(token)); !ok {
 			return string(token)
 		}
 	}
 }
 
+/* LoadFromFile adds sessions from file written by StoreToFile to DefaultStore. Use LogStore or TreeStore for persistent sessions instead. */
 func LoadFromFile(filename string) error {
 	f, err := os.Open(filename)
 	if err != nil {
@@ -129,24 +126,47 @@ func LoadFromFile(filename string) error {
 	}
 	defer f.Close()
 
+	SessionsLock.Lock()
+	defer SessionsLock.Unlock()
+
 	dec := gob.NewDecoder(f)
 	if err := dec.Decode(&Sessions); err != nil {
 		return fmt.Errorf("failed to decode sessions from file: %v", err)
 	}
+	for _, session := range Sessions {
+		if err := DefaultStore.Set(session); err != nil {
+			return fmt.Errorf("failed to store session: %w", err)
+		}
+	}
 
 	return nil
 }
 
+/* StoreToFile writes all sessions from DefaultStore to file. DefaultStore must be able to enumerate its sessions, like MemoryStore or LogStore do. */
 func StoreToFile(filename string) error {
+	ranger, ok := DefaultStore.(interface {
+		Range(f func(session Session) bool)
+	})
+	if !ok {
+		return fmt.Errorf("failed to enumerate sessions: %T does not support it", DefaultStore)
+	}
+
 	f, err := os.Create(filename)
 	if err != nil {
 		return fmt.Errorf("failed to create sessions file %q: %v", filename, err)
 	}
 	defer f.Close()
 
-	enc := gob.NewEncoder(f)
 	SessionsLock.Lock()
 	defer SessionsLock.Unlock()
+
+	Sessions = make(map[string]Session)
+	ranger.Range(func(session Session) bool {
+		Sessions[session.Token] = session
+		return true
+	})
+
+	enc := gob.NewEncoder(f)
 	if err := enc.Encode(Sessions); err != nil {
 		return fmt.Errorf("failed to encode sessions to file: %v", err)
 	}
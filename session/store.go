@@ -0,0 +1,71 @@
+package session
+
+import (
+	"github.com/anton2920/gofa/database"
+	"github.com/anton2920/gofa/encoding/wire"
+	"github.com/anton2920/gofa/l10n"
+	"github.com/anton2920/gofa/log"
+	"github.com/anton2920/gofa/time"
+	"github.com/anton2920/gofa/time/time_"
+)
+
+/* Store keeps sessions by token. Implementations must be safe for concurrent use. */
+type Store interface {
+	Get(token string) (Session, bool)
+	Set(session Session) error
+
+	/* Touch updates expiry of existing session. It's called on hot path, so it must not block readers of other sessions. */
+	Touch(token string, expiry int64) error
+
+	Del(token string) error
+	DelUser(id database.ID) error
+
+	/* Expire removes sessions that expired before now and returns their number. */
+	Expire(now int64) (int, error)
+}
+
+const (
+	/* Lifetime is time since the last request after which session expires. */
+	Lifetime = time.Week
+
+	/* SlideInterval is how often expiry of active session is extended. Extending it on every request would write to store on every request. */
+	SlideInterval = time.Hour
+)
+
+/* DefaultStore is used by New, Get and other functions of this package. It must be set before serving requests. */
+var DefaultStore Store = NewMemoryStore()
+
+/* SweepExpired removes expired sessions from store every interval. It never returns, so it should be started in its own goroutine:
+ *     go session.SweepExpired(session.DefaultStore, time.Minute) */
+func SweepExpired(store Store, interval int64) {
+	for {
+		time_.Sleep(interval)
+
+		if _, err := store.Expire(time.Now()); err != nil {
+			log.Warnf("Failed to remove expired sessions: %v", err)
+		}
+	}
+}
+
+func putSession(s *wire.Serializer, session *Session) {
+	s.Begin(1)
+	s.Int32(int32(session.ID))
+	s.Int32(int32(session.Language))
+	s.Int32(int32(session.Timezone))
+	s.Int32(int32(session.ColorScheme))
+	s.Int64(session.Expiry)
+	s.String(session.Token)
+	s.End()
+}
+
+func getSession(d *wire.Deserializer, session *Session) error {
+	d.Begin()
+	session.ID = database.ID(d.Int32())
+	session.Language = l10n.Language(d.Int32())
+	session.Timezone = time.Timezone(d.Int32())
+	session.ColorScheme = ColorScheme(d.Int32())
+	session.Expiry = d.Int64()
+	/* NOTE(anton2920): Bytes are copied, because buffer may be reused by caller. */
+	session.Token = string(d.Bytes())
+	return d.End()
+}
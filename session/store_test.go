@@ -0,0 +1,280 @@
+package session
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/anton2920/gofa/database"
+	"github.com/anton2920/gofa/io/fs"
+)
+
+func openTestFile(t *testing.T, vfs fs.VFS) fs.VFile {
+	f, err := vfs.Open("/sessions", fs.OpenForReading|fs.OpenForWriting|fs.CreateFileIfItDoesNotExist)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	return f
+}
+
+func testStore(t *testing.T, store Store) {
+	for i := 0; i < 10; i++ {
+		session := Session{ID: database.ID(i % 3), Expiry: int64(100 + i), Token: fmt.Sprintf("token%d", i)}
+		session.ColorScheme = ColorSchemeDark
+		if err := store.Set(session); err != nil {
+			t.Fatalf("Failed to set session: %v", err)
+		}
+	}
+
+	session, ok := store.Get("token4")
+	if (!ok) || (session.ID != 1) || (session.Expiry != 104) || (session.ColorScheme != ColorSchemeDark) {
+		t.Errorf("Unexpected session %+v (%v)", session, ok)
+	}
+	if _, ok := store.Get("token10"); ok {
+		t.Errorf("Expected missing session")
+	}
+
+	if err := store.Touch("token0", 1000); err != nil {
+		t.Fatalf("Failed to touch session: %v", err)
+	}
+	if session, _ := store.Get("token0"); session.Expiry != 1000 {
+		t.Errorf("Expected expiry to be extended, got %d", session.Expiry)
+	}
+
+	/* Authorization changes ID of existing session. */
+	session, _ = store.Get("token2")
+	session.ID = 7
+	if err := store.Set(session); err != nil {
+		t.Fatalf("Failed to update session: %v", err)
+	}
+
+	if err := store.DelUser(2); err != nil {
+		t.Fatalf("Failed to remove sessions of user: %v", err)
+	}
+	for _, token := range [...]string{"token5", "token8"} {
+		if _, ok := store.Get(token); ok {
+			t.Errorf("Expected %s to be removed with its user", token)
+		}
+	}
+	if _, ok := store.Get("token2"); !ok {
+		t.Errorf("Expected re-authorized session to survive removal of its old user")
+	}
+
+	if err := store.Del("token1"); err != nil {
+		t.Fatalf("Failed to remove session: %v", err)
+	}
+	if _, ok := store.Get("token1"); ok {
+		t.Errorf("Expected token1 to be removed")
+	}
+
+	/* token0 was touched, token1, token5 and token8 are already removed, token6 expires exactly now. */
+	n, err := store.Expire(106)
+	if err != nil {
+		t.Fatalf("Failed to expire sessions: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("Expected 3 expired sessions, got %d", n)
+	}
+	for i, expected := range [...]bool{true, false, false, false, false, false, true, true, false, true} {
+		if _, ok := store.Get(fmt.Sprintf("token%d", i)); ok != expected {
+			t.Errorf("token%d: expected presence %v, got %v", i, expected, ok)
+		}
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestMemoryStoreConcurrent(t *testing.T) {
+	const goroutines = 8
+
+	var wg sync.WaitGroup
+
+	store := NewMemoryStore()
+	store.Set(Session{Token: "shared", Expiry: 1})
+
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				store.Touch("shared", int64(j))
+				store.Get("shared")
+				store.Set(Session{Token: fmt.Sprintf("%d-%d", i, j), Expiry: int64(j)})
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if n, _ := store.Expire(500); n != goroutines*500 {
+		t.Errorf("Expected %d expired sessions, got %d", goroutines*500, n)
+	}
+}
+
+func TestTreeStore(t *testing.T) {
+	store, err := NewTreeStore(openTestFile(t, fs.NewMemoryFS()))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	testStore(t, store)
+}
+
+func TestLogStore(t *testing.T) {
+	vfs := fs.NewMemoryFS()
+
+	store, err := NewLogStore(vfs, "/sessions")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	testStore(t, store)
+
+	/* Replaying log must produce the same sessions. */
+	replayed, err := NewLogStore(vfs, "/sessions")
+	if err != nil {
+		t.Fatalf("Failed to replay log: %v", err)
+	}
+	store.Range(func(session Session) bool {
+		if got, ok := replayed.Get(session.Token); (!ok) || (got != session) {
+			t.Errorf("Expected %+v after replay, got %+v", session, got)
+		}
+		return true
+	})
+
+	/* Torn record at the end is truncated. */
+	f := store.File
+	size, _ := f.Size()
+	if _, err := f.WriteAt([]byte{1, 0, 0, 0, 100, 0}, int64(size)); err != nil {
+		t.Fatalf("Failed to write garbage: %v", err)
+	}
+	if _, err := NewLogStore(vfs, "/sessions"); err != nil {
+		t.Fatalf("Failed to replay log with torn record: %v", err)
+	}
+	if newSize, _ := f.Size(); newSize != size {
+		t.Errorf("Expected log to be truncated to %d bytes, got %d", size, newSize)
+	}
+
+	for i := 0; i < 100; i++ {
+		store.Set(Session{Token: fmt.Sprintf("stale%d", i), Expiry: 1})
+	}
+	store.Del("token0")
+	stale, _ := f.Size()
+
+	if err := store.Compact(); err != nil {
+		t.Fatalf("Failed to compact log: %v", err)
+	}
+	compacted, _ := store.File.Size()
+	if compacted >= stale {
+		t.Errorf("Expected log to shrink, got %d bytes from %d", compacted, stale)
+	}
+
+	/* Records appended after compaction go to the new log. */
+	store.Set(Session{Token: "new", Expiry: 1})
+	replayed, err = NewLogStore(vfs, "/sessions")
+	if err != nil {
+		t.Fatalf("Failed to replay log: %v", err)
+	}
+	for token, expected := range map[string]bool{"token0": false, "stale99": true, "new": true} {
+		if _, ok := replayed.Get(token); ok != expected {
+			t.Errorf("%s: expected presence %v after compaction, got %v", token, expected, ok)
+		}
+	}
+}
+
+func TestLogStoreCompactCrash(t *testing.T) {
+	var committed []string
+	var deleted bool
+
+	workload := func(vfs fs.VFS) error {
+		committed = committed[:0]
+		deleted = false
+
+		store, err := NewLogStore(vfs, "/sessions")
+		if err != nil {
+			return err
+		}
+		for i := 0; i < 4; i++ {
+			token := fmt.Sprintf("token%d", i)
+			if err := store.Set(Session{Token: token, Expiry: 1}); err != nil {
+				return err
+			}
+			committed = append(committed, token)
+		}
+		/* NOTE(anton2920): presence of session is unknown, until Del returns. */
+		committed = committed[1:]
+		if err := store.Del("token0"); err != nil {
+			return err
+		}
+		deleted = true
+
+		if err := store.Compact(); err != nil {
+			return err
+		}
+		for _, token := range [...]string{"after", "last"} {
+			if err := store.Set(Session{Token: token, Expiry: 1}); err != nil {
+				return err
+			}
+			committed = append(committed, token)
+		}
+		return nil
+	}
+
+	check := func(vfs fs.VFS) error {
+		store, err := NewLogStore(vfs, "/sessions")
+		if err != nil {
+			return err
+		}
+		for _, token := range committed {
+			if _, ok := store.Get(token); !ok {
+				return fmt.Errorf("committed session %s is lost", token)
+			}
+		}
+		if _, ok := store.Get("token0"); (deleted) && (ok) {
+			return fmt.Errorf("removed session is back")
+		}
+		return nil
+	}
+
+	if err := fs.RunCrashTest(workload, check); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStoreToFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "sessions.gob")
+
+	saved := DefaultStore
+	defer func() { DefaultStore = saved }()
+
+	DefaultStore = NewMemoryStore()
+	DefaultStore.Set(Session{ID: 1, Expiry: 100, Token: "token0"})
+	DefaultStore.Set(Session{ID: 2, Expiry: 200, Token: "token1"})
+	if err := StoreToFile(filename); err != nil {
+		t.Fatalf("Failed to store sessions: %v", err)
+	}
+
+	DefaultStore = NewMemoryStore()
+	if err := LoadFromFile(filename); err != nil {
+		t.Fatalf("Failed to load sessions: %v", err)
+	}
+	if session, ok := DefaultStore.Get("token1"); (!ok) || (session.ID != 2) || (session.Expiry != 200) {
+		t.Errorf("Unexpected session %+v (%v)", session, ok)
+	}
+	if len(Sessions) != 2 {
+		t.Errorf("Expected 2 sessions in snapshot, got %d", len(Sessions))
+	}
+
+	store, err := NewTreeStore(openTestFile(t, fs.NewMemoryFS()))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	DefaultStore = store
+	if err := StoreToFile(filename); err == nil {
+		t.Errorf("Expected error for store without enumeration")
+	}
+	if _, err := os.Stat(filename); err != nil {
+		t.Errorf("Expected old file to be kept: %v", err)
+	}
+}
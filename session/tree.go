@@ -0,0 +1,237 @@
+package session
+
+import (
+	"fmt"
+
+	"github.com/anton2920/gofa/container/bplus"
+	"github.com/anton2920/gofa/database"
+	"github.com/anton2920/gofa/encoding/wire"
+	"github.com/anton2920/gofa/io/fs"
+	"github.com/anton2920/gofa/log"
+	"github.com/anton2920/gofa/trace/trace_"
+)
+
+/* TreeStore keeps sessions in B+ tree. Every session has two keys:
+ *     't' token        -> session
+ *     'u' user ID token -> empty, used by DelUser. */
+type TreeStore struct {
+	Tree *bplus.Tree
+}
+
+const (
+	treeStoreToken = 't'
+	treeStoreUser  = 'u'
+)
+
+var _ Store = new(TreeStore)
+
+func NewTreeStore(f fs.VFile) (*TreeStore, error) {
+	tree, err := bplus.OpenTreeAt(f, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sessions tree: %w", err)
+	}
+	return &TreeStore{Tree: tree}, nil
+}
+
+func treeStoreTokenKey(token string) []byte {
+	key := make([]byte, 0, 1+len(token))
+	key = append(key, treeStoreToken)
+	return append(key, token...)
+}
+
+func treeStoreUserKey(id database.ID, token string) []byte {
+	key := make([]byte, 0, 5+len(token))
+	key = append(key, treeStoreUser, byte(id>>24), byte(id>>16), byte(id>>8), byte(id))
+	return append(key, token...)
+}
+
+func (store *TreeStore) Get(token string) (Session, bool) {
+	defer trace_.End(trace_.Begin(""))
+
+	var session Session
+
+	value, err := store.Tree.Get(treeStoreTokenKey(token))
+	if err != nil {
+		log.Warnf("Failed to get session: %v", err)
+		return Session{}, false
+	}
+	if value == nil {
+		return Session{}, false
+	}
+	if err := getSession(&wire.Deserializer{Buffer: value}, &session); err != nil {
+		log.Warnf("Failed to decode session: %v", err)
+		return Session{}, false
+	}
+
+	return session, true
+}
+
+/* get reads session inside of transaction, so it cannot be removed concurrently. */
+func (store *TreeStore) get(tx *bplus.Tx, token string, session *Session) (bool, error) {
+	value, err := tx.Get(treeStoreTokenKey(token))
+	if (err != nil) || (value == nil) {
+		return false, err
+	}
+	return true, getSession(&wire.Deserializer{Buffer: value}, session)
+}
+
+func (store *TreeStore) set(tx *bplus.Tx, session *Session) error {
+	var s wire.Serializer
+
+	putSession(&s, session)
+	if err := tx.Set(treeStoreTokenKey(session.Token), s.Buffer); err != nil {
+		return err
+	}
+	return tx.Set(treeStoreUserKey(session.ID, session.Token), nil)
+}
+
+func (store *TreeStore) Set(session Session) error {
+	var old Session
+
+	tx, err := store.Tree.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	/* NOTE(anton2920): session may have been authorized, so its old user key must go. */
+	ok, err := store.get(tx, session.Token, &old)
+	if err != nil {
+		return fmt.Errorf("failed to get session: %w", err)
+	}
+	if (ok) && (old.ID != session.ID) {
+		if err := tx.Del(treeStoreUserKey(old.ID, old.Token)); err != nil {
+			return fmt.Errorf("failed to remove old user key: %w", err)
+		}
+	}
+	if err := store.set(tx, &session); err != nil {
+		return fmt.Errorf("failed to set session: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (store *TreeStore) Touch(token string, expiry int64) error {
+	defer trace_.End(trace_.Begin(""))
+
+	var session Session
+
+	tx, err := store.Tree.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	ok, err := store.get(tx, token, &session)
+	if (err != nil) || (!ok) {
+		return err
+	}
+	session.Expiry = expiry
+
+	if err := store.set(tx, &session); err != nil {
+		return fmt.Errorf("failed to set session: %w", err)
+	}
+	return tx.Commit()
+}
+
+func (store *TreeStore) del(tx *bplus.Tx, session *Session) error {
+	if err := tx.Del(treeStoreTokenKey(session.Token)); err != nil {
+		return err
+	}
+	return tx.Del(treeStoreUserKey(session.ID, session.Token))
+}
+
+func (store *TreeStore) Del(token string) error {
+	var session Session
+
+	tx, err := store.Tree.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	ok, err := store.get(tx, token, &session)
+	if (err != nil) || (!ok) {
+		return err
+	}
+
+	if err := store.del(tx, &session); err != nil {
+		return fmt.Errorf("failed to remove session: %w", err)
+	}
+	return tx.Commit()
+}
+
+func (store *TreeStore) DelUser(id database.ID) error {
+	var keys [][]byte
+
+	tx, err := store.Tree.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	/* NOTE(anton2920): no other writer can commit while tx is in progress, so iterator sees what tx sees. */
+	prefix := treeStoreUserKey(id, "")
+	it, err := store.Tree.Prefix(prefix)
+	if err != nil {
+		return fmt.Errorf("failed to iterate over sessions: %w", err)
+	}
+	for it.Next() {
+		keys = append(keys, append([]byte{}, it.Key()...))
+	}
+	it.Close()
+	if it.Error != nil {
+		return fmt.Errorf("failed to iterate over sessions: %w", it.Error)
+	}
+
+	for _, key := range keys {
+		session := Session{ID: id, Token: string(key[len(prefix):])}
+		if err := store.del(tx, &session); err != nil {
+			return fmt.Errorf("failed to remove session: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (store *TreeStore) Expire(now int64) (int, error) {
+	var expired []Session
+	var session Session
+
+	tx, err := store.Tree.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	it, err := store.Tree.Prefix([]byte{treeStoreToken})
+	if err != nil {
+		return 0, fmt.Errorf("failed to iterate over sessions: %w", err)
+	}
+	for it.Next() {
+		if err := getSession(&wire.Deserializer{Buffer: it.Value()}, &session); err != nil {
+			log.Warnf("Failed to decode session: %v", err)
+			continue
+		}
+		if now-session.Expiry > 0 {
+			expired = append(expired, session)
+		}
+	}
+	it.Close()
+	if it.Error != nil {
+		return 0, fmt.Errorf("failed to iterate over sessions: %w", it.Error)
+	}
+	if len(expired) == 0 {
+		return 0, nil
+	}
+
+	for i := 0; i < len(expired); i++ {
+		if err := store.del(tx, &expired[i]); err != nil {
+			return 0, fmt.Errorf("failed to remove session: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return len(expired), nil
+}
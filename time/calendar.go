@@ -0,0 +1,182 @@
+package time
+
+/* Calendar arithmetic works on wall time in Tm, which is then converted to Unix time with FromTmIn, so that adding a day or a month keeps time of day across DST transitions. */
+
+/* dayNumber returns number of days since 1970-01-01. */
+func (tm Tm) dayNumber() int64 {
+	year := int64(tm.Year) + 1900 + int64(tm.Mon)/12
+	mon := tm.Mon % 12
+	if mon < 0 {
+		mon += 12
+		year--
+	}
+	return daysSinceEpoch(year, mon+1) + int64(tm.Mday) - 1
+}
+
+/* Normalize brings all fields into their ranges and recomputes Wday and Yday. Result is wall time: Isdst is unknown, Gmtoff and Zone are cleared. */
+func (tm Tm) Normalize() Tm {
+	tm.Gmtoff = 0
+	return ToTm(FromTm(tm))
+}
+
+func (tm Tm) AddDays(n int) Tm {
+	tm.Mday += n
+	return tm.Normalize()
+}
+
+/* AddMonths adds n calendar months. If there is no such day in the resulting month, the last day of month is used, e.g. January 31 + 1 month is February 28 or 29. */
+func (tm Tm) AddMonths(n int) Tm {
+	months := (tm.Year+1900)*12 + tm.Mon + n
+	year := months / 12
+	mon := months % 12
+	if mon < 0 {
+		mon += 12
+		year--
+	}
+
+	tm.Year = year - 1900
+	tm.Mon = mon
+	if days := daysInMonth(int64(year), mon+1); tm.Mday > days {
+		tm.Mday = days
+	}
+	return tm.Normalize()
+}
+
+func (tm Tm) AddYears(n int) Tm {
+	return tm.AddMonths(n * 12)
+}
+
+/* AddWeekdays adds n working days, skipping Saturdays and Sundays. If tm is on weekend, the first step goes to the nearest working day. */
+func (tm Tm) AddWeekdays(n int) Tm {
+	step := 1
+	if n < 0 {
+		step = -1
+		n = -n
+	}
+
+	var days int
+	wday := tm.Wday
+	for i := 0; i < n; i++ {
+		days += step
+		wday = (wday + step + 7) % 7
+		for (wday == 0) || (wday == 6) {
+			days += step
+			wday = (wday + step + 7) % 7
+		}
+	}
+
+	return tm.AddDays(days)
+}
+
+/* NextWeekday returns the first day after tm, which is wday (0 is Sunday). */
+func (tm Tm) NextWeekday(wday int) Tm {
+	days := (wday - tm.Wday + 7) % 7
+	if days == 0 {
+		days = 7
+	}
+	return tm.AddDays(days)
+}
+
+/* PrevWeekday returns the last day before tm, which is wday (0 is Sunday). */
+func (tm Tm) PrevWeekday(wday int) Tm {
+	days := (tm.Wday - wday + 7) % 7
+	if days == 0 {
+		days = 7
+	}
+	return tm.AddDays(-days)
+}
+
+/* DaysBetween returns number of days from a to b, ignoring time of day. */
+func DaysBetween(a, b Tm) int {
+	return int(b.dayNumber() - a.dayNumber())
+}
+
+func (tm Tm) DaysInMonth() int {
+	return daysInMonth(int64(tm.Year)+1900, tm.Mon+1)
+}
+
+func (tm Tm) IsLeapYear() bool {
+	return isLeap(int64(tm.Year) + 1900)
+}
+
+/* ISOWeek returns ISO 8601 week-based year and week number [1-53]. Weeks start on Monday, the first week of year contains its first Thursday. */
+func (tm Tm) ISOWeek() (year, week int) {
+	return isoWeek(&tm)
+}
+
+/* ISOWeekday returns day of week, where Monday is 1 and Sunday is 7. */
+func (tm Tm) ISOWeekday() int {
+	return (tm.Wday+6)%7 + 1
+}
+
+func midnight(tm Tm) Tm {
+	tm.Hour = 0
+	tm.Min = 0
+	tm.Sec = 0
+	return tm
+}
+
+/* StartOfDay returns the first moment of day containing t in tz. All Start* and End* functions use Unix time in nanoseconds and return half-open range [Start, End), so End is the start of the next period. */
+func StartOfDay(t int64, tz Timezone) int64 {
+	return FromTmIn(midnight(ToTmIn(t, tz)), tz)
+}
+
+func EndOfDay(t int64, tz Timezone) int64 {
+	return FromTmIn(midnight(ToTmIn(t, tz)).AddDays(1), tz)
+}
+
+/* StartOfWeek returns the first moment of week containing t in tz. Week starts on first day of week (0 is Sunday, 1 is Monday). */
+func StartOfWeek(t int64, tz Timezone, first int) int64 {
+	tm := midnight(ToTmIn(t, tz))
+	return FromTmIn(tm.AddDays(-((tm.Wday - first + 7) % 7)), tz)
+}
+
+func EndOfWeek(t int64, tz Timezone, first int) int64 {
+	tm := midnight(ToTmIn(t, tz))
+	return FromTmIn(tm.AddDays(7-(tm.Wday-first+7)%7), tz)
+}
+
+func StartOfMonth(t int64, tz Timezone) int64 {
+	tm := midnight(ToTmIn(t, tz))
+	tm.Mday = 1
+	return FromTmIn(tm, tz)
+}
+
+func EndOfMonth(t int64, tz Timezone) int64 {
+	tm := midnight(ToTmIn(t, tz))
+	tm.Mday = 1
+	return FromTmIn(tm.AddMonths(1), tz)
+}
+
+func StartOfYear(t int64, tz Timezone) int64 {
+	tm := midnight(ToTmIn(t, tz))
+	tm.Mon = 0
+	tm.Mday = 1
+	return FromTmIn(tm, tz)
+}
+
+func EndOfYear(t int64, tz Timezone) int64 {
+	tm := midnight(ToTmIn(t, tz))
+	tm.Mon = 0
+	tm.Mday = 1
+	return FromTmIn(tm.AddYears(1), tz)
+}
+
+/* fraction returns nanoseconds since the start of second, which Tm does not keep. */
+func fraction(t int64) int64 {
+	if ns := t % Second; ns < 0 {
+		return ns + Second
+	} else {
+		return ns
+	}
+}
+
+/* AddMonthsIn adds n calendar months to t in tz, keeping time of day. See Tm.AddMonths. */
+func AddMonthsIn(t int64, n int, tz Timezone) int64 {
+	return FromTmIn(ToTmIn(t, tz).AddMonths(n), tz) + fraction(t)
+}
+
+/* AddDaysIn adds n calendar days to t in tz, keeping time of day, so the result may differ from t+n*Day by DST shift. */
+func AddDaysIn(t int64, n int, tz Timezone) int64 {
+	return FromTmIn(ToTmIn(t, tz).AddDays(n), tz) + fraction(t)
+}
@@ -0,0 +1,174 @@
+package time
+
+import (
+	"os"
+	"testing"
+	stdtime "time"
+)
+
+func date(year, mon, mday int) Tm {
+	return Tm{Year: year - 1900, Mon: mon - 1, Mday: mday}.Normalize()
+}
+
+func TestAddMonths(t *testing.T) {
+	tests := [...]struct {
+		Tm       Tm
+		Months   int
+		Expected Tm
+	}{
+		{date(2024, 1, 31), 1, date(2024, 2, 29)},
+		{date(2023, 1, 31), 1, date(2023, 2, 28)},
+		{date(2023, 3, 31), -1, date(2023, 2, 28)},
+		{date(2023, 12, 15), 1, date(2024, 1, 15)},
+		{date(2023, 1, 15), -1, date(2022, 12, 15)},
+		{date(2023, 5, 31), -13, date(2022, 4, 30)},
+		{date(2023, 8, 31), 25, date(2025, 9, 30)},
+		{date(1969, 12, 31), 2, date(1970, 2, 28)},
+	}
+	for _, test := range tests {
+		if got := test.Tm.AddMonths(test.Months); got != test.Expected {
+			t.Errorf("%+v + %d months: expected %+v, got %+v", test.Tm, test.Months, test.Expected, got)
+		}
+	}
+
+	if got := date(2024, 2, 29).AddYears(1); got != date(2025, 2, 28) {
+		t.Errorf("Expected 2025-02-28, got %+v", got)
+	}
+	if got := date(2024, 2, 29).AddYears(4); got != date(2028, 2, 29) {
+		t.Errorf("Expected 2028-02-29, got %+v", got)
+	}
+}
+
+func TestCalendarDays(t *testing.T) {
+	start := stdtime.Date(1900, 1, 1, 0, 0, 0, 0, stdtime.UTC)
+	for d := start; d.Year() < 2101; d = d.AddDate(0, 0, 1) {
+		tm := ToTm(d.Unix() * Second)
+
+		year, week := tm.ISOWeek()
+		expectedYear, expectedWeek := d.ISOWeek()
+		if (year != expectedYear) || (week != expectedWeek) {
+			t.Fatalf("%v: expected week %d-%d, got %d-%d", d, expectedYear, expectedWeek, year, week)
+		}
+		if wday := tm.ISOWeekday(); (wday < 1) || (wday > 7) || (wday%7 != int(d.Weekday())) {
+			t.Fatalf("%v: wrong ISO weekday %d", d, wday)
+		}
+		if days := DaysBetween(date(1900, 1, 1), tm); days != int(d.Sub(start).Hours()/24) {
+			t.Fatalf("%v: wrong number of days since 1900-01-01: %d", d, days)
+		}
+		if n := tm.DaysInMonth(); n != d.AddDate(0, 1, -d.Day()).Day() {
+			t.Fatalf("%v: wrong number of days in month: %d", d, n)
+		}
+
+		for _, n := range [...]int{-400, -31, -1, 1, 29, 366} {
+			expected := d.AddDate(0, 0, n)
+			if got := tm.AddDays(n); got != ToTm(expected.Unix()*Second) {
+				t.Fatalf("%v + %d days: expected %v, got %+v", d, n, expected, got)
+			}
+		}
+	}
+}
+
+func TestWeekdays(t *testing.T) {
+	tests := [...]struct {
+		Tm       Tm
+		Days     int
+		Expected Tm
+	}{
+		{date(2024, 3, 1), 0, date(2024, 3, 1)},   /* Friday. */
+		{date(2024, 3, 1), 1, date(2024, 3, 4)},   /* Monday. */
+		{date(2024, 3, 1), 5, date(2024, 3, 8)},   /* Friday. */
+		{date(2024, 3, 4), -1, date(2024, 3, 1)},  /* Friday. */
+		{date(2024, 3, 4), -5, date(2024, 2, 26)}, /* Monday. */
+		{date(2024, 3, 2), 1, date(2024, 3, 4)},   /* Saturday to Monday. */
+		{date(2024, 3, 3), -1, date(2024, 3, 1)},  /* Sunday to Friday. */
+		{date(2024, 3, 2), 10, date(2024, 3, 15)}, /* Saturday to Friday. */
+	}
+	for _, test := range tests {
+		if got := test.Tm.AddWeekdays(test.Days); got != test.Expected {
+			t.Errorf("%+v + %d weekdays: expected %+v, got %+v", test.Tm, test.Days, test.Expected, got)
+		}
+	}
+
+	friday := date(2024, 3, 1)
+	if got := friday.NextWeekday(5); got != date(2024, 3, 8) {
+		t.Errorf("Expected next Friday to be 2024-03-08, got %+v", got)
+	}
+	if got := friday.NextWeekday(1); got != date(2024, 3, 4) {
+		t.Errorf("Expected next Monday to be 2024-03-04, got %+v", got)
+	}
+	if got := friday.PrevWeekday(5); got != date(2024, 2, 23) {
+		t.Errorf("Expected previous Friday to be 2024-02-23, got %+v", got)
+	}
+	if got := friday.PrevWeekday(0); got != date(2024, 2, 25) {
+		t.Errorf("Expected previous Sunday to be 2024-02-25, got %+v", got)
+	}
+}
+
+func TestStartEndIn(t *testing.T) {
+	if _, err := os.Stat(ZoneinfoDir); err != nil {
+		t.Skipf("No zoneinfo: %v", err)
+	}
+
+	for _, name := range [...]string{"Europe/Berlin", "America/New_York", "Australia/Lord_Howe"} {
+		tz, err := LoadZone(name)
+		if err != nil {
+			t.Fatalf("Failed to load zone: %v", err)
+		}
+		loc, err := stdtime.LoadLocation(name)
+		if err != nil {
+			t.Fatalf("Failed to load location: %v", err)
+		}
+
+		const step = 7919 * 13
+		for sec := int64(946684800); sec < 1893456000; sec += step {
+			d := stdtime.Unix(sec, 123).In(loc)
+			now := sec*Second + 123
+
+			day := stdtime.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, loc)
+			week := stdtime.Date(d.Year(), d.Month(), d.Day()-(int(d.Weekday())+6)%7, 0, 0, 0, 0, loc)
+			month := stdtime.Date(d.Year(), d.Month(), 1, 0, 0, 0, 0, loc)
+			year := stdtime.Date(d.Year(), 1, 1, 0, 0, 0, 0, loc)
+
+			tests := [...]struct {
+				Name     string
+				Got      int64
+				Expected stdtime.Time
+			}{
+				{"StartOfDay", StartOfDay(now, tz), day},
+				{"EndOfDay", EndOfDay(now, tz), day.AddDate(0, 0, 1)},
+				{"StartOfWeek", StartOfWeek(now, tz, 1), week},
+				{"EndOfWeek", EndOfWeek(now, tz, 1), week.AddDate(0, 0, 7)},
+				{"StartOfMonth", StartOfMonth(now, tz), month},
+				{"EndOfMonth", EndOfMonth(now, tz), month.AddDate(0, 1, 0)},
+				{"StartOfYear", StartOfYear(now, tz), year},
+				{"EndOfYear", EndOfYear(now, tz), year.AddDate(1, 0, 0)},
+			}
+			for _, test := range tests {
+				if test.Got != test.Expected.UnixNano() {
+					t.Fatalf("%s %s of %v: expected %v, got %v", name, test.Name, d, test.Expected, stdtime.Unix(0, test.Got).In(loc))
+				}
+			}
+
+			/* NOTE(anton2920): standard library resolves times in DST gap and repeated times differently and does not clamp months, so such cases are skipped. */
+			month = d.AddDate(0, 1, 0)
+			for _, test := range [...]struct {
+				Name     string
+				Got      int64
+				Expected stdtime.Time
+				Skip     bool
+			}{
+				{"AddDaysIn", AddDaysIn(now, 3, tz), d.AddDate(0, 0, 3), false},
+				{"AddMonthsIn", AddMonthsIn(now, 1, tz), month, month.Day() != d.Day()},
+			} {
+				_, before := test.Expected.Add(-3 * stdtime.Hour).Zone()
+				_, after := test.Expected.Add(3 * stdtime.Hour).Zone()
+				if (!test.Skip) && (before == after) && (test.Got != test.Expected.UnixNano()) {
+					t.Fatalf("%s %s of %v: expected %v, got %v", name, test.Name, d, test.Expected, stdtime.Unix(0, test.Got).In(loc))
+				}
+			}
+			if (StartOfDay(now, tz) > now) || (EndOfDay(now, tz) <= now) {
+				t.Fatalf("%s: %v is not within its day", name, d)
+			}
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package time
 
+/* Month and Year are fixed approximations, use Tm.AddMonths and Tm.AddYears for calendar arithmetic. */
+
 const (
 	Nanosecond  = 1
 	Microsecond = 1000 * Nanosecond
@@ -0,0 +1,393 @@
+package time
+
+import "errors"
+
+var (
+	InvalidFormat = errors.New("invalid time format")
+	InvalidValue  = errors.New("time value out of range")
+)
+
+/* parseDigits parses exactly n digits at the beginning of s. */
+func parseDigits(s string, n int) (int, string, bool) {
+	var x int
+
+	if len(s) < n {
+		return 0, s, false
+	}
+	for i := 0; i < n; i++ {
+		if (s[i] < '0') || (s[i] > '9') {
+			return 0, s, false
+		}
+		x = x*10 + int(s[i]-'0')
+	}
+	return x, s[n:], true
+}
+
+/* parseDigitsVariable parses from min to max digits at the beginning of s. */
+func parseDigitsVariable(s string, min, max int) (int, string, bool) {
+	var x, i int
+
+	for (i < len(s)) && (i < max) && (s[i] >= '0') && (s[i] <= '9') {
+		x = x*10 + int(s[i]-'0')
+		i++
+	}
+	return x, s[i:], i >= min
+}
+
+func parseByte(s string, c byte) (string, bool) {
+	if (len(s) == 0) || (s[0] != c) {
+		return s, false
+	}
+	return s[1:], true
+}
+
+func parseFold(s string, prefix string) (string, bool) {
+	if len(s) < len(prefix) {
+		return s, false
+	}
+	for i := 0; i < len(prefix); i++ {
+		if s[i]|0x20 != prefix[i]|0x20 {
+			return s, false
+		}
+	}
+	return s[len(prefix):], true
+}
+
+/* parseName parses English month or day of week name, full or abbreviated to three letters. */
+func parseName(s string, names []string, full bool) (int, string, bool) {
+	for i := 0; i < len(names); i++ {
+		if full {
+			if rest, ok := parseFold(s, names[i]); ok {
+				return i, rest, true
+			}
+		} else {
+			if rest, ok := parseFold(s, names[i][:3]); ok {
+				return i, rest, true
+			}
+		}
+	}
+	return 0, s, false
+}
+
+/* parseDate parses 'yyyy-mm-dd'. */
+func parseDate(s string, tm *Tm) (string, error) {
+	var year int
+	var ok bool
+
+	if year, s, ok = parseDigits(s, 4); !ok {
+		return s, InvalidFormat
+	}
+	if s, ok = parseByte(s, '-'); !ok {
+		return s, InvalidFormat
+	}
+	if tm.Mon, s, ok = parseDigits(s, 2); !ok {
+		return s, InvalidFormat
+	}
+	if s, ok = parseByte(s, '-'); !ok {
+		return s, InvalidFormat
+	}
+	if tm.Mday, s, ok = parseDigits(s, 2); !ok {
+		return s, InvalidFormat
+	}
+
+	if (tm.Mon < 1) || (tm.Mon > 12) || (tm.Mday < 1) || (tm.Mday > daysInMonth(int64(year), tm.Mon)) {
+		return s, InvalidValue
+	}
+	tm.Year = year - 1900
+	tm.Mon--
+
+	return s, nil
+}
+
+/* parseTime parses 'hh:mm[:ss[.fraction]]' and returns nanoseconds of fraction. If seconds are required, they are not optional. */
+func parseTime(s string, tm *Tm, seconds bool) (string, int64, error) {
+	var ns int64
+	var ok bool
+
+	if tm.Hour, s, ok = parseDigits(s, 2); !ok {
+		return s, 0, InvalidFormat
+	}
+	if s, ok = parseByte(s, ':'); !ok {
+		return s, 0, InvalidFormat
+	}
+	if tm.Min, s, ok = parseDigits(s, 2); !ok {
+		return s, 0, InvalidFormat
+	}
+
+	if rest, ok := parseByte(s, ':'); ok {
+		if tm.Sec, s, ok = parseDigits(rest, 2); !ok {
+			return s, 0, InvalidFormat
+		}
+		if rest, ok := parseByte(s, '.'); ok {
+			var i int
+
+			scale := int64(Second)
+			for (i < len(rest)) && (rest[i] >= '0') && (rest[i] <= '9') {
+				/* NOTE(anton2920): digits beyond nanoseconds are ignored. */
+				if scale > 1 {
+					scale /= 10
+					ns += int64(rest[i]-'0') * scale
+				}
+				i++
+			}
+			if i == 0 {
+				return s, 0, InvalidFormat
+			}
+			s = rest[i:]
+		}
+	} else if seconds {
+		return s, 0, InvalidFormat
+	}
+
+	/* NOTE(anton2920): 60 is leap second, it's normalized to the next minute. */
+	if (tm.Hour > 23) || (tm.Min > 59) || (tm.Sec > 60) {
+		return s, 0, InvalidValue
+	}
+
+	return s, ns, nil
+}
+
+/* parseOffset parses '+hh:mm' or '+hhmm' into seconds. */
+func parseOffset(s string, colon bool) (int, string, error) {
+	var h, m int
+	var ok bool
+
+	if len(s) == 0 {
+		return 0, s, InvalidFormat
+	}
+	sign := 1
+	switch s[0] {
+	default:
+		return 0, s, InvalidFormat
+	case '+':
+	case '-':
+		sign = -1
+	}
+	s = s[1:]
+
+	if h, s, ok = parseDigits(s, 2); !ok {
+		return 0, s, InvalidFormat
+	}
+	if colon {
+		if s, ok = parseByte(s, ':'); !ok {
+			return 0, s, InvalidFormat
+		}
+	}
+	if m, s, ok = parseDigits(s, 2); !ok {
+		return 0, s, InvalidFormat
+	}
+	if (h > 23) || (m > 59) {
+		return 0, s, InvalidValue
+	}
+
+	return sign * (h*3600 + m*60), s, nil
+}
+
+/* ParseRFC3339 parses time like '2006-01-02T15:04:05.999999999+07:00' and returns Unix time in nanoseconds. */
+func ParseRFC3339(s string) (int64, error) {
+	var tm Tm
+	var ns int64
+	var err error
+	var ok bool
+
+	if s, err = parseDate(s, &tm); err != nil {
+		return 0, err
+	}
+	if (len(s) == 0) || ((s[0] != 'T') && (s[0] != 't') && (s[0] != ' ')) {
+		return 0, InvalidFormat
+	}
+	if s, ns, err = parseTime(s[1:], &tm, true); err != nil {
+		return 0, err
+	}
+
+	if len(s) == 0 {
+		return 0, InvalidFormat
+	}
+	if s, ok = parseFold(s, "Z"); !ok {
+		if tm.Gmtoff, s, err = parseOffset(s, true); err != nil {
+			return 0, err
+		}
+	}
+	if len(s) > 0 {
+		return 0, InvalidFormat
+	}
+
+	return fromTmChecked(tm, ns)
+}
+
+/* parseZone parses zone of RFC 822 date into seconds. */
+func parseZone(s string) (int, string, error) {
+	for _, zone := range [...]string{"GMT", "UTC", "UT", "Z"} {
+		if rest, ok := parseFold(s, zone); ok {
+			return 0, rest, nil
+		}
+	}
+	return parseOffset(s, false)
+}
+
+/* ParseRFC822 parses dates allowed in HTTP headers and cookies and returns Unix time in nanoseconds:
+ *     Sun, 06 Nov 1994 08:49:37 GMT  ; RFC 822, updated by RFC 1123
+ *     Sunday, 06-Nov-94 08:49:37 GMT ; RFC 850
+ *     Sun Nov  6 08:49:37 1994       ; asctime()
+ * Day of week is optional and not checked, zone can also be numeric. */
+func ParseRFC822(s string) (int64, error) {
+	var tm Tm
+	var year int
+	var err error
+	var ok bool
+
+	var asctime bool
+	if _, rest, ok := parseName(s, CNames.Wdays[:], true); ok {
+		/* RFC 850. */
+		s = rest
+	} else if _, rest, ok := parseName(s, CNames.Wdays[:], false); ok {
+		s = rest
+		asctime = (len(s) > 0) && (s[0] == ' ')
+	}
+	if rest, ok := parseByte(s, ','); ok {
+		s = rest
+	}
+	if (len(s) > 0) && (s[0] == ' ') {
+		s = s[1:]
+	}
+
+	if asctime {
+		if tm.Mon, s, ok = parseName(s, CNames.Months[:], false); !ok {
+			return 0, InvalidFormat
+		}
+		if s, ok = parseByte(s, ' '); !ok {
+			return 0, InvalidFormat
+		}
+		if rest, ok := parseByte(s, ' '); ok {
+			s = rest
+		}
+		if tm.Mday, s, ok = parseDigitsVariable(s, 1, 2); !ok {
+			return 0, InvalidFormat
+		}
+		if s, ok = parseByte(s, ' '); !ok {
+			return 0, InvalidFormat
+		}
+		if s, _, err = parseTime(s, &tm, true); err != nil {
+			return 0, err
+		}
+		if s, ok = parseByte(s, ' '); !ok {
+			return 0, InvalidFormat
+		}
+		if year, s, ok = parseDigits(s, 4); !ok {
+			return 0, InvalidFormat
+		}
+	} else {
+		if tm.Mday, s, ok = parseDigitsVariable(s, 1, 2); !ok {
+			return 0, InvalidFormat
+		}
+
+		sep := byte(' ')
+		if (len(s) > 0) && (s[0] == '-') {
+			sep = '-'
+		}
+		if s, ok = parseByte(s, sep); !ok {
+			return 0, InvalidFormat
+		}
+		if tm.Mon, s, ok = parseName(s, CNames.Months[:], false); !ok {
+			return 0, InvalidFormat
+		}
+		if s, ok = parseByte(s, sep); !ok {
+			return 0, InvalidFormat
+		}
+
+		rest := s
+		if year, s, ok = parseDigitsVariable(s, 2, 4); (!ok) || (len(rest)-len(s) == 3) {
+			return 0, InvalidFormat
+		}
+		if len(rest)-len(s) == 2 {
+			/* NOTE(anton2920): see RFC 2822, section 4.3. */
+			if year < 50 {
+				year += 2000
+			} else {
+				year += 1900
+			}
+		}
+
+		if s, ok = parseByte(s, ' '); !ok {
+			return 0, InvalidFormat
+		}
+		if s, _, err = parseTime(s, &tm, false); err != nil {
+			return 0, err
+		}
+		if s, ok = parseByte(s, ' '); !ok {
+			return 0, InvalidFormat
+		}
+		if tm.Gmtoff, s, err = parseZone(s); err != nil {
+			return 0, err
+		}
+	}
+	if len(s) > 0 {
+		return 0, InvalidFormat
+	}
+
+	if (tm.Mday < 1) || (tm.Mday > daysInMonth(int64(year), tm.Mon+1)) {
+		return 0, InvalidValue
+	}
+	tm.Year = year - 1900
+
+	return FromTmChecked(tm)
+}
+
+/* ParseDate parses value of <input type="date">, e.g. '2006-01-02'. All fields of Tm are filled, time is midnight. */
+func ParseDate(s string) (Tm, error) {
+	var tm Tm
+
+	s, err := parseDate(s, &tm)
+	if err != nil {
+		return Tm{}, err
+	}
+	if len(s) > 0 {
+		return Tm{}, InvalidFormat
+	}
+
+	t, err := FromTmChecked(tm)
+	if err != nil {
+		return Tm{}, err
+	}
+	return ToTm(t), nil
+}
+
+/* ParseTime parses value of <input type="time">, e.g. '15:04', '15:04:05' or '15:04:05.999'. Fractions of second are ignored. */
+func ParseTime(s string) (Tm, error) {
+	var tm Tm
+
+	s, _, err := parseTime(s, &tm, false)
+	if err != nil {
+		return Tm{}, err
+	}
+	if len(s) > 0 {
+		return Tm{}, InvalidFormat
+	}
+
+	return tm, nil
+}
+
+/* ParseDateTimeLocal parses value of <input type="datetime-local">, e.g. '2006-01-02T15:04'. It has no time zone, FromTmIn converts it to Unix time. Fractions of second are ignored. */
+func ParseDateTimeLocal(s string) (Tm, error) {
+	var tm Tm
+
+	s, err := parseDate(s, &tm)
+	if err != nil {
+		return Tm{}, err
+	}
+	if (len(s) == 0) || ((s[0] != 'T') && (s[0] != ' ')) {
+		return Tm{}, InvalidFormat
+	}
+	if s, _, err = parseTime(s[1:], &tm, false); err != nil {
+		return Tm{}, err
+	}
+	if len(s) > 0 {
+		return Tm{}, InvalidFormat
+	}
+
+	t, err := FromTmChecked(tm)
+	if err != nil {
+		return Tm{}, err
+	}
+	return ToTm(t), nil
+}
@@ -0,0 +1,253 @@
+package time
+
+import (
+	"testing"
+	stdtime "time"
+)
+
+func TestFromTm(t *testing.T) {
+	for sec := int64(-2208988800); sec < 4102444800; sec += 7919 * 61 {
+		if got := FromTm(ToTm(sec * Second)); got != sec*Second {
+			t.Fatalf("Expected %d, got %d", sec*Second, got)
+		}
+	}
+
+	/* Normalization. */
+	tm := Tm{Year: 124, Mon: 13, Mday: 0, Hour: 25, Min: -1, Sec: 60}
+	expected := stdtime.Date(2024, 14, 0, 25, -1, 60, 0, stdtime.UTC).Unix() * Second
+	if got := FromTm(tm); got != expected {
+		t.Errorf("Expected %d, got %d", expected, got)
+	}
+
+	tm = Tm{Year: 70, Mday: 1, Hour: 3, Gmtoff: 3 * 3600}
+	if got := FromTm(tm); got != 0 {
+		t.Errorf("Expected offset to be subtracted, got %d", got)
+	}
+}
+
+func TestFromTmChecked(t *testing.T) {
+	tests := [...]struct {
+		Tm       Tm
+		Expected int64
+		Error    error
+	}{
+		{Tm{Year: 70, Mday: 1}, 0, nil},
+		{Tm{Year: 362, Mon: 3, Mday: 11, Hour: 23, Min: 47, Sec: 16}, MaxTime / Second * Second, nil},
+		{Tm{Year: 362, Mon: 3, Mday: 11, Hour: 23, Min: 47, Sec: 17}, 0, InvalidValue},
+		{Tm{Year: -223, Mon: 8, Mday: 21, Hour: 0, Min: 12, Sec: 44}, MinTime / Second * Second, nil},
+		{Tm{Year: -223, Mon: 8, Mday: 21, Hour: 0, Min: 12, Sec: 43}, 0, InvalidValue},
+		{Tm{Year: 1 << 40, Mday: 1}, 0, InvalidValue},
+		{Tm{Year: 70, Mon: -1 << 40, Mday: 1}, 0, InvalidValue},
+		{Tm{Year: 70, Mday: 213503982334602}, 0, InvalidValue},
+		{Tm{Year: 70, Mday: 1, Hour: 1 << 60}, 0, InvalidValue},
+		{Tm{Year: 70, Mday: 1, Sec: -1 << 62}, 0, InvalidValue},
+		{Tm{Year: 70, Mday: 1, Gmtoff: 1 << 62}, 0, InvalidValue},
+		{Tm{Year: 70, Mday: 1, Min: 153722868}, 0, InvalidValue},
+		{Tm{Year: 70, Mday: 1, Min: 153722868, Sec: -60}, 153722867 * Minute, nil},
+	}
+	for _, test := range tests {
+		got, err := FromTmChecked(test.Tm)
+		if (got != test.Expected) || (err != test.Error) {
+			t.Errorf("%+v: expected %d (%v), got %d (%v)", test.Tm, test.Expected, test.Error, got, err)
+		}
+	}
+}
+
+func TestFromTmIn(t *testing.T) {
+	tz, err := LoadZone("Europe/Berlin")
+	if err != nil {
+		t.Skipf("No zoneinfo: %v", err)
+	}
+
+	tests := [...]struct {
+		Local    string
+		Expected string
+	}{
+		{"2024-07-01T12:00", "2024-07-01T10:00:00Z"},
+		{"2024-01-01T12:00", "2024-01-01T11:00:00Z"},
+		/* Skipped by transition to DST. */
+		{"2024-03-31T02:30", "2024-03-31T01:30:00Z"},
+		/* Repeated by transition from DST. */
+		{"2024-10-27T02:30", "2024-10-27T00:30:00Z"},
+	}
+	for _, test := range tests {
+		tm, err := ParseDateTimeLocal(test.Local)
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %v", test.Local, err)
+		}
+		expected, _ := ParseRFC3339(test.Expected)
+		if got := FromTmIn(tm, tz); got != expected {
+			t.Errorf("%s: expected %d, got %d", test.Local, expected, got)
+		}
+	}
+}
+
+func TestParseRFC3339(t *testing.T) {
+	tests := [...]struct {
+		Input    string
+		Expected int64
+	}{
+		{"2006-01-02T15:04:05Z", 1136214245 * Second},
+		{"2006-01-02T15:04:05+07:00", (1136214245 - 7*3600) * Second},
+		{"2006-01-02t15:04:05.123z", 1136214245*Second + 123*Millisecond},
+		{"2006-01-02 15:04:05.999999999-11:30", (1136214245+11*3600+30*60)*Second + 999999999},
+		{"1900-02-28T00:00:00.1Z", -2203977600*Second + 100*Millisecond},
+		{"2024-02-29T23:59:59Z", 1709251199 * Second},
+		{"2262-04-11T23:47:16.854775807Z", MaxTime},
+		{"1677-09-21T00:12:43.145224192Z", MinTime},
+	}
+	for _, test := range tests {
+		got, err := ParseRFC3339(test.Input)
+		if err != nil {
+			t.Errorf("%q: failed to parse: %v", test.Input, err)
+			continue
+		}
+		if got != test.Expected {
+			t.Errorf("%q: expected %d, got %d", test.Input, test.Expected, got)
+		}
+		if expected, err := stdtime.Parse(stdtime.RFC3339Nano, test.Input); (err == nil) && (expected.UnixNano() != got) {
+			t.Errorf("%q: expected %d like Go, got %d", test.Input, expected.UnixNano(), got)
+		}
+	}
+
+	if got, _ := ParseRFC3339("2016-12-31T23:59:60Z"); got != 1483228800*Second {
+		t.Errorf("Expected leap second to be normalized, got %d", got)
+	}
+	if got, _ := ParseRFC3339("2006-01-02T15:04:05.1234567891Z"); got%Second != 123456789 {
+		t.Errorf("Expected extra digits to be ignored, got %d", got%Second)
+	}
+
+	for _, test := range [...]struct {
+		Input    string
+		Expected error
+	}{
+		{"", InvalidFormat},
+		{"2006-01-02", InvalidFormat},
+		{"2006-01-02T15:04Z", InvalidFormat},
+		{"2006-01-02T15:04:05", InvalidFormat},
+		{"2006-01-02T15:04:05.Z", InvalidFormat},
+		{"2006-01-02T15:04:05+0700", InvalidFormat},
+		{"2006-01-02T15:04:05Zjunk", InvalidFormat},
+		{"06-01-02T15:04:05Z", InvalidFormat},
+		{"2006-13-02T15:04:05Z", InvalidValue},
+		{"2023-02-29T15:04:05Z", InvalidValue},
+		{"2006-01-02T24:04:05Z", InvalidValue},
+		{"2006-01-02T15:60:05Z", InvalidValue},
+		{"2006-01-02T15:04:61Z", InvalidValue},
+		{"2006-01-02T15:04:05+24:00", InvalidValue},
+		{"2262-04-11T23:47:16.854775808Z", InvalidValue},
+		{"2262-04-11T23:47:17Z", InvalidValue},
+		{"1677-09-21T00:12:43Z", InvalidValue},
+		{"1677-09-21T00:12:43.145224191Z", InvalidValue},
+		{"9999-12-31T23:59:59Z", InvalidValue},
+		{"0001-01-01T00:00:00Z", InvalidValue},
+	} {
+		if _, err := ParseRFC3339(test.Input); err != test.Expected {
+			t.Errorf("%q: expected %v, got %v", test.Input, test.Expected, err)
+		}
+	}
+}
+
+func TestParseRFC822(t *testing.T) {
+	const expected = 784111777 * Second
+
+	for _, test := range [...]string{
+		"Sun, 06 Nov 1994 08:49:37 GMT",
+		"Sunday, 06-Nov-94 08:49:37 GMT",
+		"Sun Nov  6 08:49:37 1994",
+		"06 Nov 1994 08:49:37 GMT",
+		"Sun, 6 Nov 1994 08:49:37 UTC",
+		"sun, 06 nov 1994 11:49:37 +0300",
+		"Sun, 06 Nov 1994 03:49:37 -0500",
+		"Sun, 06 Nov 94 08:49:37 GMT",
+	} {
+		got, err := ParseRFC822(test)
+		if err != nil {
+			t.Errorf("%q: failed to parse: %v", test, err)
+			continue
+		}
+		if got != expected {
+			t.Errorf("%q: expected %d, got %d", test, expected, got)
+		}
+	}
+
+	if got, _ := ParseRFC822("Sun, 06 Nov 1994 08:49 GMT"); got != expected-37*Second {
+		t.Errorf("Expected seconds to be optional, got %d", got)
+	}
+	if got, _ := ParseRFC822("Thursday, 01-Jan-70 00:00:00 GMT"); got != 0 {
+		t.Errorf("Expected two-digit year 70 to be 1970, got %d", got)
+	}
+
+	/* Round trip with PutTmRFC822. */
+	buf := make([]byte, RFC822Len)
+	for sec := int64(0); sec < 4102444800; sec += 7919 * 613 {
+		n := PutTmRFC822(buf, ToTm(sec*Second))
+		if got, err := ParseRFC822(string(buf[:n])); (err != nil) || (got != sec*Second) {
+			t.Fatalf("%q: expected %d, got %d (%v)", buf[:n], sec*Second, got, err)
+		}
+	}
+
+	for _, test := range [...]string{
+		"",
+		"Sun, 06 Nov 1994 08:49:37",
+		"Sun, 06 Nov 1994 08:49:37 MSK",
+		"Sun, 06 Foo 1994 08:49:37 GMT",
+		"Sun, 06 Nov 199 08:49:37 GMT",
+		"Sun, 31 Nov 1994 08:49:37 GMT",
+		"Sun, 06-Nov 1994 08:49:37 GMT",
+		"Sun Nov  6 08:49:37 94",
+		"Sun, 06 Nov 1994 08:49:37 GMT ",
+		"Sun, 06 Nov 1600 08:49:37 GMT",
+		"Sun Nov  6 08:49:37 3000",
+	} {
+		if _, err := ParseRFC822(test); err == nil {
+			t.Errorf("%q: expected error", test)
+		}
+	}
+}
+
+func TestParseHTMLInputs(t *testing.T) {
+	tm, err := ParseDate("2024-02-29")
+	if (err != nil) || (tm.Year != 124) || (tm.Mon != 1) || (tm.Mday != 29) || (tm.Wday != 4) || (tm.Yday != 59) {
+		t.Errorf("Unexpected date %+v (%v)", tm, err)
+	}
+
+	tm, err = ParseTime("15:04")
+	if (err != nil) || (tm.Hour != 15) || (tm.Min != 4) || (tm.Sec != 0) {
+		t.Errorf("Unexpected time %+v (%v)", tm, err)
+	}
+	tm, err = ParseTime("15:04:05.999")
+	if (err != nil) || (tm.Sec != 5) {
+		t.Errorf("Unexpected time %+v (%v)", tm, err)
+	}
+
+	for _, test := range [...]string{"2006-01-02T15:04", "2006-01-02 15:04", "2006-01-02T15:04:05", "2006-01-02T15:04:05.123"} {
+		tm, err := ParseDateTimeLocal(test)
+		if (err != nil) || (tm.Year != 106) || (tm.Mday != 2) || (tm.Hour != 15) || (tm.Min != 4) || (tm.Wday != 1) {
+			t.Errorf("%q: unexpected time %+v (%v)", test, tm, err)
+		}
+	}
+
+	for _, test := range [...]string{"", "2006-1-02", "2006-01-32", "2006/01/02", "2006-01-02T", "1600-01-01", "3000-01-01"} {
+		if _, err := ParseDate(test); err == nil {
+			t.Errorf("%q: expected error for date", test)
+		}
+	}
+	for _, test := range [...]string{"", "2006-01-02", "2006-01-02T15", "2006-01-02T15:04Z", "2006-01-02T25:04", "9999-01-02T15:04"} {
+		if _, err := ParseDateTimeLocal(test); err == nil {
+			t.Errorf("%q: expected error for datetime-local", test)
+		}
+	}
+}
+
+func TestParseAllocations(t *testing.T) {
+	allocs := testing.AllocsPerRun(100, func() {
+		ParseRFC3339("2006-01-02T15:04:05.999999999+07:00")
+		ParseRFC822("Sunday, 06-Nov-94 08:49:37 GMT")
+		ParseRFC822("Sun, 06 Nov 1994 08:49:37 GMT junk")
+		ParseDateTimeLocal("2006-01-02T15:04:05")
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations, got %v", allocs)
+	}
+}
@@ -0,0 +1,285 @@
+package time
+
+/* Names are localized names and formats used by Strftime. */
+type Names struct {
+	Wdays      [7]string
+	WdaysShort [7]string
+
+	/* Months are used next to day of month, which in some languages requires different case, e.g. genitive in Russian. MonthsStandalone are used with %OB and %Ob. */
+	Months                [12]string
+	MonthsShort           [12]string
+	MonthsStandalone      [12]string
+	MonthsStandaloneShort [12]string
+
+	AM, PM string
+
+	/* DateFormat, TimeFormat and DateTimeFormat are used for %x, %X and %c. */
+	DateFormat     string
+	TimeFormat     string
+	DateTimeFormat string
+}
+
+/* CNames are names and formats of C locale. */
+var CNames = Names{
+	Wdays:                 [...]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+	WdaysShort:            [...]string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"},
+	Months:                [...]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+	MonthsShort:           [...]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"},
+	MonthsStandalone:      [...]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+	MonthsStandaloneShort: [...]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"},
+	AM:                    "AM",
+	PM:                    "PM",
+	DateFormat:            "%m/%d/%y",
+	TimeFormat:            "%H:%M:%S",
+	DateTimeFormat:        "%a %b %e %H:%M:%S %Y",
+}
+
+/* tmWriter writes into fixed buffer and remembers if it ran out of space. */
+type tmWriter struct {
+	Buf      []byte
+	N        int
+	Overflow bool
+
+	Names *Names
+}
+
+func (w *tmWriter) Byte(c byte) {
+	if w.N == len(w.Buf) {
+		w.Overflow = true
+		return
+	}
+	w.Buf[w.N] = c
+	w.N++
+}
+
+func (w *tmWriter) String(s string) {
+	if len(w.Buf)-w.N < len(s) {
+		w.Overflow = true
+		return
+	}
+	w.N += copy(w.Buf[w.N:], s)
+}
+
+/* Int writes x padded to width with pad, which is 0 for no padding. */
+func (w *tmWriter) Int(x int, width int, pad byte) {
+	var buf [20]byte
+
+	neg := x < 0
+	if neg {
+		x = -x
+	}
+
+	n := len(buf)
+	for {
+		n--
+		buf[n] = byte(x%10) + '0'
+		x /= 10
+		if x == 0 {
+			break
+		}
+	}
+	if neg {
+		width--
+	}
+	if pad != 0 {
+		for len(buf)-n < width {
+			n--
+			buf[n] = pad
+		}
+	}
+	if neg {
+		w.Byte('-')
+	}
+	if len(w.Buf)-w.N < len(buf)-n {
+		w.Overflow = true
+		return
+	}
+	w.N += copy(w.Buf[w.N:], buf[n:])
+}
+
+/* isoWeek returns ISO 8601 week-based year and week number. */
+func isoWeek(tm *Tm) (int, int) {
+	year := tm.Year + 1900
+	wday := (tm.Wday + 6) % 7 /* Monday is 0. */
+
+	week := (tm.Yday - wday + 10) / 7
+	if week < 1 {
+		year--
+		return year, isoWeeksInYear(year)
+	}
+	if week > isoWeeksInYear(year) {
+		return year + 1, 1
+	}
+	return year, week
+}
+
+func isoWeeksInYear(year int) int {
+	p := func(y int) int {
+		return (y + y/4 - y/100 + y/400) % 7
+	}
+	if (p(year) == 4) || (p(year-1) == 3) {
+		return 53
+	}
+	return 52
+}
+
+/* flagInt is like Int, but padding can be disabled with '-' flag. */
+func (w *tmWriter) flagInt(x int, width int, pad byte, nopad bool) {
+	if nopad {
+		pad = 0
+	}
+	w.Int(x, width, pad)
+}
+
+func (w *tmWriter) Strftime(format string, tm *Tm) {
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		if (c != '%') || (i == len(format)-1) {
+			w.Byte(c)
+			continue
+		}
+		start := i
+		i++
+
+		/* NOTE(anton2920): GNU '-' flag disables padding, 'O' modifier selects standalone month names, 'E' is accepted and ignored. */
+		var nopad, standalone bool
+		if (format[i] == '-') && (i < len(format)-1) {
+			nopad = true
+			i++
+		}
+		if ((format[i] == 'O') || (format[i] == 'E')) && (i < len(format)-1) {
+			standalone = format[i] == 'O'
+			i++
+		}
+
+		switch format[i] {
+		default:
+			w.String(format[start : i+1])
+		case '%':
+			w.Byte('%')
+		case 'n':
+			w.Byte('\n')
+		case 't':
+			w.Byte('\t')
+
+		case 'a':
+			w.String(w.Names.WdaysShort[tm.Wday%7])
+		case 'A':
+			w.String(w.Names.Wdays[tm.Wday%7])
+		case 'b', 'h':
+			if standalone {
+				w.String(w.Names.MonthsStandaloneShort[tm.Mon%12])
+			} else {
+				w.String(w.Names.MonthsShort[tm.Mon%12])
+			}
+		case 'B':
+			if standalone {
+				w.String(w.Names.MonthsStandalone[tm.Mon%12])
+			} else {
+				w.String(w.Names.Months[tm.Mon%12])
+			}
+		case 'p':
+			if tm.Hour < 12 {
+				w.String(w.Names.AM)
+			} else {
+				w.String(w.Names.PM)
+			}
+
+		case 'C':
+			w.flagInt((tm.Year+1900)/100, 2, '0', nopad)
+		case 'y':
+			w.flagInt((tm.Year+1900)%100, 2, '0', nopad)
+		case 'Y':
+			w.Int(tm.Year+1900, 0, 0)
+		case 'G':
+			year, _ := isoWeek(tm)
+			w.Int(year, 0, 0)
+		case 'g':
+			year, _ := isoWeek(tm)
+			w.flagInt(year%100, 2, '0', nopad)
+		case 'm':
+			w.flagInt(tm.Mon+1, 2, '0', nopad)
+		case 'd':
+			w.flagInt(tm.Mday, 2, '0', nopad)
+		case 'e':
+			w.flagInt(tm.Mday, 2, ' ', nopad)
+		case 'j':
+			w.flagInt(tm.Yday+1, 3, '0', nopad)
+		case 'u':
+			w.Int((tm.Wday+6)%7+1, 0, 0)
+		case 'w':
+			w.Int(tm.Wday, 0, 0)
+		case 'U':
+			w.flagInt((tm.Yday+7-tm.Wday)/7, 2, '0', nopad)
+		case 'W':
+			w.flagInt((tm.Yday+7-(tm.Wday+6)%7)/7, 2, '0', nopad)
+		case 'V':
+			_, week := isoWeek(tm)
+			w.flagInt(week, 2, '0', nopad)
+
+		case 'H':
+			w.flagInt(tm.Hour, 2, '0', nopad)
+		case 'k':
+			w.flagInt(tm.Hour, 2, ' ', nopad)
+		case 'I':
+			w.flagInt((tm.Hour+11)%12+1, 2, '0', nopad)
+		case 'l':
+			w.flagInt((tm.Hour+11)%12+1, 2, ' ', nopad)
+		case 'M':
+			w.flagInt(tm.Min, 2, '0', nopad)
+		case 'S':
+			w.flagInt(tm.Sec, 2, '0', nopad)
+		case 's':
+			w.Int(int(FromTm(*tm)/Second), 0, 0)
+
+		case 'z':
+			offset := tm.Gmtoff
+			if offset < 0 {
+				w.Byte('-')
+				offset = -offset
+			} else {
+				w.Byte('+')
+			}
+			w.Int(offset/3600, 2, '0')
+			w.Int(offset/60%60, 2, '0')
+		case 'Z':
+			if len(tm.Zone) > 0 {
+				w.String(tm.Zone)
+			} else if tm.Gmtoff == 0 {
+				w.String("UTC")
+			}
+
+		case 'c':
+			w.Strftime(w.Names.DateTimeFormat, tm)
+		case 'x':
+			w.Strftime(w.Names.DateFormat, tm)
+		case 'X':
+			w.Strftime(w.Names.TimeFormat, tm)
+		case 'D':
+			w.Strftime("%m/%d/%y", tm)
+		case 'F':
+			w.Strftime("%Y-%m-%d", tm)
+		case 'r':
+			w.Strftime("%I:%M:%S %p", tm)
+		case 'R':
+			w.Strftime("%H:%M", tm)
+		case 'T':
+			w.Strftime("%H:%M:%S", tm)
+		}
+	}
+}
+
+/* Strftime puts tm into buf according to format, like strftime(3) in C locale. It returns number of bytes written or 0, if buf is too small. Unknown conversions are written as is. */
+func Strftime(buf []byte, format string, tm Tm) int {
+	return StrftimeNames(buf, format, tm, &CNames)
+}
+
+/* StrftimeNames is like Strftime, but uses localized names and formats. */
+func StrftimeNames(buf []byte, format string, tm Tm, names *Names) int {
+	w := tmWriter{Buf: buf, Names: names}
+	w.Strftime(format, &tm)
+	if w.Overflow {
+		return 0
+	}
+	return w.N
+}
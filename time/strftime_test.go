@@ -0,0 +1,80 @@
+package time
+
+import (
+	"fmt"
+	"testing"
+	stdtime "time"
+)
+
+func TestStrftime(t *testing.T) {
+	tm := ToTm(1136214245 * Second) /* 2006-01-02 15:04:05 UTC, Monday. */
+	tests := [...]struct {
+		Format   string
+		Expected string
+	}{
+		{"%Y-%m-%d %H:%M:%S", "2006-01-02 15:04:05"},
+		{"%F %T", "2006-01-02 15:04:05"},
+		{"%a %A %b %B %h", "Mon Monday Jan January Jan"},
+		{"%c", "Mon Jan  2 15:04:05 2006"},
+		{"%D %x %X %R %r", "01/02/06 01/02/06 15:04:05 15:04 03:04:05 PM"},
+		{"%C %y %e %j %k %l %I %p", "20 06  2 002 15  3 03 PM"},
+		{"%u %w %U %W %V %G %g", "1 1 01 01 01 2006 06"},
+		{"%s %z %Z", "1136214245 +0000 UTC"},
+		{"%% %n%t %q %", "% \n\t %q %"},
+		{"%-d.%-m %-e %-j %-I %OB %Ob %Ey %-Oq", "2.1 2 2 3 January Jan 06 %-Oq"},
+		{"plain", "plain"},
+	}
+
+	buf := make([]byte, 64)
+	for _, test := range tests {
+		n := Strftime(buf, test.Format, tm)
+		if got := string(buf[:n]); got != test.Expected {
+			t.Errorf("%q: expected %q, got %q", test.Format, test.Expected, got)
+		}
+	}
+
+	if n := Strftime(buf[:10], "%Y-%m-%d %H", tm); n != 0 {
+		t.Errorf("Expected 0 for small buffer, got %d", n)
+	}
+
+	tm.Gmtoff = -(5*3600 + 30*60)
+	tm.Zone = "XST"
+	if n := Strftime(buf, "%z %Z", tm); string(buf[:n]) != "-0530 XST" {
+		t.Errorf("Unexpected zone %q", buf[:n])
+	}
+
+	/* ISO 8601 weeks against Go. */
+	for sec := int64(0); sec < 4102444800; sec += 86400*3 + 7919 {
+		tm := ToTm(sec * Second)
+		expected := stdtime.Unix(sec, 0).UTC()
+		year, week := expected.ISOWeek()
+
+		n := Strftime(buf, "%G %V", tm)
+		if got := string(buf[:n]); got != fmt.Sprintf("%d %02d", year, week) {
+			t.Fatalf("%v: expected week %d-%02d, got %s", expected, year, week, got)
+		}
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		Strftime(buf, "%c %z %Z %G-W%V-%u %s", tm)
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations, got %v", allocs)
+	}
+}
+
+func TestStrftimeNames(t *testing.T) {
+	names := CNames
+	names.Months[0] = "января"
+	names.MonthsStandalone[0] = "январь"
+	names.Wdays[1] = "понедельник"
+	names.PM = ""
+	names.DateFormat = "%-d %B %Y"
+
+	tm := ToTm(1136214245 * Second)
+	buf := make([]byte, 64)
+	n := StrftimeNames(buf, "%A, %x; %OB %p", tm, &names)
+	if expected := "понедельник, 2 января 2006; январь "; string(buf[:n]) != expected {
+		t.Errorf("Expected %q, got %q", expected, buf[:n])
+	}
+}
@@ -13,10 +13,22 @@ type Tm struct {
 	Wday  int /* days since Sunday [0-6] */
 	Yday  int /* days since January 1 [0-365] */
 	Isdst int /* Daylight Savings Time flag */
+
+	Gmtoff int    /* offset from UTC in seconds */
+	Zone   string /* timezone abbreviation */
 }
 
 const RFC822Len = 29
 
+/* MinTime and MaxTime are bounds of Unix time in nanoseconds. MinYear and MaxYear are the years they fall into. */
+const (
+	MinTime = -1 << 63
+	MaxTime = 1<<63 - 1
+
+	MinYear = 1677
+	MaxYear = 2262
+)
+
 func ToTm(t int64) Tm {
 	var tm Tm
 
@@ -97,6 +109,85 @@ func ToTm(t int64) Tm {
 	return tm
 }
 
+/* FromTm converts tm back to Unix time in nanoseconds. Fields out of their ranges are normalized, e.g. Mday 32 of January is February 1st. Wday and Yday are ignored, Gmtoff is subtracted, so it's inverse of both ToTm and ToTmIn. Result overflows for times, which are not representable, see FromTmChecked. */
+func FromTm(tm Tm) int64 {
+	return tmSeconds(tm) * Second
+}
+
+/* FromTmChecked is like FromTm, but returns InvalidValue instead of overflowing for times outside of MinTime and MaxTime, roughly years 1678-2262. */
+func FromTmChecked(tm Tm) (int64, error) {
+	return fromTmChecked(tm, 0)
+}
+
+/* fromTmChecked returns Unix time of tm plus ns, which is in [0, Second). */
+func fromTmChecked(tm Tm, ns int64) (int64, error) {
+	year := int64(tm.Year) + 1900 + int64(tm.Mon)/12
+	if (year < MinYear) || (year > MaxYear) {
+		return 0, InvalidValue
+	}
+
+	/* NOTE(anton2920): field larger than the whole range in seconds can't result in valid time, while smaller ones can't overflow sum in tmSeconds. */
+	const max = MaxTime / Second
+	for _, field := range [...]int{tm.Mday, tm.Hour, tm.Min, tm.Sec, tm.Gmtoff} {
+		if (int64(field) < -max) || (int64(field) > max) {
+			return 0, InvalidValue
+		}
+	}
+
+	sec := tmSeconds(tm)
+	switch {
+	case (sec < MinTime/Second-1) || (sec > MaxTime/Second):
+		return 0, InvalidValue
+	case sec == MinTime/Second-1:
+		/* NOTE(anton2920): MinTime is not a whole second, so the earliest second is representable only with large enough fraction. */
+		t := (sec + 1) * Second
+		if ns-Second < MinTime-t {
+			return 0, InvalidValue
+		}
+		return t + ns - Second, nil
+	default:
+		t := sec * Second
+		if t > MaxTime-ns {
+			return 0, InvalidValue
+		}
+		return t + ns, nil
+	}
+}
+
+/* tmSeconds converts tm to Unix time in seconds. */
+func tmSeconds(tm Tm) int64 {
+	year := int64(tm.Year) + 1900
+	mon := int64(tm.Mon)
+	year += mon / 12
+	mon %= 12
+	if mon < 0 {
+		mon += 12
+		year--
+	}
+
+	days := daysSinceEpoch(year, int(mon)+1) + int64(tm.Mday) - 1
+	return days*secondsPerDay + int64(tm.Hour)*3600 + int64(tm.Min)*60 + int64(tm.Sec) - int64(tm.Gmtoff)
+}
+
+/* FromTmIn converts local time in tz to Unix time in nanoseconds. Gmtoff is ignored. Time skipped by transition to DST is treated as if transition did not happen yet, time repeated by transition from DST resolves to the earlier one. */
+func FromTmIn(tm Tm, tz Timezone) int64 {
+	tm.Gmtoff = 0
+	local := FromTm(tm)
+
+	/* NOTE(anton2920): offsets a day before and after are the only candidates, unless zone has two transitions within a day. */
+	before := int64(tz.Lookup(local - Day).Offset)
+	after := int64(tz.Lookup(local + Day).Offset)
+	t1 := local - before*Second
+	t2 := local - after*Second
+
+	ok1 := int64(tz.Lookup(t1).Offset) == before
+	ok2 := int64(tz.Lookup(t2).Offset) == after
+	if (ok2) && ((!ok1) || (t2 < t1)) {
+		return t2
+	}
+	return t1
+}
+
 /* PutTmRFC822 puts tm into buffer as 'Sun, 01 Jan 1970 00:00:00 GMT'. */
 func PutTmRFC822(buf []byte, tm Tm) int {
 	var n int
@@ -0,0 +1,537 @@
+package time
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/anton2920/gofa/strings"
+)
+
+/* Zone is time zone from IANA tz database, loaded from compiled TZif file (RFC 8536). */
+type Zone struct {
+	Name string
+
+	/* Transitions are Unix times in seconds, when local time type changes to Types[TransitionTypes[i]]. */
+	Transitions     []int64
+	TransitionTypes []uint8
+	Types           []ZoneType
+
+	/* Rule is used after the last transition, if HasRule is true. */
+	Rule    ZoneRule
+	HasRule bool
+}
+
+type ZoneType struct {
+	/* Offset is in seconds east of UTC. */
+	Offset int32
+	Isdst  bool
+	Abbr   string
+}
+
+/* ZoneRule is POSIX TZ string, e.g. 'CET-1CEST,M3.5.0,M10.5.0/3'. */
+type ZoneRule struct {
+	Std ZoneType
+	Dst ZoneType
+
+	/* HasDst is false for zones without DST, e.g. 'MSK-3'. */
+	HasDst bool
+
+	Start ZoneRuleDate
+	End   ZoneRuleDate
+
+	/* StartTime and EndTime are seconds since local midnight, may be negative or greater than a day. */
+	StartTime int32
+	EndTime   int32
+}
+
+type ZoneRuleDate struct {
+	/* Kind is 'J' for Julian day without February 29, 'N' for zero-based day of year and 'M' for day of week in month. */
+	Kind  byte
+	Day   int
+	Week  int
+	Month int
+}
+
+/* TimezoneNamed is the first Timezone of zones from tz database. Their values are derived from names, so they are the same between restarts and can be stored, e.g. in session.Customization. */
+const TimezoneNamed = Timezone(1 << 16)
+
+/* ZoneinfoDir is where LoadZone looks for TZif files. */
+var ZoneinfoDir = "/usr/share/zoneinfo"
+
+var (
+	/* Zones must be registered before they are used, usually at startup. */
+	Zones = make(map[Timezone]*Zone)
+
+	/* ZoneIDs lists registered zones sorted by name. */
+	ZoneIDs []Timezone
+)
+
+const secondsPerDay = 86400
+
+/* ZoneID returns Timezone for zone with name. */
+func ZoneID(name string) Timezone {
+	h := uint32(2166136261)
+	for i := 0; i < len(name); i++ {
+		h ^= uint32(name[i])
+		h *= 16777619
+	}
+	return TimezoneNamed + Timezone(h%(1<<31-uint32(TimezoneNamed)))
+}
+
+/* LoadZone reads zone with name, e.g. 'Europe/Moscow', from ZoneinfoDir and registers it. */
+func LoadZone(name string) (Timezone, error) {
+	if (len(name) == 0) || (name[0] == '/') || (strings.FindSubstring(name, "..") >= 0) {
+		return 0, fmt.Errorf("invalid zone name %q", name)
+	}
+
+	data, err := os.ReadFile(ZoneinfoDir + "/" + name)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read zone %q: %w", name, err)
+	}
+
+	return RegisterZone(name, data)
+}
+
+/* RegisterZone parses TZif data, e.g. embedded into binary, and registers it as zone with name. */
+func RegisterZone(name string, data []byte) (Timezone, error) {
+	zone, err := ParseZone(data)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse zone %q: %w", name, err)
+	}
+	zone.Name = name
+
+	id := ZoneID(name)
+	if old, ok := Zones[id]; ok {
+		if old.Name != name {
+			return 0, fmt.Errorf("zone %q has the same ID as %q", name, old.Name)
+		}
+	} else {
+		i := sort.Search(len(ZoneIDs), func(i int) bool { return Zones[ZoneIDs[i]].Name > name })
+		ZoneIDs = append(ZoneIDs, 0)
+		copy(ZoneIDs[i+1:], ZoneIDs[i:])
+		ZoneIDs[i] = id
+	}
+	Zones[id] = zone
+	Timezone2String[id] = name
+
+	return id, nil
+}
+
+func getTZifUint32(buf []byte) uint32 {
+	return uint32(buf[0])<<24 | uint32(buf[1])<<16 | uint32(buf[2])<<8 | uint32(buf[3])
+}
+
+func getTZifInt64(buf []byte) int64 {
+	return int64(uint64(getTZifUint32(buf))<<32 | uint64(getTZifUint32(buf[4:])))
+}
+
+/* ParseZone parses TZif data of version 1, 2, 3 or 4. Leap seconds are ignored. */
+func ParseZone(data []byte) (*Zone, error) {
+	const headerSize = 44
+
+	var counts [6]int
+	zone := new(Zone)
+
+	timeSize := 4
+	for {
+		if (len(data) < headerSize) || (string(data[:4]) != "TZif") {
+			return nil, errors.New("bad header")
+		}
+		version := data[4]
+
+		for i := 0; i < len(counts); i++ {
+			counts[i] = int(getTZifUint32(data[20+i*4:]))
+		}
+		isutcnt, isstdcnt, leapcnt, timecnt, typecnt, charcnt := counts[0], counts[1], counts[2], counts[3], counts[4], counts[5]
+		if typecnt == 0 {
+			return nil, errors.New("no local time types")
+		}
+		if ((isutcnt != 0) && (isutcnt != typecnt)) || ((isstdcnt != 0) && (isstdcnt != typecnt)) {
+			return nil, errors.New("bad indicator count")
+		}
+
+		size := timecnt*timeSize + timecnt + typecnt*6 + charcnt + leapcnt*(timeSize+4) + isstdcnt + isutcnt
+		if (size < 0) || (len(data)-headerSize < size) {
+			return nil, errors.New("data is truncated")
+		}
+		data = data[headerSize:]
+
+		/* NOTE(anton2920): version 1 data is followed by the same data with 64-bit times, which is used instead. */
+		if (version >= '2') && (timeSize == 4) {
+			data = data[size:]
+			timeSize = 8
+			continue
+		}
+
+		zone.Transitions = make([]int64, timecnt)
+		for i := 0; i < timecnt; i++ {
+			if timeSize == 4 {
+				zone.Transitions[i] = int64(int32(getTZifUint32(data[i*4:])))
+			} else {
+				zone.Transitions[i] = getTZifInt64(data[i*8:])
+			}
+			if (i > 0) && (zone.Transitions[i] <= zone.Transitions[i-1]) {
+				return nil, errors.New("transitions are not sorted")
+			}
+		}
+		data = data[timecnt*timeSize:]
+
+		zone.TransitionTypes = make([]uint8, timecnt)
+		for i := 0; i < timecnt; i++ {
+			if int(data[i]) >= typecnt {
+				return nil, fmt.Errorf("bad local time type %d", data[i])
+			}
+			zone.TransitionTypes[i] = data[i]
+		}
+		data = data[timecnt:]
+
+		types := data[:typecnt*6]
+		chars := data[typecnt*6 : typecnt*6+charcnt]
+		zone.Types = make([]ZoneType, typecnt)
+		for i := 0; i < typecnt; i++ {
+			t := types[i*6:]
+			zone.Types[i].Offset = int32(getTZifUint32(t))
+			zone.Types[i].Isdst = t[4] != 0
+
+			idx := int(t[5])
+			if idx >= len(chars) {
+				return nil, fmt.Errorf("bad abbreviation index %d", idx)
+			}
+			end := idx
+			for (end < len(chars)) && (chars[end] != 0) {
+				end++
+			}
+			zone.Types[i].Abbr = string(chars[idx:end])
+		}
+		data = data[size-timecnt*timeSize-timecnt:]
+		break
+	}
+
+	/* Footer with POSIX TZ string for times after the last transition. */
+	if (timeSize == 8) && (len(data) > 0) {
+		if data[0] != '\n' {
+			return nil, errors.New("bad footer")
+		}
+		end := 1
+		for (end < len(data)) && (data[end] != '\n') {
+			end++
+		}
+		if end == len(data) {
+			return nil, errors.New("footer is truncated")
+		}
+		if end > 1 {
+			rule, err := ParseZoneRule(string(data[1:end]))
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse footer: %w", err)
+			}
+			zone.Rule = rule
+			zone.HasRule = true
+		}
+	}
+
+	return zone, nil
+}
+
+func parseZoneRuleName(s string) (string, string, bool) {
+	if (len(s) > 0) && (s[0] == '<') {
+		for i := 1; i < len(s); i++ {
+			if s[i] == '>' {
+				return s[1:i], s[i+1:], i > 1
+			}
+		}
+		return "", s, false
+	}
+
+	var i int
+	for (i < len(s)) && (((s[i] >= 'a') && (s[i] <= 'z')) || ((s[i] >= 'A') && (s[i] <= 'Z'))) {
+		i++
+	}
+	return s[:i], s[i:], i >= 3
+}
+
+func parseZoneRuleNumber(s string, max int) (int, string, bool) {
+	var n, i int
+	for (i < len(s)) && (s[i] >= '0') && (s[i] <= '9') {
+		n = n*10 + int(s[i]-'0')
+		if n > max {
+			return 0, s, false
+		}
+		i++
+	}
+	return n, s[i:], i > 0
+}
+
+/* parseZoneRuleTime parses [+-]hh[:mm[:ss]] into seconds. */
+func parseZoneRuleTime(s string) (int32, string, bool) {
+	var ok bool
+	var h, m, sec int
+
+	sign := 1
+	if (len(s) > 0) && ((s[0] == '+') || (s[0] == '-')) {
+		if s[0] == '-' {
+			sign = -1
+		}
+		s = s[1:]
+	}
+
+	/* NOTE(anton2920): RFC 8536 allows hours up to 167 in rule times. */
+	if h, s, ok = parseZoneRuleNumber(s, 167); !ok {
+		return 0, s, false
+	}
+	if (len(s) > 0) && (s[0] == ':') {
+		if m, s, ok = parseZoneRuleNumber(s[1:], 59); !ok {
+			return 0, s, false
+		}
+		if (len(s) > 0) && (s[0] == ':') {
+			if sec, s, ok = parseZoneRuleNumber(s[1:], 59); !ok {
+				return 0, s, false
+			}
+		}
+	}
+
+	return int32(sign * (h*3600 + m*60 + sec)), s, true
+}
+
+func parseZoneRuleDate(s string) (ZoneRuleDate, int32, string, bool) {
+	var date ZoneRuleDate
+	var ok bool
+
+	if (len(s) == 0) || (s[0] != ',') {
+		return date, 0, s, false
+	}
+	s = s[1:]
+
+	switch {
+	case strings.StartsWith(s, "J"):
+		date.Kind = 'J'
+		if date.Day, s, ok = parseZoneRuleNumber(s[1:], 365); (!ok) || (date.Day < 1) {
+			return date, 0, s, false
+		}
+	case strings.StartsWith(s, "M"):
+		date.Kind = 'M'
+		if date.Month, s, ok = parseZoneRuleNumber(s[1:], 12); (!ok) || (date.Month < 1) || (!strings.StartsWith(s, ".")) {
+			return date, 0, s, false
+		}
+		if date.Week, s, ok = parseZoneRuleNumber(s[1:], 5); (!ok) || (date.Week < 1) || (!strings.StartsWith(s, ".")) {
+			return date, 0, s, false
+		}
+		if date.Day, s, ok = parseZoneRuleNumber(s[1:], 6); !ok {
+			return date, 0, s, false
+		}
+	default:
+		date.Kind = 'N'
+		if date.Day, s, ok = parseZoneRuleNumber(s, 365); !ok {
+			return date, 0, s, false
+		}
+	}
+
+	t := int32(2 * 3600)
+	if strings.StartsWith(s, "/") {
+		if t, s, ok = parseZoneRuleTime(s[1:]); !ok {
+			return date, 0, s, false
+		}
+	}
+
+	return date, t, s, true
+}
+
+/* ParseZoneRule parses POSIX TZ string. Offsets in it are west of UTC, e.g. 'MSK-3' is UTC+3. */
+func ParseZoneRule(s string) (ZoneRule, error) {
+	var rule ZoneRule
+	var offset int32
+	var ok bool
+
+	input := s
+
+	if rule.Std.Abbr, s, ok = parseZoneRuleName(s); !ok {
+		return rule, fmt.Errorf("bad standard time name in %q", input)
+	}
+	if offset, s, ok = parseZoneRuleTime(s); !ok {
+		return rule, fmt.Errorf("bad standard time offset in %q", input)
+	}
+	rule.Std.Offset = -offset
+	if len(s) == 0 {
+		return rule, nil
+	}
+
+	rule.HasDst = true
+	rule.Dst.Isdst = true
+	if rule.Dst.Abbr, s, ok = parseZoneRuleName(s); !ok {
+		return rule, fmt.Errorf("bad daylight saving time name in %q", input)
+	}
+	rule.Dst.Offset = rule.Std.Offset + 3600
+	if (len(s) > 0) && (s[0] != ',') {
+		if offset, s, ok = parseZoneRuleTime(s); !ok {
+			return rule, fmt.Errorf("bad daylight saving time offset in %q", input)
+		}
+		rule.Dst.Offset = -offset
+	}
+
+	/* NOTE(anton2920): rule is implementation-defined if omitted, US one is used like in most implementations. */
+	if len(s) == 0 {
+		s = ",M3.2.0,M11.1.0"
+	}
+	if rule.Start, rule.StartTime, s, ok = parseZoneRuleDate(s); !ok {
+		return rule, fmt.Errorf("bad start of daylight saving time in %q", input)
+	}
+	if rule.End, rule.EndTime, s, ok = parseZoneRuleDate(s); !ok {
+		return rule, fmt.Errorf("bad end of daylight saving time in %q", input)
+	}
+	if len(s) > 0 {
+		return rule, fmt.Errorf("unexpected %q at the end of %q", s, input)
+	}
+
+	return rule, nil
+}
+
+func isLeap(year int64) bool {
+	return (year%4 == 0) && ((year%100 != 0) || (year%400 == 0))
+}
+
+/* daysSinceEpoch returns number of days from 1970-01-01 to the first day of month (1-12) of year. */
+func daysSinceEpoch(year int64, month int) int64 {
+	/* NOTE(anton2920): see http://howardhinnant.github.io/date_algorithms.html#days_from_civil. */
+	y := year
+	m := int64(month)
+	if m <= 2 {
+		y--
+	}
+	era := y / 400
+	if y < 0 {
+		era = (y - 399) / 400
+	}
+	yoe := y - era*400
+	mp := (m + 9) % 12
+	doy := (153*mp + 2) / 5
+	doe := yoe*365 + yoe/4 - yoe/100 + doy
+	return era*146097 + doe - 719468
+}
+
+/* yearFromDays is inverse of daysSinceEpoch. */
+func yearFromDays(days int64) int64 {
+	z := days + 719468
+	era := z / 146097
+	if z < 0 {
+		era = (z - 146096) / 146097
+	}
+	doe := z - era*146097
+	yoe := (doe - doe/1460 + doe/36524 - doe/146096) / 365
+	doy := doe - (365*yoe + yoe/4 - yoe/100)
+	mp := (5*doy + 2) / 153
+
+	year := yoe + era*400
+	if mp >= 10 {
+		/* January or February. */
+		year++
+	}
+	return year
+}
+
+func daysInMonth(year int64, month int) int {
+	days := [...]int{31, 28, 31, 30, 31, 30, 31, 31, 30, 31, 30, 31}
+	if (month == 2) && (isLeap(year)) {
+		return 29
+	}
+	return days[month-1]
+}
+
+/* day returns number of days from epoch to date in year. */
+func (date ZoneRuleDate) day(year int64) int64 {
+	switch date.Kind {
+	case 'J':
+		yday := int64(date.Day - 1)
+		if (isLeap(year)) && (date.Day >= 60) {
+			yday++
+		}
+		return daysSinceEpoch(year, 1) + yday
+	case 'N':
+		return daysSinceEpoch(year, 1) + int64(date.Day)
+	default:
+		first := daysSinceEpoch(year, date.Month)
+		/* NOTE(anton2920): 1970-01-01 is Thursday. */
+		wday := int((first%7 + 11) % 7)
+		mday := (date.Day-wday+7)%7 + (date.Week-1)*7
+		if mday >= daysInMonth(year, date.Month) {
+			mday -= 7
+		}
+		return first + int64(mday)
+	}
+}
+
+/* Lookup returns local time type at Unix time t in seconds. */
+func (rule *ZoneRule) Lookup(t int64) ZoneType {
+	if !rule.HasDst {
+		return rule.Std
+	}
+
+	local := t + int64(rule.Std.Offset)
+	days := local / secondsPerDay
+	if (local < 0) && (local%secondsPerDay != 0) {
+		days--
+	}
+	year := yearFromDays(days)
+
+	/* NOTE(anton2920): start is in standard time, end is in daylight saving time. */
+	start := rule.Start.day(year)*secondsPerDay + int64(rule.StartTime) - int64(rule.Std.Offset)
+	end := rule.End.day(year)*secondsPerDay + int64(rule.EndTime) - int64(rule.Dst.Offset)
+
+	if start < end {
+		if (t >= start) && (t < end) {
+			return rule.Dst
+		}
+	} else {
+		/* Southern hemisphere. */
+		if (t < end) || (t >= start) {
+			return rule.Dst
+		}
+	}
+	return rule.Std
+}
+
+/* Lookup returns local time type at Unix time t in seconds. */
+func (zone *Zone) Lookup(t int64) ZoneType {
+	if (len(zone.Transitions) == 0) || (t < zone.Transitions[0]) {
+		if (len(zone.Transitions) == 0) && (zone.HasRule) {
+			return zone.Rule.Lookup(t)
+		}
+		return zone.Types[0]
+	}
+
+	i := sort.Search(len(zone.Transitions), func(i int) bool { return zone.Transitions[i] > t }) - 1
+	if (i == len(zone.Transitions)-1) && (zone.HasRule) {
+		return zone.Rule.Lookup(t)
+	}
+	return zone.Types[zone.TransitionTypes[i]]
+}
+
+/* Lookup returns local time type of tz at Unix time t in nanoseconds. Unknown time zones are treated as UTC. */
+func (tz Timezone) Lookup(t int64) ZoneType {
+	if (tz > TimezoneNone) && (tz < TimezoneCount) {
+		return ZoneType{Offset: int32(tz) * 3600, Abbr: Timezone2String[tz]}
+	}
+	if zone, ok := Zones[tz]; ok {
+		sec := t / Second
+		if (t < 0) && (t%Second != 0) {
+			sec--
+		}
+		return zone.Lookup(sec)
+	}
+	return ZoneType{Abbr: "UTC"}
+}
+
+/* ToTmIn converts Unix time t in nanoseconds to local time in tz. */
+func ToTmIn(t int64, tz Timezone) Tm {
+	zt := tz.Lookup(t)
+
+	tm := ToTm(t + int64(zt.Offset)*Second)
+	tm.Isdst = 0
+	if zt.Isdst {
+		tm.Isdst = 1
+	}
+	tm.Gmtoff = int(zt.Offset)
+	tm.Zone = zt.Abbr
+
+	return tm
+}
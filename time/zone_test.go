@@ -0,0 +1,162 @@
+package time
+
+import (
+	"os"
+	"testing"
+	stdtime "time"
+)
+
+func TestParseZoneRule(t *testing.T) {
+	tests := [...]struct {
+		Input    string
+		Expected ZoneRule
+	}{
+		{"MSK-3", ZoneRule{Std: ZoneType{Offset: 3 * 3600, Abbr: "MSK"}}},
+		{"<+0530>-5:30", ZoneRule{Std: ZoneType{Offset: 5*3600 + 30*60, Abbr: "+0530"}}},
+		{"CET-1CEST,M3.5.0,M10.5.0/3", ZoneRule{
+			Std: ZoneType{Offset: 3600, Abbr: "CET"}, Dst: ZoneType{Offset: 7200, Isdst: true, Abbr: "CEST"}, HasDst: true,
+			Start: ZoneRuleDate{Kind: 'M', Month: 3, Week: 5}, StartTime: 7200,
+			End: ZoneRuleDate{Kind: 'M', Month: 10, Week: 5}, EndTime: 3 * 3600,
+		}},
+		{"EST5EDT", ZoneRule{
+			Std: ZoneType{Offset: -5 * 3600, Abbr: "EST"}, Dst: ZoneType{Offset: -4 * 3600, Isdst: true, Abbr: "EDT"}, HasDst: true,
+			Start: ZoneRuleDate{Kind: 'M', Month: 3, Week: 2}, StartTime: 7200,
+			End: ZoneRuleDate{Kind: 'M', Month: 11, Week: 1}, EndTime: 7200,
+		}},
+		{"<-03>3<-02>,M3.5.0/-2,M10.5.0/-1", ZoneRule{
+			Std: ZoneType{Offset: -3 * 3600, Abbr: "-03"}, Dst: ZoneType{Offset: -2 * 3600, Isdst: true, Abbr: "-02"}, HasDst: true,
+			Start: ZoneRuleDate{Kind: 'M', Month: 3, Week: 5}, StartTime: -7200,
+			End: ZoneRuleDate{Kind: 'M', Month: 10, Week: 5}, EndTime: -3600,
+		}},
+		{"XXX3YYY2,J60/1:30:15,300/167", ZoneRule{
+			Std: ZoneType{Offset: -3 * 3600, Abbr: "XXX"}, Dst: ZoneType{Offset: -2 * 3600, Isdst: true, Abbr: "YYY"}, HasDst: true,
+			Start: ZoneRuleDate{Kind: 'J', Day: 60}, StartTime: 3600 + 30*60 + 15,
+			End: ZoneRuleDate{Kind: 'N', Day: 300}, EndTime: 167 * 3600,
+		}},
+	}
+	for _, test := range tests {
+		rule, err := ParseZoneRule(test.Input)
+		if err != nil {
+			t.Errorf("%q: failed to parse rule: %v", test.Input, err)
+			continue
+		}
+		if rule != test.Expected {
+			t.Errorf("%q: expected %+v, got %+v", test.Input, test.Expected, rule)
+		}
+	}
+
+	for _, input := range [...]string{"", "AB-3", "MSK", "MSK-", "<MSK-3", "<>-3", "MSK-3MSD,", "MSK-3MSD,M13.1.0,M10.5.0", "MSK-3MSD,M3.6.0,M10.5.0", "MSK-3MSD,M3.5.7,M10.5.0", "MSK-3MSD,J0,J365", "MSK-3MSD,M3.5.0", "MSK-3MSD,M3.5.0/168,M10.5.0", "MSK-3MSD,M3.5.0,M10.5.0x", "MSK-3:60"} {
+		if _, err := ParseZoneRule(input); err == nil {
+			t.Errorf("%q: expected error", input)
+		}
+	}
+}
+
+func TestZoneRuleDate(t *testing.T) {
+	tests := [...]struct {
+		Date     ZoneRuleDate
+		Year     int64
+		Expected string
+	}{
+		{ZoneRuleDate{Kind: 'M', Month: 3, Week: 5, Day: 0}, 2024, "2024-03-31"},
+		{ZoneRuleDate{Kind: 'M', Month: 3, Week: 2, Day: 0}, 2024, "2024-03-10"},
+		{ZoneRuleDate{Kind: 'M', Month: 2, Week: 5, Day: 4}, 2024, "2024-02-29"},
+		{ZoneRuleDate{Kind: 'M', Month: 2, Week: 5, Day: 4}, 2023, "2023-02-23"},
+		{ZoneRuleDate{Kind: 'J', Day: 60}, 2024, "2024-03-01"},
+		{ZoneRuleDate{Kind: 'N', Day: 59}, 2024, "2024-02-29"},
+		{ZoneRuleDate{Kind: 'N', Day: 0}, 1969, "1969-01-01"},
+		{ZoneRuleDate{Kind: 'M', Month: 1, Week: 1, Day: 1}, 1900, "1900-01-01"},
+	}
+	for _, test := range tests {
+		day := test.Date.day(test.Year)
+		if got := stdtime.Unix(day*secondsPerDay, 0).UTC().Format("2006-01-02"); got != test.Expected {
+			t.Errorf("%+v in %d: expected %s, got %s", test.Date, test.Year, test.Expected, got)
+		}
+		if year := yearFromDays(day); year != test.Year {
+			t.Errorf("Expected year %d for %s, got %d", test.Year, test.Expected, year)
+		}
+	}
+}
+
+func TestZoneID(t *testing.T) {
+	/* NOTE(anton2920): IDs are stored by users, so they must never change. */
+	if id := ZoneID("Europe/Moscow"); id != 8590684 {
+		t.Errorf("Expected ID of Europe/Moscow to stay the same, got %d", id)
+	}
+	if id := ZoneID(""); id < TimezoneNamed {
+		t.Errorf("Expected ID not to intersect with offsets, got %d", id)
+	}
+}
+
+func TestTimezoneOffset(t *testing.T) {
+	tm := ToTmIn(0, Timezone(3))
+	if (tm.Hour != 3) || (tm.Gmtoff != 3*3600) || (tm.Isdst != 0) || (tm.Zone != "UTC+3") {
+		t.Errorf("Unexpected time %+v", tm)
+	}
+	if tm := ToTmIn(0, Timezone(12345)); (tm.Hour != 0) || (tm.Zone != "UTC") {
+		t.Errorf("Expected unknown zone to be UTC, got %+v", tm)
+	}
+}
+
+func TestZones(t *testing.T) {
+	if _, err := os.Stat(ZoneinfoDir); err != nil {
+		t.Skipf("No zoneinfo: %v", err)
+	}
+
+	for _, name := range [...]string{"UTC", "Europe/Moscow", "Europe/Berlin", "Europe/Dublin", "America/New_York", "America/Sao_Paulo", "America/St_Johns", "Australia/Sydney", "Australia/Lord_Howe", "Pacific/Chatham", "Asia/Kolkata", "Africa/Casablanca", "America/Godthab"} {
+		tz, err := LoadZone(name)
+		if err != nil {
+			t.Errorf("Failed to load zone: %v", err)
+			continue
+		}
+		if (Timezone2String[tz] != name) || (tz != ZoneID(name)) {
+			t.Errorf("Expected zone %s to be registered", name)
+		}
+
+		loc, err := stdtime.LoadLocation(name)
+		if err != nil {
+			t.Fatalf("Failed to load location: %v", err)
+		}
+
+		const step = 7919 * 61
+		for sec := int64(-2208988800); sec < 4102444800; sec += step {
+			expected := stdtime.Unix(sec, 0).In(loc)
+			abbr, offset := expected.Zone()
+
+			tm := ToTmIn(sec*Second, tz)
+			if (tm.Year+1900 != expected.Year()) || (tm.Mon+1 != int(expected.Month())) || (tm.Mday != expected.Day()) || (tm.Hour != expected.Hour()) || (tm.Min != expected.Minute()) || (tm.Sec != expected.Second()) || (tm.Wday != int(expected.Weekday())) || (tm.Yday != expected.YearDay()-1) {
+				t.Fatalf("%s at %d: expected %v, got %+v", name, sec, expected, tm)
+			}
+			if (tm.Gmtoff != offset) || (tm.Zone != abbr) || ((tm.Isdst == 1) != expected.IsDST()) {
+				t.Fatalf("%s at %d: expected %s %d %v, got %+v", name, sec, abbr, offset, expected.IsDST(), tm)
+			}
+		}
+	}
+
+	for i := 1; i < len(ZoneIDs); i++ {
+		if Zones[ZoneIDs[i-1]].Name >= Zones[ZoneIDs[i]].Name {
+			t.Errorf("Expected zones to be sorted by name")
+		}
+	}
+
+	for _, name := range [...]string{"", "/etc/passwd", "../etc/passwd", "Nowhere/Nothing"} {
+		if _, err := LoadZone(name); err == nil {
+			t.Errorf("%q: expected error", name)
+		}
+	}
+}
+
+func TestParseZoneCorrupted(t *testing.T) {
+	data, err := os.ReadFile(ZoneinfoDir + "/Europe/Berlin")
+	if err != nil {
+		t.Skipf("No zoneinfo: %v", err)
+	}
+	for i := 0; i < len(data); i++ {
+		ParseZone(data[:i])
+	}
+	for i := 0; i < len(data); i++ {
+		data[i] ^= 0xFF
+		ParseZone(data)
+		data[i] ^= 0xFF
+	}
+}
@@ -0,0 +1,182 @@
+/* Compares two profiles in collapsed-stack format, e.g. written by trace_.WriteCollapsed before and after optimization. Usage:
+ *     go run github.com/anton2920/gofa/trace/cmd/diff [-threshold percent] [-normalize] before.folded after.folded
+ * Profiles must contain exclusive cycles. Stacks are aggregated by anchor, which is the last name of stack, as profiler does: exclusive cycles of anchor are sum of cycles of stacks ending with it, inclusive cycles are sum of cycles of stacks containing it. For every anchor, which inclusive or exclusive cycles changed by at least threshold percents, change is printed, the largest regressions first. */
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type Cycles struct {
+	Exclusive int64
+	Inclusive int64
+}
+
+type Anchor struct {
+	Name string
+
+	Before Cycles
+	After  Cycles
+}
+
+type Profile map[string]*Anchor
+
+func (p Profile) Get(name string) *Anchor {
+	anchor, ok := p[name]
+	if !ok {
+		anchor = &Anchor{Name: name}
+		p[name] = anchor
+	}
+	return anchor
+}
+
+/* Read adds exclusive cycles from r to the last anchor of every stack and to all anchors of stack as inclusive. cycles selects Before or After of Anchor. path is only used in errors. */
+func (p Profile) Read(r io.Reader, path string, cycles func(*Anchor) *Cycles) (int64, error) {
+	var total int64
+
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if len(line) == 0 {
+			continue
+		}
+
+		space := strings.LastIndexByte(line, ' ')
+		if space == -1 {
+			return 0, fmt.Errorf("%s:%d: expected 'stack cycles', got %q", path, n, line)
+		}
+		names := strings.Split(line[:space], ";")
+		value, err := strconv.ParseInt(line[space+1:], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%s:%d: failed to parse cycles: %w", path, n, err)
+		}
+
+		cycles(p.Get(names[len(names)-1])).Exclusive += value
+		for i, name := range names {
+			/* NOTE(anton2920): recursive anchor is counted once per stack, like profiler does. */
+			var seen bool
+			for j := 0; j < i; j++ {
+				if names[j] == name {
+					seen = true
+					break
+				}
+			}
+			if !seen {
+				cycles(p.Get(name)).Inclusive += value
+			}
+		}
+		total += value
+	}
+	if err := s.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read %q: %w", path, err)
+	}
+
+	return total, nil
+}
+
+/* ReadFile is Read of file at path. */
+func (p Profile) ReadFile(path string, cycles func(*Anchor) *Cycles) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	return p.Read(f, path, cycles)
+}
+
+/* Normalize scales After cycles of every anchor by scale. */
+func (p Profile) Normalize(scale float64) {
+	for _, anchor := range p {
+		anchor.After.Exclusive = int64(float64(anchor.After.Exclusive) * scale)
+		anchor.After.Inclusive = int64(float64(anchor.After.Inclusive) * scale)
+	}
+}
+
+/* Changed returns anchors, which inclusive or exclusive cycles changed by at least threshold percents, sorted by change of inclusive cycles, the largest regressions first. */
+func (p Profile) Changed(threshold float64) []*Anchor {
+	anchors := make([]*Anchor, 0, len(p))
+	for _, anchor := range p {
+		if (math.Abs(Change(anchor.Before.Inclusive, anchor.After.Inclusive)) >= threshold) || (math.Abs(Change(anchor.Before.Exclusive, anchor.After.Exclusive)) >= threshold) {
+			anchors = append(anchors, anchor)
+		}
+	}
+	sort.Slice(anchors, func(i, j int) bool {
+		ci := Change(anchors[i].Before.Inclusive, anchors[i].After.Inclusive)
+		cj := Change(anchors[j].Before.Inclusive, anchors[j].After.Inclusive)
+		if ci != cj {
+			return ci > cj
+		}
+		return anchors[i].Name < anchors[j].Name
+	})
+	return anchors
+}
+
+/* Change returns change from before to after in percents. Anchor, which appeared, has infinite change. */
+func Change(before int64, after int64) float64 {
+	if before == 0 {
+		if after == 0 {
+			return 0
+		}
+		return math.Inf(1)
+	}
+	return 100 * float64(after-before) / float64(before)
+}
+
+func FormatChange(change float64) string {
+	if math.IsInf(change, 1) {
+		return "new"
+	}
+	return fmt.Sprintf("%+.1f%%", change)
+}
+
+func main() {
+	threshold := flag.Float64("threshold", 5, "minimal change of inclusive or exclusive cycles in percents to report")
+	normalize := flag.Bool("normalize", false, "scale cycles of the second profile, so totals of both profiles are equal")
+	flag.Parse()
+
+	log.SetFlags(0)
+	log.SetPrefix("trace/cmd/diff: ")
+
+	if flag.NArg() != 2 {
+		flag.Usage()
+		log.Fatalf("Expected two profiles")
+	}
+
+	p := make(Profile)
+	totalBefore, err := p.ReadFile(flag.Arg(0), func(a *Anchor) *Cycles { return &a.Before })
+	if err != nil {
+		log.Fatalf("Failed to read profile: %v", err)
+	}
+	totalAfter, err := p.ReadFile(flag.Arg(1), func(a *Anchor) *Cycles { return &a.After })
+	if err != nil {
+		log.Fatalf("Failed to read profile: %v", err)
+	}
+
+	if (*normalize) && (totalAfter > 0) {
+		p.Normalize(float64(totalBefore) / float64(totalAfter))
+		totalAfter = totalBefore
+	}
+	anchors := p.Changed(*threshold)
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+
+	fmt.Fprintf(w, "Total: %d -> %d cycles (%s)\n", totalBefore, totalAfter, FormatChange(Change(totalBefore, totalAfter)))
+	if len(anchors) > 0 {
+		fmt.Fprintf(w, "%9s %9s %31s %31s  %s\n", "incl", "excl", "inclusive cycles", "exclusive cycles", "anchor")
+	}
+	for _, anchor := range anchors {
+		fmt.Fprintf(w, "%9s %9s %14d -> %-14d %14d -> %-14d  %s\n", FormatChange(Change(anchor.Before.Inclusive, anchor.After.Inclusive)), FormatChange(Change(anchor.Before.Exclusive, anchor.After.Exclusive)), anchor.Before.Inclusive, anchor.After.Inclusive, anchor.Before.Exclusive, anchor.After.Exclusive, anchor.Name)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func testProfile(t *testing.T, before string, after string) Profile {
+	p := make(Profile)
+	if _, err := p.Read(strings.NewReader(before), "before", func(a *Anchor) *Cycles { return &a.Before }); err != nil {
+		t.Fatalf("Failed to read profile: %v", err)
+	}
+	if _, err := p.Read(strings.NewReader(after), "after", func(a *Anchor) *Cycles { return &a.After }); err != nil {
+		t.Fatalf("Failed to read profile: %v", err)
+	}
+	return p
+}
+
+func TestProfileRead(t *testing.T) {
+	/* NOTE(anton2920): decode is called from two places and recursively, but it's one anchor. */
+	p := testProfile(t, `
+main 10
+main;handle 20
+main;handle;decode 30
+main;load;decode 40
+main;load;decode;decode 5
+`, "")
+
+	expected := [...]struct {
+		Name      string
+		Exclusive int64
+		Inclusive int64
+	}{
+		{"main", 10, 105},
+		{"handle", 20, 50},
+		{"load", 0, 45},
+		{"decode", 75, 75},
+	}
+	if len(p) != len(expected) {
+		t.Errorf("Expected %d anchors, got %d", len(expected), len(p))
+	}
+	for _, e := range expected {
+		anchor, ok := p[e.Name]
+		if !ok {
+			t.Errorf("Anchor %q is missing", e.Name)
+			continue
+		}
+		if (anchor.Before.Exclusive != e.Exclusive) || (anchor.Before.Inclusive != e.Inclusive) {
+			t.Errorf("Anchor %q: expected %d/%d cycles, got %d/%d", e.Name, e.Exclusive, e.Inclusive, anchor.Before.Exclusive, anchor.Before.Inclusive)
+		}
+	}
+}
+
+func TestProfileReadErrors(t *testing.T) {
+	for _, line := range [...]string{"main", "main;handle abc"} {
+		p := make(Profile)
+		if _, err := p.Read(strings.NewReader(line), "test", func(a *Anchor) *Cycles { return &a.Before }); err == nil {
+			t.Errorf("Expected error for %q", line)
+		}
+	}
+}
+
+func TestProfileChanged(t *testing.T) {
+	p := testProfile(t, `
+main;handle 100
+main;handle;decode 100
+main;idle 100
+`, `
+main;handle 100
+main;handle;decode 150
+main;idle 98
+main;cache 10
+`)
+
+	anchors := p.Changed(5)
+
+	expected := [...]string{"cache", "decode", "handle", "main"}
+	if len(anchors) != len(expected) {
+		t.Fatalf("Expected %d changed anchors, got %d", len(expected), len(anchors))
+	}
+	for i := 0; i < len(expected); i++ {
+		if anchors[i].Name != expected[i] {
+			t.Errorf("Expected %q at %d, got %q", expected[i], i, anchors[i].Name)
+		}
+	}
+	if c := Change(p["decode"].Before.Exclusive, p["decode"].After.Exclusive); c != 50 {
+		t.Errorf("Expected decode to change by 50%%, got %v", c)
+	}
+	if !math.IsInf(Change(p["cache"].Before.Inclusive, p["cache"].After.Inclusive), 1) {
+		t.Errorf("Expected new anchor to have infinite change")
+	}
+
+	p.Normalize(0.5)
+	if p["decode"].After.Exclusive != 75 {
+		t.Errorf("Expected normalized cycles to be 75, got %d", p["decode"].After.Exclusive)
+	}
+}
@@ -8,7 +8,9 @@ import (
 	"unsafe"
 
 	"github.com/anton2920/gofa/bools"
+	"github.com/anton2920/gofa/bytes"
 	"github.com/anton2920/gofa/cpu"
+	"github.com/anton2920/gofa/encoding"
 	"github.com/anton2920/gofa/fmt"
 	"github.com/anton2920/gofa/funcs"
 	"github.com/anton2920/gofa/strings"
@@ -39,12 +41,26 @@ type Block struct {
 	AnchorIndex int32
 }
 
+/* Event is block recorded by Profiler in event mode, see RecordEvents. */
+type Event struct {
+	PC    uintptr
+	Label string
+
+	StartCycles cpu.Cycles
+	EndCycles   cpu.Cycles
+}
+
 type Profiler struct {
 	Prefix string
 
 	Anchors       []Anchor
 	CurrentParent int32
 
+	/* Events is ring of the last ended blocks, EventsCount is number of blocks ended since BeginProfile. Profiler belongs to one thread, so ThreadID is 'tid' of its events in Chrome trace. */
+	Events      []Event
+	EventsCount int
+	ThreadID    int
+
 	StartCycles cpu.Cycles
 	EndCycles   cpu.Cycles
 }
@@ -146,12 +162,29 @@ func (p *Profiler) BeginProfile() {
 		p.Anchors[i] = Anchor{}
 	}
 	p.CurrentParent = 0
+	p.EventsCount = 0
 	p.StartCycles = cpu.ReadPerformanceCounter()
 }
 
+/* RecordEvents makes Profiler remember begin and end of the last n blocks in addition to aggregates, so they can be written with WriteChromeTrace. n is rounded up to power of two, 0 disables recording. */
+func (p *Profiler) RecordEvents(n int) {
+	if n <= 0 {
+		p.Events = nil
+		return
+	}
+
+	size := 1
+	for size < n {
+		size <<= 1
+	}
+	p.Events = make([]Event, size)
+	p.EventsCount = 0
+}
+
 //go:nosplit
 func (b Block) End() {
-	elapsed := cpu.ReadPerformanceCounter() - b.StartCycles
+	endCycles := cpu.ReadPerformanceCounter()
+	elapsed := endCycles - b.StartCycles
 	anchor := &b.Profiler.Anchors[b.AnchorIndex]
 	parent := &b.Profiler.Anchors[b.ParentIndex]
 	b.Profiler.CurrentParent = b.ParentIndex
@@ -165,6 +198,50 @@ func (b Block) End() {
 	anchor.PC = b.PC
 	anchor.Label = b.Label
 	anchor.ParentIndex = b.ParentIndex
+
+	if p := b.Profiler; len(p.Events) > 0 {
+		event := &p.Events[p.EventsCount&(len(p.Events)-1)]
+		event.PC = b.PC
+		event.Label = b.Label
+		event.StartCycles = b.StartCycles
+		event.EndCycles = endCycles
+		p.EventsCount++
+	}
+}
+
+/* Merge adds anchors of other to p, e.g. to combine profilers of different threads. Anchors are matched by PC, so both profilers must have the same number of anchors. Elapsed time of other is added to elapsed time of p, so percents of combined profile are relative to time of all merged profilers. */
+func (p *Profiler) Merge(other *Profiler) {
+	half := len(other.Anchors) / 2
+
+	/* NOTE(anton2920): all anchors are inserted before parents are resolved, so parent, which is not inserted yet, does not take slot of another anchor. */
+	for i := 0; i < half; i++ {
+		anchor := &other.Anchors[i]
+		if anchor.HitCount == 0 {
+			continue
+		}
+
+		merged := &p.Anchors[p.anchorIndexForPC(anchor.PC)]
+		merged.PC = anchor.PC
+		merged.Label = anchor.Label
+		merged.HitCount += anchor.HitCount
+		merged.ElapsedCyclesExclusive += anchor.ElapsedCyclesExclusive
+		merged.ElapsedCyclesInclusive += anchor.ElapsedCyclesInclusive
+	}
+	for i := 0; i < half; i++ {
+		anchor := &other.Anchors[i]
+		if anchor.HitCount == 0 {
+			continue
+		}
+
+		merged := &p.Anchors[p.anchorIndexForPC(anchor.PC)]
+		if parent := &other.Anchors[anchor.ParentIndex]; parent.HitCount > 0 {
+			merged.ParentIndex = p.anchorIndexForPC(parent.PC)
+		} else {
+			merged.ParentIndex = 0
+		}
+	}
+
+	p.EndCycles += other.EndCycles - other.StartCycles
 }
 
 func (p *Profiler) dumpTimeElapsed(f *fmt.Formatter, label string, totalElapsed cpu.Cycles, curr *Anchor, parent *Anchor) {
@@ -197,22 +274,19 @@ func (p *Profiler) DumpProfile(buffer []byte) int {
 
 	f.S(strings.Or(p.Prefix, defaultPrefix)).S("Total time: ").Prec(4).F(totalElapsed.ToMilliseconds()).S("ms").Ln()
 
-	/* NOTE(anton2920): Anchor.ParentIndex uses original order, so we need to preserve it after Sort. Copy filled half to the latter half to create a backup. */
+	/* NOTE(anton2920): anchors are found by PC and ParentIndex in the first half, so it must stay in original order. Copy is sorted in the latter half, which is not used otherwise. */
 	half := len(p.Anchors) / 2
-	copy(p.Anchors[half:], p.Anchors[:half])
+	sorted := p.Anchors[half:]
+	copy(sorted, p.Anchors[:half])
 
-	SortAnchors(p.Anchors[:half])
+	SortAnchors(sorted)
 
-	for i := 0; i < half; i++ {
-		anchor := &p.Anchors[i]
-		parent := &p.Anchors[half+int(anchor.ParentIndex)]
+	for i := 0; i < len(sorted); i++ {
+		anchor := &sorted[i]
+		parent := &p.Anchors[anchor.ParentIndex]
 
 		if anchor.HitCount > 0 {
-			label := anchor.Label
-			if len(label) == 0 {
-				label = runtime.FuncForPC(anchor.PC).Name()
-			}
-			p.dumpTimeElapsed(&f, label, totalElapsed, anchor, parent)
+			p.dumpTimeElapsed(&f, anchorName(anchor), totalElapsed, anchor, parent)
 			totalCycles += anchor.ElapsedCyclesExclusive
 			totalHits += anchor.HitCount
 		}
@@ -228,3 +302,105 @@ func (p *Profiler) DumpProfile(buffer []byte) int {
 
 	return len(f.String())
 }
+
+/* anchorName returns label of anchor or name of function it is in. */
+func anchorName(anchor *Anchor) string {
+	if len(anchor.Label) > 0 {
+		return anchor.Label
+	}
+	return runtime.FuncForPC(anchor.PC).Name()
+}
+
+/* WriteCollapsed writes profile in collapsed-stack format used by flamegraph.pl: one line per anchor with names of its ancestors and itself separated by ';' and its cycles, e.g.
+ *     main.main;net/http.RequestsHandler;net/http.RequestHandler 123456
+ * Exclusive cycles are written, unless inclusive is true. Only exclusive ones add up to correct flame graph. */
+func (p *Profiler) WriteCollapsed(f *fmt.Formatter, inclusive bool) {
+	var stack [64]int32
+
+	half := len(p.Anchors) / 2
+	for i := 0; i < half; i++ {
+		anchor := &p.Anchors[i]
+		if anchor.HitCount == 0 {
+			continue
+		}
+
+		cycles := anchor.ElapsedCyclesExclusive
+		if inclusive {
+			cycles = anchor.ElapsedCyclesInclusive
+		}
+		/* NOTE(anton2920): cycles of children are subtracted from parent's exclusive cycles, so they may wrap for blocks ended out of order. */
+		if int64(cycles) <= 0 {
+			continue
+		}
+
+		n := 0
+		for index := int32(i); (index != 0) && (n < len(stack)); index = p.Anchors[index].ParentIndex {
+			/* NOTE(anton2920): recursive anchors may be parents of each other, so stack ends at the first repeated anchor. */
+			var seen bool
+			for j := 0; j < n; j++ {
+				if stack[j] == index {
+					seen = true
+					break
+				}
+			}
+			if seen {
+				break
+			}
+			stack[n] = index
+			n++
+		}
+
+		for j := n - 1; j >= 0; j-- {
+			f.S(anchorName(&p.Anchors[stack[j]]))
+			if j > 0 {
+				f.S(";")
+			}
+		}
+		f.S(" ").D64(int64(cycles)).Ln()
+	}
+}
+
+/* WriteChromeTrace writes events recorded by profilers in Chrome Trace Event format, which can be opened in Perfetto or chrome://tracing. Every block is complete ('X') event on thread p.ThreadID, timestamps are in microseconds since the earliest BeginProfile. */
+func WriteChromeTrace(f *fmt.Formatter, profilers ...*Profiler) {
+	var base cpu.Cycles
+	for _, p := range profilers {
+		if (p.StartCycles > 0) && ((base == 0) || (p.StartCycles < base)) {
+			base = p.StartCycles
+		}
+	}
+
+	f.S(`{"traceEvents":[`)
+	var quoted []byte
+	var n int
+	for _, p := range profilers {
+		mask := len(p.Events) - 1
+		count := p.EventsCount
+		start := 0
+		if count > len(p.Events) {
+			/* NOTE(anton2920): ring has wrapped, so the oldest event is the one to be overwritten next. */
+			start = count
+			count = len(p.Events)
+		}
+
+		for i := 0; i < count; i++ {
+			event := &p.Events[(start+i)&mask]
+
+			name := event.Label
+			if len(name) == 0 {
+				name = runtime.FuncForPC(event.PC).Name()
+			}
+
+			if l := encoding.JSONStringLength(name); l > len(quoted) {
+				quoted = make([]byte, l)
+			}
+			l := encoding.PutJSONString(quoted, name)
+
+			if n > 0 {
+				f.S(",")
+			}
+			f.S(`{"name":`).S(bytes.AsString(quoted[:l])).S(`,"ph":"X","ts":`).Prec(3).F((event.StartCycles - base).ToMicroseconds()).S(`,"dur":`).Prec(3).F((event.EndCycles - event.StartCycles).ToMicroseconds()).S(`,"pid":1,"tid":`).D(p.ThreadID).S(`}`)
+			n++
+		}
+	}
+	f.S(`],"displayTimeUnit":"ns"}`)
+}
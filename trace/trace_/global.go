@@ -4,9 +4,14 @@
 package trace_
 
 import (
+	"runtime"
+	"sync"
+	"sync/atomic"
 	"unsafe"
 
+	"github.com/anton2920/gofa/context"
 	"github.com/anton2920/gofa/cpu"
+	"github.com/anton2920/gofa/fmt"
 	"github.com/anton2920/gofa/funcs"
 	"github.com/anton2920/gofa/os"
 	"github.com/anton2920/gofa/time"
@@ -14,22 +19,265 @@ import (
 	"github.com/anton2920/gofa/trace"
 )
 
-var prof trace.Profiler
+/* thread is profiler of goroutine registered with BeginThread. G is runtime's pointer to goroutine, which identifies it, while it is alive. Free slot has zero G. */
+type thread struct {
+	G        uintptr
+	Profiler trace.Profiler
+}
+
+const MaxThreads = 256
+
+var (
+	/* shared is used by all goroutines, which are not registered with BeginThread. Its anchors and parent chain are updated without synchronization, so blocks of such goroutines, which run concurrently, may be lost or attributed to wrong parent. */
+	shared trace.Profiler
+
+	/* retired accumulates profilers of threads ended with EndThread. */
+	retired trace.Profiler
+
+	threads      [MaxThreads]thread
+	threadsCount int32
+	threadsLock  sync.Mutex
+
+	/* threadsIndex is open-addressed table, which maps G to 1+index of its slot in threads. 0 is empty entry. It holds at most half of entries, so every probe sequence ends soon. */
+	threadsIndex [2 * MaxThreads]int32
+
+	/* threadsGen is odd, while threadsIndex is rebuilt by EndThread. */
+	threadsGen uint32
+
+	anchorsCount = 8192
+	eventsCount  int
+)
 
 func init() {
+	initProfiler(&shared, 0)
+	retired.Anchors = make([]trace.Anchor, anchorsCount)
+	retired.Prefix = "[trace_ ended]: "
+}
+
+func initProfiler(p *trace.Profiler, id int) {
+	var buf [32]byte
+	var f fmt.Formatter
+
 	/* NOTE(anton2920): len must be a power of two for fast modulus calculation. */
-	prof.Anchors = make([]trace.Anchor, 8192)
-	prof.Prefix = "[trace_]: "
+	p.Anchors = make([]trace.Anchor, anchorsCount)
+	p.RecordEvents(eventsCount)
+	p.ThreadID = id
+
+	if id == 0 {
+		p.Prefix = "[trace_]: "
+	} else {
+		f.InitWithByteSlice(buf[:])
+		p.Prefix = string(f.S("[trace_ #").D(id).S("]: ").Bytes())
+	}
+}
+
+/* SetAnchorsCount sets size of anchor table of every profiler, which limits number of distinct blocks. n is rounded up to power of two. Profilers are merged by anchor index, so size cannot change, after any thread is registered: SetAnchorsCount returns false then. It must be called before BeginThread and BeginProfile. */
+func SetAnchorsCount(n int) bool {
+	threadsLock.Lock()
+	defer threadsLock.Unlock()
+
+	if threadsCount > 0 {
+		return false
+	}
+
+	size := 2
+	for size < n {
+		size <<= 1
+	}
+	anchorsCount = size
+	shared.Anchors = make([]trace.Anchor, anchorsCount)
+	retired.Anchors = make([]trace.Anchor, anchorsCount)
+	return true
+}
+
+/* RecordEvents enables recording of the last n blocks of every thread for WriteChromeTrace. It must be called before BeginProfile. */
+func RecordEvents(n int) {
+	threadsLock.Lock()
+	defer threadsLock.Unlock()
+
+	eventsCount = n
+	shared.RecordEvents(n)
+	for i := 0; i < int(threadsCount); i++ {
+		if threads[i].G != 0 {
+			threads[i].Profiler.RecordEvents(n)
+		}
+	}
+}
+
+/* threadHash returns start of probe sequence for g in threadsIndex. */
+//go:nosplit
+func threadHash(g uintptr) int {
+	/* NOTE(anton2920): goroutines are allocated with large alignment, so low bits are mixed in with Fibonacci hashing. */
+	return int(uint32(uint64(g)*11400714819323198485>>32) & uint32(len(threadsIndex)-1))
+}
+
+/* probeThread returns index of slot of g in threads or -1, if there is no entry for g in threadsIndex. */
+//go:nosplit
+func probeThread(g uintptr) int {
+	mask := len(threadsIndex) - 1
+
+	h := threadHash(g)
+	for i := 0; i < len(threadsIndex); i++ {
+		idx := atomic.LoadInt32(&threadsIndex[h])
+		if idx == 0 {
+			break
+		} else if atomic.LoadUintptr(&threads[idx-1].G) == g {
+			return int(idx - 1)
+		}
+		h = (h + 1) & mask
+	}
+	return -1
+}
+
+/* threadSlot returns index of slot of g in threads or -1, if g is not registered. */
+//go:nosplit
+func threadSlot(g uintptr) int {
+	for {
+		/* NOTE(anton2920): entry found during rebuild is still valid, since its G is checked, but missing one may have been cleared, so lookup is repeated. */
+		gen := atomic.LoadUint32(&threadsGen)
+		if n := probeThread(g); n != -1 {
+			return n
+		}
+		if (gen&1 == 0) && (atomic.LoadUint32(&threadsGen) == gen) {
+			return -1
+		}
+	}
+}
+
+/* insertThread adds entry for g with slot n to threadsIndex. threadsLock must be held. */
+func insertThread(g uintptr, n int) {
+	mask := len(threadsIndex) - 1
+	h := threadHash(g)
+	for atomic.LoadInt32(&threadsIndex[h]) != 0 {
+		h = (h + 1) & mask
+	}
+	atomic.StoreInt32(&threadsIndex[h], int32(n)+1)
+}
+
+/* rebuildIndex fills threadsIndex with entries of registered threads only. Entries can't simply be emptied, because that would break probe sequences of other threads. threadsLock must be held. */
+func rebuildIndex() {
+	atomic.AddUint32(&threadsGen, 1)
+	for i := 0; i < len(threadsIndex); i++ {
+		atomic.StoreInt32(&threadsIndex[i], 0)
+	}
+	for i := 0; i < int(threadsCount); i++ {
+		if threads[i].G != 0 {
+			insertThread(threads[i].G, i)
+		}
+	}
+	atomic.AddUint32(&threadsGen, 1)
+}
+
+/* BeginThread locks calling goroutine to its thread and gives it own profiler, so blocks of different workers neither break parent chains of each other nor race on anchors. It must be called once at the start of long-lived worker goroutine. Profilers of all threads are merged by PrintProfile. Goroutines, which don't call BeginThread, and threads beyond MaxThreads share one profiler, see shared. */
+func BeginThread() {
+	runtime.LockOSThread()
+
+	threadsLock.Lock()
+	defer threadsLock.Unlock()
+
+	n := int(threadsCount)
+	for i := 0; i < int(threadsCount); i++ {
+		if threads[i].G == 0 {
+			n = i
+			break
+		}
+	}
+	if n == MaxThreads {
+		/* NOTE(anton2920): thread falls back to shared profiler. */
+		return
+	}
+
+	g := currentThread()
+	t := &threads[n]
+	initProfiler(&t.Profiler, n+1)
+	t.Profiler.BeginProfile()
+	atomic.StoreUintptr(&t.G, g)
+
+	/* NOTE(anton2920): profiler is published after it is initialized, so profiler() may read threads without lock. */
+	insertThread(g, n)
+
+	if n == int(threadsCount) {
+		atomic.StoreInt32(&threadsCount, int32(n)+1)
+	}
+}
+
+/* EndThread unregisters profiler of the calling goroutine and unlocks it from its thread. Blocks of ended thread are merged into separate profile, so they are still reported, while its slot is reused by the next BeginThread. Its events are not kept for WriteChromeTrace. It must be called by goroutine, which called BeginThread, after its last block has ended. */
+func EndThread() {
+	defer runtime.UnlockOSThread()
+
+	threadsLock.Lock()
+	defer threadsLock.Unlock()
+
+	n := threadSlot(currentThread())
+	if n == -1 {
+		return
+	}
+
+	t := &threads[n]
+	t.Profiler.EndProfile()
+	if used(&t.Profiler) {
+		retired.Merge(&t.Profiler)
+	}
+
+	atomic.StoreUintptr(&t.G, 0)
+	rebuildIndex()
+}
+
+/* profiler returns profiler of the current thread. */
+//go:nosplit
+func profiler() *trace.Profiler {
+	if n := threadSlot(currentThread()); n != -1 {
+		return &threads[n].Profiler
+	}
+	return &shared
+}
+
+/* profilers returns shared profiler followed by profilers of all registered threads. */
+func profilers() []*trace.Profiler {
+	n := int(atomic.LoadInt32(&threadsCount))
+
+	ps := make([]*trace.Profiler, 0, n+1)
+	ps = append(ps, &shared)
+	for i := 0; i < n; i++ {
+		if atomic.LoadUintptr(&threads[i].G) != 0 {
+			ps = append(ps, &threads[i].Profiler)
+		}
+	}
+	return ps
+}
+
+/* reported returns profilers() followed by profiler of ended threads. */
+func reported() []*trace.Profiler {
+	return append(profilers(), &retired)
+}
+
+/* used reports whether any block of p has ended. */
+func used(p *trace.Profiler) bool {
+	for i := 0; i < len(p.Anchors)/2; i++ {
+		if p.Anchors[i].HitCount > 0 {
+			return true
+		}
+	}
+	return false
 }
 
 func BeginProfile() {
-	prof.BeginProfile()
+	ps := profilers()
+	for i := 0; i < len(ps); i++ {
+		ps[i].BeginProfile()
+	}
+
+	threadsLock.Lock()
+	/* NOTE(anton2920): Merge adds elapsed time of ended threads to EndCycles, so their total is not time since BeginProfile. */
+	retired.BeginProfile()
+	retired.EndCycles = retired.StartCycles
+	threadsLock.Unlock()
 }
 
 //go:nosplit
 func Begin(label string) trace.Block {
 	cpu.WaitForLoadOperationsToComplete()
-	return prof.BeginBody(funcs.GetCallerPC(unsafe.Pointer(&label)), label)
+	return profiler().BeginBody(funcs.GetCallerPC(unsafe.Pointer(&label)), label)
 }
 
 //go:nosplit
@@ -37,13 +285,114 @@ func End(t trace.Block) {
 	t.End()
 }
 
-func EndAndPrintProfile() {
-	prof.EndProfile()
+func EndProfile() {
+	ps := profilers()
+	for i := 0; i < len(ps); i++ {
+		ps[i].EndProfile()
+	}
 
 	/* NOTE(anton2920): before accessing anchors, wait for possible background work to stop. */
 	time_.Sleep(200 * time.Millisecond)
+}
+
+func printProfile(p *trace.Profiler) {
+	var ctx context.Context
 
 	profile := make([]byte, 16*1024)
-	n := prof.DumpProfile(profile)
-	os.WriteToFile(os.StandardErrorStream, profile[:n])
+	n := p.DumpProfile(profile)
+	os.WriteToFile(&ctx, os.StandardErrorStream, profile[:n])
+}
+
+/* combine merges profilers of all threads. */
+func combine() *trace.Profiler {
+	var combined trace.Profiler
+
+	combined.Anchors = make([]trace.Anchor, anchorsCount)
+	combined.Prefix = shared.Prefix
+
+	/* NOTE(anton2920): lock prevents EndThread from merging into retired profiler, while it is read. */
+	threadsLock.Lock()
+	defer threadsLock.Unlock()
+
+	ps := reported()
+	for i := 0; i < len(ps); i++ {
+		if used(ps[i]) {
+			combined.Merge(ps[i])
+		}
+	}
+	return &combined
+}
+
+/* PrintProfile prints profile combined from profilers of all threads. Percents of total are relative to time of all threads. */
+func PrintProfile() {
+	printProfile(combine())
+}
+
+/* PrintThreadProfiles prints profile of every thread separately. Threads ended with EndThread are printed as one profile. */
+func PrintThreadProfiles() {
+	threadsLock.Lock()
+	defer threadsLock.Unlock()
+
+	ps := reported()
+	for i := 0; i < len(ps); i++ {
+		if used(ps[i]) {
+			printProfile(ps[i])
+		}
+	}
+}
+
+func EndAndPrintProfile() {
+	EndProfile()
+	PrintProfile()
+}
+
+/* writeFile creates or truncates file at path and writes output of write to it. */
+func writeFile(path string, write func(*fmt.Formatter)) {
+	var ctx context.Context
+	var f fmt.Formatter
+
+	message := make([]byte, 512)
+	f.InitWithByteSlice(message)
+	f.TruncateOnOverflow("")
+	f.S(shared.Prefix)
+
+	h, ok := os.OpenOrCreateFile(&ctx, path, os.OpenForWriting, os.CreateFileIfItDoesNotExist|os.TruncateSizeToZero, 0644)
+	if !ok {
+		os.WriteToFile(&ctx, os.StandardErrorStream, f.S("failed to open ").Q(path).S(": ").S(os.ErrorCodeString(ctx.ErrorCode())).Ln().Bytes())
+		return
+	}
+
+	var w fmt.Formatter
+	w.InitWithByteSlice(make([]byte, 16*1024))
+	w.FlushOnOverflow(func(buf []byte) {
+		if _, written := os.WriteToFile(&ctx, h, buf); !written {
+			ok = false
+		}
+	})
+	write(&w)
+	w.Flush()
+
+	if !ok {
+		os.WriteToFile(&ctx, os.StandardErrorStream, f.S("failed to write to ").Q(path).S(": ").S(os.ErrorCodeString(ctx.ErrorCode())).Ln().Bytes())
+	}
+	os.CloseHandle(&ctx, h)
+}
+
+/* WriteChromeTrace writes events recorded by all threads to file at path, see trace.WriteChromeTrace. */
+func WriteChromeTrace(path string) {
+	writeFile(path, func(f *fmt.Formatter) {
+		trace.WriteChromeTrace(f, profilers()...)
+	})
+}
+
+func EndAndWriteChromeTrace(path string) {
+	EndProfile()
+	WriteChromeTrace(path)
+}
+
+/* WriteCollapsed writes profile combined from profilers of all threads to file at path in collapsed-stack format, see trace.Profiler.WriteCollapsed. Two such files can be compared with trace/cmd/diff. */
+func WriteCollapsed(path string, inclusive bool) {
+	writeFile(path, func(f *fmt.Formatter) {
+		combine().WriteCollapsed(f, inclusive)
+	})
 }
@@ -3,6 +3,16 @@
 
 package trace_
 
+func SetAnchorsCount(_ int) bool {
+	return true
+}
+
+func RecordEvents(_ int) {}
+
+func BeginThread() {}
+
+func EndThread() {}
+
 //go:nosplit
 func BeginProfile() {}
 
@@ -14,5 +24,17 @@ func Begin(_ string) int {
 //go:nosplit
 func End(_ int) {}
 
+func EndProfile() {}
+
+func PrintProfile() {}
+
+func PrintThreadProfiles() {}
+
 //go:nosplit
 func EndAndPrintProfile() {}
+
+func WriteChromeTrace(_ string) {}
+
+func EndAndWriteChromeTrace(_ string) {}
+
+func WriteCollapsed(_ string, _ bool) {}
@@ -0,0 +1,150 @@
+//go:build gofatrace
+// +build gofatrace
+
+package trace_
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/anton2920/gofa/trace"
+)
+
+/* block ends block, which has begun in function with fixed PC, so blocks of all threads are merged into the same anchor. */
+func block(label string) {
+	End(Begin(label))
+}
+
+func TestThreads(t *testing.T) {
+	const workers = 4
+
+	BeginProfile()
+
+	var wg sync.WaitGroup
+	ps := make([]*trace.Profiler, workers)
+	ready := make(chan struct{})
+	end := make(chan struct{})
+	endOdd := make(chan struct{})
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			BeginThread()
+			ps[i] = profiler()
+			for j := 0; j <= i; j++ {
+				block("worker")
+			}
+			ready <- struct{}{}
+			<-end
+			if i%2 == 1 {
+				<-endOdd
+			}
+			EndThread()
+		}(i)
+	}
+	for i := 0; i < workers; i++ {
+		<-ready
+	}
+
+	if SetAnchorsCount(2 * anchorsCount) {
+		t.Errorf("Expected SetAnchorsCount to fail after threads are registered")
+	}
+	for i := 0; i < workers; i++ {
+		if ps[i] == &shared {
+			t.Errorf("Worker %d uses shared profiler", i)
+		}
+		for j := 0; j < i; j++ {
+			if ps[i] == ps[j] {
+				t.Errorf("Workers %d and %d share profiler", j, i)
+			}
+		}
+	}
+	if profiler() != &shared {
+		t.Errorf("Unregistered goroutine does not use shared profiler")
+	}
+	block("worker")
+
+	close(end)
+	for len(profilers()) > 1+workers/2 {
+		runtime.Gosched()
+	}
+	count := threadsCount
+	close(endOdd)
+	wg.Wait()
+
+	if n := len(profilers()); n != 1 {
+		t.Errorf("Expected only shared profiler after EndThread, got %d profilers", n)
+	}
+
+	/* NOTE(anton2920): each worker i ended i+1 blocks, plus one block of shared profiler. */
+	combined := combine()
+	var hits int
+	for i := 0; i < len(combined.Anchors)/2; i++ {
+		if combined.Anchors[i].Label == "worker" {
+			hits += combined.Anchors[i].HitCount
+		}
+	}
+	if expected := 1 + workers*(workers+1)/2; hits != expected {
+		t.Errorf("Expected %d hits in combined profile, got %d", expected, hits)
+	}
+
+	/* NOTE(anton2920): slots of ended threads are reused. */
+	done := make(chan struct{})
+	go func() {
+		BeginThread()
+		EndThread()
+		done <- struct{}{}
+	}()
+	<-done
+	if threadsCount != count {
+		t.Errorf("Expected slot of ended thread to be reused, got %d slots instead of %d", threadsCount, count)
+	}
+}
+
+func TestThreadsIndex(t *testing.T) {
+	var p *trace.Profiler
+
+	ready := make(chan struct{})
+	end := make(chan struct{})
+	go func() {
+		BeginThread()
+		p = profiler()
+		ready <- struct{}{}
+		<-end
+		if profiler() != p {
+			t.Errorf("Long-lived worker lost its profiler")
+		}
+		EndThread()
+		ready <- struct{}{}
+	}()
+	<-ready
+
+	/* NOTE(anton2920): more threads than entries in index are registered and ended one after another. */
+	done := make(chan struct{})
+	for i := 0; i < 2*len(threadsIndex); i++ {
+		go func() {
+			BeginThread()
+			EndThread()
+			done <- struct{}{}
+		}()
+		<-done
+	}
+
+	var entries int
+	for i := 0; i < len(threadsIndex); i++ {
+		if threadsIndex[i] != 0 {
+			entries++
+		}
+	}
+	if entries != 1 {
+		t.Errorf("Expected 1 entry for registered thread, got %d", entries)
+	}
+	if profiler() != &shared {
+		t.Errorf("Unregistered goroutine does not use shared profiler")
+	}
+
+	close(end)
+	<-ready
+}
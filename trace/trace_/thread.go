@@ -0,0 +1,8 @@
+//go:build gofatrace
+// +build gofatrace
+
+package trace_
+
+/* currentThread returns runtime's pointer to the current goroutine. Goroutines registered with BeginThread are locked to their threads, so it identifies thread too. */
+//go:nosplit
+func currentThread() uintptr
@@ -0,0 +1,12 @@
+//go:build gofatrace && 386
+//+build gofatrace,386
+
+/* From "textflag.h". */
+#define NOSPLIT	4
+
+
+/* func currentThread() uintptr */
+TEXT ·currentThread(SB), NOSPLIT, $0-4
+	MOVL	(TLS), AX
+	MOVL	AX, ret+0(FP)
+	RET
@@ -0,0 +1,12 @@
+//go:build gofatrace && amd64
+//+build gofatrace,amd64
+
+/* From "textflag.h". */
+#define NOSPLIT	4
+
+
+/* func currentThread() uintptr */
+TEXT ·currentThread(SB), NOSPLIT, $0-8
+	MOVQ	(TLS), AX
+	MOVQ	AX, ret+0(FP)
+	RET
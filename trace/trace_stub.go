@@ -3,6 +3,8 @@
 
 package trace
 
+import "github.com/anton2920/gofa/fmt"
+
 type (
 	Block    struct{}
 	Profiler struct{}
@@ -16,10 +18,18 @@ func (p Profiler) Begin(_ string) int { return 0 }
 
 func (p Profiler) BeginProfile() {}
 
+func (p Profiler) RecordEvents(_ int) {}
+
 //go:nosplit
 func (b Block) End() {}
 
 //go:nosplit
 func (p Profiler) EndProfile() {}
 
+func (p Profiler) Merge(_ *Profiler) {}
+
+func (p Profiler) WriteCollapsed(_ *fmt.Formatter, _ bool) {}
+
 func (p Profiler) DumpProfile(_ []byte) int { return 0 }
+
+func WriteChromeTrace(_ *fmt.Formatter, _ ...*Profiler) {}
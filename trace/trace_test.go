@@ -0,0 +1,169 @@
+//go:build gofatrace
+// +build gofatrace
+
+package trace
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/anton2920/gofa/cpu"
+	"github.com/anton2920/gofa/fmt"
+)
+
+func TestWriteChromeTrace(t *testing.T) {
+	labels := [...]string{
+		"plain",
+		`quote " and \ backslash`,
+		"control\x00\x01\n\t",
+		"bad\xff\xfeutf8",
+		"line\u2028sep\u2029",
+	}
+
+	/* NOTE(anton2920): 1 MHz makes every cycle a microsecond. */
+	hz := cpu.CPUHz
+	cpu.CPUHz = 1000000
+	defer func() { cpu.CPUHz = hz }()
+
+	var p Profiler
+	p.ThreadID = 7
+	p.StartCycles = 100
+	p.Events = make([]Event, 8)
+	for i, label := range labels {
+		p.Events[i] = Event{Label: label, StartCycles: p.StartCycles + 10*cpu.Cycles(i), EndCycles: p.StartCycles + 10*cpu.Cycles(i) + 5}
+	}
+	p.EventsCount = len(labels)
+
+	var f fmt.Formatter
+	f.GrowOnOverflow(nil)
+	WriteChromeTrace(&f, &p)
+
+	var trace struct {
+		TraceEvents []struct {
+			Name string
+			Ph   string
+			Ts   float64
+			Dur  float64
+			Tid  int
+		}
+	}
+	if err := json.Unmarshal(f.Bytes(), &trace); err != nil {
+		t.Fatalf("Failed to parse %s: %v", f.Bytes(), err)
+	}
+	if len(trace.TraceEvents) != len(labels) {
+		t.Fatalf("Expected %d events, got %d", len(labels), len(trace.TraceEvents))
+	}
+	for i, event := range trace.TraceEvents {
+		expected := labels[i]
+		if i == 3 {
+			expected = "bad\ufffd\ufffdutf8"
+		}
+		if (event.Name != expected) || (event.Ph != "X") || (event.Tid != p.ThreadID) {
+			t.Errorf("Event %d: expected name %q on thread %d, got %q (%q) on thread %d", i, expected, p.ThreadID, event.Name, event.Ph, event.Tid)
+		}
+		if (event.Ts != float64(10*i)) || (event.Dur != 5) {
+			t.Errorf("Event %d: expected ts=%d, dur=5, got ts=%v, dur=%v", i, 10*i, event.Ts, event.Dur)
+		}
+	}
+}
+
+func TestProfilerMerge(t *testing.T) {
+	const (
+		outer = 0x1001
+		inner = 0x1002
+	)
+
+	profile := func(hits int) *Profiler {
+		p := new(Profiler)
+		p.Anchors = make([]Anchor, 64)
+		p.BeginProfile()
+		for i := 0; i < hits; i++ {
+			b := p.BeginBody(outer, "outer")
+			p.BeginBody(inner, "inner").End()
+			b.End()
+		}
+		p.EndProfile()
+		return p
+	}
+
+	a := profile(2)
+	b := profile(3)
+
+	var merged Profiler
+	merged.Anchors = make([]Anchor, 64)
+	merged.Merge(a)
+	merged.Merge(b)
+
+	o := &merged.Anchors[merged.anchorIndexForPC(outer)]
+	i := &merged.Anchors[merged.anchorIndexForPC(inner)]
+	if (o.Label != "outer") || (o.HitCount != 5) {
+		t.Errorf("Expected outer anchor with 5 hits, got %q with %d", o.Label, o.HitCount)
+	}
+	if (i.Label != "inner") || (i.HitCount != 5) {
+		t.Errorf("Expected inner anchor with 5 hits, got %q with %d", i.Label, i.HitCount)
+	}
+	if i.ParentIndex != merged.anchorIndexForPC(outer) {
+		t.Errorf("Expected parent of inner anchor to be %d, got %d", merged.anchorIndexForPC(outer), i.ParentIndex)
+	}
+	if o.ParentIndex != 0 {
+		t.Errorf("Expected outer anchor to be root, got parent %d", o.ParentIndex)
+	}
+
+	ai := &a.Anchors[a.anchorIndexForPC(inner)]
+	bi := &b.Anchors[b.anchorIndexForPC(inner)]
+	if i.ElapsedCyclesInclusive != ai.ElapsedCyclesInclusive+bi.ElapsedCyclesInclusive {
+		t.Errorf("Expected inclusive time %d, got %d", ai.ElapsedCyclesInclusive+bi.ElapsedCyclesInclusive, i.ElapsedCyclesInclusive)
+	}
+	if total := (a.EndCycles - a.StartCycles) + (b.EndCycles - b.StartCycles); merged.EndCycles-merged.StartCycles != total {
+		t.Errorf("Expected total time %d, got %d", total, merged.EndCycles-merged.StartCycles)
+	}
+}
+
+func TestDumpProfileOrder(t *testing.T) {
+	const (
+		outer = 0x1001
+		inner = 0x1002
+	)
+
+	var p Profiler
+	p.Anchors = make([]Anchor, 64)
+	p.BeginProfile()
+	for i := 0; i < 3; i++ {
+		b := p.BeginBody(outer, "outer")
+		p.BeginBody(inner, "inner").End()
+		b.End()
+	}
+	p.EndProfile()
+
+	var f fmt.Formatter
+	f.GrowOnOverflow(nil)
+	p.WriteCollapsed(&f, true)
+	before := f.String()
+
+	anchors := make([]Anchor, len(p.Anchors)/2)
+	copy(anchors, p.Anchors)
+
+	p.DumpProfile(make([]byte, 4096))
+	for i := 0; i < len(anchors); i++ {
+		if p.Anchors[i] != anchors[i] {
+			t.Fatalf("Anchor %d changed by DumpProfile", i)
+		}
+	}
+
+	/* Profiler is still usable after DumpProfile. */
+	var g fmt.Formatter
+	g.GrowOnOverflow(nil)
+	p.WriteCollapsed(&g, true)
+	if g.String() != before {
+		t.Errorf("Expected the same collapsed profile after DumpProfile, got %q instead of %q", g.String(), before)
+	}
+	if !strings.Contains(before, "outer;inner ") {
+		t.Errorf("Expected inner block to be child of outer one, got %q", before)
+	}
+
+	p.BeginBody(inner, "inner").End()
+	if hits := p.Anchors[p.anchorIndexForPC(inner)].HitCount; hits != 4 {
+		t.Errorf("Expected 4 hits of inner block, got %d", hits)
+	}
+}